@@ -1,5 +1,7 @@
 package api
 
+import "time"
+
 // Wrapper struct for the request parameters.
 // The actual parameters are stored in "client_params".
 type FleetLockRequest struct {
@@ -38,3 +40,84 @@ type FleetlockHealthResponse struct {
 	// Empty when there is no error.
 	Error string `json:"error"`
 }
+
+// Not part of the actual api specification, used for the server to indicate it is ready to serve requests.
+type FleetlockReadinessResponse struct {
+	// "ok" when all components are healthy, "error" otherwise
+	Status string `json:"status"`
+	// The status of every checked component, e.g. "storage" or "kubernetes"
+	Components map[string]FleetlockHealthResponse `json:"components"`
+}
+
+// Not part of the actual api specification, returned by the admin api when listing groups.
+type AdminGroupsResponse struct {
+	Groups []AdminGroup `json:"groups"`
+}
+
+// Not part of the actual api specification, the status of a group as returned by the admin api.
+type AdminGroup struct {
+	// Name of the group
+	Name string `json:"name"`
+	// The number of slots configured for the group
+	Slots int `json:"slots"`
+	// The number of slots currently in use
+	Used int `json:"used"`
+	// The current holders of the slots.
+	// Only returned when requesting a single group.
+	Locks []AdminLock `json:"locks,omitempty"`
+}
+
+// Not part of the actual api specification, a single slot held by a client.
+type AdminLock struct {
+	// The id of the client holding the slot
+	ID string `json:"id"`
+	// When the slot was reserved
+	Created time.Time `json:"created"`
+	// The authenticated client that reserved the slot, empty without client authentication
+	Owner string `json:"owner,omitempty"`
+}
+
+// Not part of the actual api specification, the status of the last drain of a node as returned by the admin api.
+type DrainStatus struct {
+	// Name of the node
+	Node string `json:"node"`
+	// One of draining, done, error or interrupted
+	State string `json:"state"`
+	// When the current drain attempt was started
+	Started time.Time `json:"started"`
+	// The number of failed attempts to drain the node
+	Failures int `json:"failures"`
+	// Pods that still need to be evicted or are waiting for deletion, in the format namespace/name
+	PodsRemaining []string `json:"pods_remaining"`
+	// Pods that could not be evicted and why
+	FailedEvictions []FailedEviction `json:"failed_evictions"`
+}
+
+// Not part of the actual api specification, a pod that could not be evicted.
+type FailedEviction struct {
+	// The pod in the format namespace/name
+	Pod string `json:"pod"`
+	// Why the eviction failed
+	Reason string `json:"reason"`
+}
+
+// Not part of the actual api specification, returned by the admin api when querying the audit log.
+type AuditEventsResponse struct {
+	Events []AuditEvent `json:"events"`
+}
+
+// Not part of the actual api specification, a single entry of the audit log.
+type AuditEvent struct {
+	// When the event happened
+	Time time.Time `json:"time"`
+	// One of reserve, reserve_denied, release, force_release, drain_started, drain_finished, drain_failed or uncordon
+	Type  string `json:"type"`
+	Group string `json:"group,omitempty"`
+	ID    string `json:"id,omitempty"`
+	// The kubernetes node matching the id, if known
+	Node string `json:"node,omitempty"`
+	// Address of the client that caused the event, empty for events caused by the server itself
+	Remote string `json:"remote,omitempty"`
+	// Additional information, e.g. why a reservation was denied
+	Reason string `json:"reason,omitempty"`
+}
@@ -1,8 +1,11 @@
 package client
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 
 	"github.com/heathcliff26/fleetlock/pkg/api"
@@ -12,6 +15,10 @@ type FleetlockClient struct {
 	url   string
 	group string
 	appID string
+	token string
+
+	tlsConfig  *tls.Config
+	httpClient *http.Client
 
 	mutex sync.RWMutex
 }
@@ -48,6 +55,16 @@ func NewEmptyClient() (*FleetlockClient, error) {
 	}, nil
 }
 
+// Create a new client for the admin api of the server, which does not need a group or id
+func NewAdminClient(url string) (*FleetlockClient, error) {
+	c := &FleetlockClient{}
+	err := c.SetURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 // Aquire a lock for this machine
 func (c *FleetlockClient) Lock() error {
 	ok, res, err := c.doRequest("/v1/pre-reboot")
@@ -70,6 +87,17 @@ func (c *FleetlockClient) Release() error {
 	return fmt.Errorf("failed to release lock kind=\"%s\" reason=\"%s\"", res.Kind, res.Value)
 }
 
+// Renew the lease of the hold lock
+func (c *FleetlockClient) Renew() error {
+	ok, res, err := c.doRequest("/v1/renew")
+	if err != nil {
+		return err
+	} else if ok {
+		return nil
+	}
+	return fmt.Errorf("failed to renew lock kind=\"%s\" reason=\"%s\"", res.Kind, res.Value)
+}
+
 func (c *FleetlockClient) doRequest(path string) (bool, api.FleetLockResponse, error) {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
@@ -84,8 +112,11 @@ func (c *FleetlockClient) doRequest(path string) (bool, api.FleetLockResponse, e
 	}
 	req.Header.Set("fleet-lock-protocol", "true")
 	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.getHTTPClient().Do(req)
 	if err != nil {
 		return false, api.FleetLockResponse{}, fmt.Errorf("failed to send request to server: %v", err)
 	}
@@ -169,3 +200,87 @@ func (c *FleetlockClient) SetID(id string) error {
 	c.appID = id
 	return nil
 }
+
+// Get the bearer token
+func (c *FleetlockClient) GetToken() string {
+	if c == nil {
+		return ""
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.token
+}
+
+// Send the token as bearer token with every request, an empty token disables it
+func (c *FleetlockClient) SetToken(token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.token = token
+}
+
+// Authenticate with the given client certificate and key
+func (c *FleetlockClient) SetClientCertificate(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load client certificate: %v", err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cfg := c.cloneTLSConfig()
+	cfg.Certificates = []tls.Certificate{cert}
+	c.setTLSConfig(cfg)
+	return nil
+}
+
+// Verify the server certificate with the given CA instead of the system roots
+func (c *FleetlockClient) SetCACertificate(caFile string) error {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CA certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("CA certificate \"%s\" does not contain any PEM encoded certificates", caFile)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cfg := c.cloneTLSConfig()
+	cfg.RootCAs = pool
+	c.setTLSConfig(cfg)
+	return nil
+}
+
+// Return the http client configured with the tls settings, or the default client.
+// Needs to be called while holding the lock.
+func (c *FleetlockClient) getHTTPClient() *http.Client {
+	if c.httpClient == nil {
+		return http.DefaultClient
+	}
+	return c.httpClient
+}
+
+// Return a copy of the current tls config, as it may not be modified once in use.
+// Needs to be called while holding the lock.
+func (c *FleetlockClient) cloneTLSConfig() *tls.Config {
+	if c.tlsConfig == nil {
+		return &tls.Config{}
+	}
+	return c.tlsConfig.Clone()
+}
+
+// Use a dedicated http client with the given tls config, as the default client is shared.
+// Needs to be called while holding the write lock.
+func (c *FleetlockClient) setTLSConfig(cfg *tls.Config) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+
+	c.tlsConfig = cfg
+	c.httpClient = &http.Client{Transport: transport}
+}
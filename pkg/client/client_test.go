@@ -1,8 +1,20 @@
 package client
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/heathcliff26/fleetlock/pkg/fake"
 	"github.com/stretchr/testify/assert"
@@ -97,6 +109,22 @@ func TestRelease(t *testing.T) {
 	assert.Error(err, "Should not succeed")
 }
 
+func TestRenew(t *testing.T) {
+	assert := assert.New(t)
+
+	c, srv := NewFakeServer(t, http.StatusOK, "/v1/renew")
+	defer srv.Close()
+
+	err := c.Renew()
+	assert.NoError(err, "Should succeed")
+
+	c2, srv2 := NewFakeServer(t, http.StatusConflict, "/v1/renew")
+	defer srv2.Close()
+
+	err = c2.Renew()
+	assert.Error(err, "Should not succeed")
+}
+
 func TestGetAndSet(t *testing.T) {
 	t.Run("URL", func(t *testing.T) {
 		assert := assert.New(t)
@@ -140,6 +168,17 @@ func TestGetAndSet(t *testing.T) {
 
 		assert.Error(c.SetID(""), "Should not accept empty id")
 	})
+	t.Run("Token", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var c *FleetlockClient
+		assert.Empty(c.GetToken(), "Should not panic when reading token from nil pointer")
+
+		c = &FleetlockClient{}
+
+		c.SetToken("secret")
+		assert.Equal("secret", c.GetToken(), "token should match")
+	})
 }
 
 func NewFakeServer(t *testing.T, statusCode int, path string) (*FleetlockClient, *fake.FakeServer) {
@@ -156,3 +195,72 @@ func NewFakeServer(t *testing.T, statusCode int, path string) (*FleetlockClient,
 	}
 	return c, srv
 }
+
+func TestToken(t *testing.T) {
+	c, srv := NewFakeServer(t, http.StatusOK, "/v1/pre-reboot")
+	defer srv.Close()
+
+	srv.Token = "secret"
+	c.SetToken("secret")
+
+	assert.NoError(t, c.Lock(), "Should succeed")
+}
+
+func TestClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	writeTestCertificate(t, certFile, keyFile)
+
+	var clientCN string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		clientCN = req.TLS.PeerCertificates[0].Subject.CommonName
+		_, _ = rw.Write([]byte(`{"kind":"success","value":"ok"}`))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600), "Should write CA")
+
+	c, err := NewClient(srv.URL, "default")
+	require.NoError(t, err, "Should create client")
+
+	assert := assert.New(t)
+
+	assert.Error(c.Lock(), "Should not trust the server without the CA")
+
+	require.NoError(t, c.SetCACertificate(caFile), "Should load CA")
+	assert.Error(c.Lock(), "Should fail without a client certificate")
+
+	require.NoError(t, c.SetClientCertificate(certFile, keyFile), "Should load client certificate")
+	assert.NoError(c.Lock(), "Should succeed with a client certificate")
+	assert.Equal("node1", clientCN, "Should have sent the client certificate")
+
+	assert.Error(c.SetClientCertificate("not-a-file", keyFile), "Should fail on missing certificate")
+	assert.Error(c.SetCACertificate("not-a-file"), "Should fail on missing CA")
+	assert.Error(c.SetCACertificate(keyFile), "Should fail when the CA contains no certificate")
+}
+
+// Write a self-signed client certificate and its key to the given paths
+func writeTestCertificate(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Should generate key")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "node1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "Should create certificate")
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err, "Should marshal key")
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600), "Should write certificate")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600), "Should write key")
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"encoding/json/v2"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/api"
+)
+
+// Restricts the events returned by GetHistory, empty fields match everything
+type HistoryQuery struct {
+	Group string
+	ID    string
+	// Only return events at or after this time
+	Since time.Time
+	// Only return the newest events, unlimited when 0
+	Limit int
+}
+
+// Fetch the audit log from the admin api of the server, authenticating with the token of the client.
+// Returns the events sorted from oldest to newest.
+func (c *FleetlockClient) GetHistory(query HistoryQuery) ([]api.AuditEvent, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	params := url.Values{}
+	if query.Group != "" {
+		params.Set("group", query.Group)
+	}
+	if query.ID != "" {
+		params.Set("id", query.ID)
+	}
+	if !query.Since.IsZero() {
+		params.Set("since", query.Since.Format(time.RFC3339))
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	target := c.url + "/admin/v1/events"
+	if len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http get request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	res, err := c.getHTTPClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to server: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		resBody, err := api.ParseResponse(res.Body)
+		if err != nil {
+			return nil, fmt.Errorf("server returned status %d", res.StatusCode)
+		}
+		return nil, fmt.Errorf("failed to fetch history kind=\"%s\" reason=\"%s\"", resBody.Kind, resBody.Value)
+	}
+
+	var resBody api.AuditEventsResponse
+	err = json.UnmarshalRead(res.Body, &resBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response body: %v", err)
+	}
+	return resBody.Events, nil
+}
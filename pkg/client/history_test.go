@@ -0,0 +1,93 @@
+package client
+
+import (
+	"encoding/json/v2"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHistory(t *testing.T) {
+	events := []api.AuditEvent{
+		{
+			Time:   time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC),
+			Type:   "reserve",
+			Group:  "default",
+			ID:     "User1",
+			Remote: "192.0.2.1",
+		},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer token" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			_ = json.MarshalWrite(rw, api.FleetLockResponse{Kind: "unauthorized", Value: "Missing or invalid bearer token"})
+			return
+		}
+
+		assert.Equal(t, "/admin/v1/events", req.URL.Path, "Should call the events endpoint")
+		assert.Equal(t, "default", req.URL.Query().Get("group"))
+		assert.Equal(t, "User1", req.URL.Query().Get("id"))
+		assert.Equal(t, "2024-01-01T00:00:00Z", req.URL.Query().Get("since"))
+		assert.Equal(t, "10", req.URL.Query().Get("limit"))
+
+		_ = json.MarshalWrite(rw, api.AuditEventsResponse{Events: events})
+	}))
+	t.Cleanup(srv.Close)
+
+	query := HistoryQuery{
+		Group: "default",
+		ID:    "User1",
+		Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Limit: 10,
+	}
+
+	newAdminClient := func(t *testing.T, url, token string) *FleetlockClient {
+		c, err := NewAdminClient(url)
+		require.NoError(t, err, "Should create client")
+		c.SetToken(token)
+		return c
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		res, err := newAdminClient(t, srv.URL+"/", "token").GetHistory(query)
+
+		require.NoError(t, err)
+		assert.Equal(t, events, res)
+	})
+	t.Run("Unauthorized", func(t *testing.T) {
+		res, err := newAdminClient(t, srv.URL, "wrong").GetHistory(query)
+
+		assert.Nil(t, res)
+		assert.ErrorContains(t, err, "unauthorized")
+	})
+	t.Run("TLS", func(t *testing.T) {
+		tlsSrv := httptest.NewTLSServer(srv.Config.Handler)
+		t.Cleanup(tlsSrv.Close)
+
+		caFile := filepath.Join(t.TempDir(), "ca.crt")
+		require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsSrv.Certificate().Raw}), 0600), "Should write CA")
+
+		c := newAdminClient(t, tlsSrv.URL, "token")
+		_, err := c.GetHistory(query)
+		assert.Error(t, err, "Should not trust the server without the CA")
+
+		require.NoError(t, c.SetCACertificate(caFile), "Should load CA")
+		res, err := c.GetHistory(query)
+		require.NoError(t, err, "Should use the configured CA")
+		assert.Equal(t, events, res)
+	})
+	t.Run("MissingUrl", func(t *testing.T) {
+		_, err := NewAdminClient("")
+
+		assert.Error(t, err)
+	})
+}
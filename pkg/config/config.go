@@ -7,6 +7,7 @@ import (
 
 	"github.com/heathcliff26/fleetlock/pkg/k8s"
 	lockmanager "github.com/heathcliff26/fleetlock/pkg/lock-manager"
+	"github.com/heathcliff26/fleetlock/pkg/notify"
 	"github.com/heathcliff26/fleetlock/pkg/server"
 	"go.yaml.in/yaml/v3"
 )
@@ -33,23 +34,33 @@ type Config struct {
 	Server           *server.ServerConfig      `yaml:"server,omitempty"`
 	Storage          lockmanager.StorageConfig `yaml:"storage,omitempty"`
 	Groups           lockmanager.Groups        `yaml:"groups,omitempty"`
+	Audit            lockmanager.AuditConfig   `yaml:"audit,omitempty"`
+	Notifications    notify.Config             `yaml:"notifications,omitempty"`
 }
 
 // Parse a given string and set the resulting log level
 func setLogLevel(level string) error {
+	l, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	logLevel.Set(l)
+	return nil
+}
+
+func parseLogLevel(level string) (slog.Level, error) {
 	switch strings.ToLower(level) {
 	case "debug":
-		logLevel.Set(slog.LevelDebug)
+		return slog.LevelDebug, nil
 	case "info":
-		logLevel.Set(slog.LevelInfo)
+		return slog.LevelInfo, nil
 	case "warn":
-		logLevel.Set(slog.LevelWarn)
+		return slog.LevelWarn, nil
 	case "error":
-		logLevel.Set(slog.LevelError)
+		return slog.LevelError, nil
 	default:
-		return NewErrUnknownLogLevel(level)
+		return 0, NewErrUnknownLogLevel(level)
 	}
-	return nil
 }
 
 func DefaultConfig() *Config {
@@ -78,11 +89,28 @@ func LoadConfig(path string, env bool) (*Config, error) {
 		return nil, err
 	}
 
+	c, err = parseConfig(f, env)
+	if err != nil {
+		return nil, err
+	}
+
+	err = setLogLevel(c.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Parse and validate the content of a config file, without applying anything
+func parseConfig(f []byte, env bool) (*Config, error) {
+	c := DefaultConfig()
+
 	if env {
 		f = []byte(os.ExpandEnv(string(f)))
 	}
 
-	err = yaml.Unmarshal(f, &c)
+	err := yaml.Unmarshal(f, &c)
 	if err != nil {
 		return nil, err
 	}
@@ -107,7 +135,7 @@ func (c *Config) Defaults() {
 }
 
 func (c *Config) Validate() error {
-	err := setLogLevel(c.LogLevel)
+	_, err := parseLogLevel(c.LogLevel)
 	if err != nil {
 		return err
 	}
@@ -122,5 +150,15 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	err = c.Audit.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = c.Notifications.Validate()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -4,12 +4,14 @@ import (
 	"log/slog"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/heathcliff26/fleetlock/pkg/k8s"
 	lockmanager "github.com/heathcliff26/fleetlock/pkg/lock-manager"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/kubernetes"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/sql"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/valkey"
+	"github.com/heathcliff26/fleetlock/pkg/notify"
 	"github.com/heathcliff26/fleetlock/pkg/server"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -26,6 +28,7 @@ func TestValidConfigs(t *testing.T) {
 				Cert:    "foo.crt",
 				Key:     "foo.key",
 			},
+			ShutdownTimeout: server.DEFAULT_SHUTDOWN_TIMEOUT,
 		},
 		Storage: lockmanager.StorageConfig{
 			Type: "sqlite",
@@ -38,10 +41,35 @@ func TestValidConfigs(t *testing.T) {
 				Slots: 5,
 			},
 			"foo": lockmanager.GroupConfig{
-				Slots: 3,
+				Slots:      3,
+				MaxLockAge: 2 * time.Hour,
 			},
 			"bar": lockmanager.GroupConfig{
 				Slots: 10,
+				Schedule: &lockmanager.Schedule{
+					Timezone: "Europe/Berlin",
+					Windows: []lockmanager.MaintenanceWindow{
+						{
+							Days:     []string{"Sat", "Sun"},
+							Start:    "02:00",
+							Duration: 3 * time.Hour,
+						},
+					},
+				},
+			},
+		},
+		Audit: lockmanager.AuditConfig{
+			Sink: lockmanager.AuditSinkBoth,
+			File: "/var/log/fleetlock/audit.log",
+		},
+		Notifications: notify.Config{
+			SlotsFullAfter: time.Hour,
+			Webhooks: []notify.WebhookConfig{
+				{
+					URL:    "https://hooks.slack.com/services/T000/B000/XXXX",
+					Events: []string{notify.EventReserve, notify.EventDrainFailed},
+					Format: notify.FormatSlack,
+				},
 			},
 		},
 	}
@@ -179,6 +207,16 @@ func TestInvalidConfigs(t *testing.T) {
 			Path:   "testdata/invalid-4.yaml",
 			Result: "errors.ErrorGroupSlotsOutOfRange",
 		},
+		{
+			Name:   "InvalidAudit",
+			Path:   "testdata/invalid-5.yaml",
+			Result: "errors.ErrorAuditFileRequired",
+		},
+		{
+			Name:   "InvalidNotifications",
+			Path:   "testdata/invalid-6.yaml",
+			Result: "*notify.ErrorInvalidWebhookURL",
+		},
 	}
 
 	for _, tCase := range tMatrix {
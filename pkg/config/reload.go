@@ -0,0 +1,126 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+// How often the config file is checked for changes
+const configReloadInterval = 30 * time.Second
+
+// Reloads the config file when it changes or the process receives SIGHUP.
+// Only the log level and the groups are applied at runtime, all other changes require a restart.
+type Watcher struct {
+	path string
+	env  bool
+
+	// The config currently in use
+	current *Config
+	content []byte
+
+	onReload func(*Config)
+}
+
+// Create a watcher for the config file at path, current is the config loaded from it.
+// The callback is called with the new config after every successful reload.
+func NewWatcher(path string, env bool, current *Config, onReload func(*Config)) (*Watcher, error) {
+	// #nosec G304 -- Local users can decide on their file path themselves.
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Watcher{
+		path:     path,
+		env:      env,
+		current:  current,
+		content:  content,
+		onReload: onReload,
+	}, nil
+}
+
+// Reload the config on SIGHUP or when the file changes, until the context is cancelled
+func (w *Watcher) Run(ctx context.Context) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			slog.Info("Received SIGHUP, reloading config", slog.String("path", w.path))
+			err = w.Reload(true)
+		case <-time.After(configReloadInterval):
+			err = w.Reload(false)
+		}
+		if err != nil {
+			slog.Error("Failed to reload config, keeping the current one", "error", err, slog.String("path", w.path))
+		}
+	}
+}
+
+// Read the config file and apply it if it has changed, or always when force is set.
+// Keeps the current config when the new one is invalid.
+func (w *Watcher) Reload(force bool) error {
+	// #nosec G304 -- Local users can decide on their file path themselves.
+	content, err := os.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+	if !force && bytes.Equal(content, w.content) {
+		return nil
+	}
+
+	cfg, err := parseConfig(content, w.env)
+	if err != nil {
+		return err
+	}
+	w.content = content
+
+	warnStaticChanges(w.current, cfg)
+
+	if cfg.LogLevel != w.current.LogLevel {
+		// Already validated when parsing
+		_ = setLogLevel(cfg.LogLevel)
+		slog.Info("Changed log level", slog.String("level", cfg.LogLevel))
+	}
+
+	// Keep everything that can't be applied, so it is compared against the running config next time
+	next := *w.current
+	next.LogLevel = cfg.LogLevel
+	next.Groups = cfg.Groups
+	w.current = &next
+
+	slog.Info("Reloaded config", slog.String("path", w.path))
+	w.onReload(&next)
+	return nil
+}
+
+// Warn about all changes that can only be applied by restarting
+func warnStaticChanges(current, cfg *Config) {
+	sections := []struct {
+		name     string
+		old, new any
+	}{
+		{"kubernetes", current.KubernetesConfig, cfg.KubernetesConfig},
+		{"server", current.Server, cfg.Server},
+		{"storage", current.Storage, cfg.Storage},
+		{"audit", current.Audit, cfg.Audit},
+		{"notifications", current.Notifications, cfg.Notifications},
+	}
+
+	for _, section := range sections {
+		if !reflect.DeepEqual(section.old, section.new) {
+			slog.Warn("Config changed in a section that can't be reloaded, restart to apply the changes", slog.String("section", section.name))
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	lockmanager "github.com/heathcliff26/fleetlock/pkg/lock-manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reloadTestConfig = `
+logLevel: info
+groups:
+  default:
+    slots: 1
+`
+
+func TestWatcherReload(t *testing.T) {
+	t.Cleanup(func() {
+		_ = setLogLevel(DEFAULT_LOG_LEVEL)
+	})
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(t *testing.T, content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600), "Should write config")
+	}
+	writeConfig(t, reloadTestConfig)
+
+	cfg, err := LoadConfig(path, false)
+	require.NoError(t, err, "Should load config")
+
+	var reloaded *Config
+	w, err := NewWatcher(path, false, cfg, func(c *Config) {
+		reloaded = c
+	})
+	require.NoError(t, err, "Should create watcher")
+
+	t.Run("Unchanged", func(t *testing.T) {
+		reloaded = nil
+
+		assert.NoError(t, w.Reload(false), "Should succeed")
+		assert.Nil(t, reloaded, "Should not reload an unchanged file")
+	})
+	t.Run("Forced", func(t *testing.T) {
+		reloaded = nil
+
+		assert.NoError(t, w.Reload(true), "Should succeed")
+		assert.Equal(t, cfg, reloaded, "Should reload an unchanged file when forced")
+	})
+	t.Run("GroupsAndLogLevel", func(t *testing.T) {
+		reloaded = nil
+		writeConfig(t, `
+logLevel: debug
+groups:
+  default:
+    slots: 2
+  workers:
+    slots: 1
+`)
+
+		assert := assert.New(t)
+
+		assert.NoError(w.Reload(false), "Should succeed")
+		require.NotNil(t, reloaded, "Should call the callback")
+		assert.Equal(lockmanager.Groups{
+			"default": lockmanager.GroupConfig{Slots: 2},
+			"workers": lockmanager.GroupConfig{Slots: 1},
+		}, reloaded.Groups, "Should apply the new groups")
+		assert.Equal("debug", reloaded.LogLevel)
+		assert.Equal(slog.LevelDebug, logLevel.Level(), "Should set the new log level")
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		reloaded = nil
+		current := w.current
+		writeConfig(t, "logLevel: foo\n")
+
+		assert := assert.New(t)
+
+		assert.Error(w.Reload(false), "Should fail")
+		assert.Nil(reloaded, "Should not call the callback")
+		assert.Same(current, w.current, "Should keep the current config")
+		assert.Equal(slog.LevelDebug, logLevel.Level(), "Should keep the log level")
+	})
+	t.Run("StaticChanges", func(t *testing.T) {
+		reloaded = nil
+		writeConfig(t, reloadTestConfig+`
+storage:
+  type: sqlite
+  sqlite:
+    file: /tmp/fleetlock.db
+`)
+
+		assert := assert.New(t)
+
+		assert.NoError(w.Reload(false), "Should succeed")
+		require.NotNil(t, reloaded, "Should call the callback")
+		assert.Equal(cfg.Storage, reloaded.Storage, "Should keep the storage in use")
+		assert.Equal(lockmanager.Groups{"default": lockmanager.GroupConfig{Slots: 1}}, reloaded.Groups, "Should apply the groups")
+	})
+	t.Run("MissingFile", func(t *testing.T) {
+		require.NoError(t, os.Remove(path), "Should remove config")
+
+		assert.Error(t, w.Reload(false), "Should fail")
+	})
+}
+
+func TestNewWatcherMissingFile(t *testing.T) {
+	_, err := NewWatcher("not-a-file", false, DefaultConfig(), func(_ *Config) {})
+
+	assert.Error(t, err, "Should fail")
+}
@@ -26,6 +26,8 @@ type FakeServer struct {
 	Group string
 	// The expected id, ignored when empty
 	ID string
+	// The expected bearer token, ignored when empty
+	Token string
 }
 
 // Create a new fake server.
@@ -43,7 +45,7 @@ func NewFakeServer(t *testing.T, statusCode int, path string) *FakeServer {
 }
 
 func (s *FakeServer) handleRequest(rw http.ResponseWriter, req *http.Request) {
-	s.assert.Contains([]string{"/v1/pre-reboot", "/v1/steady-state"}, req.URL.String(), "Should request a valid url")
+	s.assert.Contains([]string{"/v1/pre-reboot", "/v1/steady-state", "/v1/renew"}, req.URL.String(), "Should request a valid url")
 	if s.Path != "" {
 		s.assert.Equal(s.Path, req.URL.String(), "Should use the specified URL")
 	}
@@ -51,6 +53,10 @@ func (s *FakeServer) handleRequest(rw http.ResponseWriter, req *http.Request) {
 	s.assert.Equal(http.MethodPost, req.Method, "Should be POST request")
 	s.assert.Equal("true", strings.ToLower(req.Header.Get("fleet-lock-protocol")), "fleet-lock-protocol header should be set")
 
+	if s.Token != "" {
+		s.assert.Equal("Bearer "+s.Token, req.Header.Get("Authorization"), "Should send the bearer token")
+	}
+
 	params, err := api.ParseRequest(req.Body)
 	s.assert.NoError(err, "Request should have the correct format")
 
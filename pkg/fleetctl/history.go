@@ -0,0 +1,119 @@
+package fleetctl
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagNameToken = "token"
+	flagNameSince = "since"
+	flagNameLimit = "limit"
+
+	envAdminToken = "FLEETLOCK_ADMIN_TOKEN"
+)
+
+// Create a new history command
+func NewHistoryCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "show the audit log of the server, requires the admin api",
+		Args:  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, query, err := getHistoryQueryFromCMD(cmd)
+			if err != nil {
+				return err
+			}
+
+			c, err := client.NewAdminClient(args[0])
+			if err != nil {
+				return err
+			}
+			c.SetToken(token)
+			err = setClientTLSFromCMD(cmd, c)
+			if err != nil {
+				return err
+			}
+
+			events, err := c.GetHistory(query)
+			if err != nil {
+				exitError(cmd, err)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TIME\tTYPE\tGROUP\tID\tNODE\tREMOTE\tREASON")
+			for _, e := range events {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format(time.RFC3339), e.Type, e.Group, e.ID, e.Node, e.Remote, e.Reason)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringP(flagNameGroup, "g", "", "Only show events of this group")
+	cmd.Flags().StringP(flagNameID, "i", "", "Only show events of this id")
+	cmd.Flags().StringP(flagNameToken, "t", "", "Token for the admin api, defaults to $"+envAdminToken)
+	cmd.Flags().String(flagNameSince, "", "Only show newer events, either a duration like 24h or a RFC3339 timestamp")
+	cmd.Flags().IntP(flagNameLimit, "n", 0, "Only show the newest n events, 0 for all")
+	addTLSFlagsToCMD(cmd)
+
+	return cmd
+}
+
+// Parse the flags of the history command
+func getHistoryQueryFromCMD(cmd *cobra.Command) (string, client.HistoryQuery, error) {
+	var query client.HistoryQuery
+
+	token, err := cmd.Flags().GetString(flagNameToken)
+	if err != nil {
+		return "", query, err
+	}
+	if token == "" {
+		token = os.Getenv(envAdminToken)
+	}
+	if token == "" {
+		return "", query, fmt.Errorf("missing token for the admin api, use --%s or $%s", flagNameToken, envAdminToken)
+	}
+
+	query.Group, err = cmd.Flags().GetString(flagNameGroup)
+	if err != nil {
+		return "", query, err
+	}
+	query.ID, err = cmd.Flags().GetString(flagNameID)
+	if err != nil {
+		return "", query, err
+	}
+	query.Limit, err = cmd.Flags().GetInt(flagNameLimit)
+	if err != nil {
+		return "", query, err
+	}
+
+	since, err := cmd.Flags().GetString(flagNameSince)
+	if err != nil {
+		return "", query, err
+	}
+	if since != "" {
+		query.Since, err = parseSince(since)
+		if err != nil {
+			return "", query, err
+		}
+	}
+
+	return token, query, nil
+}
+
+// Parse either a duration relative to now or a timestamp
+func parseSince(since string) (time.Time, error) {
+	d, err := time.ParseDuration(since)
+	if err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid value \"%s\" for --%s, needs to be a duration or RFC3339 timestamp", since, flagNameSince)
+	}
+	return t, nil
+}
@@ -0,0 +1,131 @@
+package fleetctl
+
+import (
+	"bytes"
+	"encoding/json/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHistoryCommand(t *testing.T) {
+	cmd := NewHistoryCommand()
+
+	assert := assert.New(t)
+
+	assert.Equal("history", cmd.Use)
+	assert.True(cmd.HasLocalFlags())
+}
+
+func TestHistoryCommand(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"), "Should send the token")
+		assert.Equal(t, "default", req.URL.Query().Get("group"), "Should filter by group")
+		assert.Equal(t, "5", req.URL.Query().Get("limit"), "Should limit the events")
+
+		res := api.AuditEventsResponse{
+			Events: []api.AuditEvent{
+				{
+					Time:   time.Now(),
+					Type:   "reserve_denied",
+					Group:  "default",
+					ID:     "User1",
+					Remote: "192.0.2.1",
+					Reason: "slots_full",
+				},
+			},
+		}
+		_ = json.MarshalWrite(rw, res)
+	}))
+	defer srv.Close()
+
+	t.Run("Success", func(t *testing.T) {
+		cmd := NewHistoryCommand()
+		cmd.SetArgs([]string{srv.URL, "--token", "token", "--group", "default", "-n", "5", "--since", "24h"})
+
+		b := &bytes.Buffer{}
+		cmd.SetOut(b)
+
+		err := cmd.Execute()
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		assert.Contains(b.String(), "TIME")
+		assert.Contains(b.String(), "reserve_denied")
+		assert.Contains(b.String(), "192.0.2.1")
+		assert.Contains(b.String(), "slots_full")
+	})
+	t.Run("TokenFromEnv", func(t *testing.T) {
+		t.Setenv(envAdminToken, "token")
+
+		cmd := NewHistoryCommand()
+		cmd.SetArgs([]string{srv.URL, "--group", "default", "-n", "5"})
+		cmd.SetOut(&bytes.Buffer{})
+
+		assert.NoError(t, cmd.Execute())
+	})
+	t.Run("MissingToken", func(t *testing.T) {
+		t.Setenv(envAdminToken, "")
+
+		cmd := NewHistoryCommand()
+		cmd.SetArgs([]string{srv.URL})
+
+		err := cmd.Execute()
+
+		assert.ErrorContains(t, err, "missing token")
+	})
+	t.Run("InvalidSince", func(t *testing.T) {
+		cmd := NewHistoryCommand()
+		cmd.SetArgs([]string{srv.URL, "--token", "token", "--since", "yesterday"})
+
+		err := cmd.Execute()
+
+		assert.ErrorContains(t, err, "invalid value \"yesterday\"")
+	})
+	t.Run("MissingCA", func(t *testing.T) {
+		cmd := NewHistoryCommand()
+		cmd.SetArgs([]string{srv.URL, "--token", "token", "--" + flagNameCA, "not-a-file"})
+
+		err := cmd.Execute()
+
+		assert.ErrorContains(t, err, "failed to read CA certificate")
+	})
+	t.Run("MissingArgs", func(t *testing.T) {
+		cmd := NewHistoryCommand()
+
+		err := cmd.Execute()
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "accepts 1 arg(s), received 0")
+	})
+}
+
+func TestParseSince(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	res, err := parseSince("2024-01-01T00:00:00Z")
+	require.NoError(err)
+	assert.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), res)
+
+	res, err = parseSince("1h")
+	require.NoError(err)
+	assert.WithinDuration(time.Now().Add(-time.Hour), res, time.Minute)
+}
+
+func TestHistoryCommandExitError(t *testing.T) {
+	if os.Getenv("RUN_CRASH_TEST") == "1" {
+		cmd := NewHistoryCommand()
+		cmd.SetArgs([]string{"http://127.0.0.1:1", "--token", "token"})
+		_ = cmd.Execute()
+		os.Exit(0)
+	}
+	execExitTest(t, "TestHistoryCommandExitError", true)
+}
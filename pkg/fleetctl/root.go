@@ -25,6 +25,7 @@ func NewRootCommand() *cobra.Command {
 	rootCmd.AddCommand(
 		NewLockCommand(),
 		NewReleaseCommand(),
+		NewHistoryCommand(),
 		NewIDCommand(),
 		version.NewCommand(Name),
 	)
@@ -11,11 +11,24 @@ import (
 const (
 	flagNameGroup = "group"
 	flagNameID    = "id"
+	flagNameCert  = "cert"
+	flagNameKey   = "key"
+	flagNameCA    = "ca"
+
+	envToken = "FLEETLOCK_TOKEN"
 )
 
 func addCommonFlagsToCMD(cmd *cobra.Command) {
 	cmd.Flags().StringP(flagNameGroup, "g", "default", "Name of the lock group")
 	cmd.Flags().StringP(flagNameID, "i", "", "Specify the id to use, defaults to zincati appID")
+	cmd.Flags().StringP(flagNameToken, "t", "", "Bearer token to authenticate with, defaults to $"+envToken)
+	addTLSFlagsToCMD(cmd)
+}
+
+func addTLSFlagsToCMD(cmd *cobra.Command) {
+	cmd.Flags().String(flagNameCert, "", "Client certificate to authenticate with, requires --"+flagNameKey)
+	cmd.Flags().String(flagNameKey, "", "Private key of the client certificate")
+	cmd.Flags().String(flagNameCA, "", "CA to verify the server certificate, defaults to the system CAs")
 }
 
 // Takes care if parsing the arguments and creating a client from them
@@ -45,9 +58,58 @@ func getClientFromCMD(cmd *cobra.Command, args []string) (*client.FleetlockClien
 		}
 	}
 
+	err = setClientCredentialsFromCMD(cmd, c)
+	if err != nil {
+		return nil, err
+	}
+
 	return c, nil
 }
 
+// Configure the token, client certificate and CA of the client from the flags
+func setClientCredentialsFromCMD(cmd *cobra.Command, c *client.FleetlockClient) error {
+	token, err := cmd.Flags().GetString(flagNameToken)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		token = os.Getenv(envToken)
+	}
+	c.SetToken(token)
+
+	return setClientTLSFromCMD(cmd, c)
+}
+
+// Configure the client certificate and CA of the client from the flags
+func setClientTLSFromCMD(cmd *cobra.Command, c *client.FleetlockClient) error {
+	cert, err := cmd.Flags().GetString(flagNameCert)
+	if err != nil {
+		return err
+	}
+	key, err := cmd.Flags().GetString(flagNameKey)
+	if err != nil {
+		return err
+	}
+	if (cert == "") != (key == "") {
+		return fmt.Errorf("--%s and --%s need to be used together", flagNameCert, flagNameKey)
+	}
+	if cert != "" {
+		err = c.SetClientCertificate(cert, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	ca, err := cmd.Flags().GetString(flagNameCA)
+	if err != nil {
+		return err
+	}
+	if ca != "" {
+		return c.SetCACertificate(ca)
+	}
+	return nil
+}
+
 // Print the error information on stderr and exit with code 1
 func exitError(cmd *cobra.Command, err error) {
 	cmd.PrintErrln("Fatal: " + err.Error())
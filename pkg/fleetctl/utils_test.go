@@ -26,6 +26,10 @@ func TestAddCommonFlagsToCMD(t *testing.T) {
 	assert.True(cmd.HasLocalFlags(), "Command should have local flags")
 	assert.NotNil(cmd.Flags().Lookup(flagNameGroup), "Should have group flag")
 	assert.NotNil(cmd.Flags().Lookup(flagNameID), "Should have id flag")
+	assert.NotNil(cmd.Flags().Lookup(flagNameToken), "Should have token flag")
+	assert.NotNil(cmd.Flags().Lookup(flagNameCert), "Should have cert flag")
+	assert.NotNil(cmd.Flags().Lookup(flagNameKey), "Should have key flag")
+	assert.NotNil(cmd.Flags().Lookup(flagNameCA), "Should have ca flag")
 }
 
 func TestGetClientFromCMD(t *testing.T) {
@@ -94,6 +98,55 @@ func TestGetClientFromCMD(t *testing.T) {
 	}
 }
 
+func TestGetClientFromCMDCredentials(t *testing.T) {
+	newCMD := func() *cobra.Command {
+		cmd := &cobra.Command{
+			Use: "test",
+		}
+		addCommonFlagsToCMD(cmd)
+		return cmd
+	}
+	url := "https://fleetlock.example.org"
+
+	t.Run("Token", func(t *testing.T) {
+		cmd := newCMD()
+		require.NoError(t, cmd.ParseFlags([]string{"--" + flagNameToken, "secret"}), "Should parse the flags")
+
+		c, err := getClientFromCMD(cmd, []string{url})
+		require.NoError(t, err, "Should create client")
+		assert.Equal(t, "secret", c.GetToken(), "Should have the token set")
+	})
+	t.Run("TokenFromEnv", func(t *testing.T) {
+		t.Setenv(envToken, "env-secret")
+		cmd := newCMD()
+
+		c, err := getClientFromCMD(cmd, []string{url})
+		require.NoError(t, err, "Should create client")
+		assert.Equal(t, "env-secret", c.GetToken(), "Should read the token from the environment")
+	})
+	t.Run("CertWithoutKey", func(t *testing.T) {
+		cmd := newCMD()
+		require.NoError(t, cmd.ParseFlags([]string{"--" + flagNameCert, "client.crt"}), "Should parse the flags")
+
+		_, err := getClientFromCMD(cmd, []string{url})
+		assert.Error(t, err, "Should require a key")
+	})
+	t.Run("MissingCert", func(t *testing.T) {
+		cmd := newCMD()
+		require.NoError(t, cmd.ParseFlags([]string{"--" + flagNameCert, "not-a-file", "--" + flagNameKey, "not-a-file"}), "Should parse the flags")
+
+		_, err := getClientFromCMD(cmd, []string{url})
+		assert.Error(t, err, "Should fail to load the certificate")
+	})
+	t.Run("MissingCA", func(t *testing.T) {
+		cmd := newCMD()
+		require.NoError(t, cmd.ParseFlags([]string{"--" + flagNameCA, "not-a-file"}), "Should parse the flags")
+
+		_, err := getClientFromCMD(cmd, []string{url})
+		assert.Error(t, err, "Should fail to load the CA")
+	})
+}
+
 func execExitTest(t *testing.T, test string, exitsError bool) {
 	cmd := exec.Command(os.Args[0], "-test.run="+test)
 	cmd.Env = append(os.Environ(), "RUN_CRASH_TEST=1")
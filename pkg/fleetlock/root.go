@@ -1,11 +1,16 @@
 package fleetlock
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/heathcliff26/fleetlock/pkg/config"
 	"github.com/heathcliff26/fleetlock/pkg/k8s"
+	"github.com/heathcliff26/fleetlock/pkg/notify"
 	"github.com/heathcliff26/fleetlock/pkg/server"
 	"github.com/heathcliff26/fleetlock/pkg/version"
 	"github.com/spf13/cobra"
@@ -62,19 +67,74 @@ func run(cmd *cobra.Command, configPath string, env bool) {
 		exitError(cmd, fmt.Errorf("failed to load configuration: %w", err))
 	}
 
+	group := cfg.Groups.ExclusiveWithSharedStorage(cfg.Storage, cfg.KubernetesConfig.LeaderElection.Enabled)
+	if group != "" {
+		slog.Warn("exclusiveWith is only checked by the server handling the request, it is not guaranteed when multiple replicas share the storage without leader election", slog.String("group", group), slog.String("storage", cfg.Storage.Type))
+	}
+
+	notifier, err := notify.NewNotifier(cfg.Notifications)
+	if err != nil {
+		exitError(cmd, fmt.Errorf("failed to create notifier: %w", err))
+	}
+
 	k8s, err := k8s.NewClient(cfg.KubernetesConfig)
 	if err != nil {
 		exitError(cmd, fmt.Errorf("failed to create kubernetes client: %w", err))
 	}
+	if k8s != nil {
+		k8s.SetNotifier(notifier)
+	}
 
-	s, err := server.NewServer(cfg.Server, cfg.Groups, cfg.Storage, k8s)
+	s, err := server.NewServer(cfg.Server, cfg.Groups, cfg.Storage, cfg.Audit, k8s)
 	if err != nil {
 		exitError(cmd, fmt.Errorf("failed to create server: %w", err))
 	}
+	s.SetNotifier(notifier)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if cfg.KubernetesConfig.LeaderElection.Enabled {
+		if k8s == nil {
+			exitError(cmd, fmt.Errorf("leader election requires running inside kubernetes or a kubeconfig"))
+		}
+		elector, err := k8s.NewLeaderElector(cfg.KubernetesConfig.LeaderElection)
+		if err != nil {
+			exitError(cmd, fmt.Errorf("failed to create leader elector: %w", err))
+		}
+		s.SetLeaderElector(elector)
+		go elector.Run(ctx)
+	}
+
+	if configPath != "" {
+		watcher, err := config.NewWatcher(configPath, env, cfg, func(cfg *config.Config) {
+			s.UpdateGroups(cfg.Groups)
+		})
+		if err != nil {
+			exitError(cmd, fmt.Errorf("failed to watch configuration: %w", err))
+		}
+		go watcher.Run(ctx)
+	}
+
+	shutdownComplete := make(chan struct{})
+	go func() {
+		defer close(shutdownComplete)
+
+		<-ctx.Done()
+		slog.Info("Received signal, shutting down")
+		err := s.Shutdown()
+		if err != nil {
+			slog.Error("Failed to shutdown cleanly", "error", err)
+		}
+		notifier.Wait()
+	}()
+
 	err = s.Run()
 	if err != nil {
 		exitError(cmd, fmt.Errorf("failed to run server: %w", err))
 	}
+	// Run returns as soon as the server stops listening, but the shutdown continues with the drains and storage
+	<-shutdownComplete
 }
 
 // Print the error information on stderr and exit with code 1
@@ -4,20 +4,36 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/heathcliff26/fleetlock/pkg/k8s/utils"
+	"github.com/heathcliff26/fleetlock/pkg/metrics"
+	"github.com/heathcliff26/fleetlock/pkg/notify"
 	systemdutils "github.com/heathcliff26/fleetlock/pkg/systemd-utils"
 
 	v1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/rest"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+const (
+	evictionRetryInitialInterval = time.Second
+	evictionRetryMaxInterval     = 30 * time.Second
+	podDeletionPollInterval      = 2 * time.Second
+	// Time to update the lease after the drain failed or was interrupted, as the context of the drain may be done already
+	leaseUpdateTimeout = 10 * time.Second
+	// Time for the api server to answer the readiness check
+	pingTimeout = 5 * time.Second
 )
 
 type Client struct {
@@ -25,6 +41,14 @@ type Client struct {
 	namespace           string
 	drainTimeoutSeconds int32
 	drainRetries        int
+	groupLabel          string
+	groupLabelMode      string
+	healthGates         HealthGates
+	waitForNodeReady    bool
+	deleteEmptyDirData  bool
+	force               bool
+	podSelector         string
+	notifier            *notify.Notifier
 }
 
 // Create a new kubernetes client, defaults to in-cluster if no kubeconfig is provided
@@ -45,11 +69,27 @@ func NewClient(config Config) (*Client, error) {
 		return nil, NewErrorDrainTimeoutSecondsInvalid()
 	}
 
+	_, err = labels.Parse(config.PodSelector)
+	if err != nil {
+		return nil, NewErrorInvalidPodSelector(config.PodSelector, err)
+	}
+
+	if config.GroupLabelMode != GroupLabelModeOverride && config.GroupLabelMode != GroupLabelModeValidate {
+		return nil, NewErrorInvalidGroupLabelMode(config.GroupLabelMode)
+	}
+
 	return &Client{
 		client:              client,
 		namespace:           ns,
 		drainTimeoutSeconds: config.DrainTimeoutSeconds,
 		drainRetries:        config.DrainRetries,
+		groupLabel:          config.GroupLabel,
+		groupLabelMode:      config.GroupLabelMode,
+		healthGates:         config.HealthGates,
+		waitForNodeReady:    config.WaitForNodeReady,
+		deleteEmptyDirData:  config.DeleteEmptyDirData,
+		force:               config.Force,
+		podSelector:         config.PodSelector,
 	}, nil
 }
 
@@ -57,17 +97,31 @@ func NewClient(config Config) (*Client, error) {
 // Initialize the fake k8s client with the provided runtime objects.
 func NewFakeClient(objects ...runtime.Object) (*Client, *fake.Clientset) {
 	fakeclient := fake.NewClientset(objects...)
+	// The fake clientset does not delete pods on eviction
+	fakeclient.PrependReactor("create", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(clienttesting.CreateAction).GetObject().(*policyv1.Eviction)
+		err := fakeclient.Tracker().Delete(v1.SchemeGroupVersion.WithResource("pods"), eviction.GetNamespace(), eviction.GetName())
+		return true, nil, err
+	})
 	return &Client{
 		client:              fakeclient,
 		namespace:           "fleetlock",
 		drainTimeoutSeconds: 300,
+		deleteEmptyDirData:  true,
+		force:               true,
 	}, fakeclient
 }
 
 // Drain a node from all pods and set it to unschedulable.
 // Status will be tracked in lease, only one drain will be run at a time.
-func (c *Client) DrainNode(node string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.drainTimeoutSeconds)*time.Second)
+// Calls started, which may be nil, once this call is the one running the drain.
+// When parent is cancelled, the drain is stopped and the lease marked as interrupted, so it can be continued later.
+func (c *Client) DrainNode(parent context.Context, node string, started func()) error {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(parent, time.Duration(c.drainTimeoutSeconds)*time.Second)
 	defer cancel()
 
 	lease := NewLease(drainLeaseName(node), c.client.CoordinationV1().Leases(c.namespace))
@@ -75,81 +129,179 @@ func (c *Client) DrainNode(node string) error {
 	if err != nil {
 		return err
 	}
+	if started != nil {
+		started()
+	}
 
-	err = c.drainNode(ctx, node)
-	if err != nil {
-		err2 := lease.Error(ctx)
-		if err2 != nil {
-			slog.Error("Failed to set drain lease to error state", slog.String("node", node), "err", err)
+	report := func(progress DrainProgress) {
+		err := lease.SetProgress(ctx, progress)
+		if err != nil {
+			slog.Warn("Failed to update drain progress in lease", slog.String("node", node), "err", err)
 		}
+	}
+	err = c.drainNode(ctx, node, report)
+	if err != nil && parent.Err() != nil {
+		c.interruptDrain(lease, node)
+		return NewErrorDrainInterrupted()
+	}
+	metrics.ObserveDrain(time.Since(start), err)
+	if err != nil {
+		c.failDrain(lease, node, err)
 		return err
 	}
 
 	return lease.Done(ctx)
 }
 
-// Drain a node of all pods, skipping daemonsets
-func (c *Client) drainNode(ctx context.Context, node string) error {
+// Mark the drain as failed and notify about it, the drain may have failed because its context timed out
+func (c *Client) failDrain(l *lease, node string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), leaseUpdateTimeout)
+	defer cancel()
+
+	err2 := l.Error(ctx)
+	if err2 != nil {
+		slog.Error("Failed to set drain lease to error state", slog.String("node", node), "err", err2)
+	}
+	c.notifyDrainFailed(ctx, l, node, err)
+}
+
+// Mark the drain as interrupted, the context of the drain is already cancelled at this point
+func (c *Client) interruptDrain(l *lease, node string) {
+	ctx, cancel := context.WithTimeout(context.Background(), leaseUpdateTimeout)
+	defer cancel()
+
+	err := l.Interrupt(ctx)
+	if err != nil {
+		slog.Error("Failed to set drain lease to interrupted state", slog.String("node", node), "err", err)
+		return
+	}
+	slog.Warn("Interrupted drain of node", slog.String("node", node))
+}
+
+// Send notifications about failed drains to the given notifier, may be nil
+func (c *Client) SetNotifier(n *notify.Notifier) {
+	c.notifier = n
+}
+
+// Notify about the failed drain and if it was the last retry
+func (c *Client) notifyDrainFailed(ctx context.Context, l *lease, node string, err error) {
+	if c.notifier == nil {
+		return
+	}
+
+	c.notifier.Notify(notify.Event{
+		Type:   notify.EventDrainFailed,
+		Node:   node,
+		Reason: err.Error(),
+	})
+
+	if c.drainRetries < 1 {
+		return
+	}
+	fails, err := l.GetFailCounter(ctx)
+	if err != nil {
+		slog.Error("Failed to read drain failure counter", slog.String("node", node), "err", err)
+		return
+	}
+	if fails == c.drainRetries {
+		c.notifier.Notify(notify.Event{
+			Type: notify.EventDrainRetriesExhausted,
+			Node: node,
+		})
+	}
+}
+
+// Drain a node of all pods, skipping mirror pods and daemonsets.
+// Follows the semantics of kubectl drain and waits for the pods to be deleted.
+// Changes in progress are passed to report, which may be nil.
+func (c *Client) drainNode(ctx context.Context, node string, report func(DrainProgress)) error {
+	var progress DrainProgress
+	reportProgress := func() {
+		if report != nil {
+			report(progress)
+		}
+	}
+
 	_, err := c.client.CoreV1().Nodes().Patch(ctx, node, types.MergePatchType, nodeUnschedulablePatch(true), metav1.PatchOptions{})
 	if err != nil {
 		return err
 	}
 
-	pods, err := c.client.CoreV1().Pods(v1.NamespaceAll).List(ctx, metav1.ListOptions{
+	podList, err := c.client.CoreV1().Pods(v1.NamespaceAll).List(ctx, metav1.ListOptions{
 		FieldSelector: fields.SelectorFromSet(fields.Set{"spec.nodeName": node}).String(),
+		LabelSelector: c.podSelector,
 	})
 	if err != nil {
 		return err
 	}
 
-	var returnError error
-	evictSelf := false
-	selfName, selfNamespace := os.Getenv("POD_NAME"), os.Getenv("POD_NAMESPACE")
-	for _, pod := range pods.Items {
-		// Skip mirror pods
-		if _, ok := pod.Annotations[v1.MirrorPodAnnotationKey]; ok {
-			continue
+	pods, err := c.podsToEvict(podList.Items)
+	if err != nil {
+		if e, ok := err.(*ErrorCannotEvictPod); ok {
+			progress.setFailedEviction(e.namespace+"/"+e.name, e.reason)
+			reportProgress()
 		}
-		// Skip daemonsets
-		controller := metav1.GetControllerOf(&pod)
-		if controller != nil && controller.Kind == "DaemonSet" {
+		return err
+	}
+
+	var self *v1.Pod
+	selfName, selfNamespace := os.Getenv("POD_NAME"), os.Getenv("POD_NAMESPACE")
+	for _, pod := range pods {
+		if pod.GetName() == selfName && pod.GetNamespace() == selfNamespace {
 			continue
 		}
+		progress.PodsRemaining = append(progress.PodsRemaining, podName(&pod))
+	}
+	reportProgress()
 
+	var returnError error
+	evicted := make([]v1.Pod, 0, len(pods))
+	for _, pod := range pods {
 		if pod.GetName() == selfName && pod.GetNamespace() == selfNamespace {
 			slog.Debug("Delaying evicting myself until all other pods are evicted", slog.String("node", node))
-			evictSelf = true
+			self = &pod
 			continue
 		}
 
-		err = c.evictPod(ctx, pod.GetName(), pod.GetNamespace(), pod.Spec.TerminationGracePeriodSeconds)
+		blocked := func(err error) {
+			progress.setFailedEviction(podName(&pod), err.Error())
+			reportProgress()
+		}
+		err = c.evictPodWithRetry(ctx, pod.GetName(), pod.GetNamespace(), pod.Spec.TerminationGracePeriodSeconds, blocked)
+		if ctx.Err() != nil {
+			slog.Error("Aborting node drain", slog.String("node", node), "err", ctx.Err())
+			return ctx.Err()
+		}
 		if err != nil {
 			slog.Info("Failed to evict pod", "err", err, slog.String("node", node), slog.String("pod", pod.GetName()), slog.String("namespace", pod.GetNamespace()))
+			progress.setFailedEviction(podName(&pod), err.Error())
+			reportProgress()
 			returnError = NewErrorFailedToEvictAllPods()
 			continue
 		}
 		slog.Info("Evicted pod", slog.String("node", node), slog.String("pod", pod.GetName()), slog.String("namespace", pod.GetNamespace()))
-
-		done := false
-		select {
-		case <-ctx.Done():
-			slog.Error("Aborting node drain", slog.String("node", node), "err", ctx.Err())
-			if returnError == nil {
-				returnError = ctx.Err()
-			}
-			done = true
-		default:
+		if progress.removeFailedEviction(podName(&pod)) {
+			reportProgress()
 		}
+		evicted = append(evicted, pod)
+	}
 
-		if done {
-			break
-		}
+	deleted := func(pod *v1.Pod) {
+		progress.PodsRemaining = slices.DeleteFunc(progress.PodsRemaining, func(name string) bool {
+			return name == podName(pod)
+		})
+		reportProgress()
+	}
+	err = c.waitForPodsDeleted(ctx, evicted, deleted)
+	if err != nil {
+		slog.Error("Aborting node drain, pods have not been deleted in time", slog.String("node", node), "err", err)
+		return err
 	}
 
-	if evictSelf {
-		err = c.evictPod(ctx, selfName, selfNamespace, utils.Pointer(int64(10)))
+	if self != nil {
+		err = c.evictPod(ctx, self.GetName(), self.GetNamespace(), utils.Pointer(int64(10)))
 		if err != nil {
-			slog.Info("Failed to evict myself", "err", err, slog.String("node", node), slog.String("pod", selfName), slog.String("namespace", selfNamespace))
+			slog.Info("Failed to evict myself", "err", err, slog.String("node", node), slog.String("pod", self.GetName()), slog.String("namespace", self.GetNamespace()))
 			returnError = NewErrorFailedToEvictAllPods()
 		}
 	}
@@ -157,6 +309,89 @@ func (c *Client) drainNode(ctx context.Context, node string) error {
 	return returnError
 }
 
+// Filter the pods that need to be evicted.
+// Returns an error if a pod can't be evicted without force or deleteEmptyDirData.
+func (c *Client) podsToEvict(pods []v1.Pod) ([]v1.Pod, error) {
+	res := make([]v1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		// Skip mirror pods
+		if _, ok := pod.Annotations[v1.MirrorPodAnnotationKey]; ok {
+			continue
+		}
+		// Skip daemonsets
+		controller := metav1.GetControllerOf(&pod)
+		if controller != nil && controller.Kind == "DaemonSet" {
+			continue
+		}
+
+		// Finished pods can always be removed
+		if pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed {
+			if controller == nil && !c.force {
+				return nil, NewErrorCannotEvictPod(pod.GetNamespace(), pod.GetName(), "pod is not managed by a controller, set force to evict it")
+			}
+			if !c.deleteEmptyDirData && hasEmptyDir(&pod) {
+				return nil, NewErrorCannotEvictPod(pod.GetNamespace(), pod.GetName(), "pod uses emptyDir volumes, set deleteEmptyDirData to evict it")
+			}
+		}
+
+		res = append(res, pod)
+	}
+	return res, nil
+}
+
+// Evict the pod, retrying with backoff while a PodDisruptionBudget prevents the eviction.
+// Calls blocked with the reason every time the eviction is blocked.
+// Pods that have already been deleted are treated as evicted.
+func (c *Client) evictPodWithRetry(ctx context.Context, name, namespace string, terminationPeriod *int64, blocked func(error)) error {
+	backoff := evictionRetryInitialInterval
+	for {
+		err := c.evictPod(ctx, name, namespace, terminationPeriod)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if !errors.IsTooManyRequests(err) {
+			return err
+		}
+
+		slog.Info("Eviction blocked by PodDisruptionBudget, retrying", slog.String("pod", name), slog.String("namespace", namespace), slog.String("backoff", backoff.String()))
+		blocked(err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, evictionRetryMaxInterval)
+	}
+}
+
+// Wait until all given pods have been deleted or replaced by a new pod with the same name.
+// Calls deleted for every pod that is gone.
+func (c *Client) waitForPodsDeleted(ctx context.Context, pods []v1.Pod, deleted func(*v1.Pod)) error {
+	for {
+		remaining := pods[:0]
+		for _, pod := range pods {
+			p, err := c.client.CoreV1().Pods(pod.GetNamespace()).Get(ctx, pod.GetName(), metav1.GetOptions{})
+			if errors.IsNotFound(err) || (err == nil && p.GetUID() != pod.GetUID()) {
+				deleted(&pod)
+				continue
+			} else if err != nil {
+				return err
+			}
+			remaining = append(remaining, pod)
+		}
+		pods = remaining
+		if len(pods) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(podDeletionPollInterval):
+		}
+	}
+}
+
 // Find the node in the cluster with the matching machine id
 func (c *Client) FindNodeByZincatiID(zincatiID string) (string, error) {
 	nodes, err := c.client.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
@@ -180,6 +415,47 @@ func (c *Client) FindNodeByZincatiID(zincatiID string) (string, error) {
 	return "", nil
 }
 
+// Enable deriving the group of a node from the given label.
+// Mode needs to be one of GroupLabelModeOverride or GroupLabelModeValidate.
+func (c *Client) SetGroupLabel(label, mode string) {
+	c.groupLabel = label
+	c.groupLabelMode = mode
+}
+
+// Return the configured group label and mode, label is empty when disabled
+func (c *Client) GroupLabel() (string, string) {
+	return c.groupLabel, c.groupLabelMode
+}
+
+// Return the group of the node from the configured label.
+// Returns an empty string when the node does not have the label.
+func (c *Client) GetNodeGroup(node string) (string, error) {
+	n, err := c.client.CoreV1().Nodes().Get(context.Background(), node, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return n.GetLabels()[c.groupLabel], nil
+}
+
+// Count the nodes matching the given label selector, counts all nodes when empty
+func (c *Client) CountNodes(selector string) (int, error) {
+	nodes, err := c.client.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(nodes.Items), nil
+}
+
+// Check if the api server is reachable and the nodes can be listed
+func (c *Client) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	_, err := c.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{Limit: 1})
+	return err
+}
+
 // Uncordon a node
 func (c *Client) UncordonNode(node string) error {
 	_, err := c.client.CoreV1().Nodes().Patch(context.Background(), node, types.MergePatchType, nodeUnschedulablePatch(false), metav1.PatchOptions{})
@@ -190,6 +466,12 @@ func (c *Client) UncordonNode(node string) error {
 	return NewLease(drainLeaseName(node), c.client.CoordinationV1().Leases(c.namespace)).Delete(context.Background())
 }
 
+// Return the status of the last drain of the node.
+// Returns nil if the node has not been drained.
+func (c *Client) GetDrainStatus(node string) (*DrainStatus, error) {
+	return NewLease(drainLeaseName(node), c.client.CoordinationV1().Leases(c.namespace)).Status(context.Background())
+}
+
 // Check if a node has been drained
 func (c *Client) IsDrained(node string) (bool, error) {
 	ctx := context.Background()
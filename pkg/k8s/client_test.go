@@ -2,11 +2,17 @@ package k8s
 
 import (
 	"context"
+	"encoding/json/v2"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
 	"testing"
 	"testing/synctest"
 	"time"
 
 	"github.com/heathcliff26/fleetlock/pkg/k8s/utils"
+	"github.com/heathcliff26/fleetlock/pkg/notify"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	coordv1 "k8s.io/api/coordination/v1"
@@ -77,6 +83,24 @@ func TestNewClient(t *testing.T) {
 		assert.Nil(t, c, "Should not return a client")
 		assert.Error(t, err, "Should return an error")
 	})
+	t.Run("InvalidGroupLabelMode", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.Kubeconfig = "testdata/kubeconfig"
+		cfg.GroupLabelMode = "unknown"
+
+		c, err := NewClient(cfg)
+		assert.Nil(t, c, "Should not return a client")
+		assert.Equal(t, NewErrorInvalidGroupLabelMode("unknown"), err)
+	})
+	t.Run("InvalidPodSelector", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.Kubeconfig = "testdata/kubeconfig"
+		cfg.PodSelector = "app in ("
+
+		c, err := NewClient(cfg)
+		assert.Nil(t, c, "Should not return a client")
+		assert.ErrorAs(t, err, new(*ErrorInvalidPodSelector))
+	})
 	t.Run("Success", func(t *testing.T) {
 		cfg := NewDefaultConfig()
 		cfg.Kubeconfig = "testdata/kubeconfig"
@@ -103,7 +127,7 @@ func TestDrainNode(t *testing.T) {
 
 		ctx := t.Context()
 
-		err := c.DrainNode(testNodeName)
+		err := c.DrainNode(context.Background(), testNodeName, nil)
 
 		assert := assert.New(t)
 
@@ -133,8 +157,22 @@ func TestDrainNode(t *testing.T) {
 		}
 		_, _ = client.CoordinationV1().Leases(testNamespace).Create(t.Context(), lease, metav1.CreateOptions{})
 
-		err := c.DrainNode(testNodeName)
+		started := false
+		err := c.DrainNode(context.Background(), testNodeName, func() {
+			started = true
+		})
 		assert.Equal(t, NewErrorDrainIsLocked(), err, "Should return an error signaling that a drain is already in progress")
+		assert.False(t, started, "Should not call started when another drain is running")
+	})
+	t.Run("Started", func(t *testing.T) {
+		c, _ := initTestCluster(t)
+
+		started := 0
+		err := c.DrainNode(context.Background(), testNodeName, func() {
+			started++
+		})
+		require.NoError(t, err, "Should not throw an error")
+		assert.Equal(t, 1, started, "Should call started once")
 	})
 	t.Run("LeaseInvalid", func(t *testing.T) {
 		c, client := initTestCluster(t)
@@ -150,7 +188,7 @@ func TestDrainNode(t *testing.T) {
 		}
 		_, _ = client.CoordinationV1().Leases(testNamespace).Create(t.Context(), lease, metav1.CreateOptions{})
 
-		err := c.DrainNode(testNodeName)
+		err := c.DrainNode(context.Background(), testNodeName, nil)
 		assert.Equal(t, NewErrorInvalidLease(), err, "Should return an error signaling that the lease is invalid")
 	})
 	t.Run("LeaseExpired", func(t *testing.T) {
@@ -171,7 +209,7 @@ func TestDrainNode(t *testing.T) {
 		}
 		_, _ = client.CoordinationV1().Leases(testNamespace).Create(ctx, lease, metav1.CreateOptions{})
 
-		err := c.DrainNode(testNodeName)
+		err := c.DrainNode(context.Background(), testNodeName, nil)
 
 		assert := assert.New(t)
 
@@ -195,7 +233,7 @@ func TestDrainNode(t *testing.T) {
 
 			c.drainTimeoutSeconds = 1
 
-			err := c.DrainNode(testNodeName)
+			err := c.DrainNode(context.Background(), testNodeName, nil)
 
 			assert := assert.New(t)
 
@@ -205,6 +243,368 @@ func TestDrainNode(t *testing.T) {
 			assert.Equal("1", lease.GetAnnotations()[leaseFailCounterName], "Lease fail counter should be 1")
 		})
 	})
+	t.Run("Interrupted", func(t *testing.T) {
+		c, client := initTestCluster(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		client.PrependReactor("create", "pods", func(_ clienttesting.Action) (bool, runtime.Object, error) {
+			cancel()
+			return false, nil, nil
+		})
+
+		err := c.DrainNode(ctx, testNodeName, nil)
+
+		assert := assert.New(t)
+
+		assert.Equal(NewErrorDrainInterrupted(), err, "Should return an error signaling the interruption")
+		lease, _ := client.CoordinationV1().Leases(testNamespace).Get(t.Context(), drainLeaseName(testNodeName), metav1.GetOptions{})
+		assert.Equal(leaseStateInterrupted, *lease.Spec.HolderIdentity, "Lease state should be interrupted")
+		assert.NotContains(lease.GetAnnotations(), leaseFailCounterName, "Should not count as failure")
+	})
+	t.Run("LeaseInterrupted", func(t *testing.T) {
+		c, client := initTestCluster(t)
+
+		ctx := t.Context()
+
+		lease := &coordv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: c.namespace,
+				Name:      drainLeaseName(testNodeName),
+			},
+			Spec: coordv1.LeaseSpec{
+				HolderIdentity:       utils.Pointer(leaseStateInterrupted),
+				LeaseDurationSeconds: utils.Pointer(int32(300)),
+				AcquireTime:          &metav1.MicroTime{Time: time.Now()},
+			},
+		}
+		_, _ = client.CoordinationV1().Leases(testNamespace).Create(ctx, lease, metav1.CreateOptions{})
+
+		err := c.DrainNode(context.Background(), testNodeName, nil)
+
+		assert := assert.New(t)
+
+		require.NoError(t, err, "Should continue the interrupted drain without waiting for the lease to expire")
+
+		lease, _ = client.CoordinationV1().Leases(testNamespace).Get(ctx, drainLeaseName(testNodeName), metav1.GetOptions{})
+		assert.Equal(leaseStateDone, *lease.Spec.HolderIdentity, "Lease should indicate node is drained")
+		assert.NotContains(lease.GetAnnotations(), leaseFailCounterName, "Should not count as failure")
+	})
+}
+
+func TestDrainNodeNotifications(t *testing.T) {
+	events := make(chan notify.Event, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		var event notify.Event
+		err := json.UnmarshalRead(req.Body, &event)
+		assert.NoError(t, err, "Should send parsable event")
+		events <- event
+	}))
+	t.Cleanup(srv.Close)
+
+	notifier, err := notify.NewNotifier(notify.Config{
+		Webhooks: []notify.WebhookConfig{{URL: srv.URL}},
+	})
+	require.NoError(t, err)
+
+	c, client := initTestCluster(t)
+	c.drainRetries = 2
+	c.SetNotifier(notifier)
+	client.PrependReactor("patch", "nodes", func(_ clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewInternalError(fmt.Errorf("test error"))
+	})
+
+	assert := assert.New(t)
+
+	assert.Error(c.DrainNode(context.Background(), testNodeName, nil), "First drain should fail")
+	notifier.Wait()
+	require.Len(t, events, 1, "Should only notify about the failure")
+	event := <-events
+	assert.Equal(notify.EventDrainFailed, event.Type)
+	assert.Equal(testNodeName, event.Node)
+	assert.Contains(event.Reason, "test error")
+
+	// Let the lease expire, so that the drain can be retried
+	lease, err := client.CoordinationV1().Leases(testNamespace).Get(t.Context(), drainLeaseName(testNodeName), metav1.GetOptions{})
+	require.NoError(t, err)
+	lease.Spec.AcquireTime = &metav1.MicroTime{Time: time.Now().Add(-time.Hour)}
+	_, err = client.CoordinationV1().Leases(testNamespace).Update(t.Context(), lease, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	assert.Error(c.DrainNode(context.Background(), testNodeName, nil), "Second drain should fail")
+	notifier.Wait()
+	require.Len(t, events, 2, "Should notify about the failure and the exhausted retries")
+	received := []string{(<-events).Type, (<-events).Type}
+	assert.ElementsMatch([]string{notify.EventDrainFailed, notify.EventDrainRetriesExhausted}, received)
+}
+
+func TestDrainNodeEvictionSemantics(t *testing.T) {
+	newPod := func(name string, managed, emptyDir bool, phase v1.PodPhase) *v1.Pod {
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: testNamespace,
+				Labels: map[string]string{
+					"app": name,
+				},
+			},
+			Spec: v1.PodSpec{
+				NodeName: testNodeName,
+			},
+			Status: v1.PodStatus{
+				Phase: phase,
+			},
+		}
+		if managed {
+			pod.OwnerReferences = []metav1.OwnerReference{
+				{
+					Kind:       "ReplicaSet",
+					Name:       "replicaset",
+					Controller: utils.Pointer(true),
+				},
+			}
+		}
+		if emptyDir {
+			pod.Spec.Volumes = []v1.Volume{
+				{
+					Name:         "data",
+					VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+				},
+			}
+		}
+		return pod
+	}
+
+	tMatrix := []struct {
+		Name               string
+		Pod                *v1.Pod
+		DeleteEmptyDirData bool
+		Force              bool
+		PodSelector        string
+		Error              error
+		Evicted            bool
+	}{
+		{
+			Name:    "ManagedPod",
+			Pod:     newPod("pod", true, false, v1.PodRunning),
+			Evicted: true,
+		},
+		{
+			Name:  "UnmanagedPodWithoutForce",
+			Pod:   newPod("pod", false, false, v1.PodRunning),
+			Error: NewErrorCannotEvictPod(testNamespace, "pod", "pod is not managed by a controller, set force to evict it"),
+		},
+		{
+			Name:    "UnmanagedPodWithForce",
+			Pod:     newPod("pod", false, false, v1.PodRunning),
+			Force:   true,
+			Evicted: true,
+		},
+		{
+			Name:    "FinishedUnmanagedPod",
+			Pod:     newPod("pod", false, false, v1.PodSucceeded),
+			Evicted: true,
+		},
+		{
+			Name:  "EmptyDirWithoutDeleteEmptyDirData",
+			Pod:   newPod("pod", true, true, v1.PodRunning),
+			Error: NewErrorCannotEvictPod(testNamespace, "pod", "pod uses emptyDir volumes, set deleteEmptyDirData to evict it"),
+		},
+		{
+			Name:               "EmptyDirWithDeleteEmptyDirData",
+			Pod:                newPod("pod", true, true, v1.PodRunning),
+			DeleteEmptyDirData: true,
+			Evicted:            true,
+		},
+		{
+			Name:        "PodSelectorMatches",
+			Pod:         newPod("pod", true, false, v1.PodRunning),
+			PodSelector: "app=pod",
+			Evicted:     true,
+		},
+		{
+			Name:        "PodSelectorDoesNotMatch",
+			Pod:         newPod("pod", false, true, v1.PodRunning),
+			PodSelector: "app=other",
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			c, client := initTestCluster(t)
+			_ = client.Tracker().Delete(v1.SchemeGroupVersion.WithResource("pods"), testNamespace, testPodName)
+			_, err := client.CoreV1().Pods(testNamespace).Create(t.Context(), tCase.Pod, metav1.CreateOptions{})
+			require.NoError(t, err, "Should create pod")
+
+			c.deleteEmptyDirData = tCase.DeleteEmptyDirData
+			c.force = tCase.Force
+			c.podSelector = tCase.PodSelector
+
+			err = c.drainNode(t.Context(), testNodeName, nil)
+
+			assert := assert.New(t)
+
+			assert.Equal(tCase.Error, err)
+			_, err = client.CoreV1().Pods(testNamespace).Get(t.Context(), tCase.Pod.GetName(), metav1.GetOptions{})
+			assert.Equal(tCase.Evicted, errors.IsNotFound(err), "Pod should only be removed when evicted")
+		})
+	}
+}
+
+func TestDrainNodePodDisruptionBudgetRetry(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		c, client := initTestCluster(t)
+
+		attempts := 0
+		client.PrependReactor("create", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "eviction" {
+				return false, nil, nil
+			}
+			attempts++
+			if attempts < 3 {
+				return true, nil, errors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+			}
+			return false, nil, nil
+		})
+
+		start := time.Now()
+		err := c.drainNode(t.Context(), testNodeName, nil)
+
+		assert := assert.New(t)
+
+		assert.NoError(err, "Should drain node after retrying")
+		assert.Equal(3, attempts, "Should retry blocked evictions")
+		assert.Equal(evictionRetryInitialInterval*3, time.Since(start), "Should back off exponentially")
+
+		_, err = client.CoreV1().Pods(testNamespace).Get(t.Context(), testPodName, metav1.GetOptions{})
+		assert.True(errors.IsNotFound(err), "Pod should be deleted")
+	})
+}
+
+func TestDrainNodeProgress(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		c, client := initTestCluster(t)
+
+		attempts := 0
+		client.PrependReactor("create", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "eviction" {
+				return false, nil, nil
+			}
+			attempts++
+			if attempts < 2 {
+				return true, nil, errors.NewTooManyRequests("blocked by pdb", 0)
+			}
+			return false, nil, nil
+		})
+
+		var reports []DrainProgress
+		report := func(progress DrainProgress) {
+			reports = append(reports, DrainProgress{
+				PodsRemaining:   slices.Clone(progress.PodsRemaining),
+				FailedEvictions: slices.Clone(progress.FailedEvictions),
+			})
+		}
+
+		err := c.drainNode(t.Context(), testNodeName, report)
+		require.NoError(t, err, "Should drain node")
+
+		pod := testNamespace + "/" + testPodName
+		expectedReports := []DrainProgress{
+			{
+				PodsRemaining: []string{pod},
+			},
+			{
+				PodsRemaining:   []string{pod},
+				FailedEvictions: []FailedEviction{{Pod: pod, Reason: "blocked by pdb"}},
+			},
+			{
+				PodsRemaining:   []string{pod},
+				FailedEvictions: []FailedEviction{},
+			},
+			{
+				PodsRemaining:   []string{},
+				FailedEvictions: []FailedEviction{},
+			},
+		}
+		assert.Equal(t, expectedReports, reports)
+	})
+}
+
+func TestGetDrainStatus(t *testing.T) {
+	t.Run("NoLease", func(t *testing.T) {
+		c, _ := initTestCluster(t)
+
+		status, err := c.GetDrainStatus(testNodeName)
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		assert.Nil(status, "Should not return a status when the node has not been drained")
+	})
+	t.Run("Done", func(t *testing.T) {
+		c, _ := initTestCluster(t)
+
+		require.NoError(t, c.DrainNode(context.Background(), testNodeName, nil), "Should drain node")
+
+		status, err := c.GetDrainStatus(testNodeName)
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		require.NotNil(t, status)
+		assert.Equal(leaseStateDone, status.State)
+		assert.True(status.Done(), "Drain should be done")
+		assert.Equal(0, status.Failures)
+		assert.Empty(status.Progress.PodsRemaining)
+		assert.Empty(status.Progress.FailedEvictions)
+	})
+	t.Run("Error", func(t *testing.T) {
+		c, _ := initTestCluster(t)
+		c.force = false
+
+		err := c.DrainNode(context.Background(), testNodeName, nil)
+		require.Error(t, err, "Should fail to drain unmanaged pod")
+
+		status, err := c.GetDrainStatus(testNodeName)
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		require.NotNil(t, status)
+		assert.Equal(leaseStateError, status.State)
+		assert.False(status.Done(), "Failed drain should not be done")
+		assert.Equal(1, status.Failures)
+		expectedFailures := []FailedEviction{
+			{
+				Pod:    testNamespace + "/" + testPodName,
+				Reason: "pod is not managed by a controller, set force to evict it",
+			},
+		}
+		assert.Equal(expectedFailures, status.Progress.FailedEvictions)
+	})
+}
+
+func TestDrainNodeWaitForPodDeletion(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		c, client := initTestCluster(t)
+
+		// Accept the eviction without deleting the pod, simulating a pod that takes time to terminate
+		client.PrependReactor("create", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return action.GetSubresource() == "eviction", nil, nil
+		})
+		go func() {
+			time.Sleep(10 * time.Second)
+			_ = client.CoreV1().Pods(testNamespace).Delete(context.Background(), testPodName, metav1.DeleteOptions{})
+		}()
+
+		start := time.Now()
+		err := c.drainNode(t.Context(), testNodeName, nil)
+
+		assert := assert.New(t)
+
+		assert.NoError(err, "Should drain node")
+		assert.GreaterOrEqual(time.Since(start), 10*time.Second, "Should wait for the pod to be deleted")
+	})
 }
 
 func TestFindNodeByZincatiID(t *testing.T) {
@@ -222,6 +622,67 @@ func TestFindNodeByZincatiID(t *testing.T) {
 	assert.Nil(err, "Should not return an error when no node has been found")
 }
 
+func TestCountNodes(t *testing.T) {
+	c, client := initTestCluster(t)
+
+	worker := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "worker",
+			Labels: map[string]string{
+				"node-role.kubernetes.io/worker": "",
+			},
+		},
+	}
+	_, err := client.CoreV1().Nodes().Create(t.Context(), worker, metav1.CreateOptions{})
+	require.NoError(t, err, "Should create node")
+
+	assert := assert.New(t)
+
+	count, err := c.CountNodes("")
+	assert.NoError(err)
+	assert.Equal(2, count, "Should count all nodes without selector")
+
+	count, err = c.CountNodes("node-role.kubernetes.io/worker")
+	assert.NoError(err)
+	assert.Equal(1, count, "Should only count matching nodes")
+}
+
+func TestPing(t *testing.T) {
+	c, client := initTestCluster(t)
+
+	assert := assert.New(t)
+
+	assert.NoError(c.Ping(), "Should succeed when the api is reachable")
+
+	client.PrependReactor("list", "nodes", func(_ clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewInternalError(fmt.Errorf("test error"))
+	})
+	assert.Error(c.Ping(), "Should fail when the nodes can't be listed")
+}
+
+func TestGetNodeGroup(t *testing.T) {
+	c, client := initTestCluster(t)
+	c.SetGroupLabel("fleetlock.heathcliff.eu/group", GroupLabelModeOverride)
+
+	assert := assert.New(t)
+
+	group, err := c.GetNodeGroup(testNodeName)
+	assert.NoError(err)
+	assert.Empty(group, "Should return empty group when node has no label")
+
+	node, _ := client.CoreV1().Nodes().Get(t.Context(), testNodeName, metav1.GetOptions{})
+	node.Labels = map[string]string{"fleetlock.heathcliff.eu/group": "workers"}
+	_, err = client.CoreV1().Nodes().Update(t.Context(), node, metav1.UpdateOptions{})
+	require.NoError(t, err, "Should update node")
+
+	group, err = c.GetNodeGroup(testNodeName)
+	assert.NoError(err)
+	assert.Equal("workers", group, "Should return group from label")
+
+	_, err = c.GetNodeGroup("not-a-node")
+	assert.Error(err, "Should fail for unknown node")
+}
+
 func TestUncordonNode(t *testing.T) {
 	c, client := initTestCluster(t)
 
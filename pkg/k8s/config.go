@@ -1,13 +1,66 @@
 package k8s
 
+import "time"
+
+const (
+	// Use the group from the node label instead of the one requested by the client
+	GroupLabelModeOverride = "override"
+	// Reject requests where the requested group does not match the node label
+	GroupLabelModeValidate = "validate"
+)
+
 type Config struct {
 	Kubeconfig          string `yaml:"kubeconfig,omitempty"`
 	DrainTimeoutSeconds int32  `yaml:"drainTimeoutSeconds,omitempty"`
 	DrainRetries        int    `yaml:"drainRetries,omitempty"`
+	// Evict pods using emptyDir volumes, the data in them will be lost
+	DeleteEmptyDirData bool `yaml:"deleteEmptyDirData,omitempty"`
+	// Evict pods that are not managed by a controller, they will not be recreated
+	Force bool `yaml:"force,omitempty"`
+	// Only evict pods matching this label selector
+	PodSelector string `yaml:"podSelector,omitempty"`
+	// Node label containing the group of the node, disabled when empty
+	GroupLabel     string `yaml:"groupLabel,omitempty"`
+	GroupLabelMode string `yaml:"groupLabelMode,omitempty"`
+	// Checks the cluster needs to pass before a new slot is reserved
+	HealthGates HealthGates `yaml:"healthGates,omitempty"`
+	// Wait for the node and its daemonset pods to be ready before releasing the slot
+	WaitForNodeReady bool `yaml:"waitForNodeReady,omitempty"`
+	// Only the elected replica serves locking requests
+	LeaderElection LeaderElectionConfig `yaml:"leaderElection,omitempty"`
+}
+
+type LeaderElectionConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Name of the lease used for the election
+	LeaseName string `yaml:"leaseName,omitempty"`
+	// Time after which a leader that stopped renewing the lease is replaced
+	LeaseDuration time.Duration `yaml:"leaseDuration,omitempty"`
+}
+
+type HealthGates struct {
+	// All other nodes need to be Ready
+	NodesReady bool `yaml:"nodesReady,omitempty"`
+	// No other node may be cordoned
+	NoCordonedNodes bool `yaml:"noCordonedNodes,omitempty"`
+	// No PodDisruptionBudget may have less healthy pods than desired
+	PodDisruptionBudgets bool `yaml:"podDisruptionBudgets,omitempty"`
+}
+
+// Check if any gate is enabled
+func (g HealthGates) Enabled() bool {
+	return g.NodesReady || g.NoCordonedNodes || g.PodDisruptionBudgets
 }
 
 func NewDefaultConfig() Config {
 	return Config{
 		DrainTimeoutSeconds: 300,
+		DeleteEmptyDirData:  true,
+		Force:               true,
+		GroupLabelMode:      GroupLabelModeOverride,
+		LeaderElection: LeaderElectionConfig{
+			LeaseName:     "fleetlock-leader",
+			LeaseDuration: 15 * time.Second,
+		},
 	}
 }
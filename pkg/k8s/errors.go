@@ -20,6 +20,16 @@ func (e ErrorDrainIsLocked) Error() string {
 	return "Can't drain node, as another drain is already in progress"
 }
 
+type ErrorDrainInterrupted struct{}
+
+func NewErrorDrainInterrupted() error {
+	return ErrorDrainInterrupted{}
+}
+
+func (e ErrorDrainInterrupted) Error() string {
+	return "Drain was interrupted before it finished"
+}
+
 type ErrorInvalidLease struct{}
 
 func NewErrorInvalidLease() error {
@@ -39,3 +49,90 @@ func NewErrorDrainTimeoutSecondsInvalid() error {
 func (e ErrorDrainTimeoutSecondsInvalid) Error() string {
 	return "drainTimeoutSeconds value needs to be greater than 0"
 }
+
+type ErrorLeaderLeaseDurationTooShort struct{}
+
+func NewErrorLeaderLeaseDurationTooShort() error {
+	return ErrorLeaderLeaseDurationTooShort{}
+}
+
+func (e ErrorLeaderLeaseDurationTooShort) Error() string {
+	return "leaderElection.leaseDuration needs to be at least " + minLeaderLeaseDuration.String()
+}
+
+type ErrorInvalidGroupLabelMode struct {
+	mode string
+}
+
+func NewErrorInvalidGroupLabelMode(mode string) error {
+	return &ErrorInvalidGroupLabelMode{mode: mode}
+}
+
+func (e *ErrorInvalidGroupLabelMode) Error() string {
+	return "groupLabelMode needs to be either \"" + GroupLabelModeOverride + "\" or \"" + GroupLabelModeValidate + "\", got \"" + e.mode + "\""
+}
+
+type ErrorNodeNotReady struct {
+	node string
+}
+
+func NewErrorNodeNotReady(node string) error {
+	return &ErrorNodeNotReady{node: node}
+}
+
+func (e *ErrorNodeNotReady) Error() string {
+	return "Node \"" + e.node + "\" is not ready"
+}
+
+type ErrorNodeCordoned struct {
+	node string
+}
+
+func NewErrorNodeCordoned(node string) error {
+	return &ErrorNodeCordoned{node: node}
+}
+
+func (e *ErrorNodeCordoned) Error() string {
+	return "Node \"" + e.node + "\" is cordoned"
+}
+
+type ErrorPodDisruptionBudgetViolated struct {
+	namespace, name string
+}
+
+func NewErrorPodDisruptionBudgetViolated(namespace, name string) error {
+	return &ErrorPodDisruptionBudgetViolated{namespace: namespace, name: name}
+}
+
+func (e *ErrorPodDisruptionBudgetViolated) Error() string {
+	return "PodDisruptionBudget \"" + e.namespace + "/" + e.name + "\" has less healthy pods than desired"
+}
+
+type ErrorCannotEvictPod struct {
+	namespace, name, reason string
+}
+
+func NewErrorCannotEvictPod(namespace, name, reason string) error {
+	return &ErrorCannotEvictPod{namespace: namespace, name: name, reason: reason}
+}
+
+func (e *ErrorCannotEvictPod) Error() string {
+	return "Can't evict pod \"" + e.namespace + "/" + e.name + "\": " + e.reason
+}
+
+type ErrorInvalidPodSelector struct {
+	selector string
+	err      error
+}
+
+func NewErrorInvalidPodSelector(selector string, err error) error {
+	return &ErrorInvalidPodSelector{selector: selector, err: err}
+}
+
+func (e *ErrorInvalidPodSelector) Error() string {
+	return "Invalid podSelector \"" + e.selector + "\": " + e.err.Error()
+}
+
+func (e *ErrorInvalidPodSelector) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,121 @@
+package k8s
+
+import (
+	"context"
+	"log/slog"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// Set the health gates that need to pass before a slot is reserved
+func (c *Client) SetHealthGates(gates HealthGates) {
+	c.healthGates = gates
+}
+
+// Check if any health gate is enabled
+func (c *Client) HealthGatesEnabled() bool {
+	return c.healthGates.Enabled()
+}
+
+// Run the enabled health gates, ignoring the given node.
+// Returns an error describing the first failed gate.
+func (c *Client) CheckHealthGates(node string) error {
+	ctx := context.Background()
+
+	if c.healthGates.NodesReady || c.healthGates.NoCordonedNodes {
+		nodes, err := c.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+
+		for _, n := range nodes.Items {
+			if n.GetName() == node {
+				continue
+			}
+			if c.healthGates.NodesReady && !isNodeReady(&n) {
+				return NewErrorNodeNotReady(n.GetName())
+			}
+			if c.healthGates.NoCordonedNodes && n.Spec.Unschedulable {
+				return NewErrorNodeCordoned(n.GetName())
+			}
+		}
+	}
+
+	if c.healthGates.PodDisruptionBudgets {
+		pdbs, err := c.client.PolicyV1().PodDisruptionBudgets(v1.NamespaceAll).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+
+		for _, pdb := range pdbs.Items {
+			if pdb.Status.CurrentHealthy < pdb.Status.DesiredHealthy {
+				return NewErrorPodDisruptionBudgetViolated(pdb.GetNamespace(), pdb.GetName())
+			}
+		}
+	}
+
+	return nil
+}
+
+// Enable waiting for the node to be ready before releasing the slot
+func (c *Client) SetWaitForNodeReady(wait bool) {
+	c.waitForNodeReady = wait
+}
+
+// Check if the slot should only be released after the node is ready
+func (c *Client) WaitForNodeReady() bool {
+	return c.waitForNodeReady
+}
+
+// Check if the node is Ready and all daemonset pods on it are running and ready
+func (c *Client) IsNodeReady(node string) (bool, error) {
+	ctx := context.Background()
+
+	n, err := c.client.CoreV1().Nodes().Get(ctx, node, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	if !isNodeReady(n) {
+		return false, nil
+	}
+
+	pods, err := c.client.CoreV1().Pods(v1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.SelectorFromSet(fields.Set{"spec.nodeName": node}).String(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, pod := range pods.Items {
+		controller := metav1.GetControllerOf(&pod)
+		if controller == nil || controller.Kind != "DaemonSet" {
+			continue
+		}
+		if pod.Status.Phase != v1.PodRunning || !isPodReady(&pod) {
+			slog.Debug("Daemonset pod is not ready yet", slog.String("node", node), slog.String("pod", pod.GetName()), slog.String("namespace", pod.GetNamespace()))
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func isNodeReady(node *v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func isPodReady(pod *v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
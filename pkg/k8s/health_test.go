@@ -0,0 +1,260 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/heathcliff26/fleetlock/pkg/k8s/utils"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newTestNode(name string, ready, unschedulable bool) *v1.Node {
+	status := v1.ConditionTrue
+	if !ready {
+		status = v1.ConditionFalse
+	}
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1.NodeSpec{
+			Unschedulable: unschedulable,
+		},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{
+				{
+					Type:   v1.NodeReady,
+					Status: status,
+				},
+			},
+		},
+	}
+}
+
+func newTestPDB(name string, current, desired int32) *policyv1.PodDisruptionBudget {
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+		},
+		Status: policyv1.PodDisruptionBudgetStatus{
+			CurrentHealthy: current,
+			DesiredHealthy: desired,
+		},
+	}
+}
+
+func TestCheckHealthGates(t *testing.T) {
+	allGates := HealthGates{
+		NodesReady:           true,
+		NoCordonedNodes:      true,
+		PodDisruptionBudgets: true,
+	}
+
+	tMatrix := []struct {
+		Name    string
+		Gates   HealthGates
+		Objects []runtime.Object
+		Error   error
+	}{
+		{
+			Name:  "Healthy",
+			Gates: allGates,
+			Objects: []runtime.Object{
+				newTestNode("node-1", true, false),
+				newTestNode("node-2", true, false),
+				newTestPDB("pdb", 2, 2),
+			},
+		},
+		{
+			Name:  "NodeNotReady",
+			Gates: allGates,
+			Objects: []runtime.Object{
+				newTestNode("node-1", true, false),
+				newTestNode("node-2", false, false),
+			},
+			Error: NewErrorNodeNotReady("node-2"),
+		},
+		{
+			Name:  "NodeWithoutReadyCondition",
+			Gates: allGates,
+			Objects: []runtime.Object{
+				newTestNode("node-1", true, false),
+				&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+			},
+			Error: NewErrorNodeNotReady("node-2"),
+		},
+		{
+			Name:  "NodeCordoned",
+			Gates: allGates,
+			Objects: []runtime.Object{
+				newTestNode("node-1", true, false),
+				newTestNode("node-2", true, true),
+			},
+			Error: NewErrorNodeCordoned("node-2"),
+		},
+		{
+			Name:  "IgnoresOwnNode",
+			Gates: allGates,
+			Objects: []runtime.Object{
+				newTestNode("node-1", false, true),
+				newTestNode("node-2", true, false),
+			},
+		},
+		{
+			Name:  "PodDisruptionBudgetViolated",
+			Gates: allGates,
+			Objects: []runtime.Object{
+				newTestNode("node-1", true, false),
+				newTestPDB("pdb", 1, 2),
+			},
+			Error: NewErrorPodDisruptionBudgetViolated(testNamespace, "pdb"),
+		},
+		{
+			Name:  "GatesDisabled",
+			Gates: HealthGates{},
+			Objects: []runtime.Object{
+				newTestNode("node-1", true, false),
+				newTestNode("node-2", false, true),
+				newTestPDB("pdb", 1, 2),
+			},
+		},
+		{
+			Name: "OnlyNodesReady",
+			Gates: HealthGates{
+				NodesReady: true,
+			},
+			Objects: []runtime.Object{
+				newTestNode("node-1", true, false),
+				newTestNode("node-2", true, true),
+				newTestPDB("pdb", 1, 2),
+			},
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			c, _ := NewFakeClient(tCase.Objects...)
+			c.SetHealthGates(tCase.Gates)
+
+			assert.Equal(t, tCase.Error, c.CheckHealthGates("node-1"))
+		})
+	}
+}
+
+func TestHealthGatesEnabled(t *testing.T) {
+	c, _ := NewFakeClient()
+	assert.False(t, c.HealthGatesEnabled(), "Should be disabled by default")
+
+	c.SetHealthGates(HealthGates{PodDisruptionBudgets: true})
+	assert.True(t, c.HealthGatesEnabled(), "Should be enabled when any gate is set")
+}
+
+func TestIsNodeReady(t *testing.T) {
+	newDaemonSetPod := func(phase v1.PodPhase, ready bool) *v1.Pod {
+		status := v1.ConditionTrue
+		if !ready {
+			status = v1.ConditionFalse
+		}
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "daemonset-pod",
+				Namespace: testNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						Kind:       "DaemonSet",
+						Name:       "daemonset",
+						Controller: utils.Pointer(true),
+					},
+				},
+			},
+			Spec: v1.PodSpec{
+				NodeName: "node-1",
+			},
+			Status: v1.PodStatus{
+				Phase: phase,
+				Conditions: []v1.PodCondition{
+					{
+						Type:   v1.PodReady,
+						Status: status,
+					},
+				},
+			},
+		}
+	}
+
+	tMatrix := []struct {
+		Name    string
+		Objects []runtime.Object
+		Ready   bool
+	}{
+		{
+			Name: "Ready",
+			Objects: []runtime.Object{
+				newTestNode("node-1", true, false),
+				newDaemonSetPod(v1.PodRunning, true),
+			},
+			Ready: true,
+		},
+		{
+			Name: "NodeNotReady",
+			Objects: []runtime.Object{
+				newTestNode("node-1", false, false),
+				newDaemonSetPod(v1.PodRunning, true),
+			},
+		},
+		{
+			Name: "DaemonSetPodPending",
+			Objects: []runtime.Object{
+				newTestNode("node-1", true, false),
+				newDaemonSetPod(v1.PodPending, false),
+			},
+		},
+		{
+			Name: "DaemonSetPodNotReady",
+			Objects: []runtime.Object{
+				newTestNode("node-1", true, false),
+				newDaemonSetPod(v1.PodRunning, false),
+			},
+		},
+		{
+			Name: "IgnoresOtherPods",
+			Objects: []runtime.Object{
+				newTestNode("node-1", true, false),
+				&v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "pod",
+						Namespace: testNamespace,
+					},
+					Spec: v1.PodSpec{
+						NodeName: "node-1",
+					},
+				},
+			},
+			Ready: true,
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			c, _ := NewFakeClient(tCase.Objects...)
+
+			ready, err := c.IsNodeReady("node-1")
+
+			assert := assert.New(t)
+
+			assert.NoError(err)
+			assert.Equal(tCase.Ready, ready)
+		})
+	}
+
+	t.Run("UnknownNode", func(t *testing.T) {
+		c, _ := NewFakeClient()
+
+		_, err := c.IsNodeReady("node-1")
+		assert.Error(t, err, "Should fail for unknown node")
+	})
+}
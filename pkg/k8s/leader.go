@@ -0,0 +1,205 @@
+package k8s
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	client "k8s.io/client-go/kubernetes/typed/coordination/v1"
+)
+
+// Shorter durations would need the lease to be renewed more often than the api server can reliably answer
+const minLeaderLeaseDuration = 3 * time.Second
+
+// Time to give up the lease on shutdown
+const leaderReleaseTimeout = 5 * time.Second
+
+// Elects a single leader between multiple replicas by holding a lease.
+// A leader that can't renew the lease steps down before the lease expires,
+// so there is never more than one replica considering itself the leader.
+type LeaderElector struct {
+	client        client.LeaseInterface
+	name          string
+	identity      string
+	leaseDuration time.Duration
+
+	// Last time the lease was successfully renewed by this replica, in unix nanoseconds. Zero when not leading.
+	renewed atomic.Int64
+	// Holder of the lease when last observed
+	leader atomic.Pointer[string]
+}
+
+// Create a new leader elector using a lease in the namespace of the client.
+// The identity of this replica is its hostname, which is the pod name inside kubernetes.
+func (c *Client) NewLeaderElector(cfg LeaderElectionConfig) (*LeaderElector, error) {
+	if cfg.LeaseDuration < minLeaderLeaseDuration {
+		return nil, NewErrorLeaderLeaseDurationTooShort()
+	}
+
+	identity, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	return newLeaderElector(c.client.CoordinationV1().Leases(c.namespace), cfg.LeaseName, identity, cfg.LeaseDuration), nil
+}
+
+func newLeaderElector(client client.LeaseInterface, name, identity string, leaseDuration time.Duration) *LeaderElector {
+	e := &LeaderElector{
+		client:        client,
+		name:          name,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+	}
+	e.leader.Store(new(string))
+	return e
+}
+
+// Try to acquire and renew the lease until ctx is cancelled.
+// Releases the lease before returning, so another replica can take over immediately.
+func (e *LeaderElector) Run(ctx context.Context) {
+	slog.Info("Starting leader election", slog.String("lease", e.name), slog.String("identity", e.identity))
+	for {
+		err := e.tryAcquireOrRenew(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("Failed to acquire or renew leader lease", slog.String("lease", e.name), "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-time.After(e.leaseDuration / 5):
+		}
+	}
+}
+
+// Returns true if this replica holds the lease and renewed it recently enough
+func (e *LeaderElector) IsLeader() bool {
+	renewed := e.renewed.Load()
+	if renewed == 0 {
+		return false
+	}
+	// Step down with enough margin before the lease expires for the other replicas
+	return time.Since(time.Unix(0, renewed)) < e.leaseDuration*2/3
+}
+
+// Returns the identity of the current leader as last observed, empty if unknown
+func (e *LeaderElector) Leader() string {
+	return *e.leader.Load()
+}
+
+func (e *LeaderElector) tryAcquireOrRenew(ctx context.Context) error {
+	now := metav1.NowMicro()
+
+	lease, err := e.client.Get(ctx, e.name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		lease = &coordv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: e.name,
+			},
+			Spec: coordv1.LeaseSpec{
+				HolderIdentity:       &e.identity,
+				LeaseDurationSeconds: e.leaseDurationSeconds(),
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		_, err = e.client.Create(ctx, lease, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		e.setLeader(now.Time)
+		return nil
+	} else if err != nil {
+		// Keep leading until the renew deadline passes, the api server might only be briefly unavailable
+		return err
+	}
+
+	holder := ""
+	if lease.Spec.HolderIdentity != nil {
+		holder = *lease.Spec.HolderIdentity
+	}
+	if holder != "" && holder != e.identity && !leaderLeaseExpired(lease) {
+		e.setFollower()
+		e.leader.Store(&holder)
+		return nil
+	}
+
+	if holder != e.identity {
+		lease.Spec.HolderIdentity = &e.identity
+		lease.Spec.AcquireTime = &now
+		transitions := int32(1)
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions + 1
+		}
+		lease.Spec.LeaseTransitions = &transitions
+	}
+	lease.Spec.LeaseDurationSeconds = e.leaseDurationSeconds()
+	lease.Spec.RenewTime = &now
+
+	// The update fails on conflict, when another replica modified the lease since it was read
+	_, err = e.client.Update(ctx, lease, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	e.setLeader(now.Time)
+	return nil
+}
+
+// Give up the lease if it is held by this replica
+func (e *LeaderElector) release() {
+	if e.renewed.Swap(0) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), leaderReleaseTimeout)
+	defer cancel()
+
+	lease, err := e.client.Get(ctx, e.name, metav1.GetOptions{})
+	if err != nil {
+		slog.Warn("Failed to release leader lease", slog.String("lease", e.name), "error", err)
+		return
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != e.identity {
+		return
+	}
+	lease.Spec.HolderIdentity = nil
+	_, err = e.client.Update(ctx, lease, metav1.UpdateOptions{})
+	if err != nil {
+		slog.Warn("Failed to release leader lease", slog.String("lease", e.name), "error", err)
+		return
+	}
+	slog.Info("Released leader lease", slog.String("lease", e.name))
+}
+
+func (e *LeaderElector) setLeader(renewed time.Time) {
+	if e.renewed.Swap(renewed.UnixNano()) == 0 {
+		slog.Info("Became leader", slog.String("lease", e.name), slog.String("identity", e.identity))
+	}
+	e.leader.Store(&e.identity)
+}
+
+func (e *LeaderElector) setFollower() {
+	if e.renewed.Swap(0) != 0 {
+		slog.Warn("Lost leadership", slog.String("lease", e.name), slog.String("identity", e.identity))
+	}
+}
+
+func (e *LeaderElector) leaseDurationSeconds() *int32 {
+	seconds := int32(e.leaseDuration.Seconds())
+	return &seconds
+}
+
+// Check if the holder of the lease failed to renew it in time
+func leaderLeaseExpired(lease *coordv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return time.Since(lease.Spec.RenewTime.Time) > time.Duration(*lease.Spec.LeaseDurationSeconds)*time.Second
+}
@@ -0,0 +1,110 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testLeaderLease = "fleetlock-leader"
+
+func newTestElectors(t *testing.T) (*LeaderElector, *LeaderElector, *Client) {
+	t.Helper()
+
+	c, _ := NewFakeClient()
+	leases := c.client.CoordinationV1().Leases(c.namespace)
+	return newLeaderElector(leases, testLeaderLease, "replica-a", 15*time.Second), newLeaderElector(leases, testLeaderLease, "replica-b", 15*time.Second), c
+}
+
+func TestNewLeaderElector(t *testing.T) {
+	c, _ := NewFakeClient()
+
+	_, err := c.NewLeaderElector(LeaderElectionConfig{Enabled: true, LeaseName: testLeaderLease, LeaseDuration: time.Second})
+	assert.Equal(t, NewErrorLeaderLeaseDurationTooShort(), err, "Should reject a too short lease duration")
+
+	e, err := c.NewLeaderElector(NewDefaultConfig().LeaderElection)
+	require.NoError(t, err, "Should create elector with default config")
+	assert.NotEmpty(t, e.identity, "Should use the hostname as identity")
+	assert.False(t, e.IsLeader(), "Should not be leader before running")
+}
+
+func TestLeaderElection(t *testing.T) {
+	t.Run("AcquireAndFollow", func(t *testing.T) {
+		assert := assert.New(t)
+		a, b, c := newTestElectors(t)
+		ctx := context.Background()
+
+		assert.NoError(a.tryAcquireOrRenew(ctx), "First replica should create the lease")
+		assert.NoError(b.tryAcquireOrRenew(ctx), "Second replica should observe the lease")
+
+		assert.True(a.IsLeader(), "First replica should be leader")
+		assert.False(b.IsLeader(), "Second replica should follow")
+		assert.Equal("replica-a", b.Leader(), "Second replica should know the leader")
+
+		assert.NoError(a.tryAcquireOrRenew(ctx), "Leader should renew the lease")
+		assert.True(a.IsLeader(), "Leader should stay leader after renewing")
+
+		lease, err := c.client.CoordinationV1().Leases(c.namespace).Get(ctx, testLeaderLease, metav1.GetOptions{})
+		require.NoError(t, err, "Should get the lease")
+		assert.Equal("replica-a", *lease.Spec.HolderIdentity)
+		assert.Equal(int32(15), *lease.Spec.LeaseDurationSeconds)
+	})
+	t.Run("TakeOverExpiredLease", func(t *testing.T) {
+		assert := assert.New(t)
+		_, b, c := newTestElectors(t)
+		ctx := context.Background()
+
+		holder := "replica-a"
+		renewed := metav1.NewMicroTime(time.Now().Add(-time.Minute))
+		duration := int32(15)
+		_, err := c.client.CoordinationV1().Leases(c.namespace).Create(ctx, &coordv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: testLeaderLease},
+			Spec: coordv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &renewed,
+				RenewTime:            &renewed,
+			},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err, "Should create expired lease")
+
+		assert.NoError(b.tryAcquireOrRenew(ctx), "Should take over the expired lease")
+		assert.True(b.IsLeader(), "Should be leader after taking over")
+
+		lease, err := c.client.CoordinationV1().Leases(c.namespace).Get(ctx, testLeaderLease, metav1.GetOptions{})
+		require.NoError(t, err, "Should get the lease")
+		assert.Equal("replica-b", *lease.Spec.HolderIdentity)
+		assert.Equal(int32(1), *lease.Spec.LeaseTransitions, "Should count the transition")
+	})
+	t.Run("StepDownWhenRenewDeadlinePassed", func(t *testing.T) {
+		a, _, _ := newTestElectors(t)
+
+		assert.NoError(t, a.tryAcquireOrRenew(context.Background()), "Should acquire the lease")
+		a.renewed.Store(time.Now().Add(-11 * time.Second).UnixNano())
+		assert.False(t, a.IsLeader(), "Should step down before the lease expires")
+	})
+	t.Run("ReleaseOnShutdown", func(t *testing.T) {
+		assert := assert.New(t)
+		a, b, _ := newTestElectors(t)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan struct{})
+		go func() {
+			a.Run(ctx)
+			close(done)
+		}()
+		assert.Eventually(a.IsLeader, 5*time.Second, 10*time.Millisecond, "Should become leader")
+
+		cancel()
+		<-done
+		assert.False(a.IsLeader(), "Should not be leader after stopping")
+
+		assert.NoError(b.tryAcquireOrRenew(context.Background()), "Second replica should acquire the released lease")
+		assert.True(b.IsLeader(), "Second replica should take over immediately")
+	})
+}
@@ -2,6 +2,8 @@ package k8s
 
 import (
 	"context"
+	"encoding/json/v2"
+	"slices"
 	"strconv"
 	"time"
 
@@ -13,12 +15,64 @@ import (
 )
 
 const (
-	leaseStateDone     = "done"
-	leaseStateDraining = "draining"
-	leaseStateError    = "error"
+	leaseStateDone        = "done"
+	leaseStateDraining    = "draining"
+	leaseStateError       = "error"
+	leaseStateInterrupted = "interrupted"
 )
 
-const leaseFailCounterName = "fleetlock.heathcliff.eu/DrainFailCount"
+const (
+	leaseFailCounterName   = "fleetlock.heathcliff.eu/DrainFailCount"
+	leaseDrainProgressName = "fleetlock.heathcliff.eu/DrainProgress"
+)
+
+// Progress of a node drain, stored as json in an annotation of the lease
+type DrainProgress struct {
+	// Pods that still need to be evicted or are waiting for deletion, in the format namespace/name
+	PodsRemaining []string `json:"podsRemaining,omitempty"`
+	// Pods that could not be evicted and why
+	FailedEvictions []FailedEviction `json:"failedEvictions,omitempty"`
+}
+
+type FailedEviction struct {
+	// The pod in the format namespace/name
+	Pod    string `json:"pod"`
+	Reason string `json:"reason"`
+}
+
+// Record a failed eviction for the pod, replacing previous failures of the same pod
+func (p *DrainProgress) setFailedEviction(pod, reason string) {
+	for i := range p.FailedEvictions {
+		if p.FailedEvictions[i].Pod == pod {
+			p.FailedEvictions[i].Reason = reason
+			return
+		}
+	}
+	p.FailedEvictions = append(p.FailedEvictions, FailedEviction{Pod: pod, Reason: reason})
+}
+
+// Remove failed evictions of the pod, returns true if there where any
+func (p *DrainProgress) removeFailedEviction(pod string) bool {
+	n := len(p.FailedEvictions)
+	p.FailedEvictions = slices.DeleteFunc(p.FailedEvictions, func(f FailedEviction) bool {
+		return f.Pod == pod
+	})
+	return len(p.FailedEvictions) != n
+}
+
+// Current state of a node drain
+type DrainStatus struct {
+	// One of draining, done, error or interrupted
+	State    string
+	Started  time.Time
+	Failures int
+	Progress DrainProgress
+}
+
+// Returns true when the drain has finished
+func (s *DrainStatus) Done() bool {
+	return s.State == leaseStateDone
+}
 
 type lease struct {
 	name   string
@@ -136,7 +190,8 @@ func (l *lease) Lock(ctx context.Context, duration int32) error {
 
 	validUntil := l.lease.Spec.AcquireTime.Add(time.Duration(*l.lease.Spec.LeaseDurationSeconds) * time.Second)
 
-	if time.Now().After(validUntil) {
+	// An interrupted drain can be continued right away, it does not count as failed attempt
+	if time.Now().After(validUntil) || *l.lease.Spec.HolderIdentity == leaseStateInterrupted {
 		if *l.lease.Spec.HolderIdentity == leaseStateDraining {
 			err = l.increaseFailCounter(ctx)
 			if err != nil {
@@ -146,6 +201,7 @@ func (l *lease) Lock(ctx context.Context, duration int32) error {
 
 		*l.lease.Spec.HolderIdentity = leaseStateDraining
 		l.lease.Spec.AcquireTime = &metav1.MicroTime{Time: time.Now()}
+		delete(l.lease.Annotations, leaseDrainProgressName)
 
 		err = l.update(ctx)
 		if err != nil {
@@ -215,3 +271,75 @@ func (l *lease) Error(ctx context.Context) error {
 	}
 	return nil
 }
+
+// Set the lease to interrupted, so the drain can be started again without waiting for the lease to expire
+func (l *lease) Interrupt(ctx context.Context) error {
+	if l.lease == nil {
+		err := l.get(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	*l.lease.Spec.HolderIdentity = leaseStateInterrupted
+	return l.update(ctx)
+}
+
+// Store the progress of the drain in the lease
+func (l *lease) SetProgress(ctx context.Context, progress DrainProgress) error {
+	if l.lease == nil {
+		err := l.get(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+
+	if l.lease.Annotations == nil {
+		l.lease.Annotations = make(map[string]string)
+	}
+	l.lease.Annotations[leaseDrainProgressName] = string(data)
+
+	return l.update(ctx)
+}
+
+// Return the current status of the drain.
+// Returns nil if the lease does not exist.
+func (l *lease) Status(ctx context.Context) (*DrainStatus, error) {
+	if l.lease == nil {
+		err := l.get(ctx)
+		if errors.IsNotFound(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if l.lease.Spec.AcquireTime == nil || l.lease.Spec.HolderIdentity == nil {
+		return nil, NewErrorInvalidLease()
+	}
+
+	failures, err := l.getFailCounter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &DrainStatus{
+		State:    *l.lease.Spec.HolderIdentity,
+		Started:  l.lease.Spec.AcquireTime.Time,
+		Failures: failures,
+	}
+
+	if data, ok := l.lease.GetAnnotations()[leaseDrainProgressName]; ok {
+		err = json.Unmarshal([]byte(data), &status.Progress)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return status, nil
+}
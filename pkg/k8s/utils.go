@@ -2,6 +2,8 @@ package k8s
 
 import (
 	"fmt"
+
+	v1 "k8s.io/api/core/v1"
 )
 
 func drainLeaseName(id string) string {
@@ -11,3 +13,17 @@ func drainLeaseName(id string) string {
 func nodeUnschedulablePatch(desired bool) []byte {
 	return []byte(fmt.Sprintf("{\"spec\":{\"unschedulable\":%t}}", desired))
 }
+
+func hasEmptyDir(pod *v1.Pod) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.EmptyDir != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the name of the pod in the format namespace/name
+func podName(pod *v1.Pod) string {
+	return pod.GetNamespace() + "/" + pod.GetName()
+}
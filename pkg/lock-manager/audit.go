@@ -0,0 +1,88 @@
+package lockmanager
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/audit"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+)
+
+// Start writing audit events to the configured sink.
+// Does nothing when no sink is configured.
+func (lm *LockManager) EnableAudit(cfg AuditConfig) error {
+	err := cfg.Validate()
+	if err != nil {
+		return err
+	}
+
+	var sink audit.Sink
+	switch cfg.Sink {
+	case "":
+		return nil
+	case AuditSinkStorage:
+		sink, err = lm.storageSink()
+	case AuditSinkFile:
+		sink, err = audit.NewFileSink(cfg.File)
+	case AuditSinkBoth:
+		var storage, file audit.Sink
+		storage, err = lm.storageSink()
+		if err != nil {
+			return err
+		}
+		file, err = audit.NewFileSink(cfg.File)
+		sink = audit.NewMultiSink(storage, file)
+	}
+	if err != nil {
+		return err
+	}
+
+	lm.SetAuditSink(sink)
+	return nil
+}
+
+// Use a custom sink for the audit events
+func (lm *LockManager) SetAuditSink(sink audit.Sink) {
+	lm.auditSink.Store(&sink)
+}
+
+// Check if audit events are recorded
+func (lm *LockManager) AuditEnabled() bool {
+	return lm.auditSink.Load() != nil
+}
+
+// Write the event to the audit log, sets the time if missing.
+// Failures are only logged, as they should not prevent the operation itself.
+func (lm *LockManager) RecordEvent(event types.Event) {
+	sink := lm.auditSink.Load()
+	if sink == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	err := (*sink).Record(event)
+	if err != nil {
+		slog.Error("Failed to record audit event", "error", err, slog.String("type", event.Type), slog.String("group", event.Group), slog.String("id", event.ID))
+	}
+}
+
+// Return the events from the audit log matching the filter, sorted from oldest to newest
+func (lm *LockManager) QueryEvents(filter types.EventFilter) ([]types.Event, error) {
+	sink := lm.auditSink.Load()
+	if sink == nil {
+		return nil, errors.NewErrorAuditDisabled()
+	}
+	return (*sink).Query(filter)
+}
+
+func (lm *LockManager) storageSink() (audit.Sink, error) {
+	es, ok := lm.backend().(audit.EventStorage)
+	if !ok {
+		return nil, errors.NewErrorAuditNotSupported()
+	}
+	return audit.NewStorageSink(es), nil
+}
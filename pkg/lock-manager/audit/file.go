@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json/v2"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+)
+
+// Maximum length of a single line in the audit file
+const maxLineLength = 64 * 1024
+
+// Writes events as JSON lines to a file
+type FileSink struct {
+	path string
+	file *os.File
+	lock sync.Mutex
+}
+
+// Create a new sink appending to the given file, creates the file if necessary
+func NewFileSink(path string) (*FileSink, error) {
+	// #nosec G304 -- Local users can decide on their file path themselves.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{
+		path: path,
+		file: f,
+	}, nil
+}
+
+// Append the event to the file
+func (s *FileSink) Record(event types.Event) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, err = s.file.Write(b)
+	return err
+}
+
+// Read the file and return the matching events.
+// Lines that can't be parsed are skipped, e.g. when the last write was interrupted.
+func (s *FileSink) Query(filter types.EventFilter) ([]types.Event, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// #nosec G304 -- Local users can decide on their file path themselves.
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := make([]types.Event, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineLength)
+	for scanner.Scan() {
+		var event types.Event
+		err = json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			slog.Warn("Skipping invalid line in audit file", slog.String("file", s.path), "error", err)
+			continue
+		}
+		if filter.Matches(event) {
+			result = append(result, event)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return filter.Truncate(result), nil
+}
+
+// Close the file
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSink(t *testing.T) {
+	t.Run("RecordAndQuery", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "audit.log")
+		sink, err := NewFileSink(path)
+		require.NoError(err, "Should create sink")
+		t.Cleanup(func() {
+			_ = sink.Close()
+		})
+
+		start := time.Now().UTC()
+		events := []types.Event{
+			{Time: start, Type: types.EventReserve, Group: "default", ID: "User1"},
+			{Time: start.Add(time.Second), Type: types.EventReserve, Group: "other", ID: "User2"},
+			{Time: start.Add(2 * time.Second), Type: types.EventRelease, Group: "default", ID: "User1"},
+		}
+		for _, event := range events {
+			require.NoError(sink.Record(event), "Should record event")
+		}
+
+		res, err := sink.Query(types.EventFilter{})
+		assert.NoError(err)
+		assert.Equal(events, res, "Should return all events")
+
+		res, err = sink.Query(types.EventFilter{Group: "default", Limit: 1})
+		assert.NoError(err)
+		assert.Equal(events[2:], res, "Should return only the newest matching event")
+
+		info, err := os.Stat(path)
+		require.NoError(err)
+		assert.Equal(os.FileMode(0600), info.Mode().Perm(), "Should only be readable by the owner")
+	})
+	t.Run("Append", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "audit.log")
+		event := types.Event{Time: time.Now().UTC(), Type: types.EventReserve, Group: "default", ID: "User1"}
+
+		for range 2 {
+			sink, err := NewFileSink(path)
+			require.NoError(err, "Should create sink")
+			require.NoError(sink.Record(event), "Should record event")
+			require.NoError(sink.Close(), "Should close sink")
+		}
+
+		sink, err := NewFileSink(path)
+		require.NoError(err, "Should create sink")
+		t.Cleanup(func() {
+			_ = sink.Close()
+		})
+
+		res, err := sink.Query(types.EventFilter{})
+		assert.NoError(err)
+		assert.Len(res, 2, "Should keep existing events")
+	})
+	t.Run("SkipInvalidLines", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "audit.log")
+		content := `{"time":"2024-01-01T00:00:00Z","type":"reserve","group":"default","id":"User1"}
+not json
+{"time":"2024-01-01T00:01:00Z","type":"rele`
+		require.NoError(os.WriteFile(path, []byte(content), 0600))
+
+		sink, err := NewFileSink(path)
+		require.NoError(err, "Should create sink")
+		t.Cleanup(func() {
+			_ = sink.Close()
+		})
+
+		res, err := sink.Query(types.EventFilter{})
+		assert.NoError(err)
+		require.Len(res, 1, "Should skip invalid lines")
+		assert.Equal("User1", res[0].ID)
+	})
+	t.Run("InvalidPath", func(t *testing.T) {
+		_, err := NewFileSink(filepath.Join(t.TempDir(), "missing", "audit.log"))
+		assert.Error(t, err, "Should fail when the directory does not exist")
+	})
+}
@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"errors"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+)
+
+// Destination of audit events
+type Sink interface {
+	// Persist the event
+	Record(event types.Event) error
+	// Return the events matching the filter, sorted from oldest to newest
+	Query(filter types.EventFilter) ([]types.Event, error)
+	// Calls all necessary finalization if necessary
+	Close() error
+}
+
+// Implemented by storage backends that can persist audit events
+type EventStorage interface {
+	// Persist the event
+	RecordEvent(event types.Event) error
+	// Return the events matching the filter, sorted from oldest to newest
+	QueryEvents(filter types.EventFilter) ([]types.Event, error)
+}
+
+type storageSink struct {
+	storage EventStorage
+}
+
+// Create a sink writing the events to the storage backend.
+// Closing the sink does not close the storage.
+func NewStorageSink(storage EventStorage) Sink {
+	return &storageSink{storage: storage}
+}
+
+func (s *storageSink) Record(event types.Event) error {
+	return s.storage.RecordEvent(event)
+}
+
+func (s *storageSink) Query(filter types.EventFilter) ([]types.Event, error) {
+	return s.storage.QueryEvents(filter)
+}
+
+func (s *storageSink) Close() error {
+	return nil
+}
+
+type multiSink struct {
+	sinks []Sink
+}
+
+// Create a sink writing the events to all given sinks.
+// Queries are answered by the first sink.
+func NewMultiSink(sinks ...Sink) Sink {
+	return &multiSink{sinks: sinks}
+}
+
+func (m *multiSink) Record(event types.Event) error {
+	var errs []error
+	for _, s := range m.sinks {
+		errs = append(errs, s.Record(event))
+	}
+	return errors.Join(errs...)
+}
+
+func (m *multiSink) Query(filter types.EventFilter) ([]types.Event, error) {
+	return m.sinks[0].Query(filter)
+}
+
+func (m *multiSink) Close() error {
+	var errs []error
+	for _, s := range m.sinks {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
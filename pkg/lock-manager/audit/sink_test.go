@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/memory"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageSink(t *testing.T) {
+	assert := assert.New(t)
+
+	storage := memory.NewMemoryBackend([]string{"default"})
+	sink := NewStorageSink(storage)
+
+	event := types.Event{Time: time.Now(), Type: types.EventReserve, Group: "default", ID: "User1"}
+	assert.NoError(sink.Record(event), "Should record event")
+
+	res, err := storage.QueryEvents(types.EventFilter{})
+	assert.NoError(err)
+	assert.Equal([]types.Event{event}, res, "Should write the event to the storage")
+
+	res, err = sink.Query(types.EventFilter{})
+	assert.NoError(err)
+	assert.Equal([]types.Event{event}, res, "Should read the events from the storage")
+
+	assert.NoError(sink.Close())
+}
+
+func TestMultiSink(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	storage := memory.NewMemoryBackend([]string{"default"})
+	file, err := NewFileSink(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(err, "Should create file sink")
+
+	sink := NewMultiSink(NewStorageSink(storage), file)
+
+	event := types.Event{Time: time.Now().UTC(), Type: types.EventReserve, Group: "default", ID: "User1"}
+	assert.NoError(sink.Record(event), "Should record event")
+
+	res, err := storage.QueryEvents(types.EventFilter{})
+	assert.NoError(err)
+	assert.Equal([]types.Event{event}, res, "Should write the event to the storage")
+
+	res, err = file.Query(types.EventFilter{})
+	assert.NoError(err)
+	assert.Equal([]types.Event{event}, res, "Should write the event to the file")
+
+	res, err = sink.Query(types.EventFilter{})
+	assert.NoError(err)
+	assert.Equal([]types.Event{event}, res, "Should answer queries from the first sink")
+
+	assert.NoError(sink.Close(), "Should close all sinks")
+	assert.Error(file.Record(event), "File should be closed")
+}
@@ -0,0 +1,145 @@
+package lockmanager
+
+import (
+	"path/filepath"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/kubernetes"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/memory"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnableAudit(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		lm := NewManagerWithStorage(NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
+
+		assert := assert.New(t)
+
+		assert.NoError(lm.EnableAudit(AuditConfig{}))
+		assert.False(lm.AuditEnabled(), "Should not enable audit without sink")
+
+		lm.RecordEvent(types.Event{Type: types.EventReserve})
+		_, err := lm.QueryEvents(types.EventFilter{})
+		assert.Equal(errors.NewErrorAuditDisabled(), err)
+	})
+	t.Run("Storage", func(t *testing.T) {
+		storage := memory.NewMemoryBackend([]string{"default"})
+		lm := NewManagerWithStorage(NewDefaultGroups(), storage)
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		require.NoError(lm.EnableAudit(AuditConfig{Sink: AuditSinkStorage}))
+		assert.True(lm.AuditEnabled())
+
+		lm.RecordEvent(types.Event{Type: types.EventReserve, Group: "default", ID: "User1"})
+
+		events, err := storage.QueryEvents(types.EventFilter{})
+		assert.NoError(err)
+		require.Len(events, 1, "Should write event to storage")
+		assert.WithinDuration(time.Now(), events[0].Time, time.Minute, "Should set the time of the event")
+
+		events, err = lm.QueryEvents(types.EventFilter{})
+		assert.NoError(err)
+		assert.Len(events, 1, "Should query events from storage")
+	})
+	t.Run("File", func(t *testing.T) {
+		storage := memory.NewMemoryBackend([]string{"default"})
+		lm := NewManagerWithStorage(NewDefaultGroups(), storage)
+		t.Cleanup(func() {
+			_ = lm.Close()
+		})
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		require.NoError(lm.EnableAudit(AuditConfig{Sink: AuditSinkFile, File: filepath.Join(t.TempDir(), "audit.log")}))
+
+		lm.RecordEvent(types.Event{Type: types.EventReserve, Group: "default", ID: "User1"})
+
+		events, err := storage.QueryEvents(types.EventFilter{})
+		assert.NoError(err)
+		assert.Empty(events, "Should not write event to storage")
+
+		events, err = lm.QueryEvents(types.EventFilter{})
+		assert.NoError(err)
+		assert.Len(events, 1, "Should query events from file")
+	})
+	t.Run("Both", func(t *testing.T) {
+		storage := memory.NewMemoryBackend([]string{"default"})
+		lm := NewManagerWithStorage(NewDefaultGroups(), storage)
+		t.Cleanup(func() {
+			_ = lm.Close()
+		})
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		require.NoError(lm.EnableAudit(AuditConfig{Sink: AuditSinkBoth, File: filepath.Join(t.TempDir(), "audit.log")}))
+
+		lm.RecordEvent(types.Event{Type: types.EventReserve, Group: "default", ID: "User1"})
+
+		events, err := storage.QueryEvents(types.EventFilter{})
+		assert.NoError(err)
+		assert.Len(events, 1, "Should write event to storage")
+
+		events, err = lm.QueryEvents(types.EventFilter{})
+		assert.NoError(err)
+		assert.Len(events, 1, "Should query events")
+	})
+	t.Run("StorageNotSupported", func(t *testing.T) {
+		storage, _ := kubernetes.NewKubernetesBackendWithFakeClient("fleetlock")
+		lm := NewManagerWithStorage(NewDefaultGroups(), storage)
+
+		err := lm.EnableAudit(AuditConfig{Sink: AuditSinkStorage})
+		assert.Equal(t, errors.NewErrorAuditNotSupported(), err)
+		assert.False(t, lm.AuditEnabled())
+	})
+	t.Run("InvalidConfig", func(t *testing.T) {
+		lm := NewManagerWithStorage(NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
+
+		err := lm.EnableAudit(AuditConfig{Sink: AuditSinkFile})
+		assert.Equal(t, errors.NewErrorAuditFileRequired(), err)
+		assert.False(t, lm.AuditEnabled())
+	})
+}
+
+func TestReleaseStaleLocksRecordsEvent(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		groups := Groups{
+			"default": GroupConfig{
+				Slots:      1,
+				MaxLockAge: 10 * time.Minute,
+			},
+		}
+		storage := memory.NewMemoryBackend([]string{"default"})
+		lm := NewManagerWithStorage(groups, storage)
+		t.Cleanup(func() {
+			_ = lm.Close()
+		})
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		require.NoError(lm.EnableAudit(AuditConfig{Sink: AuditSinkStorage}))
+
+		ok, err := lm.Reserve("default", "old")
+		require.True(ok)
+		require.NoError(err)
+
+		synctest.Sleep(11 * time.Minute)
+
+		events, err := lm.QueryEvents(types.EventFilter{})
+		assert.NoError(err)
+		require.Len(events, 1, "Should record the release of the stale lock")
+		assert.Equal(types.EventForceRelease, events[0].Type)
+		assert.Equal("default", events[0].Group)
+		assert.Equal("old", events[0].ID)
+		assert.Empty(events[0].Remote, "Should not set a remote for events caused by the server")
+	})
+}
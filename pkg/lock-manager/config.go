@@ -1,14 +1,30 @@
 package lockmanager
 
 import (
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/consul"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/etcd"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/file"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/kubernetes"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/mongodb"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/raft"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/sql"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/valkey"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
+// Group names are used in the keys of the storage backends, which rely on them not containing separators like "/", ":", "," or "_"
+const GroupNamePattern = "^[a-zA-Z0-9.-]+$"
+
+var groupNameRegex = regexp.MustCompile(GroupNamePattern)
+
 type StorageConfig struct {
 	Type       string                      `yaml:"type"`
 	SQLite     sql.SQLiteConfig            `yaml:"sqlite,omitempty"`
@@ -18,12 +34,43 @@ type StorageConfig struct {
 	Etcd       etcd.EtcdConfig             `yaml:"etcd,omitempty"`
 	Kubernetes kubernetes.KubernetesConfig `yaml:"kubernetes,omitempty"`
 	MongoDB    mongodb.MongoDBConfig       `yaml:"mongodb,omitempty"`
+	Raft       raft.RaftConfig             `yaml:"raft,omitempty"`
+	File       file.FileConfig             `yaml:"file,omitempty"`
+	Consul     consul.ConsulConfig         `yaml:"consul,omitempty"`
+}
+
+// Possible destinations of the audit log
+const (
+	AuditSinkStorage = "storage"
+	AuditSinkFile    = "file"
+	AuditSinkBoth    = "both"
+)
+
+type AuditConfig struct {
+	// Where to write audit events, one of storage, file or both. Disabled when empty.
+	Sink string `yaml:"sink,omitempty"`
+	// Path of the JSON lines file used by the file sink
+	File string `yaml:"file,omitempty"`
 }
 
 type Groups map[string]GroupConfig
 
 type GroupConfig struct {
-	Slots int `yaml:"slots"`
+	// Fixed number of slots. Used as fallback when maxUnavailable is set, until the nodes have been counted.
+	Slots int `yaml:"slots,omitempty"`
+	// Percentage of matching kubernetes nodes that can hold a slot at the same time, e.g. "20%".
+	// Takes precedence over slots, the result is rounded down with a minimum of 1.
+	MaxUnavailable string `yaml:"maxUnavailable,omitempty"`
+	// Label selector for the nodes counted for maxUnavailable, counts all nodes when empty
+	NodeSelector string `yaml:"nodeSelector,omitempty"`
+	// Locks held longer than this are released automatically. Disabled when 0.
+	MaxLockAge time.Duration `yaml:"maxLockAge,omitempty"`
+	// Locks expire unless they are renewed within this duration. Disabled when 0.
+	LeaseDuration time.Duration `yaml:"leaseDuration,omitempty"`
+	// New slots can only be reserved inside the maintenance windows. Always allowed when nil.
+	Schedule *Schedule `yaml:"schedule,omitempty"`
+	// Groups that can't hold locks at the same time as this one. Applies in both directions.
+	ExclusiveWith []string `yaml:"exclusiveWith,omitempty"`
 }
 
 // Create a new storage config with default values
@@ -41,11 +88,87 @@ func NewDefaultGroups() Groups {
 	return groups
 }
 
+func (c AuditConfig) Validate() error {
+	switch c.Sink {
+	case "", AuditSinkStorage:
+		return nil
+	case AuditSinkFile, AuditSinkBoth:
+		if c.File == "" {
+			return errors.NewErrorAuditFileRequired()
+		}
+		return nil
+	default:
+		return errors.NewErrorUnknownAuditSink(c.Sink)
+	}
+}
+
 func (g Groups) Validate() error {
-	for _, v := range g {
-		if v.Slots < 1 {
+	for name, v := range g {
+		if !ValidGroupName(name) {
+			return errors.NewErrorInvalidGroupName(name)
+		}
+		if v.Slots < 0 || (v.Slots < 1 && v.MaxUnavailable == "") {
 			return errors.NewErrorGroupSlotsOutOfRange()
 		}
+		if v.MaxUnavailable != "" {
+			_, ok := parsePercentage(v.MaxUnavailable)
+			if !ok {
+				return errors.NewErrorInvalidMaxUnavailable(name, v.MaxUnavailable)
+			}
+		}
+		_, err := labels.Parse(v.NodeSelector)
+		if err != nil {
+			return errors.NewErrorInvalidNodeSelector(name, err)
+		}
+		if v.MaxLockAge < 0 {
+			return errors.NewErrorGroupMaxLockAgeOutOfRange()
+		}
+		if v.LeaseDuration < 0 {
+			return errors.NewErrorGroupLeaseDurationOutOfRange()
+		}
+		if v.Schedule != nil {
+			err := v.Schedule.Validate()
+			if err != nil {
+				return err
+			}
+		}
+		for _, other := range v.ExclusiveWith {
+			if _, ok := g[other]; !ok || other == name {
+				return errors.NewErrorInvalidExclusiveGroup(name, other)
+			}
+		}
 	}
 	return nil
 }
+
+// Groups configured with exclusiveWith are only checked by the server handling the request and not atomically in the storage.
+// Returns the first group using exclusiveWith when the storage could be shared by multiple servers without leader election, empty otherwise.
+func (g Groups) ExclusiveWithSharedStorage(storage StorageConfig, leaderElection bool) string {
+	if storage.Type == "memory" || leaderElection {
+		return ""
+	}
+	for _, name := range slices.Sorted(maps.Keys(g)) {
+		if len(g[name].ExclusiveWith) > 0 {
+			return name
+		}
+	}
+	return ""
+}
+
+// Check if the name can be used as a group
+func ValidGroupName(name string) bool {
+	return groupNameRegex.MatchString(name)
+}
+
+// Parse a percentage in the format "20%", needs to be between 1 and 100
+func parsePercentage(s string) (int, bool) {
+	value, ok := strings.CutSuffix(s, "%")
+	if !ok {
+		return 0, false
+	}
+	percentage, err := strconv.Atoi(value)
+	if err != nil || percentage < 1 || percentage > 100 {
+		return 0, false
+	}
+	return percentage, true
+}
@@ -2,6 +2,7 @@ package lockmanager
 
 import (
 	"testing"
+	"time"
 
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
 	"github.com/stretchr/testify/assert"
@@ -60,6 +61,92 @@ func TestGroupsValidate(t *testing.T) {
 			},
 			Result: errors.NewErrorGroupSlotsOutOfRange(),
 		},
+		{
+			Name: "NegativeMaxLockAge",
+			Groups: Groups{
+				"default": GroupConfig{
+					Slots:      1,
+					MaxLockAge: -time.Minute,
+				},
+			},
+			Result: errors.NewErrorGroupMaxLockAgeOutOfRange(),
+		},
+		{
+			Name: "NegativeLeaseDuration",
+			Groups: Groups{
+				"default": GroupConfig{
+					Slots:         1,
+					LeaseDuration: -time.Minute,
+				},
+			},
+			Result: errors.NewErrorGroupLeaseDurationOutOfRange(),
+		},
+		{
+			Name: "MaxUnavailableWithoutSlots",
+			Groups: Groups{
+				"default": GroupConfig{
+					MaxUnavailable: "20%",
+					NodeSelector:   "node-role.kubernetes.io/worker",
+				},
+			},
+			Result: nil,
+		},
+		{
+			Name: "InvalidMaxUnavailable",
+			Groups: Groups{
+				"default": GroupConfig{
+					MaxUnavailable: "120%",
+				},
+			},
+			Result: errors.NewErrorInvalidMaxUnavailable("default", "120%"),
+		},
+		{
+			Name: "MaxUnavailableWithoutPercent",
+			Groups: Groups{
+				"default": GroupConfig{
+					MaxUnavailable: "20",
+				},
+			},
+			Result: errors.NewErrorInvalidMaxUnavailable("default", "20"),
+		},
+		{
+			Name: "InvalidGroupName",
+			Groups: Groups{
+				"fleetlock_events": GroupConfig{
+					Slots: 1,
+				},
+			},
+			Result: errors.NewErrorInvalidGroupName("fleetlock_events"),
+		},
+		{
+			Name: "GroupNameWithSeparator",
+			Groups: Groups{
+				"control/plane": GroupConfig{
+					Slots: 1,
+				},
+			},
+			Result: errors.NewErrorInvalidGroupName("control/plane"),
+		},
+		{
+			Name: "UnknownExclusiveGroup",
+			Groups: Groups{
+				"default": GroupConfig{
+					Slots:         1,
+					ExclusiveWith: []string{"unknown"},
+				},
+			},
+			Result: errors.NewErrorInvalidExclusiveGroup("default", "unknown"),
+		},
+		{
+			Name: "ExclusiveWithItself",
+			Groups: Groups{
+				"default": GroupConfig{
+					Slots:         1,
+					ExclusiveWith: []string{"default"},
+				},
+			},
+			Result: errors.NewErrorInvalidExclusiveGroup("default", "default"),
+		},
 	}
 
 	for _, tCase := range tMatrix {
@@ -70,3 +157,94 @@ func TestGroupsValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestAuditConfigValidate(t *testing.T) {
+	tMatrix := []struct {
+		Name   string
+		Config AuditConfig
+		Result error
+	}{
+		{
+			Name:   "Disabled",
+			Config: AuditConfig{},
+		},
+		{
+			Name:   "Storage",
+			Config: AuditConfig{Sink: AuditSinkStorage},
+		},
+		{
+			Name:   "File",
+			Config: AuditConfig{Sink: AuditSinkFile, File: "/var/log/fleetlock/audit.log"},
+		},
+		{
+			Name:   "Both",
+			Config: AuditConfig{Sink: AuditSinkBoth, File: "/var/log/fleetlock/audit.log"},
+		},
+		{
+			Name:   "FileMissingPath",
+			Config: AuditConfig{Sink: AuditSinkFile},
+			Result: errors.NewErrorAuditFileRequired(),
+		},
+		{
+			Name:   "BothMissingPath",
+			Config: AuditConfig{Sink: AuditSinkBoth},
+			Result: errors.NewErrorAuditFileRequired(),
+		},
+		{
+			Name:   "UnknownSink",
+			Config: AuditConfig{Sink: "syslog"},
+			Result: errors.NewErrorUnknownAuditSink("syslog"),
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert.Equal(t, tCase.Result, tCase.Config.Validate())
+		})
+	}
+}
+
+func TestGroupsExclusiveWithSharedStorage(t *testing.T) {
+	groups := Groups{
+		"control-plane": {Slots: 1, ExclusiveWith: []string{"compute"}},
+		"compute":       {Slots: 1},
+	}
+
+	tMatrix := []struct {
+		Name           string
+		Groups         Groups
+		Storage        string
+		LeaderElection bool
+		Result         string
+	}{
+		{
+			Name:    "Memory",
+			Groups:  groups,
+			Storage: "memory",
+		},
+		{
+			Name:           "SharedWithLeaderElection",
+			Groups:         groups,
+			Storage:        "postgres",
+			LeaderElection: true,
+		},
+		{
+			Name:    "SharedWithoutExclusiveGroups",
+			Groups:  NewDefaultGroups(),
+			Storage: "postgres",
+		},
+		{
+			Name:    "Shared",
+			Groups:  groups,
+			Storage: "postgres",
+			Result:  "control-plane",
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			group := tCase.Groups.ExclusiveWithSharedStorage(StorageConfig{Type: tCase.Storage}, tCase.LeaderElection)
+			assert.Equal(t, tCase.Result, group)
+		})
+	}
+}
@@ -34,6 +34,18 @@ func (e *ErrorUnkownStorageType) Error() string {
 	return fmt.Sprintf("Unsupported storage type \"%s\" selected", e.Type)
 }
 
+type ErrorInvalidGroupName struct {
+	group string
+}
+
+func NewErrorInvalidGroupName(group string) error {
+	return &ErrorInvalidGroupName{group: group}
+}
+
+func (e *ErrorInvalidGroupName) Error() string {
+	return fmt.Sprintf("Invalid group name \"%s\", it may only contain letters, digits, \".\" and \"-\"", e.group)
+}
+
 type ErrorGroupSlotsOutOfRange struct{}
 
 func NewErrorGroupSlotsOutOfRange() error {
@@ -43,3 +55,260 @@ func NewErrorGroupSlotsOutOfRange() error {
 func (e ErrorGroupSlotsOutOfRange) Error() string {
 	return "At least one group has not enough slots, need at least 1"
 }
+
+type ErrorGroupMaxLockAgeOutOfRange struct{}
+
+func NewErrorGroupMaxLockAgeOutOfRange() error {
+	return ErrorGroupMaxLockAgeOutOfRange{}
+}
+
+func (e ErrorGroupMaxLockAgeOutOfRange) Error() string {
+	return "At least one group has a negative maxLockAge"
+}
+
+type ErrorGroupLeaseDurationOutOfRange struct{}
+
+func NewErrorGroupLeaseDurationOutOfRange() error {
+	return ErrorGroupLeaseDurationOutOfRange{}
+}
+
+func (e ErrorGroupLeaseDurationOutOfRange) Error() string {
+	return "At least one group has a negative leaseDuration"
+}
+
+type ErrorInvalidSchedule struct {
+	reason string
+}
+
+func NewErrorInvalidSchedule(reason string) error {
+	return &ErrorInvalidSchedule{reason: reason}
+}
+
+func (e *ErrorInvalidSchedule) Error() string {
+	return fmt.Sprintf("Invalid schedule: %s", e.reason)
+}
+
+type ErrorOutsideMaintenanceWindow struct {
+	group string
+}
+
+func NewErrorOutsideMaintenanceWindow(group string) error {
+	return &ErrorOutsideMaintenanceWindow{group: group}
+}
+
+func (e *ErrorOutsideMaintenanceWindow) Error() string {
+	return fmt.Sprintf("Group %s is outside of its maintenance window", e.group)
+}
+
+type ErrorInvalidExclusiveGroup struct {
+	group, other string
+}
+
+func NewErrorInvalidExclusiveGroup(group, other string) error {
+	return &ErrorInvalidExclusiveGroup{group: group, other: other}
+}
+
+func (e *ErrorInvalidExclusiveGroup) Error() string {
+	return fmt.Sprintf("Group %s can't be exclusive with \"%s\", it needs to be another configured group", e.group, e.other)
+}
+
+type ErrorBlockedByGroup struct {
+	group, other string
+}
+
+func NewErrorBlockedByGroup(group, other string) error {
+	return &ErrorBlockedByGroup{group: group, other: other}
+}
+
+func (e *ErrorBlockedByGroup) Error() string {
+	return fmt.Sprintf("Group %s is blocked while group %s holds locks", e.group, e.other)
+}
+
+type ErrorInvalidMaxUnavailable struct {
+	group, value string
+}
+
+func NewErrorInvalidMaxUnavailable(group, value string) error {
+	return &ErrorInvalidMaxUnavailable{group: group, value: value}
+}
+
+func (e *ErrorInvalidMaxUnavailable) Error() string {
+	return fmt.Sprintf("Group %s has invalid maxUnavailable \"%s\", needs to be a percentage between 1%% and 100%%", e.group, e.value)
+}
+
+type ErrorInvalidNodeSelector struct {
+	group string
+	err   error
+}
+
+func NewErrorInvalidNodeSelector(group string, err error) error {
+	return &ErrorInvalidNodeSelector{group: group, err: err}
+}
+
+func (e *ErrorInvalidNodeSelector) Error() string {
+	return fmt.Sprintf("Group %s has invalid nodeSelector: %v", e.group, e.err)
+}
+
+func (e *ErrorInvalidNodeSelector) Unwrap() error {
+	return e.err
+}
+
+type ErrorUnknownAuditSink struct {
+	sink string
+}
+
+func NewErrorUnknownAuditSink(sink string) error {
+	return &ErrorUnknownAuditSink{sink: sink}
+}
+
+func (e *ErrorUnknownAuditSink) Error() string {
+	return fmt.Sprintf("Unsupported audit sink \"%s\", needs to be one of storage, file or both", e.sink)
+}
+
+type ErrorAuditFileRequired struct{}
+
+func NewErrorAuditFileRequired() error {
+	return ErrorAuditFileRequired{}
+}
+
+func (e ErrorAuditFileRequired) Error() string {
+	return "The audit sink writes to a file, but no file is configured"
+}
+
+type ErrorAuditNotSupported struct{}
+
+func NewErrorAuditNotSupported() error {
+	return ErrorAuditNotSupported{}
+}
+
+func (e ErrorAuditNotSupported) Error() string {
+	return "The storage backend does not support storing audit events"
+}
+
+type ErrorAuditDisabled struct{}
+
+func NewErrorAuditDisabled() error {
+	return ErrorAuditDisabled{}
+}
+
+func (e ErrorAuditDisabled) Error() string {
+	return "The audit log is disabled"
+}
+
+type ErrorReserveConflict struct {
+	group string
+}
+
+func NewErrorReserveConflict(group string) error {
+	return &ErrorReserveConflict{group: group}
+}
+
+func (e *ErrorReserveConflict) Error() string {
+	return fmt.Sprintf("Failed to reserve a slot in group %s, it was changed concurrently too often", e.group)
+}
+
+type ErrorRaftNoPeers struct{}
+
+func NewErrorRaftNoPeers() error {
+	return ErrorRaftNoPeers{}
+}
+
+func (e ErrorRaftNoPeers) Error() string {
+	return "The raft storage needs either peers or a discovery service and the number of replicas"
+}
+
+type ErrorRaftDataDirRequired struct{}
+
+func NewErrorRaftDataDirRequired() error {
+	return ErrorRaftDataDirRequired{}
+}
+
+func (e ErrorRaftDataDirRequired) Error() string {
+	return "The raft storage needs a data directory to persist its log"
+}
+
+type ErrorRaftNotAPeer struct {
+	address string
+}
+
+func NewErrorRaftNotAPeer(address string) error {
+	return &ErrorRaftNotAPeer{address: address}
+}
+
+func (e *ErrorRaftNotAPeer) Error() string {
+	return fmt.Sprintf("The advertised address %s is not one of the raft peers", e.address)
+}
+
+type ErrorRaftInvalidHostname struct {
+	hostname string
+}
+
+func NewErrorRaftInvalidHostname(hostname string) error {
+	return &ErrorRaftInvalidHostname{hostname: hostname}
+}
+
+func (e *ErrorRaftInvalidHostname) Error() string {
+	return fmt.Sprintf("Can't discover raft peers, the hostname %s does not end with the ordinal of a statefulset pod", e.hostname)
+}
+
+type ErrorRaftTimeout struct{}
+
+func NewErrorRaftTimeout() error {
+	return ErrorRaftTimeout{}
+}
+
+func (e ErrorRaftTimeout) Error() string {
+	return "Timed out waiting for the raft cluster, there might be no leader"
+}
+
+type ErrorRaftNoLeader struct{}
+
+func NewErrorRaftNoLeader() error {
+	return ErrorRaftNoLeader{}
+}
+
+func (e ErrorRaftNoLeader) Error() string {
+	return "The raft cluster has no leader"
+}
+
+type ErrorFilePathRequired struct{}
+
+func NewErrorFilePathRequired() error {
+	return ErrorFilePathRequired{}
+}
+
+func (e ErrorFilePathRequired) Error() string {
+	return "The file storage needs the path of the file to save the locks in"
+}
+
+type ErrorFileLockingNotSupported struct{}
+
+func NewErrorFileLockingNotSupported() error {
+	return ErrorFileLockingNotSupported{}
+}
+
+func (e ErrorFileLockingNotSupported) Error() string {
+	return "The file storage is not supported on this platform, it can't lock the file"
+}
+
+type ErrorConsulInvalidAddress struct {
+	address string
+}
+
+func NewErrorConsulInvalidAddress(address string) error {
+	return &ErrorConsulInvalidAddress{address: address}
+}
+
+func (e *ErrorConsulInvalidAddress) Error() string {
+	return fmt.Sprintf("Invalid consul address %s, expected an url starting with http:// or https://", e.address)
+}
+
+type ErrorConsulNoLeader struct{}
+
+func NewErrorConsulNoLeader() error {
+	return ErrorConsulNoLeader{}
+}
+
+func (e ErrorConsulNoLeader) Error() string {
+	return "The consul cluster has no leader"
+}
@@ -0,0 +1,84 @@
+package lockmanager
+
+import (
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+	"github.com/heathcliff26/fleetlock/pkg/metrics"
+)
+
+// Wraps a StorageBackend and records the latency of all operations
+type instrumentedStorage struct {
+	backend StorageBackend
+}
+
+func observe(operation string, start time.Time, err error) {
+	metrics.ObserveStorageOperation(operation, time.Since(start), err)
+}
+
+func (s *instrumentedStorage) Reserve(group, id, owner string, ttl time.Duration) error {
+	start := time.Now()
+	err := s.backend.Reserve(group, id, owner, ttl)
+	observe("reserve", start, err)
+	return err
+}
+
+func (s *instrumentedStorage) TryReserve(group, id, owner string, slots int, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := s.backend.TryReserve(group, id, owner, slots, ttl)
+	observe("try_reserve", start, err)
+	return ok, err
+}
+
+func (s *instrumentedStorage) Renew(group, id string, ttl time.Duration) error {
+	start := time.Now()
+	err := s.backend.Renew(group, id, ttl)
+	observe("renew", start, err)
+	return err
+}
+
+func (s *instrumentedStorage) GetLocks(group string) (int, error) {
+	start := time.Now()
+	count, err := s.backend.GetLocks(group)
+	observe("get_locks", start, err)
+	return count, err
+}
+
+func (s *instrumentedStorage) Release(group, id string) error {
+	start := time.Now()
+	err := s.backend.Release(group, id)
+	observe("release", start, err)
+	return err
+}
+
+func (s *instrumentedStorage) GetStaleLocks(ts time.Duration) ([]types.Lock, error) {
+	start := time.Now()
+	locks, err := s.backend.GetStaleLocks(ts)
+	observe("get_stale_locks", start, err)
+	return locks, err
+}
+
+func (s *instrumentedStorage) ListLocks(group string) ([]types.Lock, error) {
+	start := time.Now()
+	locks, err := s.backend.ListLocks(group)
+	observe("list_locks", start, err)
+	return locks, err
+}
+
+func (s *instrumentedStorage) HasLock(group, id string) (bool, error) {
+	start := time.Now()
+	ok, err := s.backend.HasLock(group, id)
+	observe("has_lock", start, err)
+	return ok, err
+}
+
+func (s *instrumentedStorage) Ping() error {
+	start := time.Now()
+	err := s.backend.Ping()
+	observe("ping", start, err)
+	return err
+}
+
+func (s *instrumentedStorage) Close() error {
+	return s.backend.Close()
+}
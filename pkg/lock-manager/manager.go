@@ -1,35 +1,95 @@
 package lockmanager
 
 import (
+	"context"
+	"log/slog"
+	"maps"
+	"reflect"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/audit"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/consul"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/etcd"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/file"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/kubernetes"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/memory"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/mongodb"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/raft"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/sql"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/valkey"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
 )
 
+const (
+	staleLockCheckInterval = time.Minute
+	slotRefreshInterval    = time.Minute
+)
+
 type LockManager struct {
-	groups  map[string]*lockGroup
-	storage StorageBackend
+	// Replaced as a whole when the groups are updated
+	groups     map[string]*lockGroup
+	groupsLock sync.RWMutex
+	storage    StorageBackend
+	// Destination of audit events, nil when disabled
+	auditSink atomic.Pointer[audit.Sink]
+
+	// Stops all background tasks, cancelled by Close
+	ctx    context.Context
+	cancel context.CancelFunc
+	// Used to recompute the slots, nil when not set
+	nodeCounter          NodeCounter
+	staleLockCheckActive atomic.Bool
+	slotRefreshActive    atomic.Bool
 }
 
+// The config and exclusive groups are not changed after creation.
+// When the groups are updated, a new lockGroup is created that shares the RWLock with the old one.
 type lockGroup struct {
 	Config GroupConfig
-	RWLock sync.RWMutex
+	RWLock *sync.RWMutex
+	// Sorted names of the groups that can't hold locks at the same time as this one
+	Exclusive []string
+	// Current number of slots, can change when computed from maxUnavailable
+	slots atomic.Int64
+}
+
+// Implemented by storage backends that need to know all groups in advance
+type GroupStorage interface {
+	// Prepare the storage for the group, does nothing if it already exists
+	AddGroup(group string)
+}
+
+// Counts the nodes matching a label selector, implemented by k8s.Client
+type NodeCounter interface {
+	CountNodes(selector string) (int, error)
+}
+
+// Current state of a group
+type GroupStatus struct {
+	Name  string
+	Slots int
+	Locks []types.Lock
 }
 
 // It is assumed that each group itself is multi-read, single-write.
 // There can be multiple writes to different groups happening in parallel though.
 type StorageBackend interface {
 	// Reserve a lock for the given group.
-	// Returns true if the lock is successfully reserved, even if the lock is already held by the specific id
-	Reserve(group, id string) error
+	// Returns true if the lock is successfully reserved, even if the lock is already held by the specific id.
+	// The owner is saved with the lock and returned as part of it, it is not changed when the lock already exists.
+	// When ttl is greater than 0, the lock expires unless it is renewed in time.
+	Reserve(group, id, owner string, ttl time.Duration) error
+	// Reserve a lock for the given group, if the group holds less than the given number of locks.
+	// Returns true if the lock is reserved, even if it was already held by the id, and false when all slots are taken.
+	// Checking and reserving has to be atomic, as multiple servers can share the same storage.
+	TryReserve(group, id, owner string, slots int, ttl time.Duration) (bool, error)
+	// Extend the lock held by the id, so that it expires ttl from now.
+	// Does not fail when no lock is held.
+	Renew(group, id string, ttl time.Duration) error
 	// Returns the current number of locks for the given group
 	GetLocks(group string) (int, error)
 	// Release the lock currently held by the id.
@@ -37,8 +97,12 @@ type StorageBackend interface {
 	Release(group, id string) error
 	// Return all locks older than x
 	GetStaleLocks(ts time.Duration) ([]types.Lock, error)
+	// Return all locks currently held in the given group
+	ListLocks(group string) ([]types.Lock, error)
 	// Check if a given id already has a lock for this group
 	HasLock(group, id string) (bool, error)
+	// Check if the backend is reachable, used for the readiness check
+	Ping() error
 	// Calls all necessary finalization if necessary
 	Close() error
 }
@@ -70,6 +134,12 @@ func NewManager(groups Groups, storageCfg StorageConfig) (*LockManager, error) {
 		storage, err = kubernetes.NewKubernetesBackend(storageCfg.Kubernetes)
 	case "mongodb":
 		storage, err = mongodb.NewMongoDBBackend(storageCfg.MongoDB)
+	case "raft":
+		storage, err = raft.NewRaftBackend(storageCfg.Raft)
+	case "file":
+		storage, err = file.NewFileBackend(storageCfg.File)
+	case "consul":
+		storage, err = consul.NewConsulBackend(storageCfg.Consul)
 	default:
 		err = errors.NewErrorUnkownStorageType(storageCfg.Type)
 	}
@@ -77,18 +147,20 @@ func NewManager(groups Groups, storageCfg StorageConfig) (*LockManager, error) {
 		return nil, err
 	}
 
-	return &LockManager{
-		groups:  initGroups(groups),
-		storage: storage,
-	}, nil
+	return NewManagerWithStorage(groups, storage), nil
 }
 
 // Create a new LockManager with custom StorageBackend
 func NewManagerWithStorage(groups Groups, storage StorageBackend) *LockManager {
-	return &LockManager{
+	ctx, cancel := context.WithCancel(context.Background())
+	lm := &LockManager{
 		groups:  initGroups(groups),
-		storage: storage,
+		storage: &instrumentedStorage{backend: storage},
+		ctx:     ctx,
+		cancel:  cancel,
 	}
+	lm.startStaleLockCheck()
+	return lm
 }
 
 func initGroups(groups Groups) map[string]*lockGroup {
@@ -96,13 +168,82 @@ func initGroups(groups Groups) map[string]*lockGroup {
 	for name, cfg := range groups {
 		g[name] = &lockGroup{
 			Config: cfg,
+			RWLock: &sync.RWMutex{},
+		}
+		// Until the nodes are counted, the slots are used as fallback for maxUnavailable
+		g[name].slots.Store(int64(max(cfg.Slots, 1)))
+	}
+
+	// Exclusion is mutual, so it needs to apply to both groups regardless of where it is configured
+	for name, cfg := range groups {
+		for _, other := range cfg.ExclusiveWith {
+			if g[other] == nil || other == name {
+				continue
+			}
+			if !slices.Contains(g[name].Exclusive, other) {
+				g[name].Exclusive = append(g[name].Exclusive, other)
+			}
+			if !slices.Contains(g[other].Exclusive, name) {
+				g[other].Exclusive = append(g[other].Exclusive, name)
+			}
 		}
 	}
+	for _, lGroup := range g {
+		slices.Sort(lGroup.Exclusive)
+	}
+
 	return g
 }
 
+// Replace the groups with the given ones, without interrupting requests in progress.
+// Locks held in removed groups are kept in the storage, but can't be used until the group is added again.
+func (lm *LockManager) UpdateGroups(groups Groups) {
+	newGroups := initGroups(groups)
+
+	if gs, ok := lm.backend().(GroupStorage); ok {
+		for name := range newGroups {
+			gs.AddGroup(name)
+		}
+	}
+
+	lm.groupsLock.Lock()
+	for name, lGroup := range newGroups {
+		old := lm.groups[name]
+		if old == nil {
+			slog.Info("Added group", slog.String("group", name))
+			continue
+		}
+		// Requests in progress still use the old group, so both need to share the same lock
+		lGroup.RWLock = old.RWLock
+		// Keep the computed slots, otherwise the group falls back to slots until the nodes are counted again
+		if lGroup.Config.MaxUnavailable != "" && lGroup.Config.MaxUnavailable == old.Config.MaxUnavailable && lGroup.Config.NodeSelector == old.Config.NodeSelector {
+			lGroup.slots.Store(old.slots.Load())
+		}
+		if !reflect.DeepEqual(lGroup.Config, old.Config) {
+			slog.Info("Updated group", slog.String("group", name))
+		}
+	}
+	for name := range lm.groups {
+		if newGroups[name] == nil {
+			slog.Info("Removed group", slog.String("group", name))
+		}
+	}
+	lm.groups = newGroups
+	lm.groupsLock.Unlock()
+
+	// The new groups may need background tasks that were not needed before
+	lm.startStaleLockCheck()
+	lm.startSlotRefresh()
+}
+
 // Reserve a slot for the given group and id
 func (lm *LockManager) Reserve(group, id string) (bool, error) {
+	return lm.ReserveAs(group, id, "")
+}
+
+// Reserve a slot for the given group and id on behalf of the owner.
+// The owner is the identity of the authenticated client, it is only saved for new reservations.
+func (lm *LockManager) ReserveAs(group, id, owner string) (bool, error) {
 	lGroup, err := lm.getGroup(group, id)
 	if err != nil {
 		return false, err
@@ -116,10 +257,22 @@ func (lm *LockManager) Reserve(group, id string) (bool, error) {
 		return lm.storage.HasLock(group, id)
 	}
 	ok, err := checkHasLock()
-	if ok && err == nil {
-		return true, nil
-	} else if err != nil {
+	if err != nil {
 		return false, err
+	} else if ok {
+		if lGroup.Config.LeaseDuration == 0 {
+			return true, nil
+		}
+		// Reserving again counts as heartbeat for the lease
+		ok, err = lm.Renew(group, id)
+		if err != nil || ok {
+			return ok, err
+		}
+		// The lock expired in the meantime, so try to reserve a new one
+	}
+
+	if !lGroup.Config.Schedule.IsOpen(time.Now()) {
+		return false, errors.NewErrorOutsideMaintenanceWindow(group)
 	}
 
 	// Use own function to ensure lock is released
@@ -128,34 +281,96 @@ func (lm *LockManager) Reserve(group, id string) (bool, error) {
 		lGroup.RWLock.RLock()
 		defer lGroup.RWLock.RUnlock()
 
-		return lm.checkSlots(group, lGroup.Config)
+		return lm.checkSlots(group, lGroup)
 	}
 	ok, err = checkAvailableSlots()
 	if err != nil || !ok {
 		return false, err
 	}
 
-	// Get Write Lock
+	// Get Write Lock, as well as read locks for exclusive groups to prevent them from reserving in parallel.
+	// This only covers this server, the storage ensures the slots are not exceeded when running multiple servers.
+	unlock := lm.lockForReserve(group, lGroup)
+	defer unlock()
+
+	for _, other := range lGroup.Exclusive {
+		count, err := lm.storage.GetLocks(other)
+		if err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return false, errors.NewErrorBlockedByGroup(group, other)
+		}
+	}
+
+	return lm.storage.TryReserve(group, id, owner, int(lGroup.slots.Load()), lGroup.Config.LeaseDuration)
+}
+
+// Renew the lease of the slot held by the given id.
+// Returns false if the id does not hold a slot in the group.
+func (lm *LockManager) Renew(group, id string) (bool, error) {
+	lGroup, err := lm.getGroup(group, id)
+	if err != nil {
+		return false, err
+	}
+
 	lGroup.RWLock.Lock()
 	defer lGroup.RWLock.Unlock()
 
-	// Re-check, since another write could have happened between checking the first time and now
-	ok, err = lm.checkSlots(group, lGroup.Config)
+	ok, err := lm.storage.HasLock(group, id)
 	if err != nil || !ok {
 		return false, err
 	}
 
-	err = lm.storage.Reserve(group, id)
+	if lGroup.Config.LeaseDuration == 0 {
+		return true, nil
+	}
+
+	err = lm.storage.Renew(group, id, lGroup.Config.LeaseDuration)
 	return err == nil, err
 }
 
-func (lm *LockManager) checkSlots(group string, cfg GroupConfig) (bool, error) {
+// Lock the group for writing and all of its exclusive groups for reading.
+// Locks are always acquired sorted by name to prevent deadlocks.
+// Returns a function that releases all locks.
+func (lm *LockManager) lockForReserve(group string, lGroup *lockGroup) func() {
+	groups := lm.getGroups()
+	locks := make(map[string]*sync.RWMutex, len(lGroup.Exclusive)+1)
+	locks[group] = lGroup.RWLock
+	for _, other := range lGroup.Exclusive {
+		// The group could have been removed by an update in the meantime
+		if groups[other] != nil {
+			locks[other] = groups[other].RWLock
+		}
+	}
+	names := slices.Sorted(maps.Keys(locks))
+
+	for _, name := range names {
+		if name == group {
+			locks[name].Lock()
+		} else {
+			locks[name].RLock()
+		}
+	}
+
+	return func() {
+		for _, name := range names {
+			if name == group {
+				locks[name].Unlock()
+			} else {
+				locks[name].RUnlock()
+			}
+		}
+	}
+}
+
+func (lm *LockManager) checkSlots(group string, lGroup *lockGroup) (bool, error) {
 	usedSlots, err := lm.storage.GetLocks(group)
 	if err != nil {
 		return false, err
 	}
 
-	return usedSlots < cfg.Slots, nil
+	return int64(usedSlots) < lGroup.slots.Load(), nil
 }
 
 // Release a slot for the given group and id
@@ -184,12 +399,253 @@ func (lm *LockManager) HasLock(group, id string) (bool, error) {
 	return lm.storage.HasLock(group, id)
 }
 
+// Return the lock held by the given id, nil if it does not hold a slot in the group
+func (lm *LockManager) GetLock(group, id string) (*types.Lock, error) {
+	lGroup, err := lm.getGroup(group, id)
+	if err != nil {
+		return nil, err
+	}
+
+	lGroup.RWLock.RLock()
+	defer lGroup.RWLock.RUnlock()
+
+	locks, err := lm.storage.ListLocks(group)
+	if err != nil {
+		return nil, err
+	}
+	for _, lock := range locks {
+		if lock.ID == id {
+			return &lock, nil
+		}
+	}
+	return nil, nil
+}
+
+// Return the status of all groups, sorted by name
+func (lm *LockManager) GetStatus() ([]GroupStatus, error) {
+	names := lm.groupNames()
+
+	result := make([]GroupStatus, 0, len(names))
+	for _, name := range names {
+		status, err := lm.GetGroupStatus(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// Return the configured slots and current locks of the given group
+func (lm *LockManager) GetGroupStatus(group string) (GroupStatus, error) {
+	lGroup := lm.getGroups()[group]
+	if lGroup == nil {
+		return GroupStatus{}, errors.NewErrorUnknownGroup(group)
+	}
+
+	lGroup.RWLock.RLock()
+	defer lGroup.RWLock.RUnlock()
+
+	locks, err := lm.storage.ListLocks(group)
+	if err != nil {
+		return GroupStatus{}, err
+	}
+
+	return GroupStatus{
+		Name:  group,
+		Slots: int(lGroup.slots.Load()),
+		Locks: locks,
+	}, nil
+}
+
+// Check if the storage backend is reachable
+func (lm *LockManager) Ping() error {
+	return lm.storage.Ping()
+}
+
+// Stop background tasks and close the storage backend
 func (lm *LockManager) Close() error {
+	lm.cancel()
+	if sink := lm.auditSink.Load(); sink != nil {
+		err := (*sink).Close()
+		if err != nil {
+			slog.Error("Failed to close audit sink", "error", err)
+		}
+	}
 	return lm.storage.Close()
 }
 
+// Start a background task that periodically releases locks exceeding the maxLockAge of their group.
+// Does nothing if no group has maxLockAge set.
+func (lm *LockManager) startStaleLockCheck() {
+	if lm.ctx.Err() != nil || lm.minMaxLockAge() == 0 || !lm.staleLockCheckActive.CompareAndSwap(false, true) {
+		return
+	}
+
+	go lm.periodicStaleLockCheck(lm.ctx)
+}
+
+func (lm *LockManager) periodicStaleLockCheck(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(staleLockCheckInterval):
+		}
+
+		lm.ReleaseStaleLocks()
+	}
+}
+
+// Release all locks that are held longer than the maxLockAge of their group
+func (lm *LockManager) ReleaseStaleLocks() {
+	minAge := lm.minMaxLockAge()
+	if minAge == 0 {
+		return
+	}
+
+	locks, err := lm.getStaleLocks(minAge)
+	if err != nil {
+		slog.Error("Failed to fetch stale locks", "err", err)
+		return
+	}
+
+	groups := lm.getGroups()
+	for _, lock := range locks {
+		lGroup := groups[lock.Group]
+		if lGroup == nil || lGroup.Config.MaxLockAge == 0 || time.Since(lock.Created) <= lGroup.Config.MaxLockAge {
+			continue
+		}
+
+		err = lm.Release(lock.Group, lock.ID)
+		if err != nil {
+			slog.Error("Failed to release stale lock", "err", err, slog.String("group", lock.Group), slog.String("id", lock.ID))
+			continue
+		}
+		slog.Info("Released stale lock", slog.String("group", lock.Group), slog.String("id", lock.ID), slog.Time("created", lock.Created))
+		lm.RecordEvent(types.Event{
+			Type:   types.EventForceRelease,
+			Group:  lock.Group,
+			ID:     lock.ID,
+			Reason: "exceeded maxLockAge",
+		})
+	}
+}
+
+// Fetch the stale locks from the storage while ensuring no group is written to.
+func (lm *LockManager) getStaleLocks(ts time.Duration) ([]types.Lock, error) {
+	// Lock sorted by name, the same as lockForReserve, to prevent deadlocks
+	groups := lm.getGroups()
+	for _, name := range slices.Sorted(maps.Keys(groups)) {
+		lGroup := groups[name]
+		lGroup.RWLock.RLock()
+		defer lGroup.RWLock.RUnlock()
+	}
+
+	return lm.storage.GetStaleLocks(ts)
+}
+
+// Return the smallest maxLockAge of all groups, ignoring groups where it is disabled
+func (lm *LockManager) minMaxLockAge() time.Duration {
+	var minAge time.Duration
+	for _, lGroup := range lm.getGroups() {
+		age := lGroup.Config.MaxLockAge
+		if age > 0 && (minAge == 0 || age < minAge) {
+			minAge = age
+		}
+	}
+	return minAge
+}
+
+// Compute the slots of groups with maxUnavailable from the number of matching nodes.
+// Recomputes them periodically in the background until the manager is closed.
+// Does nothing if no group has maxUnavailable set.
+func (lm *LockManager) UseNodeCounter(nc NodeCounter) {
+	lm.nodeCounter = nc
+	lm.startSlotRefresh()
+}
+
+// Start the periodic slot refresh if a node counter is set and any group has maxUnavailable
+func (lm *LockManager) startSlotRefresh() {
+	if lm.ctx.Err() != nil || lm.nodeCounter == nil || !lm.hasMaxUnavailable() {
+		return
+	}
+
+	lm.RefreshSlots(lm.nodeCounter)
+	if lm.slotRefreshActive.CompareAndSwap(false, true) {
+		go lm.periodicSlotRefresh(lm.ctx, lm.nodeCounter)
+	}
+}
+
+func (lm *LockManager) periodicSlotRefresh(ctx context.Context, nc NodeCounter) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(slotRefreshInterval):
+		}
+
+		lm.RefreshSlots(nc)
+	}
+}
+
+// Recompute the slots of all groups with maxUnavailable.
+// Keeps the previous value when the nodes can't be counted.
+func (lm *LockManager) RefreshSlots(nc NodeCounter) {
+	for name, lGroup := range lm.getGroups() {
+		if lGroup.Config.MaxUnavailable == "" {
+			continue
+		}
+
+		count, err := nc.CountNodes(lGroup.Config.NodeSelector)
+		if err != nil {
+			slog.Error("Failed to count nodes for group, keeping current slots", "err", err, slog.String("group", name), slog.String("selector", lGroup.Config.NodeSelector))
+			continue
+		}
+
+		// Already validated when loading the config
+		percentage, _ := parsePercentage(lGroup.Config.MaxUnavailable)
+		slots := int64(max(count*percentage/100, 1))
+
+		old := lGroup.slots.Swap(slots)
+		if old != slots {
+			slog.Info("Updated slots of group", slog.String("group", name), slog.Int64("slots", slots), slog.Int("nodes", count))
+		}
+	}
+}
+
+func (lm *LockManager) hasMaxUnavailable() bool {
+	for _, lGroup := range lm.getGroups() {
+		if lGroup.Config.MaxUnavailable != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the names of all groups, sorted
+func (lm *LockManager) groupNames() []string {
+	return slices.Sorted(maps.Keys(lm.getGroups()))
+}
+
+// Return the storage backend without instrumentation
+func (lm *LockManager) backend() StorageBackend {
+	if s, ok := lm.storage.(*instrumentedStorage); ok {
+		return s.backend
+	}
+	return lm.storage
+}
+
+// Return the current groups, the map must not be modified
+func (lm *LockManager) getGroups() map[string]*lockGroup {
+	lm.groupsLock.RLock()
+	defer lm.groupsLock.RUnlock()
+
+	return lm.groups
+}
+
 func (lm *LockManager) getGroup(group, id string) (*lockGroup, error) {
-	lGroup := lm.groups[group]
+	lGroup := lm.getGroups()[group]
 	if lGroup == nil {
 		return nil, errors.NewErrorUnknownGroup(group)
 	}
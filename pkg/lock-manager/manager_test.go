@@ -1,12 +1,19 @@
 package lockmanager
 
 import (
+	"fmt"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
+	"testing/synctest"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/etcd"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/kubernetes"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/memory"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/sql"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/valkey"
 	"github.com/stretchr/testify/assert"
@@ -152,7 +159,7 @@ func TestNewManager(t *testing.T) {
 				require.NoError(err, "Should create new lock manager without error")
 				require.NotNil(lm, "Should create new lock manager")
 				assert.Equal(tCase.Result.groups, lm.groups)
-				assert.Equal(tCase.Result.storage, reflect.TypeOf(lm.storage).String())
+				assert.Equal(tCase.Result.storage, reflect.TypeOf(lm.storage.(*instrumentedStorage).backend).String())
 			} else {
 				assert.Nil(lm)
 				assert.ErrorContains(err, tCase.Error)
@@ -196,3 +203,419 @@ func TestRelease(t *testing.T) {
 	err = lm.Release("default", "")
 	assert.Equal("errors.ErrorEmptyID", reflect.TypeOf(err).String())
 }
+
+func TestReleaseStaleLocks(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		groups := Groups{
+			"default": GroupConfig{
+				Slots:      2,
+				MaxLockAge: 10 * time.Minute,
+			},
+			"unlimited": GroupConfig{
+				Slots: 1,
+			},
+		}
+		lm := NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"default", "unlimited"}))
+		t.Cleanup(func() {
+			_ = lm.Close()
+		})
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		require.True(lm.staleLockCheckActive.Load(), "Should start periodic check for stale locks")
+
+		ok, err := lm.Reserve("default", "old")
+		require.True(ok)
+		require.NoError(err)
+		ok, err = lm.Reserve("unlimited", "old")
+		require.True(ok)
+		require.NoError(err)
+
+		synctest.Sleep(5 * time.Minute)
+
+		ok, err = lm.Reserve("default", "new")
+		require.True(ok)
+		require.NoError(err)
+
+		synctest.Sleep(6 * time.Minute)
+
+		ok, _ = lm.HasLock("default", "old")
+		assert.False(ok, "Should release lock exceeding maxLockAge")
+		ok, _ = lm.HasLock("default", "new")
+		assert.True(ok, "Should keep lock younger than maxLockAge")
+		ok, _ = lm.HasLock("unlimited", "old")
+		assert.True(ok, "Should keep lock of group without maxLockAge")
+	})
+}
+
+func TestLeaseDuration(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		groups := Groups{
+			"default": GroupConfig{
+				Slots:         1,
+				LeaseDuration: time.Minute,
+			},
+		}
+		lm := NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"default"}))
+		t.Cleanup(func() {
+			_ = lm.Close()
+		})
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		ok, err := lm.Reserve("default", "holder")
+		require.True(ok)
+		require.NoError(err)
+
+		synctest.Sleep(40 * time.Second)
+
+		ok, err = lm.Renew("default", "holder")
+		assert.True(ok, "Should renew held lock")
+		assert.NoError(err)
+
+		synctest.Sleep(40 * time.Second)
+
+		ok, _ = lm.HasLock("default", "holder")
+		assert.True(ok, "Should keep renewed lock")
+		ok, err = lm.Reserve("default", "other")
+		assert.False(ok, "Should not reserve while lock is held")
+		assert.NoError(err)
+
+		ok, err = lm.Reserve("default", "holder")
+		assert.True(ok, "Reserving again should renew the lock")
+		assert.NoError(err)
+
+		synctest.Sleep(61 * time.Second)
+
+		ok, _ = lm.HasLock("default", "holder")
+		assert.False(ok, "Lock should expire when not renewed")
+		ok, err = lm.Renew("default", "holder")
+		assert.False(ok, "Should not renew expired lock")
+		assert.NoError(err)
+
+		ok, err = lm.Reserve("default", "other")
+		assert.True(ok, "Should reserve slot freed by expired lock")
+		assert.NoError(err)
+	})
+}
+
+func TestReserveOutsideMaintenanceWindow(t *testing.T) {
+	// The fake clock of synctest starts on Saturday, 2000-01-01 00:00 UTC
+	synctest.Test(t, func(t *testing.T) {
+		groups := Groups{
+			"default": GroupConfig{
+				Slots: 2,
+				Schedule: &Schedule{
+					Windows: []MaintenanceWindow{
+						{
+							Days:     []string{"Sat"},
+							Start:    "00:00",
+							Duration: time.Hour,
+						},
+					},
+				},
+			},
+		}
+		lm := NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"default"}))
+		t.Cleanup(func() {
+			_ = lm.Close()
+		})
+
+		assert := assert.New(t)
+
+		ok, err := lm.Reserve("default", "holder")
+		assert.True(ok, "Should reserve inside the window")
+		assert.NoError(err)
+
+		synctest.Sleep(2 * time.Hour)
+
+		ok, err = lm.Reserve("default", "other")
+		assert.False(ok, "Should not reserve outside the window")
+		assert.Equal(errors.NewErrorOutsideMaintenanceWindow("default"), err)
+
+		ok, err = lm.Reserve("default", "holder")
+		assert.True(ok, "Should still confirm existing reservations")
+		assert.NoError(err)
+
+		assert.NoError(lm.Release("default", "holder"), "Should release outside the window")
+	})
+}
+
+func TestExclusiveGroups(t *testing.T) {
+	groups := Groups{
+		"controlplane": GroupConfig{
+			Slots:         1,
+			ExclusiveWith: []string{"workers"},
+		},
+		"workers": GroupConfig{
+			Slots: 2,
+		},
+		"other": GroupConfig{
+			Slots: 1,
+		},
+	}
+	lm := NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"controlplane", "workers", "other"}))
+	t.Cleanup(func() {
+		_ = lm.Close()
+	})
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	assert.Equal([]string{"workers"}, lm.groups["controlplane"].Exclusive)
+	assert.Equal([]string{"controlplane"}, lm.groups["workers"].Exclusive, "Exclusion should be mutual")
+	assert.Empty(lm.groups["other"].Exclusive)
+
+	ok, err := lm.Reserve("workers", "worker-1")
+	require.True(ok)
+	require.NoError(err)
+
+	ok, err = lm.Reserve("controlplane", "cp-1")
+	assert.False(ok, "Should not reserve while exclusive group holds locks")
+	assert.Equal(errors.NewErrorBlockedByGroup("controlplane", "workers"), err)
+
+	ok, err = lm.Reserve("other", "other-1")
+	assert.True(ok, "Should not block unrelated groups")
+	assert.NoError(err)
+
+	require.NoError(lm.Release("workers", "worker-1"))
+
+	ok, err = lm.Reserve("controlplane", "cp-1")
+	assert.True(ok, "Should reserve once exclusive group is idle")
+	assert.NoError(err)
+
+	ok, err = lm.Reserve("workers", "worker-1")
+	assert.False(ok, "Should block in both directions")
+	assert.Equal(errors.NewErrorBlockedByGroup("workers", "controlplane"), err)
+}
+
+func TestExclusiveGroupsConcurrentReserve(t *testing.T) {
+	groups := Groups{
+		"a": GroupConfig{
+			Slots:         10,
+			ExclusiveWith: []string{"b"},
+		},
+		"b": GroupConfig{
+			Slots: 10,
+		},
+	}
+
+	for i := range 20 {
+		lm := NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"a", "b"}))
+
+		var wg sync.WaitGroup
+		for j := range 10 {
+			for _, group := range []string{"a", "b"} {
+				wg.Go(func() {
+					_, _ = lm.Reserve(group, group+strconv.Itoa(j))
+				})
+			}
+		}
+		wg.Wait()
+
+		countA, err := lm.storage.GetLocks("a")
+		require.NoError(t, err)
+		countB, err := lm.storage.GetLocks("b")
+		require.NoError(t, err)
+
+		assert.False(t, countA > 0 && countB > 0, "Run %d: exclusive groups should never hold locks at the same time, a=%d b=%d", i, countA, countB)
+		_ = lm.Close()
+	}
+}
+
+// Counts are read by the periodic refresh while the test changes them
+type fakeNodeCounter struct {
+	lock  sync.Mutex
+	count int
+	err   error
+}
+
+func (f *fakeNodeCounter) CountNodes(_ string) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.count, f.err
+}
+
+func (f *fakeNodeCounter) set(count int, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.count = count
+	f.err = err
+}
+
+func TestRefreshSlots(t *testing.T) {
+	groups := Groups{
+		"percentage": GroupConfig{
+			MaxUnavailable: "20%",
+		},
+		"fallback": GroupConfig{
+			Slots:          3,
+			MaxUnavailable: "50%",
+		},
+		"fixed": GroupConfig{
+			Slots: 2,
+		},
+	}
+	lm := NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"percentage", "fallback", "fixed"}))
+	t.Cleanup(func() {
+		_ = lm.Close()
+	})
+
+	assert := assert.New(t)
+
+	assert.Equal(int64(1), lm.groups["percentage"].slots.Load(), "Should default to 1 slot before counting nodes")
+	assert.Equal(int64(3), lm.groups["fallback"].slots.Load(), "Should use slots before counting nodes")
+
+	nc := &fakeNodeCounter{count: 12}
+	lm.RefreshSlots(nc)
+
+	assert.Equal(int64(2), lm.groups["percentage"].slots.Load(), "Should round down")
+	assert.Equal(int64(6), lm.groups["fallback"].slots.Load())
+	assert.Equal(int64(2), lm.groups["fixed"].slots.Load(), "Should not change fixed slots")
+
+	nc.set(3, nil)
+	lm.RefreshSlots(nc)
+	assert.Equal(int64(1), lm.groups["percentage"].slots.Load(), "Should have at least 1 slot")
+
+	nc.set(100, fmt.Errorf("api unavailable"))
+	lm.RefreshSlots(nc)
+	assert.Equal(int64(1), lm.groups["percentage"].slots.Load(), "Should keep slots when counting fails")
+
+	status, err := lm.GetGroupStatus("fallback")
+	assert.NoError(err)
+	assert.Equal(1, status.Slots, "Should report the computed slots")
+}
+
+func TestUseNodeCounter(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		groups := Groups{
+			"default": GroupConfig{
+				MaxUnavailable: "10%",
+			},
+		}
+		lm := NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"default"}))
+		t.Cleanup(func() {
+			_ = lm.Close()
+		})
+
+		assert := assert.New(t)
+
+		nc := &fakeNodeCounter{count: 20}
+		lm.UseNodeCounter(nc)
+		assert.Equal(int64(2), lm.groups["default"].slots.Load(), "Should compute slots immediately")
+
+		nc.set(50, nil)
+		synctest.Sleep(slotRefreshInterval + time.Second)
+		assert.Equal(int64(5), lm.groups["default"].slots.Load(), "Should recompute slots periodically")
+	})
+}
+
+func TestNoStaleLockCheckWithoutMaxLockAge(t *testing.T) {
+	lm := NewManagerWithStorage(NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
+
+	assert.False(t, lm.staleLockCheckActive.Load(), "Should not start periodic check for stale locks")
+	assert.NoError(t, lm.Close())
+}
+
+func TestUpdateGroups(t *testing.T) {
+	lm := NewManagerWithStorage(NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
+	t.Cleanup(func() {
+		_ = lm.Close()
+	})
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ok, err := lm.Reserve("default", "node1")
+	require.NoError(err)
+	require.True(ok)
+	ok, err = lm.Reserve("default", "node2")
+	require.NoError(err)
+	assert.False(ok, "Should only have 1 slot")
+
+	oldLock := lm.groups["default"].RWLock
+
+	lm.UpdateGroups(Groups{
+		"default": GroupConfig{Slots: 2},
+		"workers": GroupConfig{Slots: 1, MaxLockAge: time.Hour},
+	})
+
+	assert.Same(oldLock, lm.groups["default"].RWLock, "Should keep the lock of existing groups")
+	assert.True(lm.staleLockCheckActive.Load(), "Should start the stale lock check for the new maxLockAge")
+
+	ok, err = lm.HasLock("default", "node1")
+	assert.NoError(err)
+	assert.True(ok, "Should keep existing locks")
+
+	ok, err = lm.Reserve("default", "node2")
+	assert.NoError(err)
+	assert.True(ok, "Should use the new number of slots")
+
+	ok, err = lm.Reserve("workers", "node3")
+	assert.NoError(err, "Should add the new group to the storage")
+	assert.True(ok, "Should reserve a slot in the new group")
+
+	lm.UpdateGroups(NewDefaultGroups())
+
+	_, err = lm.Reserve("workers", "node4")
+	assert.Equal(errors.NewErrorUnknownGroup("workers"), err, "Should remove the group")
+
+	status, err := lm.GetGroupStatus("default")
+	assert.NoError(err)
+	assert.Equal(1, status.Slots, "Should reduce the slots")
+	assert.Len(status.Locks, 2, "Should not release locks when reducing the slots")
+}
+
+func TestUpdateGroupsNodeCounter(t *testing.T) {
+	lm := NewManagerWithStorage(NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
+	t.Cleanup(func() {
+		_ = lm.Close()
+	})
+
+	lm.UseNodeCounter(&fakeNodeCounter{count: 20})
+	assert.False(t, lm.slotRefreshActive.Load(), "Should not refresh slots without maxUnavailable")
+
+	lm.UpdateGroups(Groups{
+		"default": GroupConfig{MaxUnavailable: "10%"},
+	})
+
+	assert.True(t, lm.slotRefreshActive.Load(), "Should start the slot refresh")
+	assert.Equal(t, int64(2), lm.groups["default"].slots.Load(), "Should compute slots immediately")
+}
+
+func TestUpdateGroupsKeepsComputedSlots(t *testing.T) {
+	groups := Groups{
+		"default": GroupConfig{MaxUnavailable: "20%"},
+		"workers": GroupConfig{MaxUnavailable: "20%"},
+	}
+	lm := NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"default", "workers"}))
+	t.Cleanup(func() {
+		_ = lm.Close()
+	})
+
+	nc := &fakeNodeCounter{count: 20}
+	lm.UseNodeCounter(nc)
+	require.Equal(t, int64(4), lm.groups["default"].slots.Load())
+
+	nc.set(0, fmt.Errorf("api unavailable"))
+	lm.UpdateGroups(Groups{
+		"default": GroupConfig{MaxUnavailable: "20%", LeaseDuration: time.Hour},
+		"workers": GroupConfig{MaxUnavailable: "20%", NodeSelector: "node-role.kubernetes.io/worker"},
+	})
+
+	assert.Equal(t, int64(4), lm.groups["default"].slots.Load(), "Should keep the computed slots when counting fails")
+	assert.Equal(t, int64(1), lm.groups["workers"].slots.Load(), "Should not keep the slots when the selector changed")
+}
+
+func TestUpdateGroupsAfterClose(t *testing.T) {
+	lm := NewManagerWithStorage(NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
+	require.NoError(t, lm.Close())
+
+	lm.UpdateGroups(Groups{
+		"default": GroupConfig{Slots: 1, MaxLockAge: time.Hour},
+	})
+
+	assert.False(t, lm.staleLockCheckActive.Load(), "Should not start background tasks after close")
+}
@@ -0,0 +1,123 @@
+package lockmanager
+
+import (
+	"strings"
+	"time"
+	// The container image does not contain the timezone database
+	_ "time/tzdata"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+)
+
+const maxWindowDuration = 7 * 24 * time.Hour
+
+// Restricts the times when new slots can be reserved in a group
+type Schedule struct {
+	// Name of the IANA timezone the windows are in, defaults to UTC
+	Timezone string              `yaml:"timezone,omitempty"`
+	Windows  []MaintenanceWindow `yaml:"windows"`
+}
+
+// A recurring window in which slots can be reserved
+type MaintenanceWindow struct {
+	// Weekdays on which the window starts, e.g. "Mon" or "Monday"
+	Days []string `yaml:"days"`
+	// Time of day when the window starts in the format "15:04"
+	Start    string        `yaml:"start"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+type parsedWindow struct {
+	days         [7]bool
+	hour, minute int
+	duration     time.Duration
+}
+
+// Ensure the schedule can be parsed
+func (s *Schedule) Validate() error {
+	_, _, err := s.parse()
+	return err
+}
+
+// Check if the given time is inside one of the maintenance windows.
+// Always true when no schedule is configured.
+func (s *Schedule) IsOpen(now time.Time) bool {
+	if s == nil {
+		return true
+	}
+
+	loc, windows, err := s.parse()
+	if err != nil {
+		return false
+	}
+
+	now = now.In(loc)
+	for _, w := range windows {
+		// Windows can cross midnight or last multiple days, so look back far enough to find the start
+		lookback := int(w.duration/(24*time.Hour)) + 1
+		for d := 0; d <= lookback; d++ {
+			day := now.AddDate(0, 0, -d)
+			if !w.days[day.Weekday()] {
+				continue
+			}
+			start := time.Date(day.Year(), day.Month(), day.Day(), w.hour, w.minute, 0, 0, loc)
+			if !now.Before(start) && now.Before(start.Add(w.duration)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Schedule) parse() (*time.Location, []parsedWindow, error) {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, nil, errors.NewErrorInvalidSchedule("unknown timezone \"" + s.Timezone + "\"")
+	}
+
+	if len(s.Windows) == 0 {
+		return nil, nil, errors.NewErrorInvalidSchedule("no windows defined")
+	}
+
+	windows := make([]parsedWindow, 0, len(s.Windows))
+	for _, w := range s.Windows {
+		var pw parsedWindow
+
+		if len(w.Days) == 0 {
+			return nil, nil, errors.NewErrorInvalidSchedule("window has no days")
+		}
+		for _, day := range w.Days {
+			weekday, ok := parseWeekday(day)
+			if !ok {
+				return nil, nil, errors.NewErrorInvalidSchedule("unknown weekday \"" + day + "\"")
+			}
+			pw.days[weekday] = true
+		}
+
+		start, err := time.Parse("15:04", w.Start)
+		if err != nil {
+			return nil, nil, errors.NewErrorInvalidSchedule("start \"" + w.Start + "\" is not in the format HH:MM")
+		}
+		pw.hour, pw.minute = start.Hour(), start.Minute()
+
+		if w.Duration <= 0 || w.Duration > maxWindowDuration {
+			return nil, nil, errors.NewErrorInvalidSchedule("duration needs to be between 0 and 168h")
+		}
+		pw.duration = w.Duration
+
+		windows = append(windows, pw)
+	}
+
+	return loc, windows, nil
+}
+
+func parseWeekday(day string) (time.Weekday, bool) {
+	day = strings.ToLower(day)
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := strings.ToLower(weekday.String())
+		if day == name || day == name[:3] {
+			return weekday, true
+		}
+	}
+	return 0, false
+}
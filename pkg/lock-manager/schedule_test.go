@@ -0,0 +1,159 @@
+package lockmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleValidate(t *testing.T) {
+	tMatrix := []struct {
+		Name     string
+		Schedule Schedule
+		Result   error
+	}{
+		{
+			Name: "Valid",
+			Schedule: Schedule{
+				Timezone: "Europe/Berlin",
+				Windows: []MaintenanceWindow{
+					{
+						Days:     []string{"Mon", "tuesday", "SAT"},
+						Start:    "22:30",
+						Duration: 4 * time.Hour,
+					},
+				},
+			},
+		},
+		{
+			Name: "UnknownTimezone",
+			Schedule: Schedule{
+				Timezone: "Not/A/Timezone",
+				Windows: []MaintenanceWindow{
+					{
+						Days:     []string{"Mon"},
+						Start:    "22:00",
+						Duration: time.Hour,
+					},
+				},
+			},
+			Result: errors.NewErrorInvalidSchedule("unknown timezone \"Not/A/Timezone\""),
+		},
+		{
+			Name:     "NoWindows",
+			Schedule: Schedule{},
+			Result:   errors.NewErrorInvalidSchedule("no windows defined"),
+		},
+		{
+			Name: "NoDays",
+			Schedule: Schedule{
+				Windows: []MaintenanceWindow{
+					{
+						Start:    "22:00",
+						Duration: time.Hour,
+					},
+				},
+			},
+			Result: errors.NewErrorInvalidSchedule("window has no days"),
+		},
+		{
+			Name: "UnknownWeekday",
+			Schedule: Schedule{
+				Windows: []MaintenanceWindow{
+					{
+						Days:     []string{"Funday"},
+						Start:    "22:00",
+						Duration: time.Hour,
+					},
+				},
+			},
+			Result: errors.NewErrorInvalidSchedule("unknown weekday \"Funday\""),
+		},
+		{
+			Name: "InvalidStart",
+			Schedule: Schedule{
+				Windows: []MaintenanceWindow{
+					{
+						Days:     []string{"Mon"},
+						Start:    "25:00",
+						Duration: time.Hour,
+					},
+				},
+			},
+			Result: errors.NewErrorInvalidSchedule("start \"25:00\" is not in the format HH:MM"),
+		},
+		{
+			Name: "InvalidDuration",
+			Schedule: Schedule{
+				Windows: []MaintenanceWindow{
+					{
+						Days:  []string{"Mon"},
+						Start: "22:00",
+					},
+				},
+			},
+			Result: errors.NewErrorInvalidSchedule("duration needs to be between 0 and 168h"),
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert.Equal(t, tCase.Result, tCase.Schedule.Validate())
+		})
+	}
+}
+
+func TestScheduleIsOpen(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("Failed to load timezone: %v", err)
+	}
+
+	s := &Schedule{
+		Timezone: "Europe/Berlin",
+		Windows: []MaintenanceWindow{
+			{
+				// Crosses midnight
+				Days:     []string{"Mon", "Wed"},
+				Start:    "22:00",
+				Duration: 4 * time.Hour,
+			},
+			{
+				// Spans the whole weekend
+				Days:     []string{"Sat"},
+				Start:    "00:00",
+				Duration: 48 * time.Hour,
+			},
+		},
+	}
+
+	// 2026-10-12 is a Monday
+	tMatrix := []struct {
+		Name   string
+		Time   time.Time
+		Result bool
+	}{
+		{"BeforeWindow", time.Date(2026, 10, 12, 21, 59, 0, 0, berlin), false},
+		{"StartOfWindow", time.Date(2026, 10, 12, 22, 0, 0, 0, berlin), true},
+		{"AfterMidnight", time.Date(2026, 10, 13, 1, 30, 0, 0, berlin), true},
+		{"EndOfWindow", time.Date(2026, 10, 13, 2, 0, 0, 0, berlin), false},
+		{"WrongDay", time.Date(2026, 10, 13, 23, 0, 0, 0, berlin), false},
+		{"SecondDay", time.Date(2026, 10, 14, 23, 0, 0, 0, berlin), true},
+		{"OtherTimezone", time.Date(2026, 10, 12, 20, 30, 0, 0, time.UTC), true},
+		{"Weekend", time.Date(2026, 10, 18, 12, 0, 0, 0, berlin), true},
+		{"AfterWeekend", time.Date(2026, 10, 19, 0, 0, 0, 0, berlin), false},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert.Equal(t, tCase.Result, s.IsOpen(tCase.Time))
+		})
+	}
+
+	t.Run("NoSchedule", func(t *testing.T) {
+		var s *Schedule
+		assert.True(t, s.IsOpen(time.Now()), "Should always be open without schedule")
+	})
+}
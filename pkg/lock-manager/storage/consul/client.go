@@ -0,0 +1,235 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const timeout = time.Second
+
+// Minimal client for the parts of the consul http api used by the backend
+type client struct {
+	address *url.URL
+	token   string
+	http    *http.Client
+}
+
+// Entry of the key-value store as returned by the api
+type kvPair struct {
+	Key         string `json:"Key"`
+	Value       []byte `json:"Value"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+	// Session holding the lock on the key, empty if not locked
+	Session string `json:"Session,omitempty"`
+}
+
+type txnOp struct {
+	KV txnKVOp `json:"KV"`
+}
+
+type txnKVOp struct {
+	Verb    string `json:"Verb"`
+	Key     string `json:"Key"`
+	Value   []byte `json:"Value,omitempty"`
+	Index   uint64 `json:"Index,omitzero"`
+	Session string `json:"Session,omitempty"`
+}
+
+type sessionRequest struct {
+	Name      string `json:"Name"`
+	TTL       string `json:"TTL"`
+	Behavior  string `json:"Behavior"`
+	LockDelay string `json:"LockDelay"`
+}
+
+type sessionResponse struct {
+	ID  string `json:"ID"`
+	TTL string `json:"TTL,omitempty"`
+}
+
+func newClient(cfg ConsulConfig) (*client, error) {
+	address, err := cfg.address()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &client{
+		address: address,
+		token:   cfg.Token,
+		http: &http.Client{
+			Transport: transport,
+		},
+	}, nil
+}
+
+// Return the key, nil if it does not exist
+func (c *client) getKV(key string) (*kvPair, error) {
+	var pairs []kvPair
+	found, err := c.do(http.MethodGet, "/v1/kv/"+key, nil, nil, &pairs)
+	if err != nil || !found || len(pairs) == 0 {
+		return nil, err
+	}
+	return &pairs[0], nil
+}
+
+// Return all keys with the given prefix, including their values
+func (c *client) listKV(prefix string) ([]kvPair, error) {
+	var pairs []kvPair
+	_, err := c.do(http.MethodGet, "/v1/kv/"+prefix, url.Values{"recurse": {"true"}}, nil, &pairs)
+	return pairs, err
+}
+
+// Return the names of all keys with the given prefix, sorted alphabetically
+func (c *client) listKeys(prefix string) ([]string, error) {
+	var keys []string
+	_, err := c.do(http.MethodGet, "/v1/kv/"+prefix, url.Values{"keys": {"true"}}, nil, &keys)
+	return keys, err
+}
+
+func (c *client) putKV(key string, value []byte) error {
+	_, err := c.do(http.MethodPut, "/v1/kv/"+key, nil, value, nil)
+	return err
+}
+
+func (c *client) deleteKV(key string) error {
+	_, err := c.do(http.MethodDelete, "/v1/kv/"+key, nil, nil, nil)
+	return err
+}
+
+// Execute the operations atomically, returns false if one of them failed and the transaction was rolled back
+func (c *client) txn(ops []txnOp) (bool, error) {
+	body, err := json.Marshal(ops)
+	if err != nil {
+		return false, err
+	}
+	status, data, err := c.request(http.MethodPut, "/v1/txn", nil, body)
+	if err != nil {
+		return false, err
+	}
+	switch status {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusConflict:
+		return false, nil
+	default:
+		return false, newStatusError(status, data)
+	}
+}
+
+// Create a session that deletes the keys locked by it, when it is not renewed within the ttl
+func (c *client) createSession(ttl time.Duration) (string, error) {
+	body, err := json.Marshal(sessionRequest{
+		Name:     "fleetlock",
+		TTL:      strconv.FormatInt(int64(ttl.Seconds()), 10) + "s",
+		Behavior: "delete",
+		// Otherwise released slots can't be reserved again for 15s
+		LockDelay: "0s",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var res sessionResponse
+	_, err = c.do(http.MethodPut, "/v1/session/create", nil, body, &res)
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+	return res.ID, nil
+}
+
+// Reset the ttl of the session and return it, does nothing and returns 0 if the session does not exist anymore
+func (c *client) renewSession(id string) (time.Duration, error) {
+	var res []sessionResponse
+	found, err := c.do(http.MethodPut, "/v1/session/renew/"+id, nil, nil, &res)
+	if err != nil || !found || len(res) == 0 {
+		return 0, err
+	}
+	ttl, err := time.ParseDuration(res[0].TTL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse ttl of session: %w", err)
+	}
+	return ttl, nil
+}
+
+func (c *client) destroySession(id string) error {
+	_, err := c.do(http.MethodPut, "/v1/session/destroy/"+id, nil, nil, nil)
+	return err
+}
+
+// Return the address of the raft leader of the consul cluster, empty if there is none
+func (c *client) leader() (string, error) {
+	var leader string
+	_, err := c.do(http.MethodGet, "/v1/status/leader", nil, nil, &leader)
+	return leader, err
+}
+
+// Send the request and decode the response into out, if not nil.
+// Returns false if the api responded with not found.
+func (c *client) do(method, path string, query url.Values, body []byte, out any) (bool, error) {
+	status, data, err := c.request(method, path, query, body)
+	if err != nil {
+		return false, err
+	}
+	if status == http.StatusNotFound {
+		return false, nil
+	}
+	if status != http.StatusOK {
+		return false, newStatusError(status, data)
+	}
+
+	if out != nil {
+		err = json.Unmarshal(data, out)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse response of %s: %w", path, err)
+		}
+	}
+	return true, nil
+}
+
+func (c *client) request(method, path string, query url.Values, body []byte) (int, []byte, error) {
+	u := *c.address
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response of %s: %w", path, err)
+	}
+	return res.StatusCode, data, nil
+}
+
+func newStatusError(status int, body []byte) error {
+	return fmt.Errorf("consul responded with status %d: %s", status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,69 @@
+package consul
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+)
+
+const defaultAddress = "http://localhost:8500"
+
+type ConsulConfig struct {
+	// Address of the consul agent, e.g. https://localhost:8501
+	Address string `yaml:"address,omitempty"`
+	// (Optional) ACL token used for all requests
+	Token string `yaml:"token,omitempty"`
+	// (Optional) CA certificate to verify the agent, uses the system pool when empty
+	CAFile string `yaml:"ca,omitempty"`
+	// (Optional) Client certificate for authentication
+	CertFile string `yaml:"cert,omitempty"`
+	// (Optional) Private key of client certificate for authentication
+	KeyFile string `yaml:"key,omitempty"`
+}
+
+// Parse the address of the agent, only http and https are supported
+func (c ConsulConfig) address() (*url.URL, error) {
+	address := c.Address
+	if address == "" {
+		address = defaultAddress
+	}
+
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.NewErrorConsulInvalidAddress(address)
+	}
+	return u, nil
+}
+
+// Create the tls configuration for connecting to the agent, returns nil when nothing is configured
+func (c ConsulConfig) tlsConfig() (*tls.Config, error) {
+	if c.CAFile == "" && c.CertFile == "" && c.KeyFile == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if c.CAFile != "" {
+		ca, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("failed to parse CA certificate %s", c.CAFile)
+		}
+	}
+	if c.CertFile != "" && c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,59 @@
+package consul
+
+import (
+	"testing"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddress(t *testing.T) {
+	tMatrix := []struct {
+		Name    string
+		Address string
+		Result  string
+		Error   error
+	}{
+		{
+			Name:   "Default",
+			Result: "http://localhost:8500",
+		},
+		{
+			Name:    "HTTPS",
+			Address: "https://consul.example.org:8501",
+			Result:  "https://consul.example.org:8501",
+		},
+		{
+			Name:    "MissingScheme",
+			Address: "localhost:8500",
+			Error:   errors.NewErrorConsulInvalidAddress("localhost:8500"),
+		},
+		{
+			Name:    "UnsupportedScheme",
+			Address: "unix:///var/run/consul.sock",
+			Error:   errors.NewErrorConsulInvalidAddress("unix:///var/run/consul.sock"),
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			u, err := ConsulConfig{Address: tCase.Address}.address()
+
+			assert.Equal(tCase.Error, err)
+			if tCase.Error == nil {
+				assert.Equal(tCase.Result, u.String())
+			}
+		})
+	}
+}
+
+func TestSessionTTL(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(minSessionTTL, sessionTTL(2*time.Second), "Should use the minimum of consul")
+	assert.Equal(31*time.Second, sessionTTL(30*time.Second+time.Millisecond), "Should round up to full seconds")
+	assert.Equal(time.Hour, sessionTTL(time.Hour), "Should keep full seconds")
+}
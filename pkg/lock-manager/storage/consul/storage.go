@@ -0,0 +1,387 @@
+package consul
+
+import (
+	"encoding/json/v2"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+)
+
+const (
+	keyprefix   = "com.github.heathcliff26.fleetlock/group/"
+	groupformat = keyprefix + "%s/"
+	keyformat   = groupformat + "id/%s"
+	// Written by every reservation that counts the slots, so concurrent reservations conflict
+	guardformat = groupformat + "guard"
+
+	// Events are sorted by the zero padded timestamp in the key
+	eventprefix = "com.github.heathcliff26.fleetlock/events/"
+	eventformat = eventprefix + "%020d/%s/%s"
+)
+
+// Only the newest events are kept, to limit the size of the database
+const maxEvents = 10000
+
+// How often reserving is retried when the group is changed by another server in the meantime
+const maxReserveAttempts = 5
+
+// Consul rejects sessions with a shorter ttl
+const minSessionTTL = 10 * time.Second
+
+// Default limit of operations in a single transaction
+const maxTxnOps = 64
+
+type ConsulBackend struct {
+	client *client
+}
+
+func NewConsulBackend(cfg ConsulConfig) (*ConsulBackend, error) {
+	c, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &ConsulBackend{
+		client: c,
+	}
+	err = b.Ping()
+	if err != nil {
+		return nil, fmt.Errorf("consul client failed connection check: %w", err)
+	}
+	return b, nil
+}
+
+// Reserve a lock for the given group.
+// Returns true if the lock is successfully reserved, even if the lock is already held by the specific id
+func (c *ConsulBackend) Reserve(group, id, owner string, ttl time.Duration) error {
+	key := fmt.Sprintf(keyformat, group, id)
+
+	session, err := c.newSession(ttl)
+	if err != nil {
+		return err
+	}
+
+	created, err := c.client.txn([]txnOp{
+		{KV: txnKVOp{Verb: "check-not-exists", Key: key}},
+		lockOp(key, owner, session),
+	})
+	return c.cleanupSession(session, created, err)
+}
+
+// Reserve a lock for the given group, if the group holds less than the given number of locks.
+// Returns true if the lock is reserved, even if it was already held by the id, and false when all slots are taken.
+// The lock is only created if no other reservation changed the guard of the group since counting the locks.
+func (c *ConsulBackend) TryReserve(group, id, owner string, slots int, ttl time.Duration) (bool, error) {
+	session, err := c.newSession(ttl)
+	if err != nil {
+		return false, err
+	}
+
+	reserved, created, err := c.tryReserve(group, id, owner, slots, session)
+	err = c.cleanupSession(session, created, err)
+	return reserved && err == nil, err
+}
+
+// Create the lock if there are free slots, retries when the group has been changed concurrently.
+// Returns if the lock is held and if it has been created by this call.
+func (c *ConsulBackend) tryReserve(group, id, owner string, slots int, session string) (bool, bool, error) {
+	key := fmt.Sprintf(keyformat, group, id)
+	prefix := fmt.Sprintf(keyformat, group, "")
+	guard := fmt.Sprintf(guardformat, group)
+
+	for range maxReserveAttempts {
+		// Reading the guard together with the locks ensures its index matches the counted locks
+		pairs, err := c.client.listKV(fmt.Sprintf(groupformat, group))
+		if err != nil {
+			return false, false, err
+		}
+
+		// Index 0 only succeeds if the guard does not exist yet
+		var guardIndex uint64
+		count := 0
+		for _, kv := range pairs {
+			switch {
+			case kv.Key == key:
+				return true, false, nil
+			case kv.Key == guard:
+				guardIndex = kv.ModifyIndex
+			case strings.HasPrefix(kv.Key, prefix):
+				count++
+			}
+		}
+		if count >= slots {
+			return false, false, nil
+		}
+
+		ok, err := c.client.txn([]txnOp{
+			{KV: txnKVOp{Verb: "cas", Key: guard, Index: guardIndex}},
+			{KV: txnKVOp{Verb: "check-not-exists", Key: key}},
+			lockOp(key, owner, session),
+		})
+		if err != nil {
+			return false, false, err
+		}
+		if ok {
+			return true, true, nil
+		}
+	}
+	return false, false, errors.NewErrorReserveConflict(group)
+}
+
+// Extend the lock held by the id, so that it expires ttl from now.
+// Does not fail when no lock is held.
+func (c *ConsulBackend) Renew(group string, id string, ttl time.Duration) error {
+	key := fmt.Sprintf(keyformat, group, id)
+
+	kv, err := c.client.getKV(key)
+	if err != nil || kv == nil {
+		return err
+	}
+
+	if kv.Session != "" {
+		current, err := c.client.renewSession(kv.Session)
+		if err != nil || ttl <= 0 || current == sessionTTL(ttl) {
+			return err
+		}
+	} else if ttl <= 0 {
+		return nil
+	}
+
+	// The lock was created without session or the ttl changed, so attach a new session
+	session, err := c.newSession(ttl)
+	if err != nil {
+		return err
+	}
+	ops := []txnOp{{KV: txnKVOp{Verb: "check-index", Key: key, Index: kv.ModifyIndex}}}
+	if kv.Session != "" {
+		ops = append(ops, txnOp{KV: txnKVOp{Verb: "unlock", Key: key, Value: kv.Value, Session: kv.Session}})
+	}
+	ops = append(ops, txnOp{KV: txnKVOp{Verb: "lock", Key: key, Value: kv.Value, Session: session}})
+	locked, err := c.client.txn(ops)
+	err = c.cleanupSession(session, locked, err)
+	if err != nil || !locked || kv.Session == "" {
+		return err
+	}
+
+	// The old session does not hold the lock anymore, so destroying it does not delete the key
+	err = c.client.destroySession(kv.Session)
+	if err != nil {
+		return fmt.Errorf("failed to destroy replaced session: %w", err)
+	}
+	return nil
+}
+
+// Returns the current number of locks for the given group
+func (c *ConsulBackend) GetLocks(group string) (int, error) {
+	keys, err := c.client.listKeys(fmt.Sprintf(keyformat, group, ""))
+	if err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// Release the lock currently held by the id.
+// Does not fail when no lock is held.
+func (c *ConsulBackend) Release(group string, id string) error {
+	key := fmt.Sprintf(keyformat, group, id)
+
+	kv, err := c.client.getKV(key)
+	if err != nil || kv == nil {
+		return err
+	}
+
+	err = c.client.deleteKV(key)
+	if err != nil {
+		return err
+	}
+	if kv.Session != "" {
+		err = c.client.destroySession(kv.Session)
+		if err != nil {
+			return fmt.Errorf("failed to destroy session of released lock: %w", err)
+		}
+	}
+	return nil
+}
+
+// Return all locks older than x
+func (c *ConsulBackend) GetStaleLocks(ts time.Duration) ([]types.Lock, error) {
+	pairs, err := c.client.listKV(keyprefix)
+	if err != nil {
+		return nil, err
+	}
+
+	locks, err := parseLocks(pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Lock, 0)
+	for _, lock := range locks {
+		if time.Since(lock.Created) > ts {
+			result = append(result, lock)
+		}
+	}
+	return result, nil
+}
+
+// Return all locks currently held in the given group
+func (c *ConsulBackend) ListLocks(group string) ([]types.Lock, error) {
+	pairs, err := c.client.listKV(fmt.Sprintf(keyformat, group, ""))
+	if err != nil {
+		return nil, err
+	}
+	return parseLocks(pairs)
+}
+
+// Check if a given id already has a lock for this group
+func (c *ConsulBackend) HasLock(group string, id string) (bool, error) {
+	kv, err := c.client.getKV(fmt.Sprintf(keyformat, group, id))
+	if err != nil {
+		return false, err
+	}
+	return kv != nil, nil
+}
+
+// Persist the audit event, only the newest events are kept
+func (c *ConsulBackend) RecordEvent(event types.Event) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	err = c.client.putKV(fmt.Sprintf(eventformat, event.Time.UnixNano(), event.Type, event.ID), b)
+	if err != nil {
+		return err
+	}
+
+	keys, err := c.client.listKeys(eventprefix)
+	if err != nil {
+		return fmt.Errorf("failed to get old events: %w", err)
+	}
+	if len(keys) <= maxEvents {
+		return nil
+	}
+
+	// Consul can't delete a range of keys, so the oldest events are deleted in batches
+	old := keys[:len(keys)-maxEvents]
+	for len(old) > 0 {
+		n := min(len(old), maxTxnOps)
+		ops := make([]txnOp, 0, n)
+		for _, key := range old[:n] {
+			ops = append(ops, txnOp{KV: txnKVOp{Verb: "delete", Key: key}})
+		}
+		_, err = c.client.txn(ops)
+		if err != nil {
+			return fmt.Errorf("failed to delete old events: %w", err)
+		}
+		old = old[n:]
+	}
+	return nil
+}
+
+// Return the audit events matching the filter, sorted from oldest to newest
+func (c *ConsulBackend) QueryEvents(filter types.EventFilter) ([]types.Event, error) {
+	pairs, err := c.client.listKV(eventprefix)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Event, 0, len(pairs))
+	for _, kv := range pairs {
+		var event types.Event
+		err = json.Unmarshal(kv.Value, &event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse event \"%s\": %w", kv.Key, err)
+		}
+		if filter.Matches(event) {
+			result = append(result, event)
+		}
+	}
+	return filter.Truncate(result), nil
+}
+
+// Check if the consul agent is reachable and the cluster has a leader
+func (c *ConsulBackend) Ping() error {
+	leader, err := c.client.leader()
+	if err != nil {
+		return err
+	}
+	if leader == "" {
+		return errors.NewErrorConsulNoLeader()
+	}
+	return nil
+}
+
+// Calls all necessary finalization if necessary
+func (c *ConsulBackend) Close() error {
+	c.client.http.CloseIdleConnections()
+	return nil
+}
+
+// Create a session for a lock with the given ttl, returns an empty id when the lock does not expire
+func (c *ConsulBackend) newSession(ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		return "", nil
+	}
+	return c.client.createSession(sessionTTL(ttl))
+}
+
+// Destroy the session if it is not used by a lock
+func (c *ConsulBackend) cleanupSession(session string, used bool, err error) error {
+	if session == "" || (used && err == nil) {
+		return err
+	}
+	destroyErr := c.client.destroySession(session)
+	if err == nil && destroyErr != nil {
+		err = fmt.Errorf("failed to destroy unused session: %w", destroyErr)
+	}
+	return err
+}
+
+// Create the operation writing the lock, locked by the session when not empty
+func lockOp(key, owner, session string) txnOp {
+	op := txnKVOp{
+		Verb:  "set",
+		Key:   key,
+		Value: []byte(types.FormatLockValue(time.Now(), owner)),
+	}
+	if session != "" {
+		op.Verb = "lock"
+		op.Session = session
+	}
+	return txnOp{KV: op}
+}
+
+// Round the ttl up to full seconds and the minimum supported by consul
+func sessionTTL(ttl time.Duration) time.Duration {
+	ttl = time.Duration(math.Ceil(ttl.Seconds())) * time.Second
+	return max(ttl, minSessionTTL)
+}
+
+// Convert the given key-value pairs into locks
+func parseLocks(pairs []kvPair) ([]types.Lock, error) {
+	result := make([]types.Lock, 0, len(pairs))
+	for _, kv := range pairs {
+		// Group names are validated to not contain "/", so the first match is always the separator
+		group, id, ok := strings.Cut(strings.TrimPrefix(kv.Key, keyprefix), "/id/")
+		if !ok {
+			continue
+		}
+
+		created, owner, err := types.ParseLockValue(string(kv.Value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse lock \"%s\": %w", kv.Key, err)
+		}
+		result = append(result, types.Lock{
+			Group:   group,
+			ID:      id,
+			Created: created,
+			Owner:   owner,
+		})
+	}
+	return result, nil
+}
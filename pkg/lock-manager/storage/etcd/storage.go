@@ -3,18 +3,36 @@ package etcd
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json/v2"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+	"go.etcd.io/etcd/api/v3/mvccpb"
 	"go.etcd.io/etcd/client/pkg/v3/transport"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
-const keyformat = "com.github.heathcliff26.fleetlock/group/%s/id/%s"
+const (
+	keyprefix = "com.github.heathcliff26.fleetlock/group/"
+	keyformat = keyprefix + "%s/id/%s"
+
+	// Events are sorted by the zero padded timestamp in the key
+	eventprefix = "com.github.heathcliff26.fleetlock/events/"
+	eventformat = eventprefix + "%020d/%s/%s"
+)
+
+// Only the newest events are kept, to limit the size of the database
+const maxEvents = 10000
 
 const timeout = 200 * time.Millisecond
 
+// How often reserving is retried when the group is changed by another server in the meantime
+const maxReserveAttempts = 5
+
 type EtcdBackend struct {
 	client *clientv3.Client
 }
@@ -64,24 +82,143 @@ func NewEtcdBackend(cfg EtcdConfig) (*EtcdBackend, error) {
 
 // Reserve a lock for the given group.
 // Returns true if the lock is successfully reserved, even if the lock is already held by the specific id
-func (e *EtcdBackend) Reserve(group string, id string) error {
+func (e *EtcdBackend) Reserve(group, id, owner string, ttl time.Duration) error {
 	key := fmt.Sprintf(keyformat, group, id)
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	_, err := e.client.Txn(ctx).If(
+	var opts []clientv3.OpOption
+	var leaseID clientv3.LeaseID
+	if ttl > 0 {
+		lease, err := e.client.Grant(ctx, ttlSeconds(ttl))
+		if err != nil {
+			return fmt.Errorf("failed to create lease: %w", err)
+		}
+		leaseID = lease.ID
+		opts = append(opts, clientv3.WithLease(leaseID))
+	}
+
+	res, err := e.client.Txn(ctx).If(
 		clientv3.Compare(clientv3.Version(key), "=", 0),
 	).Then(
-		clientv3.OpPut(key, time.Now().String()),
+		clientv3.OpPut(key, types.FormatLockValue(time.Now(), owner), opts...),
 	).Commit()
 
 	if err != nil {
 		return err
 	}
 
+	// The lock already existed, so the lease is not needed
+	if !res.Succeeded && leaseID != clientv3.NoLease {
+		_, err = e.client.Revoke(ctx, leaseID)
+		if err != nil {
+			return fmt.Errorf("failed to revoke unused lease: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// Reserve a lock for the given group, if the group holds less than the given number of locks.
+// Returns true if the lock is reserved, even if it was already held by the id, and false when all slots are taken.
+// The lock is only created if no lock of the group has been created or changed since counting them.
+func (e *EtcdBackend) TryReserve(group, id, owner string, slots int, ttl time.Duration) (bool, error) {
+	key := fmt.Sprintf(keyformat, group, id)
+	prefix := fmt.Sprintf(keyformat, group, "")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var opts []clientv3.OpOption
+	leaseID := clientv3.NoLease
+	if ttl > 0 {
+		lease, err := e.client.Grant(ctx, ttlSeconds(ttl))
+		if err != nil {
+			return false, fmt.Errorf("failed to create lease: %w", err)
+		}
+		leaseID = lease.ID
+		opts = append(opts, clientv3.WithLease(leaseID))
+	}
+
+	reserved, created, err := e.tryReserve(ctx, group, key, prefix, owner, slots, opts)
+	if !created && leaseID != clientv3.NoLease {
+		// Use a new context, as the old one might have expired
+		revokeCtx, revokeCancel := context.WithTimeout(context.Background(), timeout)
+		defer revokeCancel()
+		_, revokeErr := e.client.Revoke(revokeCtx, leaseID)
+		if err == nil && revokeErr != nil {
+			err = fmt.Errorf("failed to revoke unused lease: %w", revokeErr)
+		}
+	}
+	return reserved && err == nil, err
+}
+
+// Create the lock if there are free slots, retries when the group has been changed concurrently.
+// Returns if the lock is held and if it has been created by this call.
+func (e *EtcdBackend) tryReserve(ctx context.Context, group, key, prefix, owner string, slots int, opts []clientv3.OpOption) (bool, bool, error) {
+	for range maxReserveAttempts {
+		res, err := e.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+		if err != nil {
+			return false, false, err
+		}
+		for _, kv := range res.Kvs {
+			if string(kv.Key) == key {
+				return true, false, nil
+			}
+		}
+		if len(res.Kvs) >= slots {
+			return false, false, nil
+		}
+
+		// Deleted locks only free slots, so it is enough to check that no lock has been created or modified
+		txn, err := e.client.Txn(ctx).If(
+			clientv3.Compare(clientv3.ModRevision(prefix), "<", res.Header.Revision+1).WithPrefix(),
+		).Then(
+			clientv3.OpPut(key, types.FormatLockValue(time.Now(), owner), opts...),
+		).Commit()
+		if err != nil {
+			return false, false, err
+		}
+		if txn.Succeeded {
+			return true, true, nil
+		}
+	}
+	return false, false, errors.NewErrorReserveConflict(group)
+}
+
+// Extend the lock held by the id, so that it expires ttl from now.
+// Does not fail when no lock is held.
+func (e *EtcdBackend) Renew(group string, id string, ttl time.Duration) error {
+	key := fmt.Sprintf(keyformat, group, id)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	res, err := e.client.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if len(res.Kvs) == 0 {
+		return nil
+	}
+
+	leaseID := clientv3.LeaseID(res.Kvs[0].Lease)
+	if leaseID != clientv3.NoLease {
+		_, err = e.client.KeepAliveOnce(ctx, leaseID)
+		return err
+	}
+
+	// The lock was created without lease, so attach a new one
+	lease, err := e.client.Grant(ctx, ttlSeconds(ttl))
+	if err != nil {
+		return fmt.Errorf("failed to create lease: %w", err)
+	}
+	_, err = e.client.Txn(ctx).If(
+		clientv3.Compare(clientv3.ModRevision(key), "=", res.Kvs[0].ModRevision),
+	).Then(
+		clientv3.OpPut(key, "", clientv3.WithIgnoreValue(), clientv3.WithLease(lease.ID)),
+	).Commit()
+	return err
+}
+
 // Returns the current number of locks for the given group
 func (e *EtcdBackend) GetLocks(group string) (int, error) {
 	key := fmt.Sprintf(keyformat, group, "")
@@ -108,7 +245,39 @@ func (e *EtcdBackend) Release(group string, id string) error {
 
 // Return all locks older than x
 func (e *EtcdBackend) GetStaleLocks(ts time.Duration) ([]types.Lock, error) {
-	panic("not implemented") // TODO: Implement
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	res, err := e.client.Get(ctx, keyprefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	locks, err := parseLocks(res.Kvs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Lock, 0)
+	for _, lock := range locks {
+		if time.Since(lock.Created) > ts {
+			result = append(result, lock)
+		}
+	}
+	return result, nil
+}
+
+// Return all locks currently held in the given group
+func (e *EtcdBackend) ListLocks(group string) ([]types.Lock, error) {
+	key := fmt.Sprintf(keyformat, group, "")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	res, err := e.client.Get(ctx, key, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	return parseLocks(res.Kvs)
 }
 
 // Check if a given id already has a lock for this group
@@ -124,7 +293,110 @@ func (e *EtcdBackend) HasLock(group string, id string) (bool, error) {
 	return res.Count == 1, nil
 }
 
+// Persist the audit event, only the newest events are kept
+func (e *EtcdBackend) RecordEvent(event types.Event) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf(eventformat, event.Time.UnixNano(), event.Type, event.ID)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err = e.client.Put(ctx, key, string(b))
+	if err != nil {
+		return err
+	}
+
+	res, err := e.client.Get(ctx, eventprefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if err != nil {
+		return fmt.Errorf("failed to count events: %w", err)
+	}
+	if res.Count <= maxEvents {
+		return nil
+	}
+
+	res, err = e.client.Get(ctx, eventprefix, clientv3.WithPrefix(), clientv3.WithKeysOnly(), clientv3.WithLimit(res.Count-maxEvents))
+	if err != nil {
+		return fmt.Errorf("failed to get old events: %w", err)
+	}
+	if len(res.Kvs) == 0 {
+		return nil
+	}
+	_, err = e.client.Delete(ctx, eventprefix, clientv3.WithRange(string(res.Kvs[len(res.Kvs)-1].Key)+"\x00"))
+	if err != nil {
+		return fmt.Errorf("failed to delete old events: %w", err)
+	}
+	return nil
+}
+
+// Return the audit events matching the filter, sorted from oldest to newest
+func (e *EtcdBackend) QueryEvents(filter types.EventFilter) ([]types.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := eventprefix
+	if !filter.Since.IsZero() {
+		start = fmt.Sprintf(eventformat, filter.Since.UnixNano(), "", "")
+	}
+	res, err := e.client.Get(ctx, start, clientv3.WithRange(clientv3.GetPrefixRangeEnd(eventprefix)))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Event, 0, len(res.Kvs))
+	for _, kv := range res.Kvs {
+		var event types.Event
+		err = json.Unmarshal(kv.Value, &event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse event \"%s\": %w", string(kv.Key), err)
+		}
+		if filter.Matches(event) {
+			result = append(result, event)
+		}
+	}
+	return filter.Truncate(result), nil
+}
+
+// Check if the etcd cluster is reachable
+func (e *EtcdBackend) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := e.client.MemberList(ctx)
+	return err
+}
+
 // Calls all necessary finalization if necessary
 func (e *EtcdBackend) Close() error {
 	return e.client.Close()
 }
+
+// Convert the ttl into seconds, as etcd leases do not support a smaller granularity
+func ttlSeconds(ttl time.Duration) int64 {
+	return int64(math.Ceil(ttl.Seconds()))
+}
+
+// Convert the given key-value pairs into locks
+func parseLocks(kvs []*mvccpb.KeyValue) ([]types.Lock, error) {
+	result := make([]types.Lock, 0, len(kvs))
+	for _, kv := range kvs {
+		// Group names are validated to not contain "/", so the first match is always the separator
+		group, id, ok := strings.Cut(strings.TrimPrefix(string(kv.Key), keyprefix), "/id/")
+		if !ok {
+			continue
+		}
+
+		created, owner, err := types.ParseLockValue(string(kv.Value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse lock \"%s\": %w", string(kv.Key), err)
+		}
+		result = append(result, types.Lock{
+			Group:   group,
+			ID:      id,
+			Created: created,
+			Owner:   owner,
+		})
+	}
+	return result, nil
+}
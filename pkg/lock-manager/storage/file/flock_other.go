@@ -0,0 +1,17 @@
+//go:build !unix
+
+package file
+
+import (
+	"os"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+)
+
+func flock(_ *os.File) error {
+	return errors.NewErrorFileLockingNotSupported()
+}
+
+func funlock(_ *os.File) error {
+	return errors.NewErrorFileLockingNotSupported()
+}
@@ -0,0 +1,88 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json/v2"
+	"fmt"
+	"time"
+)
+
+// Version of the file format, written in the first line of the file
+const formatVersion = 1
+
+// The file starts with a header, followed by one lock per line.
+// Keeping a lock per line means a truncated file only loses the locks after the damaged line.
+type header struct {
+	Version int `json:"version"`
+}
+
+type lock struct {
+	Group   string    `json:"group"`
+	ID      string    `json:"id"`
+	Owner   string    `json:"owner,omitempty"`
+	Created time.Time `json:"created"`
+	// Zero if the lock does not expire
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+func encode(locks []lock) ([]byte, error) {
+	var buf bytes.Buffer
+
+	err := writeLine(&buf, header{Version: formatVersion})
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range locks {
+		err = writeLine(&buf, l)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func writeLine(buf *bytes.Buffer, v any) error {
+	err := json.MarshalWrite(buf, v)
+	if err != nil {
+		return err
+	}
+	return buf.WriteByte('\n')
+}
+
+// Parse the content of the file.
+// Lines that can't be parsed are skipped, damaged is true if that happened.
+// Only fails if the file was written by a newer version.
+func decode(data []byte) (locks []lock, damaged bool, err error) {
+	if len(data) == 0 {
+		return nil, false, nil
+	}
+
+	lines := bytes.Split(data, []byte{'\n'})
+	// A complete file always ends with a newline
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	} else {
+		damaged = true
+	}
+
+	var h header
+	if json.Unmarshal(lines[0], &h) != nil || h.Version < 1 {
+		damaged = true
+	} else if h.Version > formatVersion {
+		return nil, false, fmt.Errorf("unsupported file format version %d, the file was written by a newer version", h.Version)
+	}
+
+	for _, line := range lines[1:] {
+		var l lock
+		if json.Unmarshal(line, &l) != nil || l.Group == "" || l.ID == "" {
+			damaged = true
+			continue
+		}
+		locks = append(locks, l)
+	}
+	return locks, damaged, nil
+}
+
+func (l lock) expired(now time.Time) bool {
+	return !l.Expires.IsZero() && now.After(l.Expires)
+}
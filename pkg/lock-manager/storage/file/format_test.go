@@ -0,0 +1,91 @@
+package file
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	locks := []lock{
+		{Group: "default", ID: "node-a", Owner: "token:workers", Created: created},
+		{Group: "default", ID: "node-b", Created: created, Expires: created.Add(time.Hour)},
+	}
+	data, err := encode(locks)
+	require.NoError(t, err, "Should encode locks")
+
+	tMatrix := []struct {
+		Name    string
+		Data    string
+		Locks   []lock
+		Damaged bool
+		Error   bool
+	}{
+		{
+			Name:  "Complete",
+			Data:  string(data),
+			Locks: locks,
+		},
+		{
+			Name: "Empty",
+			Data: "",
+		},
+		{
+			Name: "OnlyHeader",
+			Data: "{\"version\":1}\n",
+		},
+		{
+			Name:    "TruncatedLastLine",
+			Data:    string(data[:len(data)-5]),
+			Locks:   locks[:1],
+			Damaged: true,
+		},
+		{
+			Name:    "MissingNewline",
+			Data:    string(data[:len(data)-1]),
+			Locks:   locks,
+			Damaged: true,
+		},
+		{
+			Name:    "TruncatedHeader",
+			Data:    "{\"vers",
+			Damaged: true,
+		},
+		{
+			Name:    "GarbageLine",
+			Data:    "{\"version\":1}\n\x00\x00\x00\n" + string(data[len("{\"version\":1}\n"):]),
+			Locks:   locks,
+			Damaged: true,
+		},
+		{
+			Name:  "NewerVersion",
+			Data:  "{\"version\":2}\n",
+			Error: true,
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			result, damaged, err := decode([]byte(tCase.Data))
+
+			if tCase.Error {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tCase.Damaged, damaged)
+			assert.Len(result, len(tCase.Locks))
+			for i := range min(len(result), len(tCase.Locks)) {
+				assert.Equal(tCase.Locks[i].ID, result[i].ID)
+				assert.Equal(tCase.Locks[i].Owner, result[i].Owner)
+				assert.True(tCase.Locks[i].Created.Equal(result[i].Created), "Created should match")
+				assert.True(tCase.Locks[i].Expires.Equal(result[i].Expires), "Expires should match")
+			}
+		})
+	}
+}
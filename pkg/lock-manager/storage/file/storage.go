@@ -0,0 +1,332 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	lmerrors "github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+)
+
+type FileConfig struct {
+	// Path of the file the locks are saved in.
+	// The files with the suffixes .lock, .tmp and .corrupt next to it are used by the backend as well.
+	Path string `yaml:"path,omitempty"`
+}
+
+type FileBackend struct {
+	path string
+
+	// Locked while accessing the file, so multiple processes can share it
+	lockFile *os.File
+	// The file lock is held per open file, so it does not protect against other goroutines
+	mutex sync.Mutex
+}
+
+func NewFileBackend(cfg FileConfig) (*FileBackend, error) {
+	if cfg.Path == "" {
+		return nil, lmerrors.NewErrorFilePathRequired()
+	}
+
+	err := os.MkdirAll(filepath.Dir(cfg.Path), 0700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory for lock file: %w", err)
+	}
+	lockFile, err := os.OpenFile(cfg.Path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	f := &FileBackend{
+		path:     cfg.Path,
+		lockFile: lockFile,
+	}
+
+	// Recover a damaged file on startup, instead of with the first request
+	err = f.update(func(_ *[]lock, _ time.Time) bool {
+		return false
+	})
+	if err != nil {
+		_ = lockFile.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reserve a lock for the given group.
+// Returns true if the lock is successfully reserved, even if the lock is already held by the specific id
+func (f *FileBackend) Reserve(group, id, owner string, ttl time.Duration) error {
+	return f.update(func(locks *[]lock, now time.Time) bool {
+		if hasLock(*locks, group, id) {
+			return false
+		}
+		*locks = append(*locks, newLock(group, id, owner, ttl, now))
+		return true
+	})
+}
+
+// Reserve a lock for the given group, if the group holds less than the given number of locks.
+// Returns true if the lock is reserved, even if it was already held by the id, and false when all slots are taken.
+// The file is locked while reserving, so this is atomic between all processes sharing the file.
+func (f *FileBackend) TryReserve(group, id, owner string, slots int, ttl time.Duration) (bool, error) {
+	reserved := false
+	err := f.update(func(locks *[]lock, now time.Time) bool {
+		if hasLock(*locks, group, id) {
+			reserved = true
+			return false
+		}
+		if countLocks(*locks, group) >= slots {
+			return false
+		}
+		*locks = append(*locks, newLock(group, id, owner, ttl, now))
+		reserved = true
+		return true
+	})
+	if err != nil {
+		return false, err
+	}
+	return reserved, nil
+}
+
+// Extend the lock held by the id, so that it expires ttl from now.
+// Does not fail when no lock is held.
+func (f *FileBackend) Renew(group string, id string, ttl time.Duration) error {
+	return f.update(func(locks *[]lock, now time.Time) bool {
+		for i, l := range *locks {
+			if l.Group == group && l.ID == id {
+				(*locks)[i].Expires = now.Add(ttl)
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Returns the current number of locks for the given group
+func (f *FileBackend) GetLocks(group string) (int, error) {
+	count := 0
+	err := f.update(func(locks *[]lock, _ time.Time) bool {
+		count = countLocks(*locks, group)
+		return false
+	})
+	return count, err
+}
+
+// Release the lock currently held by the id.
+// Does not fail when no lock is held.
+func (f *FileBackend) Release(group string, id string) error {
+	return f.update(func(locks *[]lock, _ time.Time) bool {
+		n := len(*locks)
+		*locks = slices.DeleteFunc(*locks, func(l lock) bool {
+			return l.Group == group && l.ID == id
+		})
+		return len(*locks) != n
+	})
+}
+
+// Return all locks older than x
+func (f *FileBackend) GetStaleLocks(ts time.Duration) ([]types.Lock, error) {
+	var result []types.Lock
+	err := f.update(func(locks *[]lock, now time.Time) bool {
+		result = make([]types.Lock, 0)
+		for _, l := range *locks {
+			if now.Sub(l.Created) > ts {
+				result = append(result, l.toLock())
+			}
+		}
+		return false
+	})
+	return result, err
+}
+
+// Return all locks currently held in the given group
+func (f *FileBackend) ListLocks(group string) ([]types.Lock, error) {
+	var result []types.Lock
+	err := f.update(func(locks *[]lock, _ time.Time) bool {
+		result = make([]types.Lock, 0)
+		for _, l := range *locks {
+			if l.Group == group {
+				result = append(result, l.toLock())
+			}
+		}
+		return false
+	})
+	return result, err
+}
+
+// Check if a given id already has a lock for this group
+func (f *FileBackend) HasLock(group, id string) (bool, error) {
+	result := false
+	err := f.update(func(locks *[]lock, _ time.Time) bool {
+		result = hasLock(*locks, group, id)
+		return false
+	})
+	return result, err
+}
+
+// Check if the file can be locked and read
+func (f *FileBackend) Ping() error {
+	return f.update(func(_ *[]lock, _ time.Time) bool {
+		return false
+	})
+}
+
+// Calls all necessary finalization if necessary
+func (f *FileBackend) Close() error {
+	return f.lockFile.Close()
+}
+
+// Read the locks while holding the file lock and save them, if fn returns true.
+// Expired locks are removed before calling fn.
+func (f *FileBackend) update(fn func(locks *[]lock, now time.Time) bool) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := flock(f.lockFile)
+	if err != nil {
+		return fmt.Errorf("failed to lock file: %w", err)
+	}
+	defer func() {
+		_ = funlock(f.lockFile)
+	}()
+
+	locks, changed, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	n := len(locks)
+	locks = slices.DeleteFunc(locks, func(l lock) bool {
+		return l.expired(now)
+	})
+	changed = changed || len(locks) != n
+
+	if fn(&locks, now) || changed {
+		return f.save(locks)
+	}
+	return nil
+}
+
+// Read the locks from the file.
+// A damaged file is backed up and the readable locks are returned, changed is true in that case.
+func (f *FileBackend) load() (locks []lock, changed bool, err error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	locks, damaged, err := decode(data)
+	if err != nil {
+		return nil, false, err
+	}
+	if !damaged {
+		return locks, false, nil
+	}
+
+	backup := f.path + ".corrupt"
+	err = os.WriteFile(backup, data, 0600)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to backup damaged file: %w", err)
+	}
+	slog.Warn("Lock file is damaged, recovered the readable locks", slog.String("file", f.path), slog.Int("locks", len(locks)), slog.String("backup", backup))
+	return locks, true, nil
+}
+
+// Atomically replace the file with the given locks.
+// The data is synced to disk before the file is replaced, so a crash leaves either the old or the new file.
+func (f *FileBackend) save(locks []lock) error {
+	data, err := encode(locks)
+	if err != nil {
+		return fmt.Errorf("failed to encode locks: %w", err)
+	}
+
+	tmp := f.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	err = os.Rename(tmp, f.path)
+	if err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	return syncDir(filepath.Dir(f.path))
+}
+
+// Sync the directory, so the rename survives a crash
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer dir.Close()
+
+	err = dir.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
+
+func newLock(group, id, owner string, ttl time.Duration, now time.Time) lock {
+	l := lock{
+		Group:   group,
+		ID:      id,
+		Owner:   owner,
+		Created: now,
+	}
+	if ttl > 0 {
+		l.Expires = now.Add(ttl)
+	}
+	return l
+}
+
+func hasLock(locks []lock, group, id string) bool {
+	for _, l := range locks {
+		if l.Group == group && l.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func countLocks(locks []lock, group string) int {
+	count := 0
+	for _, l := range locks {
+		if l.Group == group {
+			count++
+		}
+	}
+	return count
+}
+
+func (l lock) toLock() types.Lock {
+	return types.Lock{
+		Group:   l.Group,
+		ID:      l.ID,
+		Created: l.Created,
+		Owner:   l.Owner,
+	}
+}
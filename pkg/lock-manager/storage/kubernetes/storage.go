@@ -3,6 +3,7 @@ package kubernetes
 import (
 	"context"
 	"fmt"
+	"math"
 	"regexp"
 	"slices"
 	"strconv"
@@ -10,10 +11,12 @@ import (
 	"time"
 
 	"github.com/heathcliff26/fleetlock/pkg/k8s/utils"
+	lmerrors "github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
 
 	coordv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 	v1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
@@ -21,6 +24,17 @@ import (
 
 const keyformat = "fleetlock-reservation-%s-"
 
+// Kubernetes names are lowercase, so the original group name is saved as annotation
+const groupAnnotation = "fleetlock.heathcliff.eu/group"
+
+// Identity of the client that reserved the lock, only set when known
+const ownerAnnotation = "fleetlock.heathcliff.eu/owner"
+
+// How often reserving is retried when the group is changed by another server in the meantime
+const maxReserveAttempts = 5
+
+var leaseNameRegex = regexp.MustCompile("^" + fmt.Sprintf(keyformat, "(.+)") + "\\d+$")
+
 type KubernetesBackend struct {
 	client    v1.CoordinationV1Interface
 	namespace string
@@ -45,10 +59,7 @@ func NewKubernetesBackend(cfg KubernetesConfig) (*KubernetesBackend, error) {
 		}
 	}
 
-	return &KubernetesBackend{
-		client:    client.CoordinationV1(),
-		namespace: ns,
-	}, nil
+	return NewKubernetesBackendWithClient(client.CoordinationV1(), ns), nil
 }
 
 // Create a test client with a fake kubernetes clientset
@@ -59,17 +70,20 @@ func NewKubernetesBackendWithFakeClient(namespace string) (*KubernetesBackend, *
 		},
 	}
 	fakeclient := fake.NewClientset(ns)
+	return NewKubernetesBackendWithClient(fakeclient.CoordinationV1(), namespace), fakeclient
+}
+
+// Create a backend using an existing client
+func NewKubernetesBackendWithClient(client v1.CoordinationV1Interface, namespace string) *KubernetesBackend {
 	return &KubernetesBackend{
-		client:    fakeclient.CoordinationV1(),
+		client:    client,
 		namespace: namespace,
-	}, fakeclient
+	}
 }
 
 // Reserve a lock for the given group.
 // Returns true if the lock is successfully reserved, even if the lock is already held by the specific id
-func (k *KubernetesBackend) Reserve(group string, id string) error {
-	// Kubernetes names do not allow uppercase
-	group = strings.ToLower(group)
+func (k *KubernetesBackend) Reserve(group, id, owner string, ttl time.Duration) error {
 	leases, err := k.getLeasesForGroup(group)
 	if err != nil {
 		return err
@@ -77,6 +91,13 @@ func (k *KubernetesBackend) Reserve(group string, id string) error {
 
 	names := make([]string, 0)
 	for _, lease := range leases {
+		if leaseExpired(lease) {
+			err = k.client.Leases(k.namespace).Delete(context.Background(), lease.GetName(), metav1.DeleteOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to delete expired lease %s: %w", lease.GetName(), err)
+			}
+			continue
+		}
 		if *lease.Spec.HolderIdentity == id {
 			return nil
 		}
@@ -84,7 +105,8 @@ func (k *KubernetesBackend) Reserve(group string, id string) error {
 	}
 
 	i := 0
-	key := fmt.Sprintf(keyformat, group)
+	// Kubernetes names do not allow uppercase
+	key := fmt.Sprintf(keyformat, strings.ToLower(group))
 
 	name := key + strconv.Itoa(i)
 	for slices.Contains(names, name) {
@@ -92,24 +114,117 @@ func (k *KubernetesBackend) Reserve(group string, id string) error {
 		name = key + strconv.Itoa(i)
 	}
 
-	lease := &coordv1.Lease{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: k.namespace,
-			Name:      name,
-		},
-		Spec: coordv1.LeaseSpec{
-			HolderIdentity: &id,
-		},
-	}
-	_, err = k.client.Leases(k.namespace).Create(context.Background(), lease, metav1.CreateOptions{})
+	_, err = k.client.Leases(k.namespace).Create(context.Background(), newLease(k.namespace, name, group, id, owner, ttl), metav1.CreateOptions{})
 
 	return err
 }
 
+// Reserve a lock for the given group, if the group holds less than the given number of locks.
+// Returns true if the lock is reserved, even if it was already held by the id, and false when all slots are taken.
+// Every slot is a lease named after its index, so creating the lease fails when another server took the same slot in the meantime.
+func (k *KubernetesBackend) TryReserve(group, id, owner string, slots int, ttl time.Duration) (bool, error) {
+	for range maxReserveAttempts {
+		ok, retry, err := k.tryReserve(group, id, owner, slots, ttl)
+		if !retry {
+			return ok, err
+		}
+	}
+	return false, lmerrors.NewErrorReserveConflict(group)
+}
+
+// Try to create the lease for the lowest free slot.
+// Returns true as second value when the group has been changed concurrently and the reservation should be retried.
+func (k *KubernetesBackend) tryReserve(group, id, owner string, slots int, ttl time.Duration) (bool, bool, error) {
+	leases, err := k.getLeasesForGroup(group)
+	if err != nil {
+		return false, false, err
+	}
+
+	// Kubernetes names do not allow uppercase
+	key := fmt.Sprintf(keyformat, strings.ToLower(group))
+
+	used := make(map[int]bool, len(leases))
+	// Leases above the number of slots are left from before the slots have been reduced, they can't be reused
+	limit := slots
+	for _, lease := range leases {
+		if leaseExpired(lease) {
+			// Only delete the lease if it has not been taken over in the meantime
+			rv := lease.GetResourceVersion()
+			err = k.client.Leases(k.namespace).Delete(context.Background(), lease.GetName(), metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: &rv},
+			})
+			if errors.IsNotFound(err) || errors.IsConflict(err) {
+				return false, true, nil
+			} else if err != nil {
+				return false, false, fmt.Errorf("failed to delete expired lease %s: %w", lease.GetName(), err)
+			}
+			continue
+		}
+		if *lease.Spec.HolderIdentity == id {
+			return true, false, nil
+		}
+
+		i, _ := strconv.Atoi(strings.TrimPrefix(lease.GetName(), key))
+		used[i] = true
+		if i >= slots {
+			limit--
+		}
+	}
+	if len(used) >= slots {
+		return false, false, nil
+	}
+
+	i := 0
+	for used[i] {
+		i++
+	}
+	if i >= limit {
+		return false, false, nil
+	}
+
+	_, err = k.client.Leases(k.namespace).Create(context.Background(), newLease(k.namespace, key+strconv.Itoa(i), group, id, owner, ttl), metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		return false, true, nil
+	}
+	return err == nil, false, err
+}
+
+// Extend the lock held by the id, so that it expires ttl from now.
+// Does not fail when no lock is held.
+func (k *KubernetesBackend) Renew(group string, id string, ttl time.Duration) error {
+	leases, err := k.getLeasesForGroup(group)
+	if err != nil {
+		return err
+	}
+
+	for _, lease := range leases {
+		if *lease.Spec.HolderIdentity != id || leaseExpired(lease) {
+			continue
+		}
+
+		lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now()}
+		lease.Spec.LeaseDurationSeconds = utils.Pointer(ttlSeconds(ttl))
+		_, err = k.client.Leases(k.namespace).Update(context.Background(), &lease, metav1.UpdateOptions{})
+		return err
+	}
+
+	return nil
+}
+
 // Returns the current number of locks for the given group
 func (k *KubernetesBackend) GetLocks(group string) (int, error) {
 	leases, err := k.getLeasesForGroup(group)
-	return len(leases), err
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, lease := range leases {
+		if !leaseExpired(lease) {
+			count++
+		}
+	}
+	return count, nil
 }
 
 // Release the lock currently held by the id.
@@ -131,7 +246,41 @@ func (k *KubernetesBackend) Release(group string, id string) error {
 
 // Return all locks older than x
 func (k *KubernetesBackend) GetStaleLocks(ts time.Duration) ([]types.Lock, error) {
-	panic("not implemented") // TODO: Implement
+	leases, err := k.client.Leases(k.namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Lock, 0)
+	for _, lease := range leases.Items {
+		match := leaseNameRegex.FindStringSubmatch(lease.GetName())
+		if match == nil || lease.Spec.HolderIdentity == nil || leaseExpired(lease) {
+			continue
+		}
+
+		lock := leaseToLock(lease, match[1])
+		if time.Since(lock.Created) > ts {
+			result = append(result, lock)
+		}
+	}
+	return result, nil
+}
+
+// Return all locks currently held in the given group
+func (k *KubernetesBackend) ListLocks(group string) ([]types.Lock, error) {
+	leases, err := k.getLeasesForGroup(group)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Lock, 0, len(leases))
+	for _, lease := range leases {
+		if lease.Spec.HolderIdentity == nil || leaseExpired(lease) {
+			continue
+		}
+		result = append(result, leaseToLock(lease, group))
+	}
+	return result, nil
 }
 
 // Check if a given id already has a lock for this group
@@ -142,13 +291,19 @@ func (k *KubernetesBackend) HasLock(group string, id string) (bool, error) {
 	}
 
 	for _, lease := range leases {
-		if *lease.Spec.HolderIdentity == id {
+		if *lease.Spec.HolderIdentity == id && !leaseExpired(lease) {
 			return true, nil
 		}
 	}
 	return false, nil
 }
 
+// Check if the kubernetes api is reachable and the leases can be listed
+func (k *KubernetesBackend) Ping() error {
+	_, err := k.client.Leases(k.namespace).List(context.Background(), metav1.ListOptions{Limit: 1})
+	return err
+}
+
 // Calls all necessary finalization if necessary
 func (k *KubernetesBackend) Close() error {
 	return nil
@@ -176,3 +331,64 @@ func (k *KubernetesBackend) getLeasesForGroup(group string) ([]coordv1.Lease, er
 	}
 	return result, nil
 }
+
+// Create the lease for a new reservation
+func newLease(namespace, name, group, id, owner string, ttl time.Duration) *coordv1.Lease {
+	now := metav1.NewMicroTime(time.Now())
+	lease := &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Annotations: map[string]string{
+				groupAnnotation: group,
+			},
+		},
+		Spec: coordv1.LeaseSpec{
+			HolderIdentity: &id,
+			AcquireTime:    &now,
+		},
+	}
+	if owner != "" {
+		lease.Annotations[ownerAnnotation] = owner
+	}
+	if ttl > 0 {
+		lease.Spec.RenewTime = &now
+		lease.Spec.LeaseDurationSeconds = utils.Pointer(ttlSeconds(ttl))
+	}
+	return lease
+}
+
+// Convert a reservation lease into a lock.
+// The group is used as fallback for leases without annotation.
+func leaseToLock(lease coordv1.Lease, group string) types.Lock {
+	if annotation, ok := lease.GetAnnotations()[groupAnnotation]; ok {
+		group = annotation
+	}
+
+	created := lease.GetCreationTimestamp().Time
+	if lease.Spec.AcquireTime != nil {
+		created = lease.Spec.AcquireTime.Time
+	}
+
+	return types.Lock{
+		Group:   group,
+		ID:      *lease.Spec.HolderIdentity,
+		Created: created,
+		Owner:   lease.GetAnnotations()[ownerAnnotation],
+	}
+}
+
+// Check if the lease has a duration and has not been renewed in time.
+// Kubernetes does not delete expired leases, so they need to be ignored instead.
+func leaseExpired(lease coordv1.Lease) bool {
+	if lease.Spec.LeaseDurationSeconds == nil || lease.Spec.RenewTime == nil {
+		return false
+	}
+	duration := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	return time.Now().After(lease.Spec.RenewTime.Add(duration))
+}
+
+// Convert the ttl into seconds, as leases do not support a smaller granularity
+func ttlSeconds(ttl time.Duration) int32 {
+	return int32(math.Ceil(ttl.Seconds()))
+}
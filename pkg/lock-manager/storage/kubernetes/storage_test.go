@@ -4,8 +4,11 @@ import (
 	"regexp"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordv1 "k8s.io/api/coordination/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -17,11 +20,11 @@ func TestConflictingGroupNames(t *testing.T) {
 
 	group := "default"
 	id := "user"
-	err := storage.Reserve(group, id)
+	err := storage.Reserve(group, id, "", 0)
 	assert.Nil(err, "Should reserve slot")
 
 	for i := 1; i < 10; i++ {
-		err := storage.Reserve(group+"-"+strconv.Itoa(i), id+strconv.Itoa(i))
+		err := storage.Reserve(group+"-"+strconv.Itoa(i), id+strconv.Itoa(i), "", 0)
 		assert.Nil(err, "Should reserve slot")
 	}
 
@@ -38,7 +41,7 @@ func TestCompliantLeaseNames(t *testing.T) {
 
 	assert := assert.New(t)
 
-	err := storage.Reserve("default", "User")
+	err := storage.Reserve("default", "User", "", 0)
 	assert.Nil(err, "Should reserve slot")
 
 	leases, _ := client.CoordinationV1().Leases(nsName).List(ctx, metav1.ListOptions{})
@@ -49,3 +52,32 @@ func TestCompliantLeaseNames(t *testing.T) {
 		assert.True(validationRegex.MatchString(lease.GetName()), "Name should be compliant with k8s")
 	}
 }
+
+func TestGetStaleLocksLegacyLease(t *testing.T) {
+	nsName := "fleetlock"
+	storage, client := NewKubernetesBackendWithFakeClient(nsName)
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	id := "legacy-user"
+	lease := &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "fleetlock-reservation-legacy-group-0",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+		},
+		Spec: coordv1.LeaseSpec{
+			HolderIdentity: &id,
+		},
+	}
+	_, err := client.CoordinationV1().Leases(nsName).Create(t.Context(), lease, metav1.CreateOptions{})
+	require.NoError(err, "Should create lease")
+
+	locks, err := storage.GetStaleLocks(time.Minute)
+	require.NoError(err, "Should get stale locks")
+	require.Len(locks, 1, "Should find the lease")
+
+	assert.Equal("legacy-group", locks[0].Group, "Should read group from name")
+	assert.Equal(id, locks[0].ID, "Should read id from holder identity")
+	assert.Equal(lease.CreationTimestamp.Time, locks[0].Created, "Should fall back to creation timestamp")
+}
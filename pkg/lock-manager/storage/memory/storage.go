@@ -1,6 +1,8 @@
 package memory
 
 import (
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
@@ -8,8 +10,12 @@ import (
 )
 
 type MemoryBackend struct {
-	// This map will be assumed to be read-only after creation
-	groups map[string]*group
+	// Groups are only added, the locks inside a group are protected by the lock manager
+	groups     map[string]*group
+	groupsLock sync.RWMutex
+
+	events     []types.Event
+	eventsLock sync.RWMutex
 }
 
 type group struct {
@@ -18,11 +24,17 @@ type group struct {
 
 type lock struct {
 	id      string
+	owner   string
 	created time.Time
+	// Zero if the lock does not expire
+	expires time.Time
 }
 
 const initialArraySize = 10
 
+// Only the newest events are kept, to limit memory usage
+const maxEvents = 10000
+
 func NewMemoryBackend(groups []string) *MemoryBackend {
 	g := make(map[string]*group)
 
@@ -38,71 +50,173 @@ func NewMemoryBackend(groups []string) *MemoryBackend {
 	}
 }
 
+// Add a group that was not known at creation, does nothing if it already exists
+func (m *MemoryBackend) AddGroup(name string) {
+	m.groupsLock.Lock()
+	defer m.groupsLock.Unlock()
+
+	if m.groups[name] == nil {
+		m.groups[name] = &group{
+			slots: make([]lock, 0, initialArraySize),
+		}
+	}
+}
+
+func (m *MemoryBackend) getGroup(name string) *group {
+	m.groupsLock.RLock()
+	defer m.groupsLock.RUnlock()
+
+	return m.groups[name]
+}
+
 // Reserve a lock for the given group.
 // Returns true if the lock is successfully reserved, even if the lock is already held by the specific id
-func (m *MemoryBackend) Reserve(group string, id string) error {
-	g := m.groups[group]
+func (m *MemoryBackend) Reserve(group, id, owner string, ttl time.Duration) error {
+	g := m.getGroup(group)
 	if g == nil {
 		// All groups should be initialized at the beginning
 		return errors.NewErrorUnknownGroup(group)
 	}
 
+	g.removeExpired()
+
 	if g.hasLock(id) {
 		return nil
 	}
 
 	lock := lock{
 		id:      id,
+		owner:   owner,
 		created: time.Now(),
 	}
+	if ttl > 0 {
+		lock.expires = lock.created.Add(ttl)
+	}
 	g.slots = append(g.slots, lock)
 
 	return nil
 }
 
+// Reserve a lock for the given group, if the group holds less than the given number of locks.
+// Returns true if the lock is reserved, even if it was already held by the id, and false when all slots are taken.
+// The memory can't be shared between servers, so the lock manager already ensures this is atomic.
+func (m *MemoryBackend) TryReserve(group, id, owner string, slots int, ttl time.Duration) (bool, error) {
+	g := m.getGroup(group)
+	if g == nil {
+		return false, errors.NewErrorUnknownGroup(group)
+	}
+
+	g.removeExpired()
+
+	if g.hasLock(id) {
+		return true, nil
+	}
+	if len(g.slots) >= slots {
+		return false, nil
+	}
+
+	return true, m.Reserve(group, id, owner, ttl)
+}
+
+// Extend the lock held by the id, so that it expires ttl from now.
+// Does not fail when no lock is held.
+func (m *MemoryBackend) Renew(group string, id string, ttl time.Duration) error {
+	g := m.getGroup(group)
+	if g == nil {
+		return errors.NewErrorUnknownGroup(group)
+	}
+
+	for i, l := range g.slots {
+		if l.id == id && !l.expired() {
+			g.slots[i].expires = time.Now().Add(ttl)
+			break
+		}
+	}
+
+	return nil
+}
+
 // Returns the current number of locks for the given group
 func (m *MemoryBackend) GetLocks(group string) (int, error) {
-	g := m.groups[group]
+	g := m.getGroup(group)
 	if g == nil {
 		return 0, nil
 	}
 
-	return len(g.slots), nil
+	count := 0
+	for _, l := range g.slots {
+		if !l.expired() {
+			count++
+		}
+	}
+	return count, nil
 }
 
 // Release the lock currently held by the id.
 // Does not fail when no lock is held.
 func (m *MemoryBackend) Release(group string, id string) error {
-	g := m.groups[group]
+	g := m.getGroup(group)
 	if g == nil {
 		return nil
 	}
 
-	var i int
-	var l lock
-	for i, l = range g.slots {
+	for i, l := range g.slots {
 		if l.id == id {
+			g.slots[i] = g.slots[len(g.slots)-1]
+			g.slots = g.slots[:len(g.slots)-1]
 			break
 		}
 	}
-	if len(g.slots) > 1 {
-		g.slots[i] = g.slots[len(g.slots)-1]
-		g.slots = g.slots[:len(g.slots)-1]
-	} else {
-		g.slots = g.slots[:0]
-	}
 
 	return nil
 }
 
 // Return all locks older than x
 func (m *MemoryBackend) GetStaleLocks(ts time.Duration) ([]types.Lock, error) {
-	panic("TODO")
+	m.groupsLock.RLock()
+	defer m.groupsLock.RUnlock()
+
+	result := make([]types.Lock, 0)
+	for name, g := range m.groups {
+		for _, l := range g.slots {
+			if !l.expired() && time.Since(l.created) > ts {
+				result = append(result, types.Lock{
+					Group:   name,
+					ID:      l.id,
+					Created: l.created,
+					Owner:   l.owner,
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+// Return all locks currently held in the given group
+func (m *MemoryBackend) ListLocks(group string) ([]types.Lock, error) {
+	g := m.getGroup(group)
+	if g == nil {
+		return nil, errors.NewErrorUnknownGroup(group)
+	}
+
+	result := make([]types.Lock, 0, len(g.slots))
+	for _, l := range g.slots {
+		if l.expired() {
+			continue
+		}
+		result = append(result, types.Lock{
+			Group:   group,
+			ID:      l.id,
+			Created: l.created,
+			Owner:   l.owner,
+		})
+	}
+	return result, nil
 }
 
 // Check if a given id already has a lock for this group
 func (m *MemoryBackend) HasLock(group, id string) (bool, error) {
-	g := m.groups[group]
+	g := m.getGroup(group)
 	if g == nil {
 		// All groups should be initialized at the beginning
 		return false, errors.NewErrorUnknownGroup(group)
@@ -110,6 +224,31 @@ func (m *MemoryBackend) HasLock(group, id string) (bool, error) {
 	return g.hasLock(id), nil
 }
 
+// Persist the audit event, only the newest events are kept
+func (m *MemoryBackend) RecordEvent(event types.Event) error {
+	m.eventsLock.Lock()
+	defer m.eventsLock.Unlock()
+
+	m.events = append(m.events, event)
+	if len(m.events) > maxEvents {
+		m.events = slices.Delete(m.events, 0, len(m.events)-maxEvents)
+	}
+	return nil
+}
+
+// Return the audit events matching the filter, sorted from oldest to newest
+func (m *MemoryBackend) QueryEvents(filter types.EventFilter) ([]types.Event, error) {
+	m.eventsLock.RLock()
+	defer m.eventsLock.RUnlock()
+
+	return filter.Apply(m.events), nil
+}
+
+// Check if the backend is reachable, always succeeds for memory
+func (m *MemoryBackend) Ping() error {
+	return nil
+}
+
 // Calls all necessary finalization if necessary
 func (m *MemoryBackend) Close() error {
 	return nil
@@ -117,9 +256,18 @@ func (m *MemoryBackend) Close() error {
 
 func (g *group) hasLock(id string) bool {
 	for _, lock := range g.slots {
-		if id == lock.id {
+		if id == lock.id && !lock.expired() {
 			return true
 		}
 	}
 	return false
 }
+
+// Remove all expired locks, requires exclusive access to the group
+func (g *group) removeExpired() {
+	g.slots = slices.DeleteFunc(g.slots, lock.expired)
+}
+
+func (l lock) expired() bool {
+	return !l.expires.IsZero() && time.Now().After(l.expires)
+}
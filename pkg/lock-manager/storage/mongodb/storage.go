@@ -6,13 +6,24 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 const DEFAULT_DATABASE = "fleetlock"
 
+// Group names are validated to not contain "_", so this never collides with the collection of a group
+const eventsCollection = "fleetlock_events"
+
+// Only the newest events are kept, to limit the size of the database
+const maxEvents = 10000
+
+// How often reserving is retried when the group is changed by another server in the meantime
+const maxReserveAttempts = 5
+
 type MongoDBBackend struct {
 	client   *mongo.Client
 	database string
@@ -26,6 +37,20 @@ type MongoDBConfig struct {
 type MongoLock struct {
 	ID      string    `bson:"_id,omitempty"`
 	Created time.Time `bson:"created,omitempty"`
+	Expires time.Time `bson:"expires,omitempty"`
+	Owner   string    `bson:"owner,omitempty"`
+	// The slot taken by the lock, unique per group. Not set for locks created without a slot limit.
+	Slot *int `bson:"slot,omitempty"`
+}
+
+type MongoEvent struct {
+	Time   time.Time `bson:"time"`
+	Type   string    `bson:"type"`
+	Group  string    `bson:"group,omitempty"`
+	ID     string    `bson:"id,omitempty"`
+	Node   string    `bson:"node,omitempty"`
+	Remote string    `bson:"remote,omitempty"`
+	Reason string    `bson:"reason,omitempty"`
 }
 
 func NewMongoDBBackend(cfg MongoDBConfig) (*MongoDBBackend, error) {
@@ -60,22 +85,142 @@ func NewMongoDBBackend(cfg MongoDBConfig) (*MongoDBBackend, error) {
 
 // Reserve a lock for the given group.
 // Returns true if the lock is successfully reserved, even if the lock is already held by the specific id
-func (m *MongoDBBackend) Reserve(group string, id string) error {
+func (m *MongoDBBackend) Reserve(group, id, owner string, ttl time.Duration) error {
+	ctx := context.Background()
 	coll := m.client.Database(m.database).Collection(group)
 
 	newObj := MongoLock{
 		ID:      id,
 		Created: time.Now(),
+		Owner:   owner,
+	}
+	if ttl > 0 {
+		newObj.Expires = newObj.Created.Add(ttl)
+
+		err := ensureTTLIndex(ctx, coll)
+		if err != nil {
+			return err
+		}
 	}
 
-	_, err := coll.InsertOne(context.Background(), newObj)
+	// The ttl monitor only runs periodically, so expired locks might still exist
+	_, err := coll.DeleteMany(ctx, bson.D{{Key: "expires", Value: bson.D{{Key: "$lte", Value: time.Now()}}}})
+	if err != nil {
+		return fmt.Errorf("failed to delete expired locks: %w", err)
+	}
+
+	_, err = coll.InsertOne(ctx, newObj)
+	return err
+}
+
+// Reserve a lock for the given group, if the group holds less than the given number of locks.
+// Returns true if the lock is reserved, even if it was already held by the id, and false when all slots are taken.
+// Every lock takes a numbered slot with a unique index, so inserting fails when another server took the same slot in the meantime.
+func (m *MongoDBBackend) TryReserve(group, id, owner string, slots int, ttl time.Duration) (bool, error) {
+	ctx := context.Background()
+	coll := m.client.Database(m.database).Collection(group)
+
+	err := ensureSlotIndex(ctx, coll)
+	if err != nil {
+		return false, err
+	}
+	if ttl > 0 {
+		err = ensureTTLIndex(ctx, coll)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	for range maxReserveAttempts {
+		ok, retry, err := m.tryReserve(ctx, coll, id, owner, slots, ttl)
+		if !retry {
+			return ok, err
+		}
+	}
+	return false, errors.NewErrorReserveConflict(group)
+}
+
+// Try to insert the lock with the lowest free slot.
+// Returns true as second value when the group has been changed concurrently and the reservation should be retried.
+func (m *MongoDBBackend) tryReserve(ctx context.Context, coll *mongo.Collection, id, owner string, slots int, ttl time.Duration) (bool, bool, error) {
+	// The ttl monitor only runs periodically, so expired locks might still exist
+	_, err := coll.DeleteMany(ctx, bson.D{{Key: "expires", Value: bson.D{{Key: "$lte", Value: time.Now()}}}})
+	if err != nil {
+		return false, false, fmt.Errorf("failed to delete expired locks: %w", err)
+	}
+
+	cursor, err := coll.Find(ctx, activeFilter())
+	if err != nil {
+		return false, false, fmt.Errorf("failed to find locks: %w", err)
+	}
+	var locks []MongoLock
+	err = cursor.All(ctx, &locks)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to read locks: %w", err)
+	}
+
+	used := make(map[int]bool, len(locks))
+	// Locks without a slot or above the number of slots can't be moved, so they reduce the usable slots
+	limit := slots
+	for _, lock := range locks {
+		if lock.ID == id {
+			return true, false, nil
+		}
+		if lock.Slot == nil || *lock.Slot >= slots {
+			limit--
+		} else {
+			used[*lock.Slot] = true
+		}
+	}
+	if len(locks) >= slots {
+		return false, false, nil
+	}
+
+	slot := 0
+	for used[slot] {
+		slot++
+	}
+	if slot >= limit {
+		return false, false, nil
+	}
+
+	newObj := MongoLock{
+		ID:      id,
+		Created: time.Now(),
+		Owner:   owner,
+		Slot:    &slot,
+	}
+	if ttl > 0 {
+		newObj.Expires = newObj.Created.Add(ttl)
+	}
+	_, err = coll.InsertOne(ctx, newObj)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, true, nil
+	}
+	return err == nil, false, err
+}
+
+// Extend the lock held by the id, so that it expires ttl from now.
+// Does not fail when no lock is held.
+func (m *MongoDBBackend) Renew(group string, id string, ttl time.Duration) error {
+	ctx := context.Background()
+	coll := m.client.Database(m.database).Collection(group)
+
+	err := ensureTTLIndex(ctx, coll)
+	if err != nil {
+		return err
+	}
+
+	filter := activeFilter(bson.E{Key: "_id", Value: id})
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "expires", Value: time.Now().Add(ttl)}}}}
+	_, err = coll.UpdateOne(ctx, filter, update)
 	return err
 }
 
 // Returns the current number of locks for the given group
 func (m *MongoDBBackend) GetLocks(group string) (int, error) {
 	coll := m.client.Database(m.database).Collection(group)
-	count, err := coll.CountDocuments(context.Background(), MongoLock{})
+	count, err := coll.CountDocuments(context.Background(), activeFilter())
 	return int(count), err
 }
 
@@ -94,17 +239,74 @@ func (m *MongoDBBackend) Release(group string, id string) error {
 
 // Return all locks older than x
 func (m *MongoDBBackend) GetStaleLocks(ts time.Duration) ([]types.Lock, error) {
-	panic("not implemented") // TODO: Implement
+	ctx := context.Background()
+	db := m.client.Database(m.database)
+
+	groups, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: bson.D{{Key: "$ne", Value: eventsCollection}}}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+
+	filter := activeFilter(bson.E{Key: "created", Value: bson.D{{Key: "$lt", Value: time.Now().Add(-ts)}}})
+
+	result := make([]types.Lock, 0)
+	for _, group := range groups {
+		cursor, err := db.Collection(group).Find(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find stale locks in group %s: %w", group, err)
+		}
+
+		var locks []MongoLock
+		err = cursor.All(ctx, &locks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stale locks in group %s: %w", group, err)
+		}
+
+		for _, lock := range locks {
+			result = append(result, types.Lock{
+				Group:   group,
+				ID:      lock.ID,
+				Created: lock.Created,
+				Owner:   lock.Owner,
+			})
+		}
+	}
+	return result, nil
+}
+
+// Return all locks currently held in the given group
+func (m *MongoDBBackend) ListLocks(group string) ([]types.Lock, error) {
+	ctx := context.Background()
+	coll := m.client.Database(m.database).Collection(group)
+
+	cursor, err := coll.Find(ctx, activeFilter())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find locks in group %s: %w", group, err)
+	}
+
+	var locks []MongoLock
+	err = cursor.All(ctx, &locks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read locks in group %s: %w", group, err)
+	}
+
+	result := make([]types.Lock, 0, len(locks))
+	for _, lock := range locks {
+		result = append(result, types.Lock{
+			Group:   group,
+			ID:      lock.ID,
+			Created: lock.Created,
+			Owner:   lock.Owner,
+		})
+	}
+	return result, nil
 }
 
 // Check if a given id already has a lock for this group
 func (m *MongoDBBackend) HasLock(group string, id string) (bool, error) {
 	coll := m.client.Database(m.database).Collection(group)
 
-	filter := MongoLock{
-		ID: id,
-	}
-	res := coll.FindOne(context.Background(), filter)
+	res := coll.FindOne(context.Background(), activeFilter(bson.E{Key: "_id", Value: id}))
 
 	switch res.Err() {
 	case mongo.ErrNoDocuments:
@@ -116,7 +318,121 @@ func (m *MongoDBBackend) HasLock(group string, id string) (bool, error) {
 	}
 }
 
+// Persist the audit event, only the newest events are kept
+func (m *MongoDBBackend) RecordEvent(event types.Event) error {
+	ctx := context.Background()
+	coll := m.client.Database(m.database).Collection(eventsCollection)
+
+	_, err := coll.InsertOne(ctx, MongoEvent(event))
+	if err != nil {
+		return err
+	}
+
+	count, err := coll.EstimatedDocumentCount(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count events: %w", err)
+	}
+	if count <= maxEvents {
+		return nil
+	}
+
+	// Find the oldest event that should be kept and delete everything before it
+	var newest MongoEvent
+	opts := options.FindOne().SetSort(bson.D{{Key: "time", Value: -1}}).SetSkip(maxEvents - 1)
+	err = coll.FindOne(ctx, bson.D{}, opts).Decode(&newest)
+	if err != nil {
+		return fmt.Errorf("failed to find oldest event to keep: %w", err)
+	}
+	_, err = coll.DeleteMany(ctx, bson.D{{Key: "time", Value: bson.D{{Key: "$lt", Value: newest.Time}}}})
+	if err != nil {
+		return fmt.Errorf("failed to delete old events: %w", err)
+	}
+	return nil
+}
+
+// Return the audit events matching the filter, sorted from oldest to newest
+func (m *MongoDBBackend) QueryEvents(filter types.EventFilter) ([]types.Event, error) {
+	ctx := context.Background()
+	coll := m.client.Database(m.database).Collection(eventsCollection)
+
+	query := bson.D{}
+	if filter.Group != "" {
+		query = append(query, bson.E{Key: "group", Value: filter.Group})
+	}
+	if filter.ID != "" {
+		query = append(query, bson.E{Key: "id", Value: filter.ID})
+	}
+	if !filter.Since.IsZero() {
+		query = append(query, bson.E{Key: "time", Value: bson.D{{Key: "$gte", Value: filter.Since}}})
+	}
+
+	// Fetch the newest events first, so that the limit can be applied by the database
+	opts := options.Find().SetSort(bson.D{{Key: "time", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+
+	cursor, err := coll.Find(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find events: %w", err)
+	}
+
+	var events []MongoEvent
+	err = cursor.All(ctx, &events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+
+	result := make([]types.Event, len(events))
+	for i, event := range events {
+		result[len(events)-1-i] = types.Event(event)
+	}
+	return result, nil
+}
+
+// Check if the database is reachable
+func (m *MongoDBBackend) Ping() error {
+	return m.client.Ping(context.Background(), nil)
+}
+
 // Calls all necessary finalization if necessary
 func (m *MongoDBBackend) Close() error {
 	return m.client.Disconnect(context.Background())
 }
+
+// Create the index that lets MongoDB remove expired locks.
+// Creating an already existing index does nothing.
+func ensureTTLIndex(ctx context.Context, coll *mongo.Collection) error {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create ttl index: %w", err)
+	}
+	return nil
+}
+
+// Create the index that prevents multiple locks from taking the same slot.
+// Creating an already existing index does nothing.
+func ensureSlotIndex(ctx context.Context, coll *mongo.Collection) error {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "slot", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(
+			bson.D{{Key: "slot", Value: bson.D{{Key: "$exists", Value: true}}}},
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create slot index: %w", err)
+	}
+	return nil
+}
+
+// Create a filter matching all locks that have not expired, combined with the given conditions
+func activeFilter(conditions ...bson.E) bson.D {
+	notExpired := bson.E{Key: "$or", Value: bson.A{
+		bson.D{{Key: "expires", Value: bson.D{{Key: "$exists", Value: false}}}},
+		bson.D{{Key: "expires", Value: bson.D{{Key: "$gt", Value: time.Now()}}}},
+	}}
+	return append(bson.D{notExpired}, conditions...)
+}
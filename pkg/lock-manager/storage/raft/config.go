@@ -0,0 +1,105 @@
+package raft
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+)
+
+const defaultListen = ":7000"
+
+type RaftConfig struct {
+	// Address to listen on for messages from the other replicas
+	Listen string `yaml:"listen,omitempty"`
+	// Address under which the other replicas reach this one, defaults to the hostname and the port of listen.
+	// Needs to be written exactly the same as in peers.
+	Advertise string `yaml:"advertise,omitempty"`
+	// Addresses of all replicas including this one, in the form host:port
+	Peers []string `yaml:"peers,omitempty"`
+	// Discover the replicas of a statefulset, instead of configuring the peers
+	Discovery RaftDiscoveryConfig `yaml:"discovery,omitempty"`
+	// Directory for persisting the raft log
+	DataDir string `yaml:"dataDir,omitempty"`
+	// (Optional) Shared secret the replicas use to authenticate each other
+	Token string `yaml:"token,omitempty"`
+}
+
+type RaftDiscoveryConfig struct {
+	// Headless service of the statefulset, e.g. fleetlock-raft.fleetlock.svc.cluster.local
+	Service string `yaml:"service,omitempty"`
+	// Number of replicas of the statefulset
+	Replicas int `yaml:"replicas,omitempty"`
+}
+
+// Resolve the address of this replica and of all peers.
+// Returns the addresses keyed by raft id and the id of this replica.
+func (c RaftConfig) resolvePeers(hostname string) (map[uint64]string, uint64, error) {
+	listen := c.Listen
+	if listen == "" {
+		listen = defaultListen
+	}
+	_, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid raft listen address: %w", err)
+	}
+
+	self := c.Advertise
+	var peers []string
+	if c.Discovery.Service != "" {
+		if c.Discovery.Replicas < 1 {
+			return nil, 0, errors.NewErrorRaftNoPeers()
+		}
+		// Pods of a statefulset are named <name>-<ordinal> and resolvable as <pod>.<service>
+		i := strings.LastIndex(hostname, "-")
+		if i < 1 {
+			return nil, 0, errors.NewErrorRaftInvalidHostname(hostname)
+		}
+		_, err = strconv.Atoi(hostname[i+1:])
+		if err != nil {
+			return nil, 0, errors.NewErrorRaftInvalidHostname(hostname)
+		}
+		self = net.JoinHostPort(hostname+"."+c.Discovery.Service, port)
+		for n := range c.Discovery.Replicas {
+			peers = append(peers, net.JoinHostPort(hostname[:i]+"-"+strconv.Itoa(n)+"."+c.Discovery.Service, port))
+		}
+	} else {
+		if len(c.Peers) == 0 {
+			return nil, 0, errors.NewErrorRaftNoPeers()
+		}
+		if self == "" {
+			self = net.JoinHostPort(hostname, port)
+		}
+		peers = c.Peers
+	}
+
+	result := make(map[uint64]string, len(peers))
+	for _, address := range peers {
+		id := peerID(address)
+		if other, ok := result[id]; ok && other != address {
+			return nil, 0, fmt.Errorf("raft peers %s and %s have the same id, use different addresses", other, address)
+		}
+		result[id] = address
+	}
+
+	id := peerID(self)
+	if result[id] != self {
+		return nil, 0, errors.NewErrorRaftNotAPeer(self)
+	}
+	return result, id, nil
+}
+
+// The raft id of a replica is derived from its address, so all replicas agree on it without coordination
+func peerID(address string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(address))
+	id := h.Sum64()
+	// Zero is not a valid raft id
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
@@ -0,0 +1,109 @@
+package raft
+
+import (
+	"testing"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolvePeers(t *testing.T) {
+	tMatrix := []struct {
+		Name     string
+		Config   RaftConfig
+		Hostname string
+		Self     string
+		Peers    []string
+		Error    error
+	}{
+		{
+			Name: "StaticPeers",
+			Config: RaftConfig{
+				Peers: []string{"node-a:7000", "node-b:7000", "node-c:7000"},
+			},
+			Hostname: "node-b",
+			Self:     "node-b:7000",
+			Peers:    []string{"node-a:7000", "node-b:7000", "node-c:7000"},
+		},
+		{
+			Name: "StaticPeersWithAdvertise",
+			Config: RaftConfig{
+				Listen:    ":9000",
+				Advertise: "10.0.0.2:9000",
+				Peers:     []string{"10.0.0.1:9000", "10.0.0.2:9000"},
+			},
+			Hostname: "ignored",
+			Self:     "10.0.0.2:9000",
+			Peers:    []string{"10.0.0.1:9000", "10.0.0.2:9000"},
+		},
+		{
+			Name: "Discovery",
+			Config: RaftConfig{
+				Discovery: RaftDiscoveryConfig{
+					Service:  "fleetlock-raft.fleetlock.svc",
+					Replicas: 3,
+				},
+			},
+			Hostname: "fleetlock-1",
+			Self:     "fleetlock-1.fleetlock-raft.fleetlock.svc:7000",
+			Peers: []string{
+				"fleetlock-0.fleetlock-raft.fleetlock.svc:7000",
+				"fleetlock-1.fleetlock-raft.fleetlock.svc:7000",
+				"fleetlock-2.fleetlock-raft.fleetlock.svc:7000",
+			},
+		},
+		{
+			Name:     "NoPeers",
+			Config:   RaftConfig{},
+			Hostname: "node-a",
+			Error:    errors.NewErrorRaftNoPeers(),
+		},
+		{
+			Name: "NotAPeer",
+			Config: RaftConfig{
+				Peers: []string{"node-a:7000", "node-b:7000"},
+			},
+			Hostname: "node-c",
+			Error:    errors.NewErrorRaftNotAPeer("node-c:7000"),
+		},
+		{
+			Name: "DiscoveryWithoutOrdinal",
+			Config: RaftConfig{
+				Discovery: RaftDiscoveryConfig{
+					Service:  "fleetlock-raft",
+					Replicas: 3,
+				},
+			},
+			Hostname: "fleetlock-abcde",
+			Error:    errors.NewErrorRaftInvalidHostname("fleetlock-abcde"),
+		},
+		{
+			Name: "DiscoveryWithoutReplicas",
+			Config: RaftConfig{
+				Discovery: RaftDiscoveryConfig{
+					Service: "fleetlock-raft",
+				},
+			},
+			Hostname: "fleetlock-0",
+			Error:    errors.NewErrorRaftNoPeers(),
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			peers, id, err := tCase.Config.resolvePeers(tCase.Hostname)
+
+			assert.Equal(tCase.Error, err)
+			if tCase.Error != nil {
+				return
+			}
+			assert.Equal(peerID(tCase.Self), id, "Should use the id of the own address")
+			assert.Len(peers, len(tCase.Peers))
+			for _, peer := range tCase.Peers {
+				assert.Equal(peer, peers[peerID(peer)], "Should contain peer")
+			}
+		})
+	}
+}
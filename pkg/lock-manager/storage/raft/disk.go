@@ -0,0 +1,202 @@
+package raft
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	etcdraft "go.etcd.io/raft/v3"
+	"go.etcd.io/raft/v3/raftpb"
+	"google.golang.org/protobuf/proto"
+)
+
+const dbFile = "raft.db"
+
+var (
+	bucketEntries = []byte("entries")
+	bucketState   = []byte("state")
+
+	keyHardState = []byte("hardstate")
+	keySnapshot  = []byte("snapshot")
+)
+
+// Persists the raft log, so a replica can rejoin the cluster after a restart.
+// Every write is synced to disk before it is acknowledged to the other replicas.
+type diskStorage struct {
+	db *bolt.DB
+}
+
+func openDiskStorage(dir string) (*diskStorage, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, dbFile), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open raft database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{bucketEntries, bucketState} {
+			_, err := tx.CreateBucketIfNotExists(bucket)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to initialize raft database: %w", err)
+	}
+
+	return &diskStorage{db: db}, nil
+}
+
+// Load the persisted log into the memory storage used by raft.
+// Returns the persisted snapshot and false if nothing was persisted yet.
+func (d *diskStorage) load(ms *etcdraft.MemoryStorage) (*raftpb.Snapshot, bool, error) {
+	snap := &raftpb.Snapshot{}
+	hs := &raftpb.HardState{}
+	var entries []*raftpb.Entry
+	found := false
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		state := tx.Bucket(bucketState)
+		if b := state.Get(keySnapshot); b != nil {
+			found = true
+			err := proto.Unmarshal(b, snap)
+			if err != nil {
+				return fmt.Errorf("failed to parse snapshot: %w", err)
+			}
+		}
+		if b := state.Get(keyHardState); b != nil {
+			found = true
+			err := proto.Unmarshal(b, hs)
+			if err != nil {
+				return fmt.Errorf("failed to parse hard state: %w", err)
+			}
+		}
+
+		c := tx.Bucket(bucketEntries).Cursor()
+		for k, v := c.Seek(indexKey(snap.GetMetadata().GetIndex() + 1)); k != nil; k, v = c.Next() {
+			entry := &raftpb.Entry{}
+			err := proto.Unmarshal(v, entry)
+			if err != nil {
+				return fmt.Errorf("failed to parse entry %d: %w", binary.BigEndian.Uint64(k), err)
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil || !found {
+		return snap, found, err
+	}
+
+	if !etcdraft.IsEmptySnap(snap) {
+		err = ms.ApplySnapshot(snap)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	err = ms.SetHardState(hs)
+	if err != nil {
+		return nil, false, err
+	}
+	return snap, true, ms.Append(entries)
+}
+
+// Persist the hard state and new entries of a ready, replacing conflicting entries
+func (d *diskStorage) save(hs *raftpb.HardState, entries []*raftpb.Entry) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		if !etcdraft.IsEmptyHardState(hs) {
+			b, err := proto.Marshal(hs)
+			if err != nil {
+				return err
+			}
+			err = tx.Bucket(bucketState).Put(keyHardState, b)
+			if err != nil {
+				return err
+			}
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		bucket := tx.Bucket(bucketEntries)
+		err := deleteFrom(bucket, entries[0].GetIndex())
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			b, err := proto.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			err = bucket.Put(indexKey(entry.GetIndex()), b)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Persist a snapshot and drop all entries up to compactIndex.
+// A snapshot received from the leader replaces the whole log, so compactIndex should be math.MaxUint64 then.
+func (d *diskStorage) saveSnapshot(snap *raftpb.Snapshot, compactIndex uint64) error {
+	b, err := proto.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return d.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(bucketState).Put(keySnapshot, b)
+		if err != nil {
+			return err
+		}
+
+		bucket := tx.Bucket(bucketEntries)
+		var keys [][]byte
+		c := bucket.Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= compactIndex; k, _ = c.Next() {
+			keys = append(keys, k)
+		}
+		return deleteKeys(bucket, keys)
+	})
+}
+
+func (d *diskStorage) close() error {
+	return d.db.Close()
+}
+
+// Delete all entries starting at the given index
+func deleteFrom(bucket *bolt.Bucket, index uint64) error {
+	var keys [][]byte
+	c := bucket.Cursor()
+	for k, _ := c.Seek(indexKey(index)); k != nil; k, _ = c.Next() {
+		keys = append(keys, k)
+	}
+	return deleteKeys(bucket, keys)
+}
+
+// Deleting while iterating with a cursor skips keys, so they are collected first
+func deleteKeys(bucket *bolt.Bucket, keys [][]byte) error {
+	for _, k := range keys {
+		err := bucket.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Keys are big endian, so the entries are sorted by index
+func indexKey(index uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, index)
+	return key
+}
@@ -0,0 +1,71 @@
+package raft
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Forwards the log output of raft to slog
+type logger struct {
+	id string
+}
+
+func (l logger) log(level slog.Level, msg string) {
+	slog.Log(context.Background(), level, msg, slog.String("component", "raft"), slog.String("node", l.id))
+}
+
+func (l logger) Debug(v ...any) {
+	l.log(slog.LevelDebug, fmt.Sprint(v...))
+}
+
+func (l logger) Debugf(format string, v ...any) {
+	l.log(slog.LevelDebug, fmt.Sprintf(format, v...))
+}
+
+func (l logger) Info(v ...any) {
+	l.log(slog.LevelInfo, fmt.Sprint(v...))
+}
+
+func (l logger) Infof(format string, v ...any) {
+	l.log(slog.LevelInfo, fmt.Sprintf(format, v...))
+}
+
+func (l logger) Warning(v ...any) {
+	l.log(slog.LevelWarn, fmt.Sprint(v...))
+}
+
+func (l logger) Warningf(format string, v ...any) {
+	l.log(slog.LevelWarn, fmt.Sprintf(format, v...))
+}
+
+func (l logger) Error(v ...any) {
+	l.log(slog.LevelError, fmt.Sprint(v...))
+}
+
+func (l logger) Errorf(format string, v ...any) {
+	l.log(slog.LevelError, fmt.Sprintf(format, v...))
+}
+
+func (l logger) Fatal(v ...any) {
+	l.log(slog.LevelError, fmt.Sprint(v...))
+	os.Exit(1)
+}
+
+func (l logger) Fatalf(format string, v ...any) {
+	l.log(slog.LevelError, fmt.Sprintf(format, v...))
+	os.Exit(1)
+}
+
+func (l logger) Panic(v ...any) {
+	msg := fmt.Sprint(v...)
+	l.log(slog.LevelError, msg)
+	panic(msg)
+}
+
+func (l logger) Panicf(format string, v ...any) {
+	msg := fmt.Sprintf(format, v...)
+	l.log(slog.LevelError, msg)
+	panic(msg)
+}
@@ -0,0 +1,185 @@
+package raft
+
+import (
+	"encoding/json/v2"
+	"slices"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+)
+
+// Operations replicated through the raft log
+const (
+	opReserve     = "reserve"
+	opTryReserve  = "try_reserve"
+	opRenew       = "renew"
+	opRelease     = "release"
+	opRecordEvent = "record_event"
+)
+
+// Only the newest events are kept, to limit the size of the snapshots
+const maxEvents = 10000
+
+// A change to the state, proposed by one replica and applied by all of them.
+// Applying needs to be deterministic, so the time of the request is part of the command.
+type command struct {
+	// Random identifier, used by the proposing replica to find the result
+	Request uint64    `json:"request"`
+	Op      string    `json:"op"`
+	Time    time.Time `json:"time"`
+	Group   string    `json:"group,omitempty"`
+	ID      string    `json:"id,omitempty"`
+	Owner   string    `json:"owner,omitempty"`
+	Slots   int       `json:"slots,omitempty"`
+	// When the lock expires, zero if it does not
+	Expires time.Time    `json:"expires,omitzero"`
+	Event   *types.Event `json:"event,omitempty"`
+
+	// Converted to expires when proposing
+	ttl time.Duration
+}
+
+// The replicated locks and events
+type state struct {
+	Groups map[string][]lock `json:"groups"`
+	Events []types.Event     `json:"events"`
+}
+
+type lock struct {
+	ID      string    `json:"id"`
+	Owner   string    `json:"owner,omitempty"`
+	Created time.Time `json:"created"`
+	// Zero if the lock does not expire
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+func newState() *state {
+	return &state{
+		Groups: make(map[string][]lock),
+	}
+}
+
+// Restore the state from the data of a snapshot
+func unmarshalState(data []byte) (*state, error) {
+	s := newState()
+	if len(data) == 0 {
+		return s, nil
+	}
+	err := json.Unmarshal(data, s)
+	if err != nil {
+		return nil, err
+	}
+	if s.Groups == nil {
+		s.Groups = make(map[string][]lock)
+	}
+	return s, nil
+}
+
+func (s *state) marshal() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// Apply the command and return if it succeeded.
+// The result is only meaningful for try_reserve, all other operations always succeed.
+func (s *state) apply(cmd command) bool {
+	switch cmd.Op {
+	case opReserve:
+		s.reserve(cmd)
+		return true
+	case opTryReserve:
+		s.removeExpired(cmd.Group, cmd.Time)
+		if s.hasLock(cmd.Group, cmd.ID, cmd.Time) {
+			return true
+		}
+		if len(s.Groups[cmd.Group]) >= cmd.Slots {
+			return false
+		}
+		s.reserve(cmd)
+		return true
+	case opRenew:
+		locks := s.Groups[cmd.Group]
+		for i, l := range locks {
+			if l.ID == cmd.ID && !l.expired(cmd.Time) {
+				locks[i].Expires = cmd.Expires
+				break
+			}
+		}
+		return true
+	case opRelease:
+		locks := slices.DeleteFunc(s.Groups[cmd.Group], func(l lock) bool {
+			return l.ID == cmd.ID
+		})
+		if len(locks) == 0 {
+			delete(s.Groups, cmd.Group)
+		} else {
+			s.Groups[cmd.Group] = locks
+		}
+		return true
+	case opRecordEvent:
+		if cmd.Event != nil {
+			s.Events = append(s.Events, *cmd.Event)
+			if len(s.Events) > maxEvents {
+				s.Events = slices.Delete(s.Events, 0, len(s.Events)-maxEvents)
+			}
+		}
+		return true
+	default:
+		// Entries written by a newer version are skipped, so older replicas don't crash
+		return false
+	}
+}
+
+func (s *state) reserve(cmd command) {
+	s.removeExpired(cmd.Group, cmd.Time)
+	if s.hasLock(cmd.Group, cmd.ID, cmd.Time) {
+		return
+	}
+
+	l := lock{
+		ID:      cmd.ID,
+		Owner:   cmd.Owner,
+		Created: cmd.Time,
+		Expires: cmd.Expires,
+	}
+	s.Groups[cmd.Group] = append(s.Groups[cmd.Group], l)
+}
+
+func (s *state) hasLock(group, id string, now time.Time) bool {
+	for _, l := range s.Groups[group] {
+		if l.ID == id && !l.expired(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return all locks of the group that are not expired at the given time
+func (s *state) locks(group string, now time.Time) []types.Lock {
+	result := make([]types.Lock, 0, len(s.Groups[group]))
+	for _, l := range s.Groups[group] {
+		if l.expired(now) {
+			continue
+		}
+		result = append(result, types.Lock{
+			Group:   group,
+			ID:      l.ID,
+			Created: l.Created,
+			Owner:   l.Owner,
+		})
+	}
+	return result
+}
+
+func (s *state) removeExpired(group string, now time.Time) {
+	locks, ok := s.Groups[group]
+	if !ok {
+		return
+	}
+	s.Groups[group] = slices.DeleteFunc(locks, func(l lock) bool {
+		return l.expired(now)
+	})
+}
+
+func (l lock) expired(now time.Time) bool {
+	return !l.Expires.IsZero() && now.After(l.Expires)
+}
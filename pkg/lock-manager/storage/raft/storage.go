@@ -0,0 +1,613 @@
+package raft
+
+import (
+	"context"
+	"encoding/json/v2"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+	etcdraft "go.etcd.io/raft/v3"
+	"go.etcd.io/raft/v3/raftpb"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	tickInterval = 100 * time.Millisecond
+	// A follower starts an election after not hearing from the leader for 10 ticks
+	electionTicks  = 10
+	heartbeatTicks = 1
+
+	// Time to wait for a change to be committed or a read to be confirmed by the leader
+	timeout = 5 * time.Second
+	// Time to wait before proposing again, when there was no leader to accept it
+	retryInterval = 200 * time.Millisecond
+
+	// Create a snapshot and compact the log after this many entries
+	snapshotEntries = 1000
+	// Entries kept after compacting, so slow followers can catch up without a snapshot
+	compactionMargin = 100
+)
+
+// Replicates the locks between the fleetlock replicas using raft.
+// Every replica holds the full state in memory and persists the raft log in its data directory.
+type RaftBackend struct {
+	id        uint64
+	node      etcdraft.Node
+	storage   *etcdraft.MemoryStorage
+	disk      *diskStorage
+	transport *transport
+
+	state     *state
+	stateLock sync.RWMutex
+
+	// Only accessed by the run loop
+	confState     *raftpb.ConfState
+	snapshotIndex uint64
+
+	// Protected by waitLock
+	waitLock  sync.Mutex
+	applied   uint64
+	appliedCh chan struct{}
+	proposals map[uint64]chan bool
+	reads     map[string]chan uint64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewRaftBackend(cfg RaftConfig) (*RaftBackend, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return newRaftBackend(cfg, hostname)
+}
+
+func newRaftBackend(cfg RaftConfig, hostname string) (*RaftBackend, error) {
+	if cfg.DataDir == "" {
+		return nil, errors.NewErrorRaftDataDirRequired()
+	}
+	peers, id, err := cfg.resolvePeers(hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	disk, err := openDiskStorage(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	ms := etcdraft.NewMemoryStorage()
+	snap, restart, err := disk.load(ms)
+	if err != nil {
+		_ = disk.close()
+		return nil, fmt.Errorf("failed to load raft log: %w", err)
+	}
+	s, err := unmarshalState(snap.GetData())
+	if err != nil {
+		_ = disk.close()
+		return nil, fmt.Errorf("failed to restore state from snapshot: %w", err)
+	}
+
+	b := &RaftBackend{
+		id:            id,
+		storage:       ms,
+		disk:          disk,
+		state:         s,
+		confState:     snap.GetMetadata().GetConfState(),
+		snapshotIndex: snap.GetMetadata().GetIndex(),
+		applied:       snap.GetMetadata().GetIndex(),
+		appliedCh:     make(chan struct{}),
+		proposals:     make(map[uint64]chan bool),
+		reads:         make(map[string]chan uint64),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	raftCfg := &etcdraft.Config{
+		ID:              id,
+		ElectionTick:    electionTicks,
+		HeartbeatTick:   heartbeatTicks,
+		Storage:         ms,
+		Applied:         b.applied,
+		MaxSizePerMsg:   1024 * 1024,
+		MaxInflightMsgs: peerQueueSize,
+		CheckQuorum:     true,
+		PreVote:         true,
+		Logger:          logger{id: strconv.FormatUint(id, 16)},
+	}
+	if restart {
+		slog.Info("Restarting raft replica from persisted log", slog.String("dataDir", cfg.DataDir))
+		b.node = etcdraft.RestartNode(raftCfg)
+	} else {
+		slog.Info("Starting new raft replica", slog.Any("peers", slices.Sorted(maps.Values(peers))))
+		raftPeers := make([]etcdraft.Peer, 0, len(peers))
+		for _, peerID := range slices.Sorted(maps.Keys(peers)) {
+			raftPeers = append(raftPeers, etcdraft.Peer{ID: peerID})
+		}
+		b.node = etcdraft.StartNode(raftCfg, raftPeers)
+	}
+
+	listen := cfg.Listen
+	if listen == "" {
+		listen = defaultListen
+	}
+	b.transport = newTransport(id, cfg.Token, peers, b.node)
+	err = b.transport.start(listen)
+	if err != nil {
+		b.node.Stop()
+		_ = disk.close()
+		return nil, err
+	}
+
+	go b.run()
+
+	return b, nil
+}
+
+// Drive the raft node until the backend is closed
+func (b *RaftBackend) run() {
+	defer close(b.done)
+	defer b.node.Stop()
+
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.node.Tick()
+		case rd := <-b.node.Ready():
+			err := b.handleReady(rd)
+			if err != nil {
+				// Continuing without persisting the log could break the guarantees of raft
+				slog.Error("Failed to process raft log, stopping replica", "error", err)
+				return
+			}
+			b.node.Advance()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+func (b *RaftBackend) handleReady(rd etcdraft.Ready) error {
+	if !etcdraft.IsEmptySnap(rd.Snapshot) {
+		err := b.restoreSnapshot(rd.Snapshot)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Everything needs to be on disk, before other replicas are told about it
+	err := b.disk.save(rd.HardState, rd.Entries)
+	if err != nil {
+		return fmt.Errorf("failed to persist raft log: %w", err)
+	}
+	if !etcdraft.IsEmptyHardState(rd.HardState) {
+		err = b.storage.SetHardState(rd.HardState)
+		if err != nil {
+			return err
+		}
+	}
+	err = b.storage.Append(rd.Entries)
+	if err != nil {
+		return err
+	}
+
+	b.transport.send(rd.Messages)
+
+	err = b.applyEntries(rd.CommittedEntries)
+	if err != nil {
+		return err
+	}
+
+	b.waitLock.Lock()
+	for _, rs := range rd.ReadStates {
+		if ch, ok := b.reads[string(rs.RequestCtx)]; ok {
+			ch <- rs.Index
+			delete(b.reads, string(rs.RequestCtx))
+		}
+	}
+	b.waitLock.Unlock()
+
+	return b.maybeSnapshot()
+}
+
+// Replace the state with a snapshot received from the leader
+func (b *RaftBackend) restoreSnapshot(snap *raftpb.Snapshot) error {
+	s, err := unmarshalState(snap.GetData())
+	if err != nil {
+		return fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	err = b.disk.saveSnapshot(snap, math.MaxUint64)
+	if err != nil {
+		return fmt.Errorf("failed to persist snapshot: %w", err)
+	}
+	err = b.storage.ApplySnapshot(snap)
+	if err != nil {
+		return err
+	}
+
+	b.stateLock.Lock()
+	b.state = s
+	b.stateLock.Unlock()
+
+	b.confState = snap.GetMetadata().GetConfState()
+	b.snapshotIndex = snap.GetMetadata().GetIndex()
+	b.setApplied(snap.GetMetadata().GetIndex())
+	return nil
+}
+
+func (b *RaftBackend) applyEntries(entries []*raftpb.Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	b.waitLock.Lock()
+	applied := b.applied
+	b.waitLock.Unlock()
+
+	for _, e := range entries {
+		if e.GetIndex() <= applied {
+			continue
+		}
+
+		switch e.GetType() {
+		case raftpb.EntryNormal:
+			// A new leader commits an empty entry
+			if len(e.GetData()) == 0 {
+				break
+			}
+			var cmd command
+			err := json.Unmarshal(e.GetData(), &cmd)
+			if err != nil {
+				slog.Error("Skipping unreadable raft entry", slog.Uint64("index", e.GetIndex()), "error", err)
+				break
+			}
+
+			b.stateLock.Lock()
+			result := b.state.apply(cmd)
+			b.stateLock.Unlock()
+
+			b.waitLock.Lock()
+			if ch, ok := b.proposals[cmd.Request]; ok {
+				ch <- result
+				delete(b.proposals, cmd.Request)
+			}
+			b.waitLock.Unlock()
+		case raftpb.EntryConfChange:
+			cc := &raftpb.ConfChange{}
+			err := proto.Unmarshal(e.GetData(), cc)
+			if err != nil {
+				return fmt.Errorf("failed to parse configuration change: %w", err)
+			}
+			b.confState = b.node.ApplyConfChange(cc)
+		case raftpb.EntryConfChangeV2:
+			cc := &raftpb.ConfChangeV2{}
+			err := proto.Unmarshal(e.GetData(), cc)
+			if err != nil {
+				return fmt.Errorf("failed to parse configuration change: %w", err)
+			}
+			b.confState = b.node.ApplyConfChange(cc)
+		}
+		applied = e.GetIndex()
+	}
+
+	b.setApplied(applied)
+	return nil
+}
+
+// Create a snapshot of the state and compact the log, once enough entries are applied
+func (b *RaftBackend) maybeSnapshot() error {
+	b.waitLock.Lock()
+	applied := b.applied
+	b.waitLock.Unlock()
+
+	if applied-b.snapshotIndex < snapshotEntries {
+		return nil
+	}
+
+	b.stateLock.RLock()
+	data, err := b.state.marshal()
+	b.stateLock.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	snap, err := b.storage.CreateSnapshot(applied, b.confState, data)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	compactIndex := applied - compactionMargin
+	err = b.disk.saveSnapshot(snap, compactIndex)
+	if err != nil {
+		return fmt.Errorf("failed to persist snapshot: %w", err)
+	}
+	err = b.storage.Compact(compactIndex)
+	if err != nil && err != etcdraft.ErrCompacted {
+		return fmt.Errorf("failed to compact raft log: %w", err)
+	}
+	b.snapshotIndex = applied
+	return nil
+}
+
+func (b *RaftBackend) setApplied(index uint64) {
+	b.waitLock.Lock()
+	defer b.waitLock.Unlock()
+
+	if index <= b.applied {
+		return
+	}
+	b.applied = index
+	close(b.appliedCh)
+	b.appliedCh = make(chan struct{})
+}
+
+// Replicate the command and wait until it is applied locally, returns the result of applying it
+func (b *RaftBackend) propose(cmd command) (bool, error) {
+	cmd.Request = rand.Uint64()
+	cmd.Time = time.Now()
+	if cmd.ttl > 0 {
+		cmd.Expires = cmd.Time.Add(cmd.ttl)
+	}
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return false, err
+	}
+
+	result := make(chan bool, 1)
+	b.waitLock.Lock()
+	b.proposals[cmd.Request] = result
+	b.waitLock.Unlock()
+	defer func() {
+		b.waitLock.Lock()
+		delete(b.proposals, cmd.Request)
+		b.waitLock.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Proposals are dropped while there is no leader, e.g. during an election
+	for {
+		err = b.node.Propose(ctx, data)
+		if err != etcdraft.ErrProposalDropped {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return false, errors.NewErrorRaftTimeout()
+		case <-time.After(retryInterval):
+		}
+	}
+	if ctx.Err() != nil {
+		return false, errors.NewErrorRaftTimeout()
+	} else if err != nil {
+		return false, fmt.Errorf("failed to propose change: %w", err)
+	}
+
+	select {
+	case res := <-result:
+		return res, nil
+	case <-ctx.Done():
+		return false, errors.NewErrorRaftTimeout()
+	case <-b.done:
+		return false, etcdraft.ErrStopped
+	}
+}
+
+// Wait until the state contains all changes committed before the call, then read it.
+// Ensures a replica does not return stale data, e.g. after being partitioned from the leader.
+func (b *RaftBackend) read(fn func(s *state)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	index, err := b.readIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		b.waitLock.Lock()
+		applied, ch := b.applied, b.appliedCh
+		b.waitLock.Unlock()
+		if applied >= index {
+			break
+		}
+
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return errors.NewErrorRaftTimeout()
+		case <-b.done:
+			return etcdraft.ErrStopped
+		}
+	}
+
+	b.stateLock.RLock()
+	defer b.stateLock.RUnlock()
+	fn(b.state)
+	return nil
+}
+
+// Ask the leader for its commit index
+func (b *RaftBackend) readIndex(ctx context.Context) (uint64, error) {
+	key := strconv.FormatUint(rand.Uint64(), 16)
+
+	result := make(chan uint64, 1)
+	b.waitLock.Lock()
+	b.reads[key] = result
+	b.waitLock.Unlock()
+	defer func() {
+		b.waitLock.Lock()
+		delete(b.reads, key)
+		b.waitLock.Unlock()
+	}()
+
+	// The request is silently dropped without a leader, so it is repeated until answered
+	for {
+		err := b.node.ReadIndex(ctx, []byte(key))
+		if ctx.Err() != nil {
+			return 0, errors.NewErrorRaftTimeout()
+		} else if err != nil {
+			return 0, err
+		}
+
+		select {
+		case index := <-result:
+			return index, nil
+		case <-ctx.Done():
+			return 0, errors.NewErrorRaftTimeout()
+		case <-b.done:
+			return 0, etcdraft.ErrStopped
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// Reserve a lock for the given group.
+// Returns true if the lock is successfully reserved, even if the lock is already held by the specific id
+func (b *RaftBackend) Reserve(group, id, owner string, ttl time.Duration) error {
+	_, err := b.propose(command{
+		Op:    opReserve,
+		Group: group,
+		ID:    id,
+		Owner: owner,
+		ttl:   ttl,
+	})
+	return err
+}
+
+// Reserve a lock for the given group, if the group holds less than the given number of locks.
+// Returns true if the lock is reserved, even if it was already held by the id, and false when all slots are taken.
+// The check is part of the replicated command, so it is applied in the same order on all replicas.
+func (b *RaftBackend) TryReserve(group, id, owner string, slots int, ttl time.Duration) (bool, error) {
+	return b.propose(command{
+		Op:    opTryReserve,
+		Group: group,
+		ID:    id,
+		Owner: owner,
+		Slots: slots,
+		ttl:   ttl,
+	})
+}
+
+// Extend the lock held by the id, so that it expires ttl from now.
+// Does not fail when no lock is held.
+func (b *RaftBackend) Renew(group, id string, ttl time.Duration) error {
+	_, err := b.propose(command{
+		Op:    opRenew,
+		Group: group,
+		ID:    id,
+		ttl:   ttl,
+	})
+	return err
+}
+
+// Returns the current number of locks for the given group
+func (b *RaftBackend) GetLocks(group string) (int, error) {
+	var count int
+	err := b.read(func(s *state) {
+		count = len(s.locks(group, time.Now()))
+	})
+	return count, err
+}
+
+// Release the lock currently held by the id.
+// Does not fail when no lock is held.
+func (b *RaftBackend) Release(group, id string) error {
+	_, err := b.propose(command{
+		Op:    opRelease,
+		Group: group,
+		ID:    id,
+	})
+	return err
+}
+
+// Return all locks older than x
+func (b *RaftBackend) GetStaleLocks(ts time.Duration) ([]types.Lock, error) {
+	result := make([]types.Lock, 0)
+	err := b.read(func(s *state) {
+		now := time.Now()
+		for group := range s.Groups {
+			for _, l := range s.locks(group, now) {
+				if now.Sub(l.Created) > ts {
+					result = append(result, l)
+				}
+			}
+		}
+	})
+	return result, err
+}
+
+// Return all locks currently held in the given group
+func (b *RaftBackend) ListLocks(group string) ([]types.Lock, error) {
+	var result []types.Lock
+	err := b.read(func(s *state) {
+		result = s.locks(group, time.Now())
+	})
+	return result, err
+}
+
+// Check if a given id already has a lock for this group
+func (b *RaftBackend) HasLock(group, id string) (bool, error) {
+	var ok bool
+	err := b.read(func(s *state) {
+		ok = s.hasLock(group, id, time.Now())
+	})
+	return ok, err
+}
+
+// Persist the audit event, only the newest events are kept
+func (b *RaftBackend) RecordEvent(event types.Event) error {
+	_, err := b.propose(command{
+		Op:    opRecordEvent,
+		Event: &event,
+	})
+	return err
+}
+
+// Return the audit events matching the filter, sorted from oldest to newest
+func (b *RaftBackend) QueryEvents(filter types.EventFilter) ([]types.Event, error) {
+	var result []types.Event
+	err := b.read(func(s *state) {
+		result = filter.Apply(s.Events)
+	})
+	return result, err
+}
+
+// Check if the replica is running and knows the current leader
+func (b *RaftBackend) Ping() error {
+	select {
+	case <-b.done:
+		return etcdraft.ErrStopped
+	default:
+	}
+	if b.node.Status().Lead == etcdraft.None {
+		return errors.NewErrorRaftNoLeader()
+	}
+	return nil
+}
+
+// Stop the replica, the other replicas continue as long as they have a majority
+func (b *RaftBackend) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		close(b.stop)
+		<-b.done
+		b.transport.stop()
+		err = b.disk.close()
+	})
+	return err
+}
@@ -0,0 +1,224 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	etcdraft "go.etcd.io/raft/v3"
+	"go.etcd.io/raft/v3/raftpb"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	messagePath = "/raft/v1/message"
+
+	// Messages queued per peer, raft resends dropped messages
+	peerQueueSize = 256
+	// Time to deliver a message to a peer, snapshots are small enough to share it
+	sendTimeout = 5 * time.Second
+	// Upper limit for the size of a received message
+	maxMessageSize = 64 << 20
+)
+
+// Delivers raft messages between the replicas over http
+type transport struct {
+	id     uint64
+	token  string
+	node   etcdraft.Node
+	client *http.Client
+	server *http.Server
+	peers  map[uint64]*peer
+
+	// Cancelled on stop, aborts messages currently being sent
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type peer struct {
+	id    uint64
+	url   string
+	queue chan *raftpb.Message
+}
+
+// Create a transport to the given peers, keyed by their raft id
+func newTransport(id uint64, token string, peers map[uint64]string, node etcdraft.Node) *transport {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &transport{
+		id:    id,
+		token: token,
+		node:  node,
+		client: &http.Client{
+			Timeout: sendTimeout,
+		},
+		peers:  make(map[uint64]*peer, len(peers)),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for peerID, address := range peers {
+		if peerID == id {
+			continue
+		}
+		t.peers[peerID] = &peer{
+			id:    peerID,
+			url:   "http://" + address + messagePath,
+			queue: make(chan *raftpb.Message, peerQueueSize),
+		}
+	}
+	return t
+}
+
+// Start listening for messages from the other replicas and sending messages to them
+func (t *transport) start(listen string) error {
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen for raft peers: %w", err)
+	}
+
+	router := http.NewServeMux()
+	router.HandleFunc("POST "+messagePath, t.handleMessage)
+	t.server = &http.Server{
+		Handler:     router,
+		ReadTimeout: sendTimeout,
+	}
+
+	t.wg.Go(func() {
+		err := t.server.Serve(l)
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("Raft transport stopped unexpectedly", "error", err)
+		}
+	})
+	for _, p := range t.peers {
+		t.wg.Go(func() {
+			t.sendLoop(p)
+		})
+	}
+	return nil
+}
+
+// Queue the messages for sending, drops them when the peer can't keep up
+func (t *transport) send(msgs []*raftpb.Message) {
+	for _, m := range msgs {
+		p, ok := t.peers[m.GetTo()]
+		if !ok {
+			slog.Warn("Dropping raft message to unknown peer", slog.Uint64("peer", m.GetTo()))
+			continue
+		}
+		select {
+		case p.queue <- m:
+		default:
+			t.reportFailure(m)
+		}
+	}
+}
+
+func (t *transport) sendLoop(p *peer) {
+	for {
+		var m *raftpb.Message
+		select {
+		case <-t.ctx.Done():
+			return
+		case m = <-p.queue:
+		}
+
+		err := t.post(p, m)
+		if err != nil {
+			slog.Debug("Failed to send raft message", slog.Uint64("peer", p.id), "error", err)
+			t.reportFailure(m)
+			continue
+		}
+		if m.GetType() == raftpb.MsgSnap {
+			t.node.ReportSnapshot(p.id, etcdraft.SnapshotFinish)
+		}
+	}
+}
+
+func (t *transport) post(p *peer, m *raftpb.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(t.ctx, http.MethodPost, p.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return nil
+}
+
+// Tell raft the peer is unreachable, so it slows down sending to it
+func (t *transport) reportFailure(m *raftpb.Message) {
+	t.node.ReportUnreachable(m.GetTo())
+	if m.GetType() == raftpb.MsgSnap {
+		t.node.ReportSnapshot(m.GetTo(), etcdraft.SnapshotFailure)
+	}
+}
+
+func (t *transport) handleMessage(rw http.ResponseWriter, req *http.Request) {
+	if t.token != "" {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(t.token)) != 1 {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	b, err := io.ReadAll(io.LimitReader(req.Body, maxMessageSize))
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	m := &raftpb.Message{}
+	err = proto.Unmarshal(b, m)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, ok := t.peers[m.GetFrom()]; !ok || m.GetTo() != t.id {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	err = t.node.Step(req.Context(), m)
+	if err != nil {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// Stop the server and the senders, queued messages are dropped.
+// Needs to be called after the node is stopped, as messages can't be sent anymore.
+func (t *transport) stop() {
+	if t.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		_ = t.server.Shutdown(ctx)
+	}
+	t.cancel()
+	t.wg.Wait()
+}
@@ -3,10 +3,23 @@ package sql
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
+const (
+	mysqlAddGroup = "INSERT IGNORE INTO lock_groups (group_name) VALUES (?);"
+
+	mysqlLockGroup = "SELECT group_name FROM lock_groups WHERE group_name=? FOR UPDATE;"
+
+	// MySQL does not support IF NOT EXISTS for indexes
+	mysqlCreateEventsIndex = "CREATE INDEX events_event_time ON events (event_time);"
+)
+
+// Error number of MySQL when an index with the same name already exists
+const mysqlErrDupKeyName = 1061
+
 type MySQLConfig struct {
 	Address  string `yaml:"address"`
 	Username string `yaml:"username"`
@@ -16,7 +29,15 @@ type MySQLConfig struct {
 }
 
 func NewMySQLBackend(cfg MySQLConfig) (*SQLBackend, error) {
-	connStr := createConnectionString(cfg.Username, cfg.Password, cfg.Address, cfg.Database, cfg.Options)
+	// The driver only returns time.Time for timestamps when parseTime is enabled
+	options := cfg.Options
+	if !strings.Contains(options, "parseTime=") {
+		if options != "" {
+			options += "&"
+		}
+		options += "parseTime=true"
+	}
+	connStr := createConnectionString(cfg.Username, cfg.Password, cfg.Address, cfg.Database, options)
 
 	db, err := sql.Open("mysql", connStr)
 	if err != nil {
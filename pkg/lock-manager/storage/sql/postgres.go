@@ -8,19 +8,43 @@ import (
 )
 
 const (
-	postgresReserve = `INSERT INTO locks (group_name, id, created)
-		SELECT $1,$2,$3
+	postgresReserve = `INSERT INTO locks (group_name, id, created, expires, owner)
+		SELECT $1,$2,$3,$4,$5
 		WHERE NOT EXISTS (
-			SELECT 1 FROM locks WHERE group_name=$4 AND id=$5
+			SELECT 1 FROM locks WHERE group_name=$6 AND id=$7
 		);`
 
+	postgresAddGroup = "INSERT INTO lock_groups (group_name) VALUES ($1) ON CONFLICT DO NOTHING;"
+
+	postgresLockGroup = "SELECT group_name FROM lock_groups WHERE group_name=$1 FOR UPDATE;"
+
+	postgresRenew = "UPDATE locks SET expires=$1 WHERE group_name=$2 AND id=$3;"
+
+	postgresDeleteExpired = "DELETE FROM locks WHERE group_name=$1 AND expires <= $2;"
+
 	postgresGetLocks = `SELECT COUNT(*) FROM (
-			SELECT id FROM locks WHERE group_name=$1
+			SELECT id FROM locks WHERE group_name=$1 AND (expires IS NULL OR expires > $2)
 		) AS TMP;`
 
 	postgresRelease = "DELETE FROM locks WHERE group_name=$1 AND id=$2;"
 
-	postgresHasLock = "SELECT 1 FROM locks WHERE group_name=$1 AND id=$2;"
+	postgresHasLock = "SELECT 1 FROM locks WHERE group_name=$1 AND id=$2 AND (expires IS NULL OR expires > $3);"
+
+	postgresGetStaleLocks = "SELECT group_name, id, created, owner FROM locks WHERE created < $1 AND (expires IS NULL OR expires > $2);"
+
+	postgresListLocks = "SELECT group_name, id, created, owner FROM locks WHERE group_name=$1 AND (expires IS NULL OR expires > $2);"
+
+	postgresRecordEvent = "INSERT INTO events (event_time, event_type, group_name, id, node, remote, reason) VALUES ($1,$2,$3,$4,$5,$6,$7);"
+
+	postgresTrimEvents = `DELETE FROM events WHERE event_time < (
+			SELECT event_time FROM (
+				SELECT event_time FROM events ORDER BY event_time DESC LIMIT 1 OFFSET $1
+			) AS oldest
+		);`
+
+	postgresQueryEvents = `SELECT event_time, event_type, group_name, id, node, remote, reason FROM events
+		WHERE ($1 = '' OR group_name=$2) AND ($3 = '' OR id=$4) AND event_time >= $5
+		ORDER BY event_time DESC LIMIT $6;`
 )
 
 type PostgresConfig struct {
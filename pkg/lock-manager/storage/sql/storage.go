@@ -2,9 +2,12 @@ package sql
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
 	_ "modernc.org/sqlite"
 )
@@ -14,48 +17,140 @@ const (
 	group_name VARCHAR(100) NOT NULL,
 	id VARCHAR(100) NOT NULL,
 	created TIMESTAMP NOT NULL,
+	expires TIMESTAMP NULL,
+	owner VARCHAR(255) NOT NULL DEFAULT '',
 	PRIMARY KEY (group_name,id)
 	);`
 
-	stmtReserve = `INSERT INTO locks (group_name, id, created)
-		SELECT ?,?,?
+	// One row per group, locked while reserving to prevent multiple servers from reserving the last slot at the same time
+	stmtCreateGroupsTable = `CREATE TABLE IF NOT EXISTS lock_groups (
+	group_name VARCHAR(100) NOT NULL,
+	PRIMARY KEY (group_name)
+	);`
+
+	// Used to check if the table has been created by an older version without expires column
+	stmtCheckExpiresColumn = "SELECT expires FROM locks WHERE 1=0;"
+
+	stmtAddExpiresColumn = "ALTER TABLE locks ADD COLUMN expires TIMESTAMP NULL;"
+
+	// Used to check if the table has been created by an older version without owner column
+	stmtCheckOwnerColumn = "SELECT owner FROM locks WHERE 1=0;"
+
+	stmtAddOwnerColumn = "ALTER TABLE locks ADD COLUMN owner VARCHAR(255) NOT NULL DEFAULT '';"
+
+	stmtReserve = `INSERT INTO locks (group_name, id, created, expires, owner)
+		SELECT ?,?,?,?,?
 		WHERE NOT EXISTS (
 			SELECT 1 FROM locks WHERE group_name=? AND id=?
 		);`
 
+	stmtAddGroup = "INSERT INTO lock_groups (group_name) VALUES (?) ON CONFLICT DO NOTHING;"
+
+	// SQLite has no row locks, but the first write of a transaction locks the whole database until it is committed
+	stmtLockGroup = "UPDATE lock_groups SET group_name=group_name WHERE group_name=?;"
+
+	stmtRenew = "UPDATE locks SET expires=? WHERE group_name=? AND id=?;"
+
+	stmtDeleteExpired = "DELETE FROM locks WHERE group_name=? AND expires <= ?;"
+
 	stmtGetLocks = `SELECT COUNT(*) FROM (
-			SELECT id FROM locks WHERE group_name=?
+			SELECT id FROM locks WHERE group_name=? AND (expires IS NULL OR expires > ?)
 		) AS TMP;`
 
 	stmtRelease = "DELETE FROM locks WHERE group_name=? AND id=?;"
 
-	stmtHasLock = "SELECT 1 FROM locks WHERE group_name=? AND id=?;"
+	stmtHasLock = "SELECT 1 FROM locks WHERE group_name=? AND id=? AND (expires IS NULL OR expires > ?);"
+
+	stmtGetStaleLocks = "SELECT group_name, id, created, owner FROM locks WHERE created < ? AND (expires IS NULL OR expires > ?);"
+
+	stmtListLocks = "SELECT group_name, id, created, owner FROM locks WHERE group_name=? AND (expires IS NULL OR expires > ?);"
+
+	stmtCreateEventsTable = `CREATE TABLE IF NOT EXISTS events (
+	event_time TIMESTAMP NOT NULL,
+	event_type VARCHAR(50) NOT NULL,
+	group_name VARCHAR(100) NOT NULL,
+	id VARCHAR(100) NOT NULL,
+	node VARCHAR(253) NOT NULL,
+	remote VARCHAR(255) NOT NULL,
+	reason TEXT NOT NULL
+	);`
+
+	stmtCreateEventsIndex = "CREATE INDEX IF NOT EXISTS events_event_time ON events (event_time);"
+
+	stmtRecordEvent = "INSERT INTO events (event_time, event_type, group_name, id, node, remote, reason) VALUES (?,?,?,?,?,?,?);"
+
+	// The subquery is wrapped in a derived table, as MySQL can't select from the table it deletes from
+	stmtTrimEvents = `DELETE FROM events WHERE event_time < (
+			SELECT event_time FROM (
+				SELECT event_time FROM events ORDER BY event_time DESC LIMIT 1 OFFSET ?
+			) AS oldest
+		);`
+
+	// Returns the newest events first, so the limit keeps the newest ones
+	stmtQueryEvents = `SELECT event_time, event_type, group_name, id, node, remote, reason FROM events
+		WHERE (? = '' OR group_name=?) AND (? = '' OR id=?) AND event_time >= ?
+		ORDER BY event_time DESC LIMIT ?;`
 )
 
+// Only the newest events are kept, to limit the size of the database
+const maxEvents = 10000
+
 type SQLBackend struct {
 	databaseType string
 
 	db *sql.DB
 
-	reserve  *sql.Stmt
-	getLocks *sql.Stmt
-	release  *sql.Stmt
-	hasLock  *sql.Stmt
+	reserve       *sql.Stmt
+	addGroup      *sql.Stmt
+	lockGroup     *sql.Stmt
+	renew         *sql.Stmt
+	deleteExpired *sql.Stmt
+	getLocks      *sql.Stmt
+	release       *sql.Stmt
+	hasLock       *sql.Stmt
+	getStaleLocks *sql.Stmt
+	listLocks     *sql.Stmt
+	recordEvent   *sql.Stmt
+	trimEvents    *sql.Stmt
+	queryEvents   *sql.Stmt
 }
 
 func (s *SQLBackend) init() error {
-	var reserve, get, release, has string
+	var reserve, addGroup, lockGroup, renew, deleteExpired, get, release, has, stale, list, record, trim, query string
 	switch s.databaseType {
 	case "postgres":
 		reserve = postgresReserve
+		addGroup = postgresAddGroup
+		lockGroup = postgresLockGroup
+		renew = postgresRenew
+		deleteExpired = postgresDeleteExpired
 		get = postgresGetLocks
 		release = postgresRelease
 		has = postgresHasLock
+		stale = postgresGetStaleLocks
+		list = postgresListLocks
+		record = postgresRecordEvent
+		trim = postgresTrimEvents
+		query = postgresQueryEvents
 	default:
 		reserve = stmtReserve
+		addGroup = stmtAddGroup
+		lockGroup = stmtLockGroup
+		renew = stmtRenew
+		deleteExpired = stmtDeleteExpired
 		get = stmtGetLocks
 		release = stmtRelease
 		has = stmtHasLock
+		stale = stmtGetStaleLocks
+		list = stmtListLocks
+		record = stmtRecordEvent
+		trim = stmtTrimEvents
+		query = stmtQueryEvents
+	}
+	// MySQL shares the placeholders with SQLite, but neither supports the syntax of the other for locking the group
+	if s.databaseType == "mysql" {
+		addGroup = mysqlAddGroup
+		lockGroup = mysqlLockGroup
 	}
 
 	_, err := s.db.Exec(stmtCreateTable)
@@ -63,11 +158,62 @@ func (s *SQLBackend) init() error {
 		return fmt.Errorf("failed to create lock table: %w", err)
 	}
 
+	_, err = s.db.Exec(stmtCreateGroupsTable)
+	if err != nil {
+		return fmt.Errorf("failed to create groups table: %w", err)
+	}
+
+	_, err = s.db.Exec(stmtCreateEventsTable)
+	if err != nil {
+		return fmt.Errorf("failed to create events table: %w", err)
+	}
+
+	err = s.createEventsIndex()
+	if err != nil {
+		return fmt.Errorf("failed to create index of events table: %w", err)
+	}
+
+	_, err = s.db.Exec(stmtCheckExpiresColumn)
+	if err != nil {
+		_, err = s.db.Exec(stmtAddExpiresColumn)
+		if err != nil {
+			return fmt.Errorf("failed to add expires column to lock table: %w", err)
+		}
+	}
+
+	_, err = s.db.Exec(stmtCheckOwnerColumn)
+	if err != nil {
+		_, err = s.db.Exec(stmtAddOwnerColumn)
+		if err != nil {
+			return fmt.Errorf("failed to add owner column to lock table: %w", err)
+		}
+	}
+
 	s.reserve, err = s.db.Prepare(reserve)
 	if err != nil {
 		return fmt.Errorf("failed to prepare reserve statement: %w", err)
 	}
 
+	s.addGroup, err = s.db.Prepare(addGroup)
+	if err != nil {
+		return fmt.Errorf("failed to prepare addGroup statement: %w", err)
+	}
+
+	s.lockGroup, err = s.db.Prepare(lockGroup)
+	if err != nil {
+		return fmt.Errorf("failed to prepare lockGroup statement: %w", err)
+	}
+
+	s.renew, err = s.db.Prepare(renew)
+	if err != nil {
+		return fmt.Errorf("failed to prepare renew statement: %w", err)
+	}
+
+	s.deleteExpired, err = s.db.Prepare(deleteExpired)
+	if err != nil {
+		return fmt.Errorf("failed to prepare deleteExpired statement: %w", err)
+	}
+
 	s.getLocks, err = s.db.Prepare(get)
 	if err != nil {
 		return fmt.Errorf("failed to prepare getLocks statement: %w", err)
@@ -83,13 +229,60 @@ func (s *SQLBackend) init() error {
 		return fmt.Errorf("failed to prepare hasLock statement: %w", err)
 	}
 
+	s.getStaleLocks, err = s.db.Prepare(stale)
+	if err != nil {
+		return fmt.Errorf("failed to prepare getStaleLocks statement: %w", err)
+	}
+
+	s.listLocks, err = s.db.Prepare(list)
+	if err != nil {
+		return fmt.Errorf("failed to prepare listLocks statement: %w", err)
+	}
+
+	s.recordEvent, err = s.db.Prepare(record)
+	if err != nil {
+		return fmt.Errorf("failed to prepare recordEvent statement: %w", err)
+	}
+
+	s.trimEvents, err = s.db.Prepare(trim)
+	if err != nil {
+		return fmt.Errorf("failed to prepare trimEvents statement: %w", err)
+	}
+
+	s.queryEvents, err = s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare queryEvents statement: %w", err)
+	}
+
 	return nil
 }
 
+// Create the index used to sort the events by time, if it does not exist yet
+func (s *SQLBackend) createEventsIndex() error {
+	if s.databaseType != "mysql" {
+		_, err := s.db.Exec(stmtCreateEventsIndex)
+		return err
+	}
+
+	_, err := s.db.Exec(mysqlCreateEventsIndex)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDupKeyName {
+		return nil
+	}
+	return err
+}
+
 // Reserve a lock for the given group.
 // Returns true if the lock is successfully reserved, even if the lock is already held by the specific id
-func (s *SQLBackend) Reserve(group string, id string) error {
-	_, err := s.reserve.Exec(group, id, time.Now(), group, id)
+func (s *SQLBackend) Reserve(group, id, owner string, ttl time.Duration) error {
+	now := time.Now()
+
+	_, err := s.deleteExpired.Exec(group, now)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired locks: %w", err)
+	}
+
+	_, err = s.reserve.Exec(group, id, now, expiresAt(now, ttl), owner, group, id)
 	if err != nil {
 		return fmt.Errorf("failed to reserve lock: %w", err)
 	}
@@ -97,9 +290,83 @@ func (s *SQLBackend) Reserve(group string, id string) error {
 	return nil
 }
 
+// Reserve a lock for the given group, if the group holds less than the given number of locks.
+// Returns true if the lock is reserved, even if it was already held by the id, and false when all slots are taken.
+// The row of the group stays locked until the transaction is finished, so other servers have to wait before counting the locks.
+func (s *SQLBackend) TryReserve(group, id, owner string, slots int, ttl time.Duration) (bool, error) {
+	_, err := s.addGroup.Exec(group)
+	if err != nil {
+		return false, fmt.Errorf("failed to add group: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	// Does nothing after a successful commit
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	rows, err := tx.Stmt(s.lockGroup).Query(group)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock group: %w", err)
+	}
+	err = rows.Close()
+	if err != nil {
+		return false, fmt.Errorf("failed to lock group: %w", err)
+	}
+
+	now := time.Now()
+	_, err = tx.Stmt(s.deleteExpired).Exec(group, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete expired locks: %w", err)
+	}
+
+	var exists bool
+	err = tx.Stmt(s.hasLock).QueryRow(group, id, now).Scan(&exists)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("failed to run hasLocks query: %w", err)
+	}
+	if exists {
+		return true, tx.Commit()
+	}
+
+	var count int
+	err = tx.Stmt(s.getLocks).QueryRow(group, now).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to run getLocks query: %w", err)
+	}
+	if count >= slots {
+		return false, tx.Commit()
+	}
+
+	_, err = tx.Stmt(s.reserve).Exec(group, id, now, expiresAt(now, ttl), owner, group, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve lock: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("failed to commit reservation: %w", err)
+	}
+	return true, nil
+}
+
+// Extend the lock held by the id, so that it expires ttl from now.
+// Does not fail when no lock is held.
+func (s *SQLBackend) Renew(group string, id string, ttl time.Duration) error {
+	_, err := s.renew.Exec(expiresAt(time.Now(), ttl), group, id)
+	if err != nil {
+		return fmt.Errorf("failed to renew lock: %w", err)
+	}
+
+	return nil
+}
+
 // Returns the current number of locks for the given group
 func (s *SQLBackend) GetLocks(group string) (int, error) {
-	rows, err := s.getLocks.Query(group)
+	rows, err := s.getLocks.Query(group, time.Now())
 	if err != nil {
 		return 0, fmt.Errorf("failed to run getLocks query: %w", err)
 	}
@@ -134,12 +401,38 @@ func (s *SQLBackend) Release(group string, id string) error {
 
 // Return all locks older than x
 func (s *SQLBackend) GetStaleLocks(ts time.Duration) ([]types.Lock, error) {
-	panic("TODO")
+	now := time.Now()
+	rows, err := s.getStaleLocks.Query(now.Add(-ts), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run getStaleLocks query: %w", err)
+	}
+	defer rows.Close()
+
+	result, err := scanLocks(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read getStaleLocks result: %w", err)
+	}
+	return result, nil
+}
+
+// Return all locks currently held in the given group
+func (s *SQLBackend) ListLocks(group string) ([]types.Lock, error) {
+	rows, err := s.listLocks.Query(group, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to run listLocks query: %w", err)
+	}
+	defer rows.Close()
+
+	result, err := scanLocks(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read listLocks result: %w", err)
+	}
+	return result, nil
 }
 
 // Check if a given id already has a lock for this group
 func (s *SQLBackend) HasLock(group, id string) (bool, error) {
-	rows, err := s.hasLock.Query(group, id)
+	rows, err := s.hasLock.Query(group, id, time.Now())
 	if err != nil {
 		return false, fmt.Errorf("failed to run hasLocks query: %w", err)
 	}
@@ -153,6 +446,56 @@ func (s *SQLBackend) HasLock(group, id string) (bool, error) {
 	return res, err
 }
 
+// Persist the audit event, only the newest events are kept
+func (s *SQLBackend) RecordEvent(event types.Event) error {
+	_, err := s.recordEvent.Exec(event.Time.UTC(), event.Type, event.Group, event.ID, event.Node, event.Remote, event.Reason)
+	if err != nil {
+		return fmt.Errorf("failed to record event: %w", err)
+	}
+
+	_, err = s.trimEvents.Exec(maxEvents - 1)
+	if err != nil {
+		return fmt.Errorf("failed to delete old events: %w", err)
+	}
+	return nil
+}
+
+// Return the audit events matching the filter, sorted from oldest to newest
+func (s *SQLBackend) QueryEvents(filter types.EventFilter) ([]types.Event, error) {
+	// No more than maxEvents are stored, so it also serves as limit when none is set
+	limit := maxEvents
+	if filter.Limit > 0 && filter.Limit < maxEvents {
+		limit = filter.Limit
+	}
+
+	rows, err := s.queryEvents.Query(filter.Group, filter.Group, filter.ID, filter.ID, filter.Since.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run queryEvents query: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]types.Event, 0)
+	for rows.Next() {
+		var event types.Event
+		err = rows.Scan(&event.Time, &event.Type, &event.Group, &event.ID, &event.Node, &event.Remote, &event.Reason)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read queryEvents result: %w", err)
+		}
+		result = append(result, event)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queryEvents result: %w", err)
+	}
+	slices.Reverse(result)
+	return result, nil
+}
+
+// Check if the database is reachable
+func (s *SQLBackend) Ping() error {
+	return s.db.Ping()
+}
+
 // Calls all necessary finalization if necessary
 func (s *SQLBackend) Close() error {
 	return s.db.Close()
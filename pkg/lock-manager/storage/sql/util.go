@@ -1,5 +1,12 @@
 package sql
 
+import (
+	"database/sql"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+)
+
 func createConnectionString(username, password, address, database, options string) string {
 	var connStr string
 	if username != "" {
@@ -16,3 +23,25 @@ func createConnectionString(username, password, address, database, options strin
 
 	return connStr
 }
+
+// Read all rows of a query returning group_name, id, created and owner
+func scanLocks(rows *sql.Rows) ([]types.Lock, error) {
+	result := make([]types.Lock, 0)
+	for rows.Next() {
+		var lock types.Lock
+		err := rows.Scan(&lock.Group, &lock.ID, &lock.Created, &lock.Owner)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, lock)
+	}
+	return result, rows.Err()
+}
+
+// Return the expiry time for a lock with the given ttl, NULL if it does not expire
+func expiresAt(now time.Time, ttl time.Duration) sql.NullTime {
+	if ttl <= 0 {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: now.Add(ttl), Valid: true}
+}
@@ -3,14 +3,57 @@ package valkey
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json/v2"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
 	"github.com/valkey-io/valkey-go"
 )
 
-const keyformat = "group:%s,id:%s"
+// The group is used as hash tag, so in a cluster the locks share the hash slot with the set of their group, which is named after the group.
+const keyformat = "group:{%s},id:%s"
+
+// Format of the keys before they were hash tagged, they are migrated on startup
+const legacyKeyformat = "group:%s,id:%s"
+
+// Group names are validated to not contain ":", so this never collides with the set of a group
+const eventsKey = "fleetlock:events"
+
+// Only the newest events are kept, to limit memory usage
+const maxEvents = 10000
+
+// Scripts are executed atomically, so no other server can reserve a slot between counting and reserving.
+// All keys of a group share a hash slot, so the script also works in a cluster.
+// Members of the group without hash tag are left over from expired legacy locks and removed without accessing them.
+// KEYS: group, lock key
+// ARGV: lock value, slots, ttl in milliseconds or 0
+var tryReserveScript = valkey.NewLuaScript(`
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	return 1
+end
+local prefix = "group:{" .. KEYS[1] .. "},id:"
+local count = 0
+for _, key in ipairs(redis.call("SMEMBERS", KEYS[1])) do
+	if string.sub(key, 1, #prefix) == prefix and redis.call("EXISTS", key) == 1 then
+		count = count + 1
+	else
+		redis.call("SREM", KEYS[1], key)
+	end
+end
+if count >= tonumber(ARGV[2]) then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[2], ARGV[1], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[2], ARGV[1])
+end
+redis.call("SADD", KEYS[1], KEYS[2])
+return 1
+`)
 
 type ValkeyBackend struct {
 	client valkey.Client
@@ -78,44 +121,106 @@ func NewValkeyBackend(cfg ValkeyConfig) (*ValkeyBackend, error) {
 		return nil, fmt.Errorf("failed to connect to valkey server: %v", err)
 	}
 
-	return &ValkeyBackend{
+	r := &ValkeyBackend{
 		client: client,
 		lb:     lb,
-	}, nil
+	}
+	err = r.migrateLegacyKeys(context.Background())
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	return r, nil
 }
 
 // Reserve a lock for the given group.
 // Returns true if the lock is successfully reserved, even if the lock is already held by the specific id
-func (r *ValkeyBackend) Reserve(group string, id string) error {
+func (r *ValkeyBackend) Reserve(group, id, owner string, ttl time.Duration) error {
 	key := fmt.Sprintf(keyformat, group, id)
 	ctx := context.Background()
 
-	cmdSetNX := r.client.B().Setnx().Key(key).Value(time.Now().String()).Build()
+	err := r.removeExpired(ctx, group)
+	if err != nil {
+		return err
+	}
+
+	var cmdSet valkey.Completed
+	value := types.FormatLockValue(time.Now(), owner)
+	if ttl > 0 {
+		cmdSet = r.client.B().Set().Key(key).Value(value).Nx().PxMilliseconds(ttl.Milliseconds()).Build()
+	} else {
+		cmdSet = r.client.B().Set().Key(key).Value(value).Nx().Build()
+	}
 	cmdSAdd := r.client.B().Sadd().Key(group).Member(key).Build()
 
-	ok, err := r.client.Do(ctx, cmdSetNX).AsBool()
-	if err != nil {
+	err = r.client.Do(ctx, cmdSet).Error()
+	if valkey.IsValkeyNil(err) {
+		// Key already exists
+		return nil
+	} else if err != nil {
 		return fmt.Errorf("failed to create key: %w", err)
 	}
 
-	if ok {
-		err := r.client.Do(ctx, cmdSAdd).Error()
-		if err != nil {
-			return fmt.Errorf("failed to add key to group list: %w", err)
-		}
+	err = r.client.Do(ctx, cmdSAdd).Error()
+	if err != nil {
+		return fmt.Errorf("failed to add key to group list: %w", err)
+	}
+
+	return nil
+}
+
+// Reserve a lock for the given group, if the group holds less than the given number of locks.
+// Returns true if the lock is reserved, even if it was already held by the id, and false when all slots are taken.
+func (r *ValkeyBackend) TryReserve(group, id, owner string, slots int, ttl time.Duration) (bool, error) {
+	key := fmt.Sprintf(keyformat, group, id)
+	args := []string{
+		types.FormatLockValue(time.Now(), owner),
+		strconv.Itoa(slots),
+		strconv.FormatInt(ttl.Milliseconds(), 10),
+	}
+
+	res, err := tryReserveScript.Exec(context.Background(), r.client, []string{group, key}, args).AsInt64()
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve lock: %w", err)
 	}
+	return res == 1, nil
+}
 
+// Extend the lock held by the id, so that it expires ttl from now.
+// Does not fail when no lock is held.
+func (r *ValkeyBackend) Renew(group string, id string, ttl time.Duration) error {
+	key := fmt.Sprintf(keyformat, group, id)
+
+	cmdPExpire := r.client.B().Pexpire().Key(key).Milliseconds(ttl.Milliseconds()).Build()
+	err := r.client.Do(context.Background(), cmdPExpire).Error()
+	if err != nil {
+		return fmt.Errorf("failed to renew key: %w", err)
+	}
 	return nil
 }
 
 // Returns the current number of locks for the given group
 func (r *ValkeyBackend) GetLocks(group string) (int, error) {
-	cmdSCard := r.client.B().Scard().Key(group).Build()
-	result, err := r.client.Do(context.Background(), cmdSCard).AsInt64()
+	ctx := context.Background()
+
+	keys, err := r.getGroupMembers(ctx, group)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get locks from database: %w", err)
+		return 0, err
+	}
+
+	// Expired keys are still members of the group, so only count the keys that exist
+	exists, err := r.keysExist(ctx, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, ok := range exists {
+		if ok {
+			count++
+		}
 	}
-	return int(result), nil
+	return count, nil
 }
 
 // Release the lock currently held by the id.
@@ -141,7 +246,55 @@ func (r *ValkeyBackend) Release(group string, id string) error {
 
 // Return all locks older than x
 func (r *ValkeyBackend) GetStaleLocks(ts time.Duration) ([]types.Lock, error) {
-	panic("not implemented") // TODO: Implement
+	ctx := context.Background()
+	pattern := fmt.Sprintf(keyformat, "*", "*")
+
+	result := make([]types.Lock, 0)
+	var cursor uint64
+	for {
+		cmdScan := r.client.B().Scan().Cursor(cursor).Match(pattern).Build()
+		entry, err := r.client.Do(ctx, cmdScan).AsScanEntry()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan for locks: %w", err)
+		}
+
+		for _, key := range entry.Elements {
+			lock, ok, err := r.getLock(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+			if ok && time.Since(lock.Created) > ts {
+				result = append(result, lock)
+			}
+		}
+
+		cursor = entry.Cursor
+		if cursor == 0 {
+			return result, nil
+		}
+	}
+}
+
+// Return all locks currently held in the given group
+func (r *ValkeyBackend) ListLocks(group string) ([]types.Lock, error) {
+	ctx := context.Background()
+
+	keys, err := r.getGroupMembers(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Lock, 0, len(keys))
+	for _, key := range keys {
+		lock, ok, err := r.getLock(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result = append(result, lock)
+		}
+	}
+	return result, nil
 }
 
 // Check if a given id already has a lock for this group
@@ -157,6 +310,56 @@ func (r *ValkeyBackend) HasLock(group string, id string) (bool, error) {
 	return count == 1, nil
 }
 
+// Persist the audit event, only the newest events are kept
+func (r *ValkeyBackend) RecordEvent(event types.Event) error {
+	ctx := context.Background()
+
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	cmdRPush := r.client.B().Rpush().Key(eventsKey).Element(string(b)).Build()
+	err = r.client.Do(ctx, cmdRPush).Error()
+	if err != nil {
+		return fmt.Errorf("failed to add event to list: %w", err)
+	}
+
+	cmdLTrim := r.client.B().Ltrim().Key(eventsKey).Start(-maxEvents).Stop(-1).Build()
+	err = r.client.Do(ctx, cmdLTrim).Error()
+	if err != nil {
+		return fmt.Errorf("failed to trim event list: %w", err)
+	}
+	return nil
+}
+
+// Return the audit events matching the filter, sorted from oldest to newest
+func (r *ValkeyBackend) QueryEvents(filter types.EventFilter) ([]types.Event, error) {
+	cmdLRange := r.client.B().Lrange().Key(eventsKey).Start(0).Stop(-1).Build()
+	values, err := r.client.Do(context.Background(), cmdLRange).AsStrSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events from database: %w", err)
+	}
+
+	result := make([]types.Event, 0, len(values))
+	for _, value := range values {
+		var event types.Event
+		err = json.Unmarshal([]byte(value), &event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse event: %w", err)
+		}
+		if filter.Matches(event) {
+			result = append(result, event)
+		}
+	}
+	return filter.Truncate(result), nil
+}
+
+// Check if the valkey server is reachable
+func (r *ValkeyBackend) Ping() error {
+	return r.client.Do(context.Background(), r.client.B().Ping().Build()).Error()
+}
+
 // Calls all necessary finalization if necessary
 func (r *ValkeyBackend) Close() error {
 	if r.lb != nil {
@@ -165,3 +368,177 @@ func (r *ValkeyBackend) Close() error {
 	r.client.Close()
 	return nil
 }
+
+// Return the keys of all locks in the group, including expired ones
+func (r *ValkeyBackend) getGroupMembers(ctx context.Context, group string) ([]string, error) {
+	cmdSMembers := r.client.B().Smembers().Key(group).Build()
+	keys, err := r.client.Do(ctx, cmdSMembers).AsStrSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get locks from database: %w", err)
+	}
+	return keys, nil
+}
+
+// Check for each of the given keys if it exists
+func (r *ValkeyBackend) keysExist(ctx context.Context, keys []string) ([]bool, error) {
+	cmds := make(valkey.Commands, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, r.client.B().Exists().Key(key).Build())
+	}
+
+	result := make([]bool, len(keys))
+	for i, res := range r.client.DoMulti(ctx, cmds...) {
+		count, err := res.AsInt64()
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if lock exists: %w", err)
+		}
+		result[i] = count > 0
+	}
+	return result, nil
+}
+
+// Remove the keys of expired locks from the group
+func (r *ValkeyBackend) removeExpired(ctx context.Context, group string) error {
+	keys, err := r.getGroupMembers(ctx, group)
+	if err != nil {
+		return err
+	}
+
+	exists, err := r.keysExist(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		if exists[i] {
+			continue
+		}
+
+		cmdSRem := r.client.B().Srem().Key(group).Member(key).Build()
+		err = r.client.Do(ctx, cmdSRem).Error()
+		if err != nil {
+			return fmt.Errorf("failed to remove expired key from group: %w", err)
+		}
+	}
+	return nil
+}
+
+// Move locks created before the keys were hash tagged to the current format, so they keep counting against their group
+func (r *ValkeyBackend) migrateLegacyKeys(ctx context.Context) error {
+	pattern := fmt.Sprintf(legacyKeyformat, "*", "*")
+
+	var cursor uint64
+	for {
+		cmdScan := r.client.B().Scan().Cursor(cursor).Match(pattern).Build()
+		entry, err := r.client.Do(ctx, cmdScan).AsScanEntry()
+		if err != nil {
+			return fmt.Errorf("failed to scan for legacy locks: %w", err)
+		}
+
+		for _, key := range entry.Elements {
+			err = r.migrateLegacyKey(ctx, key)
+			if err != nil {
+				return err
+			}
+		}
+
+		cursor = entry.Cursor
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// Copy the lock to the current key format, keeping its expiry, and remove the legacy key afterwards
+func (r *ValkeyBackend) migrateLegacyKey(ctx context.Context, key string) error {
+	group, id, ok := parseLegacyKey(key)
+	if !ok {
+		return nil
+	}
+
+	value, err := r.client.Do(ctx, r.client.B().Get().Key(key).Build()).ToString()
+	if err != nil && !valkey.IsValkeyNil(err) {
+		return fmt.Errorf("failed to get legacy lock \"%s\": %w", key, err)
+	}
+	if err == nil {
+		pttl, err := r.client.Do(ctx, r.client.B().Pttl().Key(key).Build()).AsInt64()
+		if err != nil {
+			return fmt.Errorf("failed to get expiry of legacy lock \"%s\": %w", key, err)
+		}
+
+		newKey := fmt.Sprintf(keyformat, group, id)
+		var cmdSet valkey.Completed
+		if pttl > 0 {
+			cmdSet = r.client.B().Set().Key(newKey).Value(value).Nx().PxMilliseconds(pttl).Build()
+		} else {
+			cmdSet = r.client.B().Set().Key(newKey).Value(value).Nx().Build()
+		}
+		err = r.client.Do(ctx, cmdSet).Error()
+		if err != nil && !valkey.IsValkeyNil(err) {
+			return fmt.Errorf("failed to migrate legacy lock \"%s\": %w", key, err)
+		}
+		err = r.client.Do(ctx, r.client.B().Sadd().Key(group).Member(newKey).Build()).Error()
+		if err != nil {
+			return fmt.Errorf("failed to add migrated lock to group list: %w", err)
+		}
+	}
+
+	err = r.client.Do(ctx, r.client.B().Del().Key(key).Build()).Error()
+	if err != nil {
+		return fmt.Errorf("failed to delete legacy lock \"%s\": %w", key, err)
+	}
+	err = r.client.Do(ctx, r.client.B().Srem().Key(group).Member(key).Build()).Error()
+	if err != nil {
+		return fmt.Errorf("failed to remove legacy lock from group list: %w", err)
+	}
+	return nil
+}
+
+// Read the lock saved under the given key.
+// Returns false if the key is not a lock or does not exist (anymore).
+func (r *ValkeyBackend) getLock(ctx context.Context, key string) (types.Lock, bool, error) {
+	group, id, ok := parseKey(key)
+	if !ok {
+		return types.Lock{}, false, nil
+	}
+
+	cmdGet := r.client.B().Get().Key(key).Build()
+	value, err := r.client.Do(ctx, cmdGet).ToString()
+	if valkey.IsValkeyNil(err) {
+		return types.Lock{}, false, nil
+	} else if err != nil {
+		return types.Lock{}, false, fmt.Errorf("failed to get lock from database: %w", err)
+	}
+
+	created, owner, err := types.ParseLockValue(value)
+	if err != nil {
+		return types.Lock{}, false, fmt.Errorf("failed to parse lock \"%s\": %w", key, err)
+	}
+
+	return types.Lock{
+		Group:   group,
+		ID:      id,
+		Created: created,
+		Owner:   owner,
+	}, true, nil
+}
+
+// Extract group and id from a key created with keyformat
+func parseKey(key string) (string, string, bool) {
+	key, ok := strings.CutPrefix(key, "group:{")
+	if !ok {
+		return "", "", false
+	}
+	// Group names are validated to not contain "}", so the first match is always the separator
+	return strings.Cut(key, "},id:")
+}
+
+// Extract group and id from a key created with legacyKeyformat
+func parseLegacyKey(key string) (string, string, bool) {
+	key, ok := strings.CutPrefix(key, "group:")
+	if !ok || strings.HasPrefix(key, "{") {
+		return "", "", false
+	}
+	// Group names are validated to not contain ",", so the first match is always the separator
+	return strings.Cut(key, ",id:")
+}
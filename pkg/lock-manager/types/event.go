@@ -0,0 +1,66 @@
+package types
+
+import "time"
+
+// Types of audit events
+const (
+	EventReserve       = "reserve"
+	EventReserveDenied = "reserve_denied"
+	EventRelease       = "release"
+	EventForceRelease  = "force_release"
+	EventDrainStarted  = "drain_started"
+	EventDrainFinished = "drain_finished"
+	EventDrainFailed   = "drain_failed"
+	EventUncordon      = "uncordon"
+)
+
+// A single entry of the audit log
+type Event struct {
+	Time  time.Time `json:"time"`
+	Type  string    `json:"type"`
+	Group string    `json:"group,omitempty"`
+	ID    string    `json:"id,omitempty"`
+	// The kubernetes node matching the id, if known
+	Node string `json:"node,omitempty"`
+	// Address of the client that caused the event, empty for events caused by the server itself
+	Remote string `json:"remote,omitempty"`
+	// Additional information, e.g. why a reservation was denied
+	Reason string `json:"reason,omitempty"`
+}
+
+// Restricts the events returned by a query, empty fields match everything
+type EventFilter struct {
+	Group string
+	ID    string
+	// Only return events at or after this time
+	Since time.Time
+	// Only return the newest events, unlimited when 0
+	Limit int
+}
+
+// Check if the event matches the filter, ignores the limit
+func (f EventFilter) Matches(e Event) bool {
+	return (f.Group == "" || f.Group == e.Group) &&
+		(f.ID == "" || f.ID == e.ID) &&
+		!e.Time.Before(f.Since)
+}
+
+// Return the matching events from a list sorted from oldest to newest.
+// Keeps only the newest events when a limit is set.
+func (f EventFilter) Apply(events []Event) []Event {
+	result := make([]Event, 0, len(events))
+	for _, e := range events {
+		if f.Matches(e) {
+			result = append(result, e)
+		}
+	}
+	return f.Truncate(result)
+}
+
+// Keep only the newest events of a list sorted from oldest to newest, when a limit is set
+func (f EventFilter) Truncate(events []Event) []Event {
+	if f.Limit > 0 && len(events) > f.Limit {
+		return events[len(events)-f.Limit:]
+	}
+	return events
+}
@@ -0,0 +1,66 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventFilterApply(t *testing.T) {
+	now := time.Now()
+	events := []Event{
+		{Time: now.Add(-3 * time.Hour), Type: EventReserve, Group: "default", ID: "node-1"},
+		{Time: now.Add(-2 * time.Hour), Type: EventReserve, Group: "workers", ID: "node-2"},
+		{Time: now.Add(-time.Hour), Type: EventRelease, Group: "default", ID: "node-1"},
+		{Time: now, Type: EventRelease, Group: "workers", ID: "node-2"},
+	}
+
+	tMatrix := []struct {
+		Name     string
+		Filter   EventFilter
+		Expected []Event
+	}{
+		{
+			Name:     "Empty",
+			Filter:   EventFilter{},
+			Expected: events,
+		},
+		{
+			Name:     "Group",
+			Filter:   EventFilter{Group: "default"},
+			Expected: []Event{events[0], events[2]},
+		},
+		{
+			Name:     "ID",
+			Filter:   EventFilter{ID: "node-2"},
+			Expected: []Event{events[1], events[3]},
+		},
+		{
+			Name:     "Since",
+			Filter:   EventFilter{Since: now.Add(-time.Hour)},
+			Expected: []Event{events[2], events[3]},
+		},
+		{
+			Name:     "LimitKeepsNewest",
+			Filter:   EventFilter{Limit: 1},
+			Expected: []Event{events[3]},
+		},
+		{
+			Name:     "Combined",
+			Filter:   EventFilter{Group: "default", Limit: 1},
+			Expected: []Event{events[2]},
+		},
+		{
+			Name:     "NoMatch",
+			Filter:   EventFilter{Group: "unknown"},
+			Expected: []Event{},
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert.Equal(t, tCase.Expected, tCase.Filter.Apply(events))
+		})
+	}
+}
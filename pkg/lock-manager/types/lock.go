@@ -1,8 +1,76 @@
 package types
 
-import "time"
+import (
+	"encoding/json/v2"
+	"strings"
+	"time"
+)
+
+// Format used by storage backends that save the creation time of a lock as a string
+const TimestampFormat = time.RFC3339Nano
+
+// Format of time.Time.String(), which was used to save timestamps by older versions
+const legacyTimestampFormat = "2006-01-02 15:04:05.999999999 -0700 MST"
 
 type Lock struct {
 	Group, ID string
 	Created   time.Time
+	// Identity of the authenticated client that reserved the lock, empty without client authentication
+	Owner string
+}
+
+// Value saved by key-value storage backends for locks with an owner
+type lockValue struct {
+	Created string `json:"created"`
+	Owner   string `json:"owner"`
+}
+
+// Encode the creation time and owner of a lock for key-value storage backends.
+// Locks without owner are saved as plain timestamp, same as by older versions.
+func FormatLockValue(created time.Time, owner string) string {
+	if owner == "" {
+		return created.Format(TimestampFormat)
+	}
+	b, _ := json.Marshal(lockValue{
+		Created: created.Format(TimestampFormat),
+		Owner:   owner,
+	})
+	return string(b)
+}
+
+// Parse a value created by FormatLockValue or a plain timestamp.
+// Returns the creation time and owner of the lock.
+func ParseLockValue(s string) (time.Time, string, error) {
+	if !strings.HasPrefix(s, "{") {
+		created, err := ParseTimestamp(s)
+		return created, "", err
+	}
+
+	var value lockValue
+	err := json.Unmarshal([]byte(s), &value)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	created, err := ParseTimestamp(value.Created)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return created, value.Owner, nil
+}
+
+// Parse a timestamp saved by a storage backend.
+// Supports both TimestampFormat and the output of time.Time.String().
+func ParseTimestamp(s string) (time.Time, error) {
+	t, err := time.Parse(TimestampFormat, s)
+	if err == nil {
+		return t, nil
+	}
+
+	// Strip the monotonic clock reading, e.g. "m=+0.000123"
+	legacy, _, _ := strings.Cut(s, " m=")
+	t, err2 := time.Parse(legacyTimestampFormat, legacy)
+	if err2 != nil {
+		return time.Time{}, err
+	}
+	return t, nil
 }
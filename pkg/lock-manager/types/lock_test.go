@@ -0,0 +1,92 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	now := time.Now()
+
+	tMatrix := []struct {
+		Name  string
+		Input string
+		Error bool
+	}{
+		{
+			Name:  "TimestampFormat",
+			Input: now.Format(TimestampFormat),
+		},
+		{
+			Name:  "LegacyFormat",
+			Input: now.String(),
+		},
+		{
+			Name:  "LegacyFormatWithoutMonotonicClock",
+			Input: now.Round(0).String(),
+		},
+		{
+			Name:  "Invalid",
+			Input: "not-a-timestamp",
+			Error: true,
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			res, err := ParseTimestamp(tCase.Input)
+			if tCase.Error {
+				assert.Error(err)
+				assert.True(res.IsZero(), "Should return zero time")
+			} else {
+				assert.NoError(err)
+				assert.True(now.Equal(res), "Should parse the original time")
+			}
+		})
+	}
+}
+
+func TestLockValue(t *testing.T) {
+	now := time.Now()
+
+	tMatrix := []struct {
+		Name  string
+		Owner string
+	}{
+		{
+			Name: "WithoutOwner",
+		},
+		{
+			Name:  "WithOwner",
+			Owner: "token:workers",
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			value := FormatLockValue(now, tCase.Owner)
+			if tCase.Owner == "" {
+				assert.Equal(now.Format(TimestampFormat), value, "Should save locks without owner as plain timestamp")
+			}
+
+			created, owner, err := ParseLockValue(value)
+			assert.NoError(err)
+			assert.True(now.Equal(created), "Should parse the original time")
+			assert.Equal(tCase.Owner, owner, "Should parse the owner")
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, _, err := ParseLockValue("{not json")
+		assert.Error(t, err)
+
+		_, _, err = ParseLockValue(`{"created":"not-a-timestamp","owner":"foo"}`)
+		assert.Error(t, err)
+	})
+}
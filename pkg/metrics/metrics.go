@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "fleetlock"
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Number of fleetlock requests by operation and kind of response",
+	}, []string{"operation", "kind"})
+
+	drainDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "drain_duration_seconds",
+		Help:      "Duration of successful node drains",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200},
+	})
+
+	drainFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "drain_failures_total",
+		Help:      "Number of failed node drains",
+	})
+
+	storageOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Latency of the storage backend by operation",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 8),
+	}, []string{"operation"})
+
+	storageOperationErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_errors_total",
+		Help:      "Number of failed storage backend operations by operation",
+	}, []string{"operation"})
+)
+
+// Create a new registry containing all fleetlock metrics, runtime metrics and the given collectors
+func NewRegistry(cs ...prometheus.Collector) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestsTotal,
+		drainDuration,
+		drainFailuresTotal,
+		storageOperationDuration,
+		storageOperationErrorsTotal,
+	)
+	reg.MustRegister(cs...)
+	return reg
+}
+
+// Count a fleetlock request by the kind of response it received
+func RecordRequest(operation, kind string) {
+	requestsTotal.WithLabelValues(operation, kind).Inc()
+}
+
+// Record the outcome of a node drain
+func ObserveDrain(duration time.Duration, err error) {
+	if err != nil {
+		drainFailuresTotal.Inc()
+		return
+	}
+	drainDuration.Observe(duration.Seconds())
+}
+
+// Record the latency and outcome of a storage operation
+func ObserveStorageOperation(operation string, duration time.Duration, err error) {
+	storageOperationDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	if err != nil {
+		storageOperationErrorsTotal.WithLabelValues(operation).Inc()
+	}
+}
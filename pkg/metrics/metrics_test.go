@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveDrain(t *testing.T) {
+	reg := NewRegistry()
+
+	ObserveDrain(10*time.Second, nil)
+	ObserveDrain(time.Second, errors.New("failed"))
+
+	families, err := reg.Gather()
+	require.NoError(t, err, "Should gather metrics")
+
+	var drainCount uint64
+	var failures float64
+	for _, family := range families {
+		switch family.GetName() {
+		case "fleetlock_drain_duration_seconds":
+			drainCount = family.GetMetric()[0].GetHistogram().GetSampleCount()
+		case "fleetlock_drain_failures_total":
+			failures = family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+
+	assert := assert.New(t)
+
+	assert.GreaterOrEqual(drainCount, uint64(1), "Should only observe the duration of successful drains")
+	assert.GreaterOrEqual(failures, float64(1), "Should count failed drains")
+}
+
+func TestObserveStorageOperation(t *testing.T) {
+	reg := NewRegistry()
+
+	ObserveStorageOperation("test_operation", time.Millisecond, nil)
+	ObserveStorageOperation("test_operation", time.Millisecond, errors.New("failed"))
+
+	families, err := reg.Gather()
+	require.NoError(t, err, "Should gather metrics")
+
+	var count uint64
+	var errorCount float64
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			if len(m.GetLabel()) != 1 || m.GetLabel()[0].GetValue() != "test_operation" {
+				continue
+			}
+			switch family.GetName() {
+			case "fleetlock_storage_operation_duration_seconds":
+				count = m.GetHistogram().GetSampleCount()
+			case "fleetlock_storage_operation_errors_total":
+				errorCount = m.GetCounter().GetValue()
+			}
+		}
+	}
+
+	assert := assert.New(t)
+
+	assert.Equal(uint64(2), count, "Should observe latency of all operations")
+	assert.Equal(float64(1), errorCount, "Should only count failed operations")
+}
@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"net/url"
+	"slices"
+	"text/template"
+	"time"
+)
+
+// Formats of the webhook body
+const (
+	FormatJSON   = "json"
+	FormatSlack  = "slack"
+	FormatMatrix = "matrix"
+)
+
+const (
+	defaultRetries        = 3
+	defaultTimeout        = 10 * time.Second
+	defaultSlotsFullAfter = 30 * time.Minute
+)
+
+type Config struct {
+	// Send slots_full once all slots of a group have been in use for this long, defaults to 30m
+	SlotsFullAfter time.Duration   `yaml:"slotsFullAfter,omitempty"`
+	Webhooks       []WebhookConfig `yaml:"webhooks,omitempty"`
+}
+
+type WebhookConfig struct {
+	URL string `yaml:"url"`
+	// Events to send to this webhook, sends all events when empty
+	Events []string `yaml:"events,omitempty"`
+	// Sign the body with HMAC-SHA256, the signature is sent in the X-Fleetlock-Signature header
+	Secret string `yaml:"secret,omitempty"`
+	// One of json, slack or matrix, defaults to json
+	Format string `yaml:"format,omitempty"`
+	// Go template for the body, overrides the format
+	Template string `yaml:"template,omitempty"`
+	// Number of retries after a failed delivery, defaults to 3. Set to -1 to disable retries.
+	Retries int `yaml:"retries,omitempty"`
+	// Timeout of a single delivery attempt, defaults to 10s
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+func (c Config) Validate() error {
+	if c.SlotsFullAfter < 0 {
+		return NewErrorInvalidSlotsFullAfter()
+	}
+	for _, w := range c.Webhooks {
+		err := w.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w WebhookConfig) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return NewErrorInvalidWebhookURL(w.URL)
+	}
+
+	for _, e := range w.Events {
+		if !slices.Contains(AllEvents, e) {
+			return NewErrorUnknownEvent(e)
+		}
+	}
+
+	switch w.Format {
+	case "", FormatJSON, FormatSlack, FormatMatrix:
+	default:
+		return NewErrorUnknownFormat(w.Format)
+	}
+
+	if w.Template != "" {
+		_, err = parseTemplate(w.Template)
+		if err != nil {
+			return NewErrorInvalidTemplate(err)
+		}
+	}
+
+	if w.Retries < -1 || w.Timeout < 0 {
+		return NewErrorInvalidRetries()
+	}
+	return nil
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("webhook").Funcs(templateFuncs).Parse(text)
+}
@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tMatrix := []struct {
+		Name   string
+		Config Config
+		Result string
+	}{
+		{
+			Name:   "Empty",
+			Config: Config{},
+		},
+		{
+			Name: "Valid",
+			Config: Config{
+				SlotsFullAfter: time.Hour,
+				Webhooks: []WebhookConfig{
+					{
+						URL:    "https://hooks.slack.com/services/T000/B000/XXXX",
+						Events: []string{EventReserve, EventDrainFailed},
+						Format: FormatSlack,
+					},
+					{
+						URL:      "http://localhost:8080/webhook",
+						Secret:   "secret",
+						Template: `{"text": {{ json .Message }}}`,
+						Retries:  -1,
+						Timeout:  time.Second,
+					},
+				},
+			},
+		},
+		{
+			Name:   "NegativeSlotsFullAfter",
+			Config: Config{SlotsFullAfter: -time.Minute},
+			Result: "notify.ErrorInvalidSlotsFullAfter",
+		},
+		{
+			Name:   "MissingURL",
+			Config: Config{Webhooks: []WebhookConfig{{}}},
+			Result: "*notify.ErrorInvalidWebhookURL",
+		},
+		{
+			Name:   "RelativeURL",
+			Config: Config{Webhooks: []WebhookConfig{{URL: "/webhook"}}},
+			Result: "*notify.ErrorInvalidWebhookURL",
+		},
+		{
+			Name:   "UnsupportedScheme",
+			Config: Config{Webhooks: []WebhookConfig{{URL: "ftp://example.org/webhook"}}},
+			Result: "*notify.ErrorInvalidWebhookURL",
+		},
+		{
+			Name:   "UnknownEvent",
+			Config: Config{Webhooks: []WebhookConfig{{URL: "https://example.org", Events: []string{"reboot"}}}},
+			Result: "*notify.ErrorUnknownEvent",
+		},
+		{
+			Name:   "UnknownFormat",
+			Config: Config{Webhooks: []WebhookConfig{{URL: "https://example.org", Format: "discord"}}},
+			Result: "*notify.ErrorUnknownFormat",
+		},
+		{
+			Name:   "InvalidTemplate",
+			Config: Config{Webhooks: []WebhookConfig{{URL: "https://example.org", Template: "{{ .Message "}}},
+			Result: "*notify.ErrorInvalidTemplate",
+		},
+		{
+			Name:   "InvalidRetries",
+			Config: Config{Webhooks: []WebhookConfig{{URL: "https://example.org", Retries: -2}}},
+			Result: "notify.ErrorInvalidRetries",
+		},
+		{
+			Name:   "NegativeTimeout",
+			Config: Config{Webhooks: []WebhookConfig{{URL: "https://example.org", Timeout: -time.Second}}},
+			Result: "notify.ErrorInvalidRetries",
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			err := tCase.Config.Validate()
+
+			if tCase.Result == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, tCase.Result, reflect.TypeOf(err).String())
+			}
+		})
+	}
+}
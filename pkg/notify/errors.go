@@ -0,0 +1,87 @@
+package notify
+
+import "fmt"
+
+type ErrorInvalidWebhookURL struct {
+	url string
+}
+
+func NewErrorInvalidWebhookURL(url string) error {
+	return &ErrorInvalidWebhookURL{url: url}
+}
+
+func (e *ErrorInvalidWebhookURL) Error() string {
+	return fmt.Sprintf("Invalid webhook url \"%s\", needs to be an absolute http or https url", e.url)
+}
+
+type ErrorUnknownEvent struct {
+	event string
+}
+
+func NewErrorUnknownEvent(event string) error {
+	return &ErrorUnknownEvent{event: event}
+}
+
+func (e *ErrorUnknownEvent) Error() string {
+	return fmt.Sprintf("Unknown notification event \"%s\"", e.event)
+}
+
+type ErrorUnknownFormat struct {
+	format string
+}
+
+func NewErrorUnknownFormat(format string) error {
+	return &ErrorUnknownFormat{format: format}
+}
+
+func (e *ErrorUnknownFormat) Error() string {
+	return fmt.Sprintf("Unknown webhook format \"%s\", needs to be one of json, slack or matrix", e.format)
+}
+
+type ErrorInvalidTemplate struct {
+	err error
+}
+
+func NewErrorInvalidTemplate(err error) error {
+	return &ErrorInvalidTemplate{err: err}
+}
+
+func (e *ErrorInvalidTemplate) Error() string {
+	return fmt.Sprintf("Failed to parse webhook template: %v", e.err)
+}
+
+func (e *ErrorInvalidTemplate) Unwrap() error {
+	return e.err
+}
+
+type ErrorInvalidRetries struct{}
+
+func NewErrorInvalidRetries() error {
+	return ErrorInvalidRetries{}
+}
+
+func (e ErrorInvalidRetries) Error() string {
+	return "Webhook retries need to be -1 or higher and the timeout can't be negative"
+}
+
+type ErrorInvalidSlotsFullAfter struct{}
+
+func NewErrorInvalidSlotsFullAfter() error {
+	return ErrorInvalidSlotsFullAfter{}
+}
+
+func (e ErrorInvalidSlotsFullAfter) Error() string {
+	return "slotsFullAfter can't be negative"
+}
+
+type ErrorWebhookStatus struct {
+	status int
+}
+
+func NewErrorWebhookStatus(status int) error {
+	return &ErrorWebhookStatus{status: status}
+}
+
+func (e *ErrorWebhookStatus) Error() string {
+	return fmt.Sprintf("Webhook returned status %d", e.status)
+}
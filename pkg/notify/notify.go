@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Events that can be sent to webhooks
+const (
+	EventReserve               = "reserve"
+	EventRelease               = "release"
+	EventSlotsFull             = "slots_full"
+	EventDrainFailed           = "drain_failed"
+	EventDrainRetriesExhausted = "drain_retries_exhausted"
+)
+
+var AllEvents = []string{EventReserve, EventRelease, EventSlotsFull, EventDrainFailed, EventDrainRetriesExhausted}
+
+// The payload sent to the webhooks
+type Event struct {
+	Type  string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Group string    `json:"group,omitempty"`
+	ID    string    `json:"id,omitempty"`
+	Node  string    `json:"node,omitempty"`
+	// Additional information, e.g. why a drain failed
+	Reason string `json:"reason,omitempty"`
+	// Human readable description of the event
+	Message string `json:"message"`
+}
+
+// Sends events to the configured webhooks.
+// All methods can be called on a nil Notifier, they do nothing in that case.
+type Notifier struct {
+	webhooks       []*webhook
+	slotsFullAfter time.Duration
+
+	// When the groups were first found to be full and if slots_full has been sent already
+	fullSince map[string]time.Time
+	notified  map[string]bool
+	lock      sync.Mutex
+
+	wg sync.WaitGroup
+}
+
+// Create a new notifier from the config.
+// Returns nil when no webhooks are configured.
+func NewNotifier(cfg Config) (*Notifier, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Webhooks) == 0 {
+		return nil, nil
+	}
+
+	webhooks := make([]*webhook, 0, len(cfg.Webhooks))
+	for _, w := range cfg.Webhooks {
+		webhooks = append(webhooks, newWebhook(w))
+	}
+
+	if cfg.SlotsFullAfter == 0 {
+		cfg.SlotsFullAfter = defaultSlotsFullAfter
+	}
+
+	return &Notifier{
+		webhooks:       webhooks,
+		slotsFullAfter: cfg.SlotsFullAfter,
+		fullSince:      make(map[string]time.Time),
+		notified:       make(map[string]bool),
+	}, nil
+}
+
+// Send the event to all webhooks subscribed to it.
+// Delivery happens in the background, failures are only logged.
+func (n *Notifier) Notify(event Event) {
+	if n == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Message == "" {
+		event.Message = defaultMessage(event)
+	}
+
+	for _, w := range n.webhooks {
+		if !w.subscribed(event.Type) {
+			continue
+		}
+		n.wg.Go(func() {
+			err := w.send(event)
+			if err != nil {
+				slog.Error("Failed to send notification", "error", err, slog.String("event", event.Type), slog.String("webhook", w.host))
+			}
+		})
+	}
+}
+
+// Record that a reservation was denied because all slots of the group are in use.
+// Sends slots_full once the group has been full for longer than slotsFullAfter.
+func (n *Notifier) SlotsFull(group string) {
+	if n == nil {
+		return
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	since, ok := n.fullSince[group]
+	if !ok {
+		n.fullSince[group] = time.Now()
+		return
+	}
+	if n.notified[group] || time.Since(since) < n.slotsFullAfter {
+		return
+	}
+	n.notified[group] = true
+
+	n.Notify(Event{
+		Type:    EventSlotsFull,
+		Group:   group,
+		Message: "All slots of group " + group + " have been in use for more than " + n.slotsFullAfter.String(),
+	})
+}
+
+// Record that the group has free slots again
+func (n *Notifier) SlotsAvailable(group string) {
+	if n == nil {
+		return
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	delete(n.fullSince, group)
+	delete(n.notified, group)
+}
+
+// Wait for all pending deliveries to finish
+func (n *Notifier) Wait() {
+	if n == nil {
+		return
+	}
+	n.wg.Wait()
+}
+
+func defaultMessage(event Event) string {
+	name := event.Node
+	if name == "" {
+		name = event.ID
+	}
+
+	switch event.Type {
+	case EventReserve:
+		return name + " reserved a slot in group " + event.Group + " and will reboot"
+	case EventRelease:
+		return name + " released its slot in group " + event.Group
+	case EventDrainFailed:
+		return "Failed to drain node " + name + ": " + event.Reason
+	case EventDrainRetriesExhausted:
+		return "Exhausted retries for draining node " + name + ", it will reboot without being drained"
+	default:
+		return "Received event " + event.Type + " for group " + event.Group
+	}
+}
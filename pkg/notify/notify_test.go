@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"encoding/json/v2"
+	"io"
+	"net/http"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNotifier(t *testing.T) {
+	t.Run("NoWebhooks", func(t *testing.T) {
+		n, err := NewNotifier(Config{})
+
+		assert.NoError(t, err)
+		assert.Nil(t, n, "Should not create a notifier without webhooks")
+	})
+	t.Run("Defaults", func(t *testing.T) {
+		n, err := NewNotifier(Config{Webhooks: []WebhookConfig{{URL: "https://example.org"}}})
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		require.NoError(err)
+		require.Len(n.webhooks, 1)
+		assert.Equal(defaultSlotsFullAfter, n.slotsFullAfter)
+		assert.Equal(FormatJSON, n.webhooks[0].format)
+		assert.Equal(defaultRetries, n.webhooks[0].retries)
+		assert.Equal(defaultTimeout, n.webhooks[0].client.Timeout)
+	})
+	t.Run("InvalidConfig", func(t *testing.T) {
+		n, err := NewNotifier(Config{Webhooks: []WebhookConfig{{URL: "example.org"}}})
+
+		assert.Nil(t, n)
+		assert.Error(t, err)
+	})
+}
+
+func TestNilNotifier(t *testing.T) {
+	var n *Notifier
+
+	assert.NotPanics(t, func() {
+		n.Notify(Event{Type: EventReserve})
+		n.SlotsFull("default")
+		n.SlotsAvailable("default")
+		n.Wait()
+	})
+}
+
+func TestNotify(t *testing.T) {
+	n, err := NewNotifier(Config{
+		Webhooks: []WebhookConfig{
+			{URL: "https://example.org/all"},
+			{URL: "https://example.org/drain", Events: []string{EventDrainFailed}},
+		},
+	})
+	require.NoError(t, err)
+	all := newTestTransport(n.webhooks[0], http.StatusOK)
+	drain := newTestTransport(n.webhooks[1], http.StatusOK)
+
+	n.Notify(Event{Type: EventReserve, Group: "default", ID: "User1"})
+	n.Notify(Event{Type: EventDrainFailed, Group: "default", ID: "User1", Node: "node1", Reason: "timeout"})
+	n.Wait()
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	assert.Len(all(), 2, "Should send all events to webhook without filter")
+	require.Len(drain(), 1, "Should only send subscribed events")
+
+	body, err := io.ReadAll(drain()[0].Body)
+	require.NoError(err)
+	var event Event
+	require.NoError(json.Unmarshal(body, &event))
+	assert.Equal(EventDrainFailed, event.Type)
+	assert.Equal("Failed to drain node node1: timeout", event.Message, "Should set default message")
+	assert.WithinDuration(time.Now(), event.Time, time.Minute, "Should set the time")
+}
+
+func TestSlotsFull(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		n, err := NewNotifier(Config{
+			SlotsFullAfter: 10 * time.Minute,
+			Webhooks:       []WebhookConfig{{URL: "https://example.org"}},
+		})
+		require.NoError(t, err)
+		requests := newTestTransport(n.webhooks[0], http.StatusOK)
+
+		assert := assert.New(t)
+
+		n.SlotsFull("default")
+		time.Sleep(5 * time.Minute)
+		n.SlotsFull("default")
+		n.Wait()
+		assert.Empty(requests(), "Should not notify before slotsFullAfter")
+
+		time.Sleep(6 * time.Minute)
+		n.SlotsFull("default")
+		n.SlotsFull("default")
+		n.Wait()
+		assert.Len(requests(), 1, "Should notify once after slotsFullAfter")
+
+		n.SlotsAvailable("default")
+		n.SlotsFull("default")
+		time.Sleep(11 * time.Minute)
+		n.SlotsFull("default")
+		n.Wait()
+		assert.Len(requests(), 2, "Should notify again after the group had free slots")
+
+		n.SlotsFull("other")
+		n.Wait()
+		assert.Len(requests(), 2, "Should track groups separately")
+	})
+}
@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json/v2"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"text/template"
+	"time"
+)
+
+const (
+	retryInitialInterval = time.Second
+	retryMaxInterval     = time.Minute
+)
+
+const (
+	headerSignature = "X-Fleetlock-Signature"
+	headerEvent     = "X-Fleetlock-Event"
+)
+
+// Functions available in webhook templates
+var templateFuncs = template.FuncMap{
+	// Encode the value as JSON, e.g. to quote and escape a string
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+type webhook struct {
+	url string
+	// Only the host is logged, as the url of chat webhooks contains the token
+	host     string
+	events   []string
+	secret   []byte
+	format   string
+	template *template.Template
+	retries  int
+	client   *http.Client
+}
+
+// Create a webhook from a validated config
+func newWebhook(cfg WebhookConfig) *webhook {
+	// Already checked during validation
+	u, _ := url.Parse(cfg.URL)
+
+	w := &webhook{
+		url:     cfg.URL,
+		host:    u.Host,
+		events:  cfg.Events,
+		format:  cfg.Format,
+		retries: cfg.Retries,
+		client:  &http.Client{Timeout: cfg.Timeout},
+	}
+	if cfg.Secret != "" {
+		w.secret = []byte(cfg.Secret)
+	}
+	if cfg.Template != "" {
+		w.template, _ = parseTemplate(cfg.Template)
+	}
+	if w.format == "" {
+		w.format = FormatJSON
+	}
+	if w.retries == 0 {
+		w.retries = defaultRetries
+	} else if w.retries < 0 {
+		w.retries = 0
+	}
+	if w.client.Timeout == 0 {
+		w.client.Timeout = defaultTimeout
+	}
+	return w
+}
+
+// Check if the webhook wants to receive the event
+func (w *webhook) subscribed(event string) bool {
+	return len(w.events) == 0 || slices.Contains(w.events, event)
+}
+
+// Send the event, retrying with exponential backoff on failure
+func (w *webhook) send(event Event) error {
+	body, err := w.body(event)
+	if err != nil {
+		return fmt.Errorf("failed to create body: %w", err)
+	}
+
+	interval := retryInitialInterval
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(event.Type, body)
+		if err == nil || !retry || attempt >= w.retries {
+			return err
+		}
+		time.Sleep(interval)
+		interval = min(interval*2, retryMaxInterval)
+	}
+}
+
+// Send the body once, returns if the delivery should be retried on failure
+func (w *webhook) post(event string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerEvent, event)
+	if w.secret != nil {
+		req.Header.Set(headerSignature, sign(w.secret, body))
+	}
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	// Client errors other than rate limiting will not go away by retrying
+	retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+	return retry, NewErrorWebhookStatus(res.StatusCode)
+}
+
+// Render the body for the event
+func (w *webhook) body(event Event) ([]byte, error) {
+	if w.template != nil {
+		var b bytes.Buffer
+		err := w.template.Execute(&b, event)
+		return b.Bytes(), err
+	}
+
+	switch w.format {
+	case FormatSlack:
+		return json.Marshal(map[string]string{"text": event.Message})
+	case FormatMatrix:
+		return json.Marshal(map[string]string{"msgtype": "m.text", "body": event.Message})
+	default:
+		return json.Marshal(event)
+	}
+}
+
+// Create the signature of the body in the format "sha256=<hex>"
+func sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,173 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json/v2"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Record the requests of the webhook and answer them with the given status codes.
+// The last status code is repeated for further requests.
+func newTestTransport(w *webhook, statusCodes ...int) func() []*http.Request {
+	var lock sync.Mutex
+	var requests []*http.Request
+	w.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		requests = append(requests, req)
+		status := statusCodes[min(len(requests), len(statusCodes))-1]
+		if status == 0 {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	return func() []*http.Request {
+		lock.Lock()
+		defer lock.Unlock()
+		return requests
+	}
+}
+
+var testEvent = Event{
+	Type:    EventReserve,
+	Time:    time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC),
+	Group:   "default",
+	ID:      "User1",
+	Message: "User1 reserved a slot in group default and will reboot",
+}
+
+func TestWebhookBody(t *testing.T) {
+	tMatrix := []struct {
+		Name   string
+		Config WebhookConfig
+		Result string
+	}{
+		{
+			Name:   "JSON",
+			Config: WebhookConfig{},
+			Result: `{"event":"reserve","time":"2024-01-01T02:00:00Z","group":"default","id":"User1","message":"User1 reserved a slot in group default and will reboot"}`,
+		},
+		{
+			Name:   "Slack",
+			Config: WebhookConfig{Format: FormatSlack},
+			Result: `{"text":"User1 reserved a slot in group default and will reboot"}`,
+		},
+		{
+			Name:   "Matrix",
+			Config: WebhookConfig{Format: FormatMatrix},
+			Result: `{"body":"User1 reserved a slot in group default and will reboot","msgtype":"m.text"}`,
+		},
+		{
+			Name:   "Template",
+			Config: WebhookConfig{Format: FormatSlack, Template: `{"text": {{ json (printf "[%s] %s" .Group .Message) }}}`},
+			Result: `{"text": "[default] User1 reserved a slot in group default and will reboot"}`,
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			tCase.Config.URL = "https://example.org"
+			w := newWebhook(tCase.Config)
+
+			body, err := w.body(testEvent)
+
+			require.NoError(t, err)
+			assert.JSONEq(t, tCase.Result, string(body))
+		})
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	t.Run("Headers", func(t *testing.T) {
+		w := newWebhook(WebhookConfig{URL: "https://example.org/webhook", Secret: "secret"})
+		requests := newTestTransport(w, http.StatusOK)
+
+		require.NoError(t, w.send(testEvent))
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		require.Len(requests(), 1)
+		req := requests()[0]
+		assert.Equal(http.MethodPost, req.Method)
+		assert.Equal("https://example.org/webhook", req.URL.String())
+		assert.Equal("application/json", req.Header.Get("Content-Type"))
+		assert.Equal(EventReserve, req.Header.Get(headerEvent))
+
+		body, err := io.ReadAll(req.Body)
+		require.NoError(err)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		assert.Equal("sha256="+hex.EncodeToString(mac.Sum(nil)), req.Header.Get(headerSignature), "Should sign the body")
+
+		var event Event
+		require.NoError(json.Unmarshal(body, &event))
+		assert.Equal(testEvent, event)
+	})
+	t.Run("NoSecret", func(t *testing.T) {
+		w := newWebhook(WebhookConfig{URL: "https://example.org/webhook"})
+		requests := newTestTransport(w, http.StatusNoContent)
+
+		require.NoError(t, w.send(testEvent))
+		assert.Empty(t, requests()[0].Header.Get(headerSignature), "Should not sign without secret")
+	})
+	t.Run("Retry", func(t *testing.T) {
+		synctest.Test(t, func(t *testing.T) {
+			w := newWebhook(WebhookConfig{URL: "https://example.org/webhook"})
+			requests := newTestTransport(w, 0, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+
+			start := time.Now()
+			err := w.send(testEvent)
+
+			assert := assert.New(t)
+
+			assert.NoError(err, "Should succeed after retries")
+			assert.Len(requests(), 4)
+			assert.Equal(7*time.Second, time.Since(start), "Should back off exponentially")
+		})
+	})
+	t.Run("RetriesExhausted", func(t *testing.T) {
+		synctest.Test(t, func(t *testing.T) {
+			w := newWebhook(WebhookConfig{URL: "https://example.org/webhook", Retries: 1})
+			requests := newTestTransport(w, http.StatusInternalServerError)
+
+			err := w.send(testEvent)
+
+			assert.Equal(t, NewErrorWebhookStatus(http.StatusInternalServerError), err)
+			assert.Len(t, requests(), 2)
+		})
+	})
+	t.Run("RetriesDisabled", func(t *testing.T) {
+		w := newWebhook(WebhookConfig{URL: "https://example.org/webhook", Retries: -1})
+		requests := newTestTransport(w, http.StatusInternalServerError)
+
+		assert.Error(t, w.send(testEvent))
+		assert.Len(t, requests(), 1)
+	})
+	t.Run("NoRetryOnClientError", func(t *testing.T) {
+		w := newWebhook(WebhookConfig{URL: "https://example.org/webhook"})
+		requests := newTestTransport(w, http.StatusNotFound)
+
+		assert.Equal(t, NewErrorWebhookStatus(http.StatusNotFound), w.send(testEvent))
+		assert.Len(t, requests(), 1)
+	})
+}
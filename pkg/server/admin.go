@@ -0,0 +1,240 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/api"
+	lmerrors "github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+)
+
+// Register the routes of the admin api
+func (s *Server) registerAdminRoutes(router *http.ServeMux) {
+	router.HandleFunc("GET /admin/v1/groups", s.adminAuth(s.handleAdminListGroups))
+	router.HandleFunc("GET /admin/v1/groups/{group}", s.adminAuth(s.handleAdminGetGroup))
+	router.HandleFunc("DELETE /admin/v1/groups/{group}/locks/{id}", s.adminAuth(s.handleAdminReleaseLock))
+	router.HandleFunc("GET /admin/v1/nodes/{node}/drain", s.adminAuth(s.handleAdminDrainStatus))
+	router.HandleFunc("GET /admin/v1/events", s.adminAuth(s.handleAdminEvents))
+}
+
+// Wrap the handler and ensure only requests with a valid bearer token are passed through
+func (s *Server) adminAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Admin.Token)) != 1 {
+			slog.Info("Rejected unauthorized request to admin api", slog.String("path", req.URL.Path), slog.String("remote", ReadUserIP(req)))
+			rw.WriteHeader(http.StatusUnauthorized)
+			sendResponse(rw, msgUnauthorized)
+			return
+		}
+
+		if !s.checkLeader(rw) {
+			return
+		}
+
+		next(rw, req)
+	}
+}
+
+// List all groups with their slot usage
+//
+//	URL: GET /admin/v1/groups
+func (s *Server) handleAdminListGroups(rw http.ResponseWriter, _ *http.Request) {
+	status, err := s.lm.GetStatus()
+	if err != nil {
+		slog.Error("Failed to fetch status of groups", "error", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		sendResponse(rw, msgUnexpectedError)
+		return
+	}
+
+	res := api.AdminGroupsResponse{
+		Groups: make([]api.AdminGroup, 0, len(status)),
+	}
+	for _, group := range status {
+		res.Groups = append(res.Groups, api.AdminGroup{
+			Name:  group.Name,
+			Slots: group.Slots,
+			Used:  len(group.Locks),
+		})
+	}
+	sendResponse(rw, res)
+}
+
+// Show a single group including the current holders of the slots
+//
+//	URL: GET /admin/v1/groups/{group}
+func (s *Server) handleAdminGetGroup(rw http.ResponseWriter, req *http.Request) {
+	group := req.PathValue("group")
+
+	status, err := s.lm.GetGroupStatus(group)
+	if err != nil {
+		sendAdminError(rw, err, group)
+		return
+	}
+
+	res := api.AdminGroup{
+		Name:  status.Name,
+		Slots: status.Slots,
+		Used:  len(status.Locks),
+		Locks: make([]api.AdminLock, 0, len(status.Locks)),
+	}
+	for _, lock := range status.Locks {
+		res.Locks = append(res.Locks, api.AdminLock{
+			ID:      lock.ID,
+			Created: lock.Created,
+			Owner:   lock.Owner,
+		})
+	}
+	sendResponse(rw, res)
+}
+
+// Force the release of the slot held by id.
+// Does not uncordon the node, as this is intended for nodes that have been removed.
+//
+//	URL: DELETE /admin/v1/groups/{group}/locks/{id}
+func (s *Server) handleAdminReleaseLock(rw http.ResponseWriter, req *http.Request) {
+	group, id := req.PathValue("group"), req.PathValue("id")
+
+	lock, err := s.lm.GetLock(group, id)
+	if err != nil {
+		sendAdminError(rw, err, group)
+		return
+	}
+	if lock == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		sendResponse(rw, msgLockNotHeld)
+		return
+	}
+
+	err = s.lm.Release(group, id)
+	if err != nil {
+		sendAdminError(rw, err, group)
+		return
+	}
+
+	remote := ReadUserIP(req)
+	slog.Info("Force released slot", slog.String("group", group), slog.String("id", id), slog.String("remote", remote))
+	s.lm.RecordEvent(types.Event{
+		Type:   types.EventForceRelease,
+		Group:  group,
+		ID:     id,
+		Remote: remote,
+		Reason: "admin api",
+	})
+	sendResponse(rw, msgSuccess)
+}
+
+// Show the progress of the last drain of a node
+//
+//	URL: GET /admin/v1/nodes/{node}/drain
+func (s *Server) handleAdminDrainStatus(rw http.ResponseWriter, req *http.Request) {
+	node := req.PathValue("node")
+
+	if s.k8s == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		sendResponse(rw, msgNoKubernetes)
+		return
+	}
+
+	status, err := s.k8s.GetDrainStatus(node)
+	if err != nil {
+		slog.Error("Failed to fetch drain status", "error", err, slog.String("node", node))
+		rw.WriteHeader(http.StatusInternalServerError)
+		sendResponse(rw, msgUnexpectedError)
+		return
+	}
+	if status == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		sendResponse(rw, msgNoDrainStatus)
+		return
+	}
+
+	res := api.DrainStatus{
+		Node:            node,
+		State:           status.State,
+		Started:         status.Started,
+		Failures:        status.Failures,
+		PodsRemaining:   status.Progress.PodsRemaining,
+		FailedEvictions: make([]api.FailedEviction, 0, len(status.Progress.FailedEvictions)),
+	}
+	for _, f := range status.Progress.FailedEvictions {
+		res.FailedEvictions = append(res.FailedEvictions, api.FailedEviction{
+			Pod:    f.Pod,
+			Reason: f.Reason,
+		})
+	}
+	sendResponse(rw, res)
+}
+
+// Query the audit log, all parameters are optional.
+// Returns the matching events sorted from oldest to newest.
+//
+//	URL: GET /admin/v1/events?group={group}&id={id}&since={RFC3339}&limit={n}
+func (s *Server) handleAdminEvents(rw http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	filter := types.EventFilter{
+		Group: query.Get("group"),
+		ID:    query.Get("id"),
+	}
+
+	var err error
+	if since := query.Get("since"); since != "" {
+		filter.Since, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			sendResponse(rw, msgInvalidEventQuery)
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 0 {
+			rw.WriteHeader(http.StatusBadRequest)
+			sendResponse(rw, msgInvalidEventQuery)
+			return
+		}
+	}
+
+	events, err := s.lm.QueryEvents(filter)
+	if errors.Is(err, lmerrors.NewErrorAuditDisabled()) {
+		rw.WriteHeader(http.StatusNotFound)
+		sendResponse(rw, msgAuditDisabled)
+		return
+	} else if err != nil {
+		slog.Error("Failed to query audit log", "error", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		sendResponse(rw, msgUnexpectedError)
+		return
+	}
+
+	res := api.AuditEventsResponse{
+		Events: make([]api.AuditEvent, 0, len(events)),
+	}
+	for _, event := range events {
+		res.Events = append(res.Events, api.AuditEvent(event))
+	}
+	sendResponse(rw, res)
+}
+
+// Send the matching response for an error returned by the lock manager
+func sendAdminError(rw http.ResponseWriter, err error, group string) {
+	var errUnknownGroup *lmerrors.ErrorUnknownGroup
+	if errors.As(err, &errUnknownGroup) {
+		rw.WriteHeader(http.StatusNotFound)
+		sendResponse(rw, msgUnknownGroup)
+		return
+	}
+
+	slog.Error("Failed to process admin request", "error", err, slog.String("group", group))
+	rw.WriteHeader(http.StatusInternalServerError)
+	sendResponse(rw, msgUnexpectedError)
+}
@@ -0,0 +1,410 @@
+package server
+
+import (
+	"context"
+	"encoding/json/v2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/api"
+	"github.com/heathcliff26/fleetlock/pkg/k8s"
+	lockmanager "github.com/heathcliff26/fleetlock/pkg/lock-manager"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/memory"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "admin-token"
+
+func newAdminTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	groups := lockmanager.Groups{
+		"default": lockmanager.GroupConfig{
+			Slots: 2,
+		},
+		"empty": lockmanager.GroupConfig{
+			Slots: 1,
+		},
+	}
+	lm := lockmanager.NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"default", "empty"}))
+
+	ok, err := lm.Reserve("default", "testUser")
+	require.NoError(t, err, "Should reserve slot")
+	require.True(t, ok, "Should reserve slot")
+
+	s := &Server{
+		cfg: &ServerConfig{
+			Admin: AdminConfig{
+				Enabled: true,
+				Token:   testAdminToken,
+			},
+		},
+		lm: lm,
+	}
+	s.createHTTPServer()
+	return s
+}
+
+func createAdminRequest(method, target string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	return req
+}
+
+func TestAdminAuth(t *testing.T) {
+	s := newAdminTestServer(t)
+
+	tMatrix := []struct {
+		Name   string
+		Header string
+	}{
+		{
+			Name: "MissingHeader",
+		},
+		{
+			Name:   "WrongToken",
+			Header: "Bearer not-the-token",
+		},
+		{
+			Name:   "NotBearer",
+			Header: "Basic " + testAdminToken,
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/v1/groups", nil)
+			if tCase.Header != "" {
+				req.Header.Set("Authorization", tCase.Header)
+			}
+			rr := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(rr, req)
+
+			res, response, err := parseResponse(rr)
+
+			assert := assert.New(t)
+
+			assert.NoError(err)
+			assert.Equal(http.StatusUnauthorized, res.StatusCode)
+			assert.Equal(msgUnauthorized, response)
+		})
+	}
+}
+
+func TestAdminDisabled(t *testing.T) {
+	s := &Server{
+		cfg: &ServerConfig{},
+		lm:  lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"})),
+	}
+	s.createHTTPServer()
+
+	rr := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodGet, "/admin/v1/groups"))
+
+	assert.Equal(t, http.StatusNotFound, rr.Result().StatusCode, "Should not serve admin api when disabled")
+}
+
+func TestAdminListGroups(t *testing.T) {
+	s := newAdminTestServer(t)
+
+	rr := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodGet, "/admin/v1/groups"))
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	require.Equal(http.StatusOK, rr.Result().StatusCode)
+	assert.Equal("application/json", rr.Header().Get("Content-Type"))
+
+	var res api.AdminGroupsResponse
+	err := json.UnmarshalRead(rr.Result().Body, &res)
+	require.NoError(err, "Response should be parsable")
+
+	expectedRes := api.AdminGroupsResponse{
+		Groups: []api.AdminGroup{
+			{
+				Name:  "default",
+				Slots: 2,
+				Used:  1,
+			},
+			{
+				Name:  "empty",
+				Slots: 1,
+				Used:  0,
+			},
+		},
+	}
+	assert.Equal(expectedRes, res)
+}
+
+func TestAdminGetGroup(t *testing.T) {
+	s := newAdminTestServer(t)
+
+	t.Run("Success", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodGet, "/admin/v1/groups/default"))
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		require.Equal(http.StatusOK, rr.Result().StatusCode)
+
+		var res api.AdminGroup
+		err := json.UnmarshalRead(rr.Result().Body, &res)
+		require.NoError(err, "Response should be parsable")
+
+		assert.Equal("default", res.Name)
+		assert.Equal(2, res.Slots)
+		assert.Equal(1, res.Used)
+		require.Len(res.Locks, 1)
+		assert.Equal("testUser", res.Locks[0].ID)
+		assert.WithinDuration(time.Now(), res.Locks[0].Created, time.Minute)
+	})
+	t.Run("UnknownGroup", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodGet, "/admin/v1/groups/unknown"))
+
+		res, response, err := parseResponse(rr)
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		assert.Equal(http.StatusNotFound, res.StatusCode)
+		assert.Equal(msgUnknownGroup, response)
+	})
+}
+
+func TestAdminReleaseLock(t *testing.T) {
+	s := newAdminTestServer(t)
+
+	t.Run("Success", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodDelete, "/admin/v1/groups/default/locks/testUser"))
+
+		res, response, err := parseResponse(rr)
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		assert.Equal(http.StatusOK, res.StatusCode)
+		assert.Equal(msgSuccess, response)
+
+		ok, _ := s.lm.HasLock("default", "testUser")
+		assert.False(ok, "Should have released the lock")
+	})
+	t.Run("NotHeld", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodDelete, "/admin/v1/groups/default/locks/otherUser"))
+
+		res, response, err := parseResponse(rr)
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		assert.Equal(http.StatusNotFound, res.StatusCode)
+		assert.Equal(msgLockNotHeld, response)
+	})
+	t.Run("UnknownGroup", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodDelete, "/admin/v1/groups/unknown/locks/testUser"))
+
+		res, response, err := parseResponse(rr)
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		assert.Equal(http.StatusNotFound, res.StatusCode)
+		assert.Equal(msgUnknownGroup, response)
+	})
+}
+
+func TestAdminDrainStatus(t *testing.T) {
+	t.Run("NoKubernetes", func(t *testing.T) {
+		s := newAdminTestServer(t)
+
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodGet, "/admin/v1/nodes/"+testNodeName+"/drain"))
+
+		res, response, err := parseResponse(rr)
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		assert.Equal(http.StatusNotFound, res.StatusCode)
+		assert.Equal(msgNoKubernetes, response)
+	})
+	t.Run("NotDrained", func(t *testing.T) {
+		s := newAdminTestServer(t)
+		s.k8s, _ = k8s.NewFakeClient()
+
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodGet, "/admin/v1/nodes/"+testNodeName+"/drain"))
+
+		res, response, err := parseResponse(rr)
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		assert.Equal(http.StatusNotFound, res.StatusCode)
+		assert.Equal(msgNoDrainStatus, response)
+	})
+	t.Run("Success", func(t *testing.T) {
+		s := newAdminTestServer(t)
+		k8sClient, fakeclient := k8s.NewFakeClient()
+		initTestCluster(t, fakeclient)
+		s.k8s = k8sClient
+
+		require.NoError(t, k8sClient.DrainNode(context.Background(), testNodeName, nil), "Should drain node")
+
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodGet, "/admin/v1/nodes/"+testNodeName+"/drain"))
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		require.Equal(http.StatusOK, rr.Result().StatusCode)
+
+		var res api.DrainStatus
+		err := json.UnmarshalRead(rr.Result().Body, &res)
+		require.NoError(err, "Response should be parsable")
+
+		assert.Equal(testNodeName, res.Node)
+		assert.Equal("done", res.State)
+		assert.Equal(0, res.Failures)
+		assert.WithinDuration(time.Now(), res.Started, time.Minute)
+		assert.Empty(res.PodsRemaining)
+		assert.Empty(res.FailedEvictions)
+	})
+}
+
+func TestAdminEvents(t *testing.T) {
+	t.Run("AuditDisabled", func(t *testing.T) {
+		s := newAdminTestServer(t)
+
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodGet, "/admin/v1/events"))
+
+		res, response, err := parseResponse(rr)
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		assert.Equal(http.StatusNotFound, res.StatusCode)
+		assert.Equal(msgAuditDisabled, response)
+	})
+
+	s := newAdminTestServer(t)
+	require.NoError(t, s.lm.EnableAudit(lockmanager.AuditConfig{Sink: lockmanager.AuditSinkStorage}))
+
+	start := time.Now().UTC()
+	events := []types.Event{
+		{Time: start, Type: types.EventReserve, Group: "default", ID: "User1", Remote: "192.0.2.1"},
+		{Time: start.Add(time.Minute), Type: types.EventReserve, Group: "empty", ID: "User2"},
+		{Time: start.Add(2 * time.Minute), Type: types.EventRelease, Group: "default", ID: "User1"},
+	}
+	for _, event := range events {
+		s.lm.RecordEvent(event)
+	}
+
+	tMatrix := []struct {
+		Name     string
+		Query    string
+		Expected []types.Event
+	}{
+		{
+			Name:     "All",
+			Expected: events,
+		},
+		{
+			Name:     "Group",
+			Query:    "?group=default",
+			Expected: []types.Event{events[0], events[2]},
+		},
+		{
+			Name:     "ID",
+			Query:    "?id=User2",
+			Expected: events[1:2],
+		},
+		{
+			Name:     "Since",
+			Query:    "?since=" + start.Add(time.Minute).Format(time.RFC3339Nano),
+			Expected: events[1:],
+		},
+		{
+			Name:     "Limit",
+			Query:    "?limit=1",
+			Expected: events[2:],
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodGet, "/admin/v1/events"+tCase.Query))
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			require.Equal(http.StatusOK, rr.Result().StatusCode)
+
+			var res api.AuditEventsResponse
+			err := json.UnmarshalRead(rr.Result().Body, &res)
+			require.NoError(err, "Response should be parsable")
+
+			require.Len(res.Events, len(tCase.Expected))
+			for i, event := range tCase.Expected {
+				assert.Equal(api.AuditEvent(event), res.Events[i])
+			}
+		})
+	}
+
+	for _, query := range []string{"?since=yesterday", "?limit=-1", "?limit=abc"} {
+		t.Run("InvalidQuery"+query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodGet, "/admin/v1/events"+query))
+
+			res, response, err := parseResponse(rr)
+
+			assert := assert.New(t)
+
+			assert.NoError(err)
+			assert.Equal(http.StatusBadRequest, res.StatusCode)
+			assert.Equal(msgInvalidEventQuery, response)
+		})
+	}
+
+	t.Run("ForceReleaseIsRecorded", func(t *testing.T) {
+		req := createAdminRequest(http.MethodDelete, "/admin/v1/groups/default/locks/testUser")
+		req.RemoteAddr = "192.0.2.2:1234"
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, req)
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		require.Equal(http.StatusOK, rr.Result().StatusCode)
+
+		res, err := s.lm.QueryEvents(types.EventFilter{Limit: 1})
+		require.NoError(err)
+		require.Len(res, 1)
+		assert.Equal(types.EventForceRelease, res[0].Type)
+		assert.Equal("testUser", res[0].ID)
+		assert.Equal("192.0.2.2:1234", res[0].Remote)
+	})
+	t.Run("ForceReleaseNotHeldIsNotRecorded", func(t *testing.T) {
+		before, err := s.lm.QueryEvents(types.EventFilter{})
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, createAdminRequest(http.MethodDelete, "/admin/v1/groups/default/locks/testUser"))
+		require.Equal(t, http.StatusNotFound, rr.Result().StatusCode)
+
+		after, err := s.lm.QueryEvents(types.EventFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, before, after, "Should not record an event")
+	})
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/heathcliff26/fleetlock/pkg/api"
+)
+
+// Allows everything when used in the list of groups or ids
+const authWildcard = "*"
+
+// Restrict which clients can reserve and release slots.
+// Clients authenticate either with a bearer token or a client certificate.
+type AuthConfig struct {
+	Enabled      bool                     `yaml:"enabled,omitempty"`
+	Tokens       []TokenCredentials       `yaml:"tokens,omitempty"`
+	Certificates []CertificateCredentials `yaml:"certificates,omitempty"`
+}
+
+// A static bearer token and what it is allowed to access
+type TokenCredentials struct {
+	// Used to identify the client in logs
+	Name        string `yaml:"name"`
+	Token       string `yaml:"token"`
+	Permissions `yaml:",inline"`
+}
+
+// A client certificate and what it is allowed to access
+type CertificateCredentials struct {
+	// Matched against the common name and the DNS, email and URI SANs of the certificate
+	Subject     string `yaml:"subject"`
+	Permissions `yaml:",inline"`
+}
+
+// The groups and ids a client may use
+type Permissions struct {
+	// Groups the client may reserve slots in, "*" allows all groups
+	Groups []string `yaml:"groups"`
+	// IDs the client may use, "*" or empty allows all ids
+	IDs []string `yaml:"ids,omitempty"`
+}
+
+// An authenticated client
+type clientIdentity struct {
+	// Unique name of the credentials, saved as owner of the reserved locks
+	name        string
+	permissions Permissions
+	// Admins can use locks owned by other clients
+	admin bool
+}
+
+// Name saved as owner of the locks reserved by the client, empty when there is no identity
+func (i *clientIdentity) owner() string {
+	if i == nil {
+		return ""
+	}
+	return i.name
+}
+
+// Check if the permissions allow using the given group and id
+func (p Permissions) Allows(group, id string) bool {
+	if !slices.Contains(p.Groups, authWildcard) && !slices.Contains(p.Groups, group) {
+		return false
+	}
+	return len(p.IDs) == 0 || slices.Contains(p.IDs, authWildcard) || slices.Contains(p.IDs, id)
+}
+
+func (cfg *ServerConfig) validateAuth() error {
+	if !cfg.Auth.Enabled {
+		return nil
+	}
+	if len(cfg.Auth.Tokens) == 0 && len(cfg.Auth.Certificates) == 0 {
+		return ErrorMissingClientCredentials{}
+	}
+
+	for _, t := range cfg.Auth.Tokens {
+		if t.Name == "" {
+			return NewErrorInvalidClientCredentials(t.Name, "token has no name")
+		}
+		if t.Token == "" {
+			return NewErrorInvalidClientCredentials(t.Name, "token is empty")
+		}
+		if len(t.Groups) == 0 {
+			return NewErrorInvalidClientCredentials(t.Name, "no groups are allowed")
+		}
+	}
+
+	for _, c := range cfg.Auth.Certificates {
+		if c.Subject == "" {
+			return NewErrorInvalidClientCredentials(c.Subject, "certificate has no subject")
+		}
+		if !cfg.SSL.Enabled || cfg.SSL.ClientCA == "" {
+			return NewErrorInvalidClientCredentials(c.Subject, "certificates require ssl with a clientCA")
+		}
+		if len(c.Groups) == 0 {
+			return NewErrorInvalidClientCredentials(c.Subject, "no groups are allowed")
+		}
+	}
+	return nil
+}
+
+// Identify the client by its bearer token or verified client certificate.
+// The token of the admin api is accepted as well and grants access to all groups.
+// Returns nil if the client could not be authenticated.
+func (s *Server) authenticate(req *http.Request) *clientIdentity {
+	// A wrong token is rejected, even if the client has a valid certificate
+	if token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+		if s.cfg.Admin.Enabled && subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Admin.Token)) == 1 {
+			return &clientIdentity{
+				name:        "admin",
+				permissions: Permissions{Groups: []string{authWildcard}},
+				admin:       true,
+			}
+		}
+		for _, t := range s.cfg.Auth.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) == 1 {
+				return &clientIdentity{
+					name:        "token:" + t.Name,
+					permissions: t.Permissions,
+				}
+			}
+		}
+		return nil
+	}
+
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	names := certificateNames(req.TLS.VerifiedChains[0][0])
+	for _, c := range s.cfg.Auth.Certificates {
+		if slices.Contains(names, c.Subject) {
+			return &clientIdentity{
+				name:        "certificate:" + c.Subject,
+				permissions: c.Permissions,
+			}
+		}
+	}
+	return nil
+}
+
+// Ensure the client is authenticated, before any work is done for the request.
+// Returns the identity of the client, which is nil when client authentication is disabled.
+func (s *Server) authenticateClient(rw http.ResponseWriter, req *http.Request, params api.FleetLockRequest) (*clientIdentity, bool) {
+	if !s.cfg.Auth.Enabled {
+		return nil, true
+	}
+
+	identity := s.authenticate(req)
+	if identity == nil {
+		slog.Info("Rejected request with missing or invalid client credentials", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID), slog.String("remote", ReadUserIP(req)))
+		rw.WriteHeader(http.StatusUnauthorized)
+		sendResponse(rw, msgClientUnauthorized)
+		return nil, false
+	}
+	return identity, true
+}
+
+// Ensure the client is allowed to use the group and id, after the group has been resolved.
+// Does nothing when there is no identity.
+func (s *Server) authorize(rw http.ResponseWriter, req *http.Request, params api.FleetLockRequest, identity *clientIdentity) bool {
+	if identity == nil || identity.permissions.Allows(params.Client.Group, params.Client.ID) {
+		return true
+	}
+
+	slog.Warn("Rejected request, client is not allowed to use group or id", slog.String("client", identity.name), slog.String("group", params.Client.Group), slog.String("id", params.Client.ID), slog.String("remote", ReadUserIP(req)))
+	rw.WriteHeader(http.StatusForbidden)
+	sendResponse(rw, msgForbidden)
+	return false
+}
+
+// Ensure a slot held by the id was reserved by the same client, admins may use any slot.
+// Slots reserved without client authentication can be used by every client.
+// Does nothing when there is no identity.
+func (s *Server) checkOwner(rw http.ResponseWriter, params api.FleetLockRequest, identity *clientIdentity) bool {
+	if identity == nil || identity.admin {
+		return true
+	}
+
+	lock, err := s.lm.GetLock(params.Client.Group, params.Client.ID)
+	if err != nil {
+		slog.Error("Failed to fetch slot", "error", err, slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
+		rw.WriteHeader(http.StatusInternalServerError)
+		sendResponse(rw, msgUnexpectedError)
+		return false
+	}
+	if lock == nil || lock.Owner == "" || lock.Owner == identity.name {
+		return true
+	}
+
+	slog.Warn("Rejected request, slot is owned by another client", slog.String("client", identity.name), slog.String("owner", lock.Owner), slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
+	rw.WriteHeader(http.StatusForbidden)
+	sendResponse(rw, msgNotOwner)
+	return false
+}
+
+// All names a client certificate can be matched by
+func certificateNames(cert *x509.Certificate) []string {
+	names := make([]string, 0, 1+len(cert.DNSNames)+len(cert.EmailAddresses)+len(cert.URIs))
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
@@ -0,0 +1,432 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/api"
+	"github.com/heathcliff26/fleetlock/pkg/k8s"
+	lockmanager "github.com/heathcliff26/fleetlock/pkg/lock-manager"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPermissionsAllows(t *testing.T) {
+	tMatrix := []struct {
+		Name        string
+		Permissions Permissions
+		Group, ID   string
+		Result      bool
+	}{
+		{
+			Name:        "AllowedGroupAnyID",
+			Permissions: Permissions{Groups: []string{"default"}},
+			Group:       "default",
+			ID:          "foo",
+			Result:      true,
+		},
+		{
+			Name:        "OtherGroup",
+			Permissions: Permissions{Groups: []string{"default"}},
+			Group:       "workers",
+			ID:          "foo",
+		},
+		{
+			Name:        "WildcardGroup",
+			Permissions: Permissions{Groups: []string{authWildcard}},
+			Group:       "workers",
+			ID:          "foo",
+			Result:      true,
+		},
+		{
+			Name:        "AllowedID",
+			Permissions: Permissions{Groups: []string{"default"}, IDs: []string{"foo", "bar"}},
+			Group:       "default",
+			ID:          "bar",
+			Result:      true,
+		},
+		{
+			Name:        "OtherID",
+			Permissions: Permissions{Groups: []string{"default"}, IDs: []string{"foo"}},
+			Group:       "default",
+			ID:          "bar",
+		},
+		{
+			Name:        "WildcardID",
+			Permissions: Permissions{Groups: []string{"default"}, IDs: []string{authWildcard}},
+			Group:       "default",
+			ID:          "bar",
+			Result:      true,
+		},
+		{
+			Name:  "NoGroups",
+			Group: "default",
+			ID:    "foo",
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			assert.Equal(t, tCase.Result, tCase.Permissions.Allows(tCase.Group, tCase.ID))
+		})
+	}
+}
+
+func TestClientAuthentication(t *testing.T) {
+	lm := lockmanager.NewManagerWithStorage(lockmanager.Groups{
+		"default": lockmanager.GroupConfig{Slots: 10},
+		"workers": lockmanager.GroupConfig{Slots: 10},
+	}, memory.NewMemoryBackend([]string{"default", "workers"}))
+	s := &Server{
+		cfg: &ServerConfig{
+			Auth: AuthConfig{
+				Enabled: true,
+				Tokens: []TokenCredentials{
+					{
+						Name:        "workers",
+						Token:       "worker-token",
+						Permissions: Permissions{Groups: []string{"workers"}},
+					},
+					{
+						Name:        "node1",
+						Token:       "node1-token",
+						Permissions: Permissions{Groups: []string{authWildcard}, IDs: []string{"node1"}},
+					},
+				},
+				Certificates: []CertificateCredentials{
+					{
+						Subject:     "node2.example.org",
+						Permissions: Permissions{Groups: []string{"default"}, IDs: []string{"node2"}},
+					},
+				},
+			},
+		},
+		lm: lm,
+	}
+	s.createHTTPServer()
+
+	cert := newTestCertificate(t, "node2", "node2.example.org")
+
+	tMatrix := []struct {
+		Name       string
+		Group, ID  string
+		Token      string
+		Cert       *x509.Certificate
+		StatusCode int
+		Response   api.FleetLockResponse
+	}{
+		{
+			Name:       "NoCredentials",
+			Group:      "workers",
+			ID:         "node1",
+			StatusCode: http.StatusUnauthorized,
+			Response:   msgClientUnauthorized,
+		},
+		{
+			Name:       "InvalidToken",
+			Group:      "workers",
+			ID:         "node1",
+			Token:      "wrong",
+			StatusCode: http.StatusUnauthorized,
+			Response:   msgClientUnauthorized,
+		},
+		{
+			Name:       "InvalidTokenWithValidCertificate",
+			Group:      "default",
+			ID:         "node2",
+			Token:      "wrong",
+			Cert:       cert,
+			StatusCode: http.StatusUnauthorized,
+			Response:   msgClientUnauthorized,
+		},
+		{
+			Name:       "TokenAllowedGroup",
+			Group:      "workers",
+			ID:         "node3",
+			Token:      "worker-token",
+			StatusCode: http.StatusOK,
+			Response:   msgSuccess,
+		},
+		{
+			Name:       "TokenForbiddenGroup",
+			Group:      "default",
+			ID:         "node3",
+			Token:      "worker-token",
+			StatusCode: http.StatusForbidden,
+			Response:   msgForbidden,
+		},
+		{
+			Name:       "TokenAllowedID",
+			Group:      "default",
+			ID:         "node1",
+			Token:      "node1-token",
+			StatusCode: http.StatusOK,
+			Response:   msgSuccess,
+		},
+		{
+			Name:       "TokenForbiddenID",
+			Group:      "default",
+			ID:         "node3",
+			Token:      "node1-token",
+			StatusCode: http.StatusForbidden,
+			Response:   msgForbidden,
+		},
+		{
+			Name:       "CertificateAllowed",
+			Group:      "default",
+			ID:         "node2",
+			Cert:       cert,
+			StatusCode: http.StatusOK,
+			Response:   msgSuccess,
+		},
+		{
+			Name:       "CertificateForbiddenGroup",
+			Group:      "workers",
+			ID:         "node2",
+			Cert:       cert,
+			StatusCode: http.StatusForbidden,
+			Response:   msgForbidden,
+		},
+		{
+			Name:       "UnknownCertificate",
+			Group:      "default",
+			ID:         "node2",
+			Cert:       newTestCertificate(t, "unknown", "unknown.example.org"),
+			StatusCode: http.StatusUnauthorized,
+			Response:   msgClientUnauthorized,
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			req := createRequest("/v1/pre-reboot", tCase.Group, tCase.ID)
+			if tCase.Token != "" {
+				req.Header.Set("Authorization", "Bearer "+tCase.Token)
+			}
+			if tCase.Cert != nil {
+				req.TLS = &tls.ConnectionState{
+					VerifiedChains: [][]*x509.Certificate{{tCase.Cert}},
+				}
+			}
+			rr := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(rr, req)
+			res, response, err := parseResponse(rr)
+
+			assert := assert.New(t)
+
+			assert.NoError(err, "Should return a valid response")
+			assert.Equal(tCase.StatusCode, res.StatusCode, "Should return the expected status code")
+			assert.Equal(tCase.Response, response, "Should return the expected response")
+		})
+	}
+}
+
+func TestLockOwner(t *testing.T) {
+	lm := lockmanager.NewManagerWithStorage(lockmanager.Groups{
+		"default": lockmanager.GroupConfig{Slots: 10},
+	}, memory.NewMemoryBackend([]string{"default"}))
+	s := &Server{
+		cfg: &ServerConfig{
+			Admin: AdminConfig{
+				Enabled: true,
+				Token:   "admin-token",
+			},
+			Auth: AuthConfig{
+				Enabled: true,
+				Tokens: []TokenCredentials{
+					{
+						Name:        "first",
+						Token:       "first-token",
+						Permissions: Permissions{Groups: []string{"default"}},
+					},
+					{
+						Name:        "second",
+						Token:       "second-token",
+						Permissions: Permissions{Groups: []string{"default"}},
+					},
+				},
+			},
+		},
+		lm: lm,
+	}
+	s.createHTTPServer()
+
+	send := func(t *testing.T, target, id, token string) (int, api.FleetLockResponse) {
+		req := createRequest(target, "default", id)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, req)
+		res, response, err := parseResponse(rr)
+		require.NoError(t, err, "Should return a valid response")
+		return res.StatusCode, response
+	}
+
+	status, response := send(t, "/v1/pre-reboot", "node1", "first-token")
+	require.Equal(t, http.StatusOK, status, "Should reserve the slot")
+	require.Equal(t, msgSuccess, response)
+
+	lock, err := lm.GetLock("default", "node1")
+	require.NoError(t, err, "Should fetch the lock")
+	require.NotNil(t, lock, "Should have reserved the lock")
+	assert.Equal(t, "token:first", lock.Owner, "Should save the client as owner")
+
+	t.Run("OtherClientCanNotRelease", func(t *testing.T) {
+		status, response := send(t, "/v1/steady-state", "node1", "second-token")
+
+		assert.Equal(t, http.StatusForbidden, status, "Should return forbidden")
+		assert.Equal(t, msgNotOwner, response, "Should return not owner")
+
+		ok, err := lm.HasLock("default", "node1")
+		assert.NoError(t, err, "Should check the lock")
+		assert.True(t, ok, "Should still hold the lock")
+	})
+	t.Run("OtherClientCanNotReserve", func(t *testing.T) {
+		status, response := send(t, "/v1/pre-reboot", "node1", "second-token")
+
+		assert.Equal(t, http.StatusForbidden, status, "Should return forbidden")
+		assert.Equal(t, msgNotOwner, response, "Should return not owner")
+	})
+	t.Run("OwnerCanReserveAgain", func(t *testing.T) {
+		status, response := send(t, "/v1/pre-reboot", "node1", "first-token")
+
+		assert.Equal(t, http.StatusOK, status, "Should succeed")
+		assert.Equal(t, msgSuccess, response, "Should return success")
+	})
+	t.Run("AdminCanRelease", func(t *testing.T) {
+		status, response := send(t, "/v1/steady-state", "node1", "admin-token")
+
+		assert.Equal(t, http.StatusOK, status, "Should succeed")
+		assert.Equal(t, msgSuccess, response, "Should return success")
+
+		ok, err := lm.HasLock("default", "node1")
+		assert.NoError(t, err, "Should check the lock")
+		assert.False(t, ok, "Should have released the lock")
+	})
+	t.Run("UnownedLock", func(t *testing.T) {
+		ok, err := lm.Reserve("default", "node2")
+		require.NoError(t, err, "Should reserve the lock without owner")
+		require.True(t, ok, "Should reserve the lock without owner")
+
+		status, response := send(t, "/v1/steady-state", "node2", "second-token")
+
+		assert.Equal(t, http.StatusOK, status, "Should succeed")
+		assert.Equal(t, msgSuccess, response, "Should return success")
+	})
+}
+
+func TestCertificateNames(t *testing.T) {
+	cert := newTestCertificate(t, "node1", "node1.example.org")
+	cert.EmailAddresses = []string{"node1@example.org"}
+	cert.URIs = []*url.URL{{Scheme: "spiffe", Host: "example.org", Path: "/node1"}}
+
+	assert.Equal(t, []string{"node1", "node1.example.org", "node1@example.org", "spiffe://example.org/node1"}, certificateNames(cert))
+}
+
+// Create a self-signed certificate with the given common name and optional DNS SAN
+func newTestCertificate(t *testing.T, cn, dnsName string) *x509.Certificate {
+	t.Helper()
+
+	_, cert := newTestKeyPair(t, cn, dnsName)
+	return cert
+}
+
+// Create a private key and a self-signed certificate for it with the given common name and optional DNS SAN
+func newTestKeyPair(t *testing.T, cn, dnsName string) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Should generate key")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if dnsName != "" {
+		template.DNSNames = []string{dnsName}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "Should create certificate")
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err, "Should parse certificate")
+	return key, cert
+}
+
+func TestAuthenticateBeforeResolveGroup(t *testing.T) {
+	const groupLabel = "fleetlock.heathcliff.eu/group"
+
+	k8sClient, fakeclient := k8s.NewFakeClient()
+	k8sClient.SetGroupLabel(groupLabel, k8s.GroupLabelModeOverride)
+	initTestCluster(t, fakeclient)
+
+	node, err := fakeclient.CoreV1().Nodes().Get(t.Context(), testNodeName, metav1.GetOptions{})
+	require.NoError(t, err, "Should get node")
+	node.Labels = map[string]string{groupLabel: "workers"}
+	_, err = fakeclient.CoreV1().Nodes().Update(t.Context(), node, metav1.UpdateOptions{})
+	require.NoError(t, err, "Should update node")
+
+	lm := lockmanager.NewManagerWithStorage(lockmanager.Groups{
+		"default": lockmanager.GroupConfig{Slots: 10},
+		"workers": lockmanager.GroupConfig{Slots: 10},
+	}, memory.NewMemoryBackend([]string{"default", "workers"}))
+	s := &Server{
+		cfg: &ServerConfig{
+			Auth: AuthConfig{
+				Enabled: true,
+				Tokens: []TokenCredentials{
+					{
+						Name:        "default",
+						Token:       "default-token",
+						Permissions: Permissions{Groups: []string{"default"}},
+					},
+				},
+			},
+		},
+		lm:  lm,
+		k8s: k8sClient,
+	}
+	s.createHTTPServer()
+
+	t.Run("NoCredentials", func(t *testing.T) {
+		fakeclient.ClearActions()
+
+		req := createRequest("/v1/pre-reboot", "default", testNodeZincatiID)
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, req)
+		res, response, err := parseResponse(rr)
+
+		assert := assert.New(t)
+
+		assert.NoError(err, "Should return a valid response")
+		assert.Equal(http.StatusUnauthorized, res.StatusCode, "Should reject the client")
+		assert.Equal(msgClientUnauthorized, response)
+		assert.Empty(fakeclient.Actions(), "Should not call the kubernetes api")
+	})
+	t.Run("PermissionsOfResolvedGroup", func(t *testing.T) {
+		req := createRequest("/v1/pre-reboot", "default", testNodeZincatiID)
+		req.Header.Set("Authorization", "Bearer default-token")
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, req)
+		res, response, err := parseResponse(rr)
+
+		assert := assert.New(t)
+
+		assert.NoError(err, "Should return a valid response")
+		assert.Equal(http.StatusForbidden, res.StatusCode, "Should check the permissions for the group of the node")
+		assert.Equal(msgForbidden, response)
+	})
+}
@@ -2,22 +2,40 @@ package server
 
 import (
 	"strconv"
+	"time"
 )
 
 const (
-	DEFAULT_SERVER_PORT     = 8080
-	DEFAULT_SERVER_PORT_SSL = 8443
+	DEFAULT_SERVER_PORT      = 8080
+	DEFAULT_SERVER_PORT_SSL  = 8443
+	DEFAULT_SHUTDOWN_TIMEOUT = 25 * time.Second
 )
 
 type ServerConfig struct {
-	Listen string    `yaml:"listen"`
-	SSL    SSLConfig `yaml:"ssl,omitempty"`
+	Listen string      `yaml:"listen"`
+	SSL    SSLConfig   `yaml:"ssl,omitempty"`
+	Admin  AdminConfig `yaml:"admin,omitempty"`
+	Auth   AuthConfig  `yaml:"auth,omitempty"`
+	// How long to wait for running requests and drains on shutdown, before drains are interrupted
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout,omitempty"`
 }
 
 type SSLConfig struct {
 	Enabled bool   `yaml:"enabled,omitempty"`
 	Cert    string `yaml:"cert,omitempty"`
 	Key     string `yaml:"key,omitempty"`
+	// CA used to verify client certificates, needed for client authentication with certificates
+	ClientCA string `yaml:"clientCA,omitempty"`
+	// Minimum TLS version accepted from clients, defaults to 1.2
+	MinVersion string `yaml:"minVersion,omitempty"`
+	// Cipher suites allowed for TLS 1.2 and lower, uses the go defaults when empty
+	CipherSuites []string `yaml:"cipherSuites,omitempty"`
+}
+
+type AdminConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Bearer token needed to access the admin api
+	Token string `yaml:"token,omitempty"`
 }
 
 // Create a default server config with
@@ -34,6 +52,9 @@ func (cfg *ServerConfig) Defaults() {
 			cfg.Listen = ":" + strconv.Itoa(DEFAULT_SERVER_PORT)
 		}
 	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = DEFAULT_SHUTDOWN_TIMEOUT
+	}
 }
 
 // Validate Server config and set default listen addr if needed
@@ -42,6 +63,18 @@ func (cfg *ServerConfig) Validate() error {
 		if cfg.SSL.Cert == "" || cfg.SSL.Key == "" {
 			return ErrorIncompleteSSlConfig{}
 		}
+		if _, err := parseTLSVersion(cfg.SSL.MinVersion); err != nil {
+			return err
+		}
+		if _, err := parseCipherSuites(cfg.SSL.CipherSuites); err != nil {
+			return err
+		}
+	}
+	if cfg.ShutdownTimeout < 0 {
+		return ErrorShutdownTimeoutOutOfRange{}
+	}
+	if cfg.Admin.Enabled && cfg.Admin.Token == "" {
+		return ErrorMissingAdminToken{}
 	}
-	return nil
+	return cfg.validateAuth()
 }
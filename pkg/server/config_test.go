@@ -3,6 +3,7 @@ package server
 import (
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -32,6 +33,7 @@ func TestDefaults(t *testing.T) {
 				SSL: SSLConfig{
 					Enabled: true,
 				},
+				ShutdownTimeout: DEFAULT_SHUTDOWN_TIMEOUT,
 			},
 		},
 		{
@@ -47,6 +49,7 @@ func TestDefaults(t *testing.T) {
 				SSL: SSLConfig{
 					Enabled: false,
 				},
+				ShutdownTimeout: DEFAULT_SHUTDOWN_TIMEOUT,
 			},
 		},
 		{
@@ -55,7 +58,19 @@ func TestDefaults(t *testing.T) {
 				Listen: ":1234",
 			},
 			Result: &ServerConfig{
-				Listen: ":1234",
+				Listen:          ":1234",
+				ShutdownTimeout: DEFAULT_SHUTDOWN_TIMEOUT,
+			},
+		},
+		{
+			Name: "CustomShutdownTimeout",
+			Config: &ServerConfig{
+				Listen:          ":1234",
+				ShutdownTimeout: time.Minute,
+			},
+			Result: &ServerConfig{
+				Listen:          ":1234",
+				ShutdownTimeout: time.Minute,
 			},
 		},
 	}
@@ -92,6 +107,30 @@ func TestValidate(t *testing.T) {
 			},
 			Result: nil,
 		},
+		{
+			Name: "SSLInvalidMinVersion",
+			Config: &ServerConfig{
+				SSL: SSLConfig{
+					Enabled:    true,
+					Cert:       "foo.crt",
+					Key:        "bar.key",
+					MinVersion: "1.4",
+				},
+			},
+			Result: NewErrorInvalidTLSVersion("1.4"),
+		},
+		{
+			Name: "SSLUnknownCipherSuite",
+			Config: &ServerConfig{
+				SSL: SSLConfig{
+					Enabled:      true,
+					Cert:         "foo.crt",
+					Key:          "bar.key",
+					CipherSuites: []string{"foo"},
+				},
+			},
+			Result: NewErrorUnknownCipherSuite("foo"),
+		},
 		{
 			Name: "SSLMissingKey",
 			Config: &ServerConfig{
@@ -112,6 +151,126 @@ func TestValidate(t *testing.T) {
 			},
 			Result: ErrorIncompleteSSlConfig{},
 		},
+		{
+			Name: "NegativeShutdownTimeout",
+			Config: &ServerConfig{
+				ShutdownTimeout: -time.Second,
+			},
+			Result: ErrorShutdownTimeoutOutOfRange{},
+		},
+		{
+			Name: "AdminValid",
+			Config: &ServerConfig{
+				Admin: AdminConfig{
+					Enabled: true,
+					Token:   "secret",
+				},
+			},
+			Result: nil,
+		},
+		{
+			Name: "AdminMissingToken",
+			Config: &ServerConfig{
+				Admin: AdminConfig{
+					Enabled: true,
+				},
+			},
+			Result: ErrorMissingAdminToken{},
+		},
+		{
+			Name: "AuthValid",
+			Config: &ServerConfig{
+				SSL: SSLConfig{
+					Enabled:  true,
+					Cert:     "foo.crt",
+					Key:      "bar.key",
+					ClientCA: "ca.crt",
+				},
+				Auth: AuthConfig{
+					Enabled: true,
+					Tokens: []TokenCredentials{
+						{Name: "workers", Token: "secret", Permissions: Permissions{Groups: []string{"workers"}}},
+					},
+					Certificates: []CertificateCredentials{
+						{Subject: "node1.example.org", Permissions: Permissions{Groups: []string{"*"}}},
+					},
+				},
+			},
+			Result: nil,
+		},
+		{
+			Name: "AuthMissingCredentials",
+			Config: &ServerConfig{
+				Auth: AuthConfig{
+					Enabled: true,
+				},
+			},
+			Result: ErrorMissingClientCredentials{},
+		},
+		{
+			Name: "AuthTokenMissingName",
+			Config: &ServerConfig{
+				Auth: AuthConfig{
+					Enabled: true,
+					Tokens: []TokenCredentials{
+						{Token: "secret", Permissions: Permissions{Groups: []string{"workers"}}},
+					},
+				},
+			},
+			Result: NewErrorInvalidClientCredentials("", "token has no name"),
+		},
+		{
+			Name: "AuthTokenEmpty",
+			Config: &ServerConfig{
+				Auth: AuthConfig{
+					Enabled: true,
+					Tokens: []TokenCredentials{
+						{Name: "workers", Permissions: Permissions{Groups: []string{"workers"}}},
+					},
+				},
+			},
+			Result: NewErrorInvalidClientCredentials("workers", "token is empty"),
+		},
+		{
+			Name: "AuthTokenMissingGroups",
+			Config: &ServerConfig{
+				Auth: AuthConfig{
+					Enabled: true,
+					Tokens: []TokenCredentials{
+						{Name: "workers", Token: "secret"},
+					},
+				},
+			},
+			Result: NewErrorInvalidClientCredentials("workers", "no groups are allowed"),
+		},
+		{
+			Name: "AuthCertificateWithoutClientCA",
+			Config: &ServerConfig{
+				SSL: SSLConfig{
+					Enabled: true,
+					Cert:    "foo.crt",
+					Key:     "bar.key",
+				},
+				Auth: AuthConfig{
+					Enabled: true,
+					Certificates: []CertificateCredentials{
+						{Subject: "node1.example.org", Permissions: Permissions{Groups: []string{"*"}}},
+					},
+				},
+			},
+			Result: NewErrorInvalidClientCredentials("node1.example.org", "certificates require ssl with a clientCA"),
+		},
+		{
+			Name: "AuthDisabled",
+			Config: &ServerConfig{
+				Auth: AuthConfig{
+					Tokens: []TokenCredentials{
+						{Name: "workers"},
+					},
+				},
+			},
+			Result: nil,
+		},
 	}
 
 	for _, tCase := range tMatrix {
@@ -5,3 +5,82 @@ type ErrorIncompleteSSlConfig struct{}
 func (e ErrorIncompleteSSlConfig) Error() string {
 	return "SSL is enabled but either key or certificate is missing"
 }
+
+type ErrorShutdownTimeoutOutOfRange struct{}
+
+func (e ErrorShutdownTimeoutOutOfRange) Error() string {
+	return "The shutdown timeout can't be negative"
+}
+
+type ErrorMissingAdminToken struct{}
+
+func (e ErrorMissingAdminToken) Error() string {
+	return "The admin api is enabled but no token is set"
+}
+
+type ErrorMissingClientCredentials struct{}
+
+func (e ErrorMissingClientCredentials) Error() string {
+	return "Client authentication is enabled but no tokens or certificates are configured"
+}
+
+type ErrorInvalidClientCredentials struct {
+	name   string
+	reason string
+}
+
+func NewErrorInvalidClientCredentials(name, reason string) error {
+	return &ErrorInvalidClientCredentials{
+		name:   name,
+		reason: reason,
+	}
+}
+
+func (e *ErrorInvalidClientCredentials) Error() string {
+	return "Invalid client credentials \"" + e.name + "\": " + e.reason
+}
+
+type ErrorInvalidTLSVersion struct {
+	version string
+}
+
+func NewErrorInvalidTLSVersion(version string) error {
+	return &ErrorInvalidTLSVersion{
+		version: version,
+	}
+}
+
+func (e *ErrorInvalidTLSVersion) Error() string {
+	return "Invalid minimum TLS version \"" + e.version + "\", expected one of 1.0, 1.1, 1.2 or 1.3"
+}
+
+type ErrorUnknownCipherSuite struct {
+	name string
+}
+
+func NewErrorUnknownCipherSuite(name string) error {
+	return &ErrorUnknownCipherSuite{
+		name: name,
+	}
+}
+
+func (e *ErrorUnknownCipherSuite) Error() string {
+	return "Unknown or insecure cipher suite \"" + e.name + "\""
+}
+
+type ErrorNotLeader struct {
+	leader string
+}
+
+func NewErrorNotLeader(leader string) error {
+	return &ErrorNotLeader{
+		leader: leader,
+	}
+}
+
+func (e *ErrorNotLeader) Error() string {
+	if e.leader == "" {
+		return "Not the leader, the current leader is unknown"
+	}
+	return "Not the leader, the current leader is \"" + e.leader + "\""
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+
+	lockmanager "github.com/heathcliff26/fleetlock/pkg/lock-manager"
+	"github.com/heathcliff26/fleetlock/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	groupSlotsDesc = prometheus.NewDesc(
+		"fleetlock_group_slots",
+		"Number of slots configured for the group",
+		[]string{"group"}, nil,
+	)
+	groupSlotsUsedDesc = prometheus.NewDesc(
+		"fleetlock_group_slots_used",
+		"Number of slots currently reserved in the group",
+		[]string{"group"}, nil,
+	)
+)
+
+// Collects the slot usage of all groups from the lock manager on every scrape
+type groupCollector struct {
+	lm *lockmanager.LockManager
+}
+
+func (c *groupCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- groupSlotsDesc
+	ch <- groupSlotsUsedDesc
+}
+
+func (c *groupCollector) Collect(ch chan<- prometheus.Metric) {
+	status, err := c.lm.GetStatus()
+	if err != nil {
+		slog.Error("Failed to fetch status of groups for metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(groupSlotsUsedDesc, err)
+		return
+	}
+
+	for _, group := range status {
+		ch <- prometheus.MustNewConstMetric(groupSlotsDesc, prometheus.GaugeValue, float64(group.Slots), group.Name)
+		ch <- prometheus.MustNewConstMetric(groupSlotsUsedDesc, prometheus.GaugeValue, float64(len(group.Locks)), group.Name)
+	}
+}
+
+// Create the handler serving the prometheus metrics
+//
+//	URL: GET /metrics
+func (s *Server) metricsHandler() http.Handler {
+	reg := metrics.NewRegistry(&groupCollector{lm: s.lm})
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+// Remembers the kind of the fleetlock response sent, so the request can be counted by it
+type kindRecorder struct {
+	http.ResponseWriter
+	kind string
+}
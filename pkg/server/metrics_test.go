@@ -0,0 +1,49 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	lockmanager "github.com/heathcliff26/fleetlock/pkg/lock-manager"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	groups := lockmanager.Groups{
+		"default": lockmanager.GroupConfig{
+			Slots: 1,
+		},
+	}
+	lm := lockmanager.NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"default"}))
+	s := &Server{
+		cfg: &ServerConfig{},
+		lm:  lm,
+	}
+	s.createHTTPServer()
+
+	for _, id := range []string{"testUser", "otherUser"} {
+		rr := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rr, createRequest("/v1/pre-reboot", "default", id))
+	}
+
+	rr := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rr.Result().StatusCode)
+	b, err := io.ReadAll(rr.Result().Body)
+	require.NoError(t, err, "Should read body")
+	body := string(b)
+
+	assert := assert.New(t)
+
+	assert.Contains(body, `fleetlock_group_slots{group="default"} 1`)
+	assert.Contains(body, `fleetlock_group_slots_used{group="default"} 1`)
+	assert.Contains(body, `fleetlock_requests_total{kind="success",operation="reserve"}`)
+	assert.Contains(body, `fleetlock_requests_total{kind="all_slots_full",operation="reserve"}`)
+	assert.Contains(body, `fleetlock_storage_operation_duration_seconds_count{operation="try_reserve"}`)
+	assert.Contains(body, "go_goroutines")
+}
@@ -1,6 +1,9 @@
 package server
 
-import "github.com/heathcliff26/fleetlock/pkg/api"
+import (
+	"github.com/heathcliff26/fleetlock/pkg/api"
+	lockmanager "github.com/heathcliff26/fleetlock/pkg/lock-manager"
+)
 
 var (
 	msgMissingFleetLockHeader = api.FleetLockResponse{
@@ -13,7 +16,7 @@ var (
 	}
 	msgInvalidGroupValue = api.FleetLockResponse{
 		Kind:  "bad_request",
-		Value: "The value of group is invalid or empty. It must conform to \"" + groupValidationPattern + "\"",
+		Value: "The value of group is invalid or empty. It must conform to \"" + lockmanager.GroupNamePattern + "\"",
 	}
 	msgEmptyID = api.FleetLockResponse{
 		Kind:  "bad_request",
@@ -35,4 +38,86 @@ var (
 		Kind:  "waiting_for_node_drain",
 		Value: "The Slot has been reserved, but the node is not yet drained",
 	}
+	msgWaitingForNodeReady = api.FleetLockResponse{
+		Kind:  "waiting_for_node_ready",
+		Value: "The node is not yet ready after the reboot, the slot will be released once it is",
+	}
+	msgOutsideMaintenanceWindow = api.FleetLockResponse{
+		Kind:  "outside_maintenance_window",
+		Value: "Could not reserve a slot as the group is currently outside of its maintenance window",
+	}
+	msgBlockedByGroup = api.FleetLockResponse{
+		Kind:  "blocked_by_group",
+		Value: "Could not reserve a slot as a mutually exclusive group currently holds locks",
+	}
+	msgGroupMismatch = api.FleetLockResponse{
+		Kind:  "group_mismatch",
+		Value: "The requested group does not match the group label of the node",
+	}
+	msgInvalidNodeGroup = api.FleetLockResponse{
+		Kind:  "invalid_node_group",
+		Value: "The group label of the node is not a valid group name. It must conform to \"" + lockmanager.GroupNamePattern + "\"",
+	}
+	msgNodesNotReady = api.FleetLockResponse{
+		Kind:  "nodes_not_ready",
+		Value: "Could not reserve a slot as another node in the cluster is not ready",
+	}
+	msgNodesCordoned = api.FleetLockResponse{
+		Kind:  "nodes_cordoned",
+		Value: "Could not reserve a slot as another node in the cluster is cordoned",
+	}
+	msgPodDisruptionBudgetViolated = api.FleetLockResponse{
+		Kind:  "pod_disruption_budget_violated",
+		Value: "Could not reserve a slot as a PodDisruptionBudget has less healthy pods than desired",
+	}
+	msgNoSlotHeld = api.FleetLockResponse{
+		Kind:  "no_slot_held",
+		Value: "Could not renew the slot as it is not reserved by the client, it may have expired",
+	}
+	msgClientUnauthorized = api.FleetLockResponse{
+		Kind:  "unauthorized",
+		Value: "Missing or invalid client token or certificate",
+	}
+	msgForbidden = api.FleetLockResponse{
+		Kind:  "forbidden",
+		Value: "The client is not allowed to use the requested group or id",
+	}
+	msgNotOwner = api.FleetLockResponse{
+		Kind:  "forbidden",
+		Value: "The slot is held by the id, but was reserved by another client",
+	}
+
+	msgUnauthorized = api.FleetLockResponse{
+		Kind:  "unauthorized",
+		Value: "Missing or invalid bearer token",
+	}
+
+	msgUnknownGroup = api.FleetLockResponse{
+		Kind:  "unknown_group",
+		Value: "The requested group is not configured",
+	}
+	msgLockNotHeld = api.FleetLockResponse{
+		Kind:  "lock_not_held",
+		Value: "The id does not hold a slot in the group",
+	}
+	msgNoKubernetes = api.FleetLockResponse{
+		Kind:  "no_kubernetes",
+		Value: "The server is not running with a kubernetes client, nodes are not drained",
+	}
+	msgNoDrainStatus = api.FleetLockResponse{
+		Kind:  "no_drain_status",
+		Value: "The node has not been drained",
+	}
+	msgNotLeader = api.FleetLockResponse{
+		Kind:  "not_leader",
+		Value: "This replica is not the leader, retry the request against the leader",
+	}
+	msgAuditDisabled = api.FleetLockResponse{
+		Kind:  "audit_disabled",
+		Value: "The audit log is not enabled",
+	}
+	msgInvalidEventQuery = api.FleetLockResponse{
+		Kind:  "invalid_event_query",
+		Value: "The parameter since needs to be a RFC3339 timestamp and limit a positive number",
+	}
 )
@@ -6,37 +6,55 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/heathcliff26/fleetlock/pkg/api"
 	"github.com/heathcliff26/fleetlock/pkg/k8s"
 	lockmanager "github.com/heathcliff26/fleetlock/pkg/lock-manager"
+	lmerrors "github.com/heathcliff26/fleetlock/pkg/lock-manager/errors"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+	"github.com/heathcliff26/fleetlock/pkg/metrics"
+	"github.com/heathcliff26/fleetlock/pkg/notify"
 	"github.com/heathcliff26/simple-fileserver/pkg/middleware"
 )
 
-const groupValidationPattern = "^[a-zA-Z0-9.-]+$"
-
-var groupValidationRegex = regexp.MustCompile(groupValidationPattern)
-
 type Server struct {
-	cfg *ServerConfig
-	lm  *lockmanager.LockManager
-	k8s *k8s.Client
+	cfg      *ServerConfig
+	lm       *lockmanager.LockManager
+	k8s      *k8s.Client
+	notifier *notify.Notifier
+	// Nil when leader election is disabled
+	leader *k8s.LeaderElector
 
 	httpServer *http.Server
+
+	// Running drains, cancelled when they don't finish before the shutdown timeout
+	drains      sync.WaitGroup
+	drainInit   sync.Once
+	drainCtx    context.Context
+	drainCancel context.CancelFunc
 }
 
 // Create a new Server
-func NewServer(cfg *ServerConfig, groups lockmanager.Groups, storageCfg lockmanager.StorageConfig, k8s *k8s.Client) (*Server, error) {
+func NewServer(cfg *ServerConfig, groups lockmanager.Groups, storageCfg lockmanager.StorageConfig, auditCfg lockmanager.AuditConfig, k8s *k8s.Client) (*Server, error) {
 	lm, err := lockmanager.NewManager(groups, storageCfg)
 	if err != nil {
 		return nil, err
 	}
 
+	err = lm.EnableAudit(auditCfg)
+	if err != nil {
+		_ = lm.Close()
+		return nil, fmt.Errorf("failed to enable audit log: %w", err)
+	}
+
 	if k8s == nil {
 		slog.Info("No kubernetes client available, will not drain nodes")
+		warnMaxUnavailableWithoutK8s(groups)
+	} else {
+		lm.UseNodeCounter(k8s)
 	}
 
 	return &Server{
@@ -46,14 +64,56 @@ func NewServer(cfg *ServerConfig, groups lockmanager.Groups, storageCfg lockmana
 	}, nil
 }
 
+// Apply changed groups to the running server, requests in progress are not interrupted
+func (s *Server) UpdateGroups(groups lockmanager.Groups) {
+	if s.k8s == nil {
+		warnMaxUnavailableWithoutK8s(groups)
+	}
+	s.lm.UpdateGroups(groups)
+}
+
+func warnMaxUnavailableWithoutK8s(groups lockmanager.Groups) {
+	for name, group := range groups {
+		if group.MaxUnavailable != "" {
+			slog.Warn("No kubernetes client available, using slots instead of maxUnavailable", slog.String("group", name))
+		}
+	}
+}
+
+// Send notifications about reservations and releases to the given notifier, may be nil
+func (s *Server) SetNotifier(n *notify.Notifier) {
+	s.notifier = n
+}
+
+// Only serve locking requests while the given elector holds the leadership, may be nil
+func (s *Server) SetLeaderElector(e *k8s.LeaderElector) {
+	s.leader = e
+}
+
 // Main entrypoint for new requests
 func (s *Server) requestHandler(rw http.ResponseWriter, req *http.Request) {
-	var handleFunc func(http.ResponseWriter, api.FleetLockRequest)
+	var handleFunc func(http.ResponseWriter, api.FleetLockRequest, string, *clientIdentity)
+	var operation string
 	switch req.URL.String() {
 	case "/v1/pre-reboot":
 		handleFunc = s.handleReserve
+		operation = "reserve"
 	case "/v1/steady-state":
 		handleFunc = s.handleRelease
+		operation = "release"
+	case "/v1/renew":
+		handleFunc = s.handleRenew
+		operation = "renew"
+	}
+
+	recorder := &kindRecorder{ResponseWriter: rw}
+	rw = recorder
+	defer func() {
+		metrics.RecordRequest(operation, recorder.kind)
+	}()
+
+	if !s.checkLeader(rw) {
+		return
 	}
 
 	// Verify FleetLock header is set
@@ -72,7 +132,7 @@ func (s *Server) requestHandler(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if strings.Contains(params.Client.Group, "\n") || !groupValidationRegex.MatchString(params.Client.Group) {
+	if strings.Contains(params.Client.Group, "\n") || !lockmanager.ValidGroupName(params.Client.Group) {
 		slog.Debug("Request contained invalid characters for group", slog.String("group", params.Client.Group), slog.String("remote", ReadUserIP(req)))
 		rw.WriteHeader(http.StatusBadRequest)
 		sendResponse(rw, msgInvalidGroupValue)
@@ -86,15 +146,78 @@ func (s *Server) requestHandler(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	handleFunc(rw, params)
+	// Authenticate first, so unauthenticated clients can't cause requests to the kubernetes api
+	identity, ok := s.authenticateClient(rw, req, params)
+	if !ok {
+		return
+	}
+
+	if s.k8s != nil && !s.resolveGroup(rw, &params) {
+		return
+	}
+
+	if !s.authorize(rw, req, params, identity) {
+		return
+	}
+
+	handleFunc(rw, params, ReadUserIP(req), identity)
+}
+
+// Reject the request if leader election is enabled and this replica is not the leader
+func (s *Server) checkLeader(rw http.ResponseWriter) bool {
+	if s.leader == nil || s.leader.IsLeader() {
+		return true
+	}
+
+	slog.Debug("Rejected request, not the leader", slog.String("leader", s.leader.Leader()))
+	rw.WriteHeader(http.StatusServiceUnavailable)
+	sendResponse(rw, msgNotLeader)
+	return false
 }
 
 // Handle requests to reserve a slot
 //
 //	URL: /v1/pre-reboot
-func (s *Server) handleReserve(rw http.ResponseWriter, params api.FleetLockRequest) {
-	ok, err := s.lm.Reserve(params.Client.Group, params.Client.ID)
-	if err != nil {
+func (s *Server) handleReserve(rw http.ResponseWriter, params api.FleetLockRequest, remote string, identity *clientIdentity) {
+	if !s.checkOwner(rw, params, identity) {
+		s.recordReserveDenied(params, remote, msgNotOwner.Kind)
+		return
+	}
+
+	if s.k8s != nil && !s.checkHealthGates(rw, params, remote) {
+		return
+	}
+
+	// Only needed to avoid reporting repeated calls as new reservations
+	held := false
+	if s.lm.AuditEnabled() || s.notifier != nil {
+		var err error
+		held, err = s.lm.HasLock(params.Client.Group, params.Client.ID)
+		if err != nil {
+			slog.Error("Failed fetch slot", "error", err, slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
+			rw.WriteHeader(http.StatusInternalServerError)
+			sendResponse(rw, msgUnexpectedError)
+			return
+		}
+	}
+
+	ok, err := s.lm.ReserveAs(params.Client.Group, params.Client.ID, identity.owner())
+	var errOutsideWindow *lmerrors.ErrorOutsideMaintenanceWindow
+	var errBlocked *lmerrors.ErrorBlockedByGroup
+	switch {
+	case errors.As(err, &errOutsideWindow):
+		slog.Debug("Could not reserve slot, group is outside of maintenance window", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
+		rw.WriteHeader(http.StatusLocked)
+		sendResponse(rw, msgOutsideMaintenanceWindow)
+		s.recordReserveDenied(params, remote, msgOutsideMaintenanceWindow.Kind)
+		return
+	case errors.As(err, &errBlocked):
+		slog.Debug("Could not reserve slot, an exclusive group holds locks", "reason", err, slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
+		rw.WriteHeader(http.StatusLocked)
+		sendResponse(rw, msgBlockedByGroup)
+		s.recordReserveDenied(params, remote, msgBlockedByGroup.Kind)
+		return
+	case err != nil:
 		slog.Error("Failed to reserve slot", "error", err, slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
 		rw.WriteHeader(http.StatusInternalServerError)
 		sendResponse(rw, msgUnexpectedError)
@@ -103,6 +226,21 @@ func (s *Server) handleReserve(rw http.ResponseWriter, params api.FleetLockReque
 
 	if ok {
 		slog.Info("Reserved slot", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
+		if !held {
+			// Repeated polls of a node holding a slot don't free any slots of the group
+			s.notifier.SlotsAvailable(params.Client.Group)
+			s.lm.RecordEvent(types.Event{
+				Type:   types.EventReserve,
+				Group:  params.Client.Group,
+				ID:     params.Client.ID,
+				Remote: remote,
+			})
+			s.notifier.Notify(notify.Event{
+				Type:  notify.EventReserve,
+				Group: params.Client.Group,
+				ID:    params.Client.ID,
+			})
+		}
 		if s.k8s != nil && !s.drainNode(rw, params) {
 			return
 		}
@@ -111,26 +249,47 @@ func (s *Server) handleReserve(rw http.ResponseWriter, params api.FleetLockReque
 		slog.Debug("Could not reserve slot, all slots where filled", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
 		rw.WriteHeader(http.StatusLocked)
 		sendResponse(rw, msgSlotsFull)
+		s.recordReserveDenied(params, remote, msgSlotsFull.Kind)
+		s.notifier.SlotsFull(params.Client.Group)
 	}
 }
 
 // Handle requests to release a slot
 //
 //	URL: /v1/steady-state
-func (s *Server) handleRelease(rw http.ResponseWriter, params api.FleetLockRequest) {
-	if s.k8s != nil {
-		ok, err := s.lm.HasLock(params.Client.Group, params.Client.ID)
+func (s *Server) handleRelease(rw http.ResponseWriter, params api.FleetLockRequest, remote string, identity *clientIdentity) {
+	if !s.checkOwner(rw, params, identity) {
+		return
+	}
+
+	held := true
+	if s.k8s != nil || s.lm.AuditEnabled() || s.notifier != nil {
+		var err error
+		held, err = s.lm.HasLock(params.Client.Group, params.Client.ID)
 		if err != nil {
 			slog.Error("Failed fetch slot", "error", err, slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
 			rw.WriteHeader(http.StatusInternalServerError)
 			sendResponse(rw, msgUnexpectedError)
 			return
 		}
-		if !ok {
-			sendResponse(rw, msgSuccess)
+	}
+
+	if s.k8s != nil {
+		if !held {
+			// The slot may have expired or been released while the node rebooted, it still needs to be uncordoned
+			drained, ok := s.hasDrainLease(rw, params)
+			if !ok {
+				return
+			}
+			if !drained {
+				sendResponse(rw, msgSuccess)
+				return
+			}
+		}
+		if s.k8s.WaitForNodeReady() && !s.waitForNodeReady(rw, params) {
 			return
 		}
-		if !s.uncordonNode(rw, params) {
+		if !s.uncordonNode(rw, params, remote) {
 			return
 		}
 	}
@@ -143,9 +302,151 @@ func (s *Server) handleRelease(rw http.ResponseWriter, params api.FleetLockReque
 		return
 	}
 	slog.Info("Released slot", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
+	if held {
+		s.lm.RecordEvent(types.Event{
+			Type:   types.EventRelease,
+			Group:  params.Client.Group,
+			ID:     params.Client.ID,
+			Remote: remote,
+		})
+		s.notifier.SlotsAvailable(params.Client.Group)
+		s.notifier.Notify(notify.Event{
+			Type:  notify.EventRelease,
+			Group: params.Client.Group,
+			ID:    params.Client.ID,
+		})
+	}
+	sendResponse(rw, msgSuccess)
+}
+
+// Handle requests to renew the lease of a slot.
+// Not part of the fleetlock protocol, used by clients to keep their slot when the group has a leaseDuration.
+//
+//	URL: /v1/renew
+func (s *Server) handleRenew(rw http.ResponseWriter, params api.FleetLockRequest, _ string, identity *clientIdentity) {
+	if !s.checkOwner(rw, params, identity) {
+		return
+	}
+
+	ok, err := s.lm.Renew(params.Client.Group, params.Client.ID)
+	if err != nil {
+		slog.Error("Failed to renew slot", "error", err, slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
+		rw.WriteHeader(http.StatusInternalServerError)
+		sendResponse(rw, msgUnexpectedError)
+		return
+	}
+
+	if !ok {
+		slog.Debug("Could not renew slot, no slot is held", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
+		rw.WriteHeader(http.StatusConflict)
+		sendResponse(rw, msgNoSlotHeld)
+		return
+	}
+
+	slog.Debug("Renewed slot", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
 	sendResponse(rw, msgSuccess)
 }
 
+// Derive the group from the label of the node, when configured.
+// Either overrides the requested group or rejects the request if they do not match.
+// Requires k8s client to be non-nil.
+func (s *Server) resolveGroup(rw http.ResponseWriter, params *api.FleetLockRequest) bool {
+	label, mode := s.k8s.GroupLabel()
+	if label == "" {
+		return true
+	}
+
+	node, ok := s.matchNodeToId(rw, *params)
+	if node == "" {
+		return ok
+	}
+
+	group, err := s.k8s.GetNodeGroup(node)
+	if err != nil {
+		slog.Error("Failed to read group label of node", "error", err, slog.String("group", params.Client.Group), slog.String("id", params.Client.ID), slog.String("node", node))
+		rw.WriteHeader(http.StatusInternalServerError)
+		sendResponse(rw, msgUnexpectedError)
+		return false
+	}
+	if group == "" {
+		slog.Warn("Node does not have the group label, using requested group", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID), slog.String("node", node), slog.String("label", label))
+		return true
+	}
+	if group == params.Client.Group {
+		return true
+	}
+	if !lockmanager.ValidGroupName(group) {
+		slog.Error("Group label of node is not a valid group name", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID), slog.String("node", node), slog.String("nodeGroup", group))
+		rw.WriteHeader(http.StatusConflict)
+		sendResponse(rw, msgInvalidNodeGroup)
+		return false
+	}
+
+	if mode == k8s.GroupLabelModeValidate {
+		slog.Warn("Requested group does not match group label of node", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID), slog.String("node", node), slog.String("nodeGroup", group))
+		rw.WriteHeader(http.StatusConflict)
+		sendResponse(rw, msgGroupMismatch)
+		return false
+	}
+
+	slog.Info("Using group from node label instead of requested group", slog.String("group", group), slog.String("requestedGroup", params.Client.Group), slog.String("id", params.Client.ID), slog.String("node", node))
+	params.Client.Group = group
+	return true
+}
+
+// Ensure the cluster is healthy before a new slot is reserved.
+// Clients already holding a slot skip the gates, as their own drain may affect them.
+// Requires k8s client to be non-nil.
+func (s *Server) checkHealthGates(rw http.ResponseWriter, params api.FleetLockRequest, remote string) bool {
+	if !s.k8s.HealthGatesEnabled() {
+		return true
+	}
+
+	ok, err := s.lm.HasLock(params.Client.Group, params.Client.ID)
+	if err != nil {
+		slog.Error("Failed fetch slot", "error", err, slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
+		rw.WriteHeader(http.StatusInternalServerError)
+		sendResponse(rw, msgUnexpectedError)
+		return false
+	}
+	if ok {
+		return true
+	}
+
+	node, ok := s.matchNodeToId(rw, params)
+	if !ok {
+		return false
+	}
+
+	err = s.k8s.CheckHealthGates(node)
+	if err == nil {
+		return true
+	}
+
+	var errNotReady *k8s.ErrorNodeNotReady
+	var errCordoned *k8s.ErrorNodeCordoned
+	var errPDB *k8s.ErrorPodDisruptionBudgetViolated
+	var res api.FleetLockResponse
+	switch {
+	case errors.As(err, &errNotReady):
+		res = msgNodesNotReady
+	case errors.As(err, &errCordoned):
+		res = msgNodesCordoned
+	case errors.As(err, &errPDB):
+		res = msgPodDisruptionBudgetViolated
+	default:
+		slog.Error("Failed to check health gates", "error", err, slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
+		rw.WriteHeader(http.StatusInternalServerError)
+		sendResponse(rw, msgUnexpectedError)
+		return false
+	}
+	slog.Info("Could not reserve slot, health gate failed", "reason", err, slog.String("group", params.Client.Group), slog.String("id", params.Client.ID))
+	rw.WriteHeader(http.StatusLocked)
+	sendResponse(rw, res)
+	s.recordReserveDenied(params, remote, res.Kind)
+	return false
+}
+
 // Drain the node after reservation and before sending success to api.
 // Requires k8s client to be non-nil.
 func (s *Server) drainNode(rw http.ResponseWriter, params api.FleetLockRequest) bool {
@@ -166,24 +467,99 @@ func (s *Server) drainNode(rw http.ResponseWriter, params api.FleetLockRequest)
 		return true
 	}
 
+	ctx := s.drainContext()
+	s.drains.Add(1)
 	go func() {
-		err := s.k8s.DrainNode(node)
-		if err != nil {
+		defer s.drains.Done()
+
+		event := types.Event{
+			Type:  types.EventDrainStarted,
+			Group: params.Client.Group,
+			ID:    params.Client.ID,
+			Node:  node,
+		}
+		// Drains that are already running elsewhere are not recorded again
+		started := false
+		err := s.k8s.DrainNode(ctx, node, func() {
+			started = true
+			s.lm.RecordEvent(event)
+		})
+		if errors.Is(err, k8s.ErrorDrainInterrupted{}) {
+			slog.Warn("Drain was interrupted by shutdown, it continues when the client calls again", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID), slog.String("node", node))
+			event.Type, event.Reason = types.EventDrainFailed, err.Error()
+		} else if err != nil {
 			slog.Error("Failed to drain node", "error", err, slog.String("group", params.Client.Group), slog.String("id", params.Client.ID), slog.String("node", node))
+			event.Type, event.Reason = types.EventDrainFailed, err.Error()
 		} else {
 			slog.Info("Node finished draining, waiting for client to call again", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID), slog.String("node", node))
+			event.Type = types.EventDrainFinished
+		}
+		if started {
+			s.lm.RecordEvent(event)
 		}
 	}()
 
+	res := msgWaitingForNodeDrain
+	status, err := s.k8s.GetDrainStatus(node)
+	if err != nil {
+		slog.Debug("Could not fetch drain progress", "error", err, slog.String("node", node))
+	} else if status != nil && !status.Done() {
+		res.Value += drainProgressMessage(status.Progress)
+	}
+
+	// Return non-200 status to indicate the request is successful but the client needs to wait as it is still being processed.
+	rw.WriteHeader(http.StatusAccepted)
+	sendResponse(rw, res)
+	return false
+}
+
+// Ensure the node is ready again after the reboot, before uncordoning it.
+// Requires k8s client to be non-nil.
+func (s *Server) waitForNodeReady(rw http.ResponseWriter, params api.FleetLockRequest) bool {
+	node, ok := s.matchNodeToId(rw, params)
+	if node == "" {
+		return ok
+	}
+
+	ready, err := s.k8s.IsNodeReady(node)
+	if err != nil {
+		slog.Error("Could not check if node is ready", "error", err, slog.String("group", params.Client.Group), slog.String("id", params.Client.ID), slog.String("node", node))
+		rw.WriteHeader(http.StatusInternalServerError)
+		sendResponse(rw, msgUnexpectedError)
+		return false
+	}
+	if ready {
+		return true
+	}
+
+	slog.Info("Node is not ready yet, waiting for client to call again", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID), slog.String("node", node))
 	// Return non-200 status to indicate the request is successful but the client needs to wait as it is still being processed.
 	rw.WriteHeader(http.StatusAccepted)
-	sendResponse(rw, msgWaitingForNodeDrain)
+	sendResponse(rw, msgWaitingForNodeReady)
 	return false
 }
 
+// Check if the node of the client has been drained and not uncordoned yet.
+// Requires k8s client to be non-nil.
+func (s *Server) hasDrainLease(rw http.ResponseWriter, params api.FleetLockRequest) (bool, bool) {
+	node, ok := s.matchNodeToId(rw, params)
+	if node == "" {
+		return false, ok
+	}
+
+	status, err := s.k8s.GetDrainStatus(node)
+	if err != nil {
+		slog.Error("Could not check if node has been drained", "error", err, slog.String("group", params.Client.Group), slog.String("id", params.Client.ID), slog.String("node", node))
+		rw.WriteHeader(http.StatusInternalServerError)
+		sendResponse(rw, msgUnexpectedError)
+		return false, false
+	}
+	return status != nil, true
+}
+
 // Uncordon the node before release.
 // Requires k8s client to be non-nil.
-func (s *Server) uncordonNode(rw http.ResponseWriter, params api.FleetLockRequest) bool {
+func (s *Server) uncordonNode(rw http.ResponseWriter, params api.FleetLockRequest, remote string) bool {
 	node, ok := s.matchNodeToId(rw, params)
 	if node == "" {
 		return ok
@@ -197,9 +573,27 @@ func (s *Server) uncordonNode(rw http.ResponseWriter, params api.FleetLockReques
 		return false
 	}
 	slog.Info("Uncordoned node", slog.String("group", params.Client.Group), slog.String("id", params.Client.ID), slog.String("node", node))
+	s.lm.RecordEvent(types.Event{
+		Type:   types.EventUncordon,
+		Group:  params.Client.Group,
+		ID:     params.Client.ID,
+		Node:   node,
+		Remote: remote,
+	})
 	return true
 }
 
+// Record that a reservation was denied, the reason is the kind of the response
+func (s *Server) recordReserveDenied(params api.FleetLockRequest, remote, reason string) {
+	s.lm.RecordEvent(types.Event{
+		Type:   types.EventReserveDenied,
+		Group:  params.Client.Group,
+		ID:     params.Client.ID,
+		Remote: remote,
+		Reason: reason,
+	})
+}
+
 func (s *Server) matchNodeToId(rw http.ResponseWriter, params api.FleetLockRequest) (string, bool) {
 	node, err := s.k8s.FindNodeByZincatiID(params.Client.ID)
 	if err != nil {
@@ -217,7 +611,7 @@ func (s *Server) matchNodeToId(rw http.ResponseWriter, params api.FleetLockReque
 }
 
 // Return a health status of the server
-// URL: /healthz
+// URL: /livez, /healthz
 func (s *Server) handleHealthCheck(rw http.ResponseWriter, _ *http.Request) {
 	rw.Header().Set("Content-Type", "application/json")
 	status := api.FleetlockHealthResponse{
@@ -226,13 +620,64 @@ func (s *Server) handleHealthCheck(rw http.ResponseWriter, _ *http.Request) {
 	sendResponse(rw, status)
 }
 
+// Check the connection to the storage backend and the kubernetes api.
+// With leader election enabled, only the leader is ready.
+// Responds with 503 if any of them fails.
+// URL: /readyz
+func (s *Server) handleReadinessCheck(rw http.ResponseWriter, _ *http.Request) {
+	checks := map[string]func() error{
+		"storage": s.lm.Ping,
+	}
+	if s.k8s != nil {
+		checks["kubernetes"] = s.k8s.Ping
+	}
+	if s.leader != nil {
+		checks["leader"] = s.checkIsLeader
+	}
+
+	res := api.FleetlockReadinessResponse{
+		Status:     "ok",
+		Components: make(map[string]api.FleetlockHealthResponse, len(checks)),
+	}
+	for name, check := range checks {
+		status := api.FleetlockHealthResponse{Status: "ok"}
+		err := check()
+		if err != nil {
+			slog.Warn("Readiness check failed", slog.String("component", name), "error", err)
+			status = api.FleetlockHealthResponse{Status: "error", Error: err.Error()}
+			res.Status = "error"
+		}
+		res.Components[name] = status
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if res.Status != "ok" {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	sendResponse(rw, res)
+}
+
+func (s *Server) checkIsLeader() error {
+	if s.leader.IsLeader() {
+		return nil
+	}
+	return NewErrorNotLeader(s.leader.Leader())
+}
+
 // Prepare the http server for usage.
 // This is in a separate function to allow testing the handler without running the server.
 func (s *Server) createHTTPServer() {
 	router := http.NewServeMux()
 	router.HandleFunc("POST /v1/pre-reboot", s.requestHandler)
 	router.HandleFunc("POST /v1/steady-state", s.requestHandler)
+	router.HandleFunc("POST /v1/renew", s.requestHandler)
 	router.HandleFunc("GET /healthz", s.handleHealthCheck)
+	router.HandleFunc("GET /livez", s.handleHealthCheck)
+	router.HandleFunc("GET /readyz", s.handleReadinessCheck)
+	router.Handle("GET /metrics", s.metricsHandler())
+	if s.cfg.Admin.Enabled {
+		s.registerAdminRoutes(router)
+	}
 
 	s.httpServer = &http.Server{
 		Addr:         s.cfg.Listen,
@@ -255,8 +700,23 @@ func (s *Server) Run() error {
 
 	var err error
 	if s.cfg.SSL.Enabled {
+		s.httpServer.TLSConfig, err = s.cfg.SSL.tlsConfig()
+		if err != nil {
+			return err
+		}
+		var certs *certificateReloader
+		certs, err = newCertificateReloader(s.cfg.SSL.Cert, s.cfg.SSL.Key)
+		if err != nil {
+			return err
+		}
+		s.httpServer.TLSConfig.GetCertificate = certs.GetCertificate
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go certs.watch(ctx)
+
 		slog.Info("Starting server with SSL", slog.String("address", s.cfg.Listen))
-		err = s.httpServer.ListenAndServeTLS(s.cfg.SSL.Cert, s.cfg.SSL.Key)
+		err = s.httpServer.ListenAndServeTLS("", "")
 	} else {
 		slog.Info("Starting server", slog.String("address", s.cfg.Listen))
 		err = s.httpServer.ListenAndServe()
@@ -269,16 +729,67 @@ func (s *Server) Run() error {
 	return fmt.Errorf("failed to start server: %w", err)
 }
 
+// Stop accepting new requests and wait for running requests and drains to finish.
+// Drains still running after the shutdown timeout are interrupted.
+// Closes the lock manager afterwards, if there is one.
 func (s *Server) Shutdown() error {
-	if s.httpServer == nil {
-		return nil
+	timeout := DEFAULT_SHUTDOWN_TIMEOUT
+	if s.cfg != nil && s.cfg.ShutdownTimeout > 0 {
+		timeout = s.cfg.ShutdownTimeout
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	slog.Info("Shutting down server")
-	err := s.httpServer.Shutdown(context.Background())
-	if err != nil {
-		return fmt.Errorf("failed to shutdown server: %w", err)
+	// Continue on errors, so that the storage is closed in any case
+	var errs []error
+	if s.httpServer != nil {
+		slog.Info("Shutting down server")
+		err := s.httpServer.Shutdown(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to shutdown server: %w", err))
+		}
+	}
+
+	s.waitForDrains(ctx)
+
+	if s.lm != nil {
+		err := s.lm.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to close lock manager: %w", err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
 	}
 	slog.Info("Server shutdown complete")
 	return nil
 }
+
+// Wait for running drains until the context is done, then interrupt the remaining ones
+func (s *Server) waitForDrains(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.drains.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	slog.Warn("Drains did not finish before the shutdown timeout, interrupting them")
+	s.drainContext()
+	s.drainCancel()
+	// Interrupted drains still need to update their lease
+	<-done
+}
+
+// Return the context for drains, creating it on first use
+func (s *Server) drainContext() context.Context {
+	s.drainInit.Do(func() {
+		s.drainCtx, s.drainCancel = context.WithCancel(context.Background())
+	})
+	return s.drainCtx
+}
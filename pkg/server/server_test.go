@@ -14,14 +14,23 @@ import (
 
 	"github.com/heathcliff26/fleetlock/pkg/api"
 	"github.com/heathcliff26/fleetlock/pkg/k8s"
+	"github.com/heathcliff26/fleetlock/pkg/k8s/utils"
 	lockmanager "github.com/heathcliff26/fleetlock/pkg/lock-manager"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/memory"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/sql"
+	lmtypes "github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+	"github.com/heathcliff26/fleetlock/pkg/notify"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	coordv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
 )
 
 const (
@@ -37,7 +46,7 @@ func TestNewServer(t *testing.T) {
 	groups := lockmanager.NewDefaultGroups()
 	storageCfg := lockmanager.NewDefaultStorageConfig()
 	k8s, _ := k8s.NewFakeClient()
-	s, err := NewServer(serverCfg, groups, storageCfg, k8s)
+	s, err := NewServer(serverCfg, groups, storageCfg, lockmanager.AuditConfig{}, k8s)
 
 	assert := assert.New(t)
 
@@ -48,7 +57,7 @@ func TestNewServer(t *testing.T) {
 
 	storageCfg.Type = "Unknown"
 
-	s, err = NewServer(serverCfg, groups, storageCfg, nil)
+	s, err = NewServer(serverCfg, groups, storageCfg, lockmanager.AuditConfig{}, nil)
 
 	assert.Nil(s)
 	assert.Equal("*errors.ErrorUnkownStorageType", reflect.TypeOf(err).String())
@@ -174,7 +183,7 @@ func TestHandleReserve(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 	params := newFleetlockRequest("default", "testUser-1")
-	s.handleReserve(rr, params)
+	s.handleReserve(rr, params, "", nil)
 	res, response, err := parseResponse(rr)
 
 	assert := assert.New(t)
@@ -185,7 +194,7 @@ func TestHandleReserve(t *testing.T) {
 
 	rr = httptest.NewRecorder()
 	params.Client.ID = "testUser-2"
-	s.handleReserve(rr, params)
+	s.handleReserve(rr, params, "", nil)
 	res, response, err = parseResponse(rr)
 
 	assert.NoError(err)
@@ -194,7 +203,7 @@ func TestHandleReserve(t *testing.T) {
 
 	rr = httptest.NewRecorder()
 	params = newFleetlockRequest("", "testUser-3")
-	s.handleReserve(rr, params)
+	s.handleReserve(rr, params, "", nil)
 	res, response, err = parseResponse(rr)
 
 	assert.NoError(err)
@@ -202,13 +211,142 @@ func TestHandleReserve(t *testing.T) {
 	assert.Equal(msgUnexpectedError, response)
 }
 
+func TestUpdateGroups(t *testing.T) {
+	lm := lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
+	s := &Server{lm: lm}
+
+	s.handleReserve(httptest.NewRecorder(), newFleetlockRequest("default", "testUser-1"), "", nil)
+
+	s.UpdateGroups(lockmanager.Groups{
+		"default": lockmanager.GroupConfig{Slots: 2},
+	})
+
+	rr := httptest.NewRecorder()
+	s.handleReserve(rr, newFleetlockRequest("default", "testUser-2"), "", nil)
+	res, response, err := parseResponse(rr)
+
+	assert := assert.New(t)
+
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, res.StatusCode, "Should use the new slots")
+	assert.Equal(msgSuccess, response)
+}
+
+func TestHandleReserveOutsideMaintenanceWindow(t *testing.T) {
+	// The fake clock of synctest starts on Saturday, 2000-01-01 00:00 UTC
+	synctest.Test(t, func(t *testing.T) {
+		groups := lockmanager.Groups{
+			"default": lockmanager.GroupConfig{
+				Slots: 1,
+				Schedule: &lockmanager.Schedule{
+					Windows: []lockmanager.MaintenanceWindow{
+						{
+							Days:     []string{"Sun"},
+							Start:    "00:00",
+							Duration: time.Hour,
+						},
+					},
+				},
+			},
+		}
+		lm := lockmanager.NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"default"}))
+		s := &Server{lm: lm}
+
+		rr := httptest.NewRecorder()
+		s.handleReserve(rr, newFleetlockRequest("default", "testUser"), "", nil)
+		res, response, err := parseResponse(rr)
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		assert.Equal(http.StatusLocked, res.StatusCode)
+		assert.Equal(msgOutsideMaintenanceWindow, response)
+	})
+}
+
+func TestHandleReserveBlockedByGroup(t *testing.T) {
+	groups := lockmanager.Groups{
+		"controlplane": lockmanager.GroupConfig{
+			Slots:         1,
+			ExclusiveWith: []string{"workers"},
+		},
+		"workers": lockmanager.GroupConfig{
+			Slots: 1,
+		},
+	}
+	lm := lockmanager.NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"controlplane", "workers"}))
+	s := &Server{lm: lm}
+
+	ok, err := lm.Reserve("workers", "worker")
+	assert.True(t, ok)
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.handleReserve(rr, newFleetlockRequest("controlplane", "testUser"), "", nil)
+	res, response, err := parseResponse(rr)
+
+	assert := assert.New(t)
+
+	assert.NoError(err)
+	assert.Equal(http.StatusLocked, res.StatusCode)
+	assert.Equal(msgBlockedByGroup, response)
+}
+
+func TestHandleReserveHealthGates(t *testing.T) {
+	k8sClient, fakeclient := k8s.NewFakeClient()
+	initTestCluster(t, fakeclient)
+	k8sClient.SetHealthGates(k8s.HealthGates{NodesReady: true})
+
+	_, err := fakeclient.CoreV1().Nodes().Create(t.Context(), &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "not-ready-node",
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	lm := lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
+	s := &Server{lm: lm, k8s: k8sClient}
+
+	t.Run("GateFailed", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		s.handleReserve(rr, newFleetlockRequest("default", testNodeZincatiID), "", nil)
+		res, response, err := parseResponse(rr)
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		assert.Equal(http.StatusLocked, res.StatusCode)
+		assert.Equal(msgNodesNotReady, response)
+
+		ok, _ := lm.HasLock("default", testNodeZincatiID)
+		assert.False(ok, "Should not reserve a slot")
+	})
+	t.Run("SlotAlreadyHeld", func(t *testing.T) {
+		ok, err := lm.Reserve("default", testNodeZincatiID)
+		assert.True(t, ok)
+		assert.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		s.handleReserve(rr, newFleetlockRequest("default", testNodeZincatiID), "", nil)
+		res, response, err := parseResponse(rr)
+
+		assert := assert.New(t)
+
+		assert.NoError(err)
+		assert.Equal(http.StatusAccepted, res.StatusCode, "Should skip gates and start draining")
+		assert.Equal(msgWaitingForNodeDrain.Kind, response.Kind)
+	})
+}
+
 func TestHandleRelease(t *testing.T) {
 	lm := lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
 	s := &Server{lm: lm}
 
 	rr := httptest.NewRecorder()
 	params := newFleetlockRequest("", "testUser")
-	s.handleRelease(rr, params)
+	s.handleRelease(rr, params, "", nil)
 	res, response, err := parseResponse(rr)
 
 	assert := assert.New(t)
@@ -218,6 +356,48 @@ func TestHandleRelease(t *testing.T) {
 	assert.Equal(msgUnexpectedError, response)
 }
 
+func TestHandleRenew(t *testing.T) {
+	groups := lockmanager.Groups{
+		"default": lockmanager.GroupConfig{
+			Slots:         1,
+			LeaseDuration: time.Minute,
+		},
+	}
+	lm := lockmanager.NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"default"}))
+	s := &Server{cfg: &ServerConfig{}, lm: lm}
+	s.createHTTPServer()
+
+	assert := assert.New(t)
+
+	rr := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rr, createRequest("/v1/renew", "default", "testUser"))
+	res, response, err := parseResponse(rr)
+
+	assert.NoError(err)
+	assert.Equal(http.StatusConflict, res.StatusCode)
+	assert.Equal(msgNoSlotHeld, response)
+
+	ok, err := lm.Reserve("default", "testUser")
+	assert.True(ok)
+	assert.NoError(err)
+
+	rr = httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rr, createRequest("/v1/renew", "default", "testUser"))
+	res, response, err = parseResponse(rr)
+
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal(msgSuccess, response)
+
+	rr = httptest.NewRecorder()
+	s.handleRenew(rr, newFleetlockRequest("", "testUser"), "", nil)
+	res, response, err = parseResponse(rr)
+
+	assert.NoError(err)
+	assert.Equal(http.StatusInternalServerError, res.StatusCode)
+	assert.Equal(msgUnexpectedError, response)
+}
+
 func TestHandleReleaseHasLockError(t *testing.T) {
 	lm := lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
 	k8sClient, fakeclient := k8s.NewFakeClient()
@@ -231,7 +411,7 @@ func TestHandleReleaseHasLockError(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 	params := newFleetlockRequest("", "testUser")
-	s.handleRelease(rr, params)
+	s.handleRelease(rr, params, "", nil)
 	res, response, err := parseResponse(rr)
 
 	assert.NoError(err)
@@ -268,7 +448,7 @@ func TestHandleReleaseUncordonNodeError(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 	params := newFleetlockRequest("default", "testUser")
-	s.handleRelease(rr, params)
+	s.handleRelease(rr, params, "", nil)
 	res, response, err := parseResponse(rr)
 
 	assert.NoError(err)
@@ -279,6 +459,52 @@ func TestHandleReleaseUncordonNodeError(t *testing.T) {
 	assert.True(ok, "Lock should still be held after failed uncordonNode")
 }
 
+func TestHandleReleaseWaitForNodeReady(t *testing.T) {
+	assert := assert.New(t)
+
+	lm := lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
+	ok, err := lm.Reserve("default", testNodeZincatiID)
+	assert.NoError(err, "Should reserve lock")
+	assert.True(ok, "Should reserve lock")
+
+	k8sClient, fakeclient := k8s.NewFakeClient()
+	initTestCluster(t, fakeclient)
+	k8sClient.SetWaitForNodeReady(true)
+
+	s := &Server{
+		lm:  lm,
+		k8s: k8sClient,
+	}
+
+	rr := httptest.NewRecorder()
+	s.handleRelease(rr, newFleetlockRequest("default", testNodeZincatiID), "", nil)
+	res, response, err := parseResponse(rr)
+
+	assert.NoError(err)
+	assert.Equal(http.StatusAccepted, res.StatusCode)
+	assert.Equal(msgWaitingForNodeReady, response)
+
+	ok, _ = lm.HasLock("default", testNodeZincatiID)
+	assert.True(ok, "Lock should still be held while node is not ready")
+
+	node, err := fakeclient.CoreV1().Nodes().Get(t.Context(), testNodeName, metav1.GetOptions{})
+	assert.NoError(err)
+	node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+	_, err = fakeclient.CoreV1().Nodes().UpdateStatus(t.Context(), node, metav1.UpdateOptions{})
+	assert.NoError(err)
+
+	rr = httptest.NewRecorder()
+	s.handleRelease(rr, newFleetlockRequest("default", testNodeZincatiID), "", nil)
+	res, response, err = parseResponse(rr)
+
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal(msgSuccess, response)
+
+	ok, _ = lm.HasLock("default", testNodeZincatiID)
+	assert.False(ok, "Lock should be released once the node is ready")
+}
+
 func TestDrainNode(t *testing.T) {
 	synctest.Test(t, func(t *testing.T) {
 		groups := lockmanager.NewDefaultGroups()
@@ -298,7 +524,7 @@ func TestDrainNode(t *testing.T) {
 		// Drain non-existing node
 		rr := httptest.NewRecorder()
 		params := newFleetlockRequest("default", "abcdef123456789")
-		s.handleReserve(rr, params)
+		s.handleReserve(rr, params, "", nil)
 		res, response, err := parseResponse(rr)
 
 		assert.NoError(err, "Requests should be handled without error")
@@ -308,18 +534,18 @@ func TestDrainNode(t *testing.T) {
 		// Drain existing node
 		rr = httptest.NewRecorder()
 		params.Client.ID = testNodeZincatiID
-		s.handleReserve(rr, params)
+		s.handleReserve(rr, params, "", nil)
 		res, response, err = parseResponse(rr)
 
 		assert.NoError(err, "Requests should be handled without error")
 		assert.Equal(http.StatusAccepted, res.StatusCode, "Should return 202 Accepted when node is still being drained")
-		assert.Equal(msgWaitingForNodeDrain, response, "Should return message for node drain in progress")
+		assert.Equal(msgWaitingForNodeDrain.Kind, response.Kind, "Should return message for node drain in progress")
 
 		// Wait for the node to be drained
 		synctest.Sleep(time.Minute)
 
 		rr = httptest.NewRecorder()
-		s.handleReserve(rr, params)
+		s.handleReserve(rr, params, "", nil)
 		res, response, err = parseResponse(rr)
 
 		assert.NoError(err, "Requests should be handled without error")
@@ -328,6 +554,185 @@ func TestDrainNode(t *testing.T) {
 	})
 }
 
+func TestDrainNodeProgress(t *testing.T) {
+	lm := lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
+	k8sClient, fakeclient := k8s.NewFakeClient()
+	initTestCluster(t, fakeclient)
+	s := &Server{
+		lm:  lm,
+		k8s: k8sClient,
+	}
+
+	// A drain that is still in progress
+	lease := &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "fleetlock-drain-" + testNodeName,
+			Annotations: map[string]string{
+				"fleetlock.heathcliff.eu/DrainProgress": `{"podsRemaining":["default/app","default/db"],"failedEvictions":[{"pod":"default/db","reason":"blocked by pdb"}]}`,
+			},
+		},
+		Spec: coordv1.LeaseSpec{
+			HolderIdentity:       utils.Pointer("draining"),
+			LeaseDurationSeconds: utils.Pointer(int32(300)),
+			AcquireTime:          &metav1.MicroTime{Time: time.Now()},
+		},
+	}
+	_, err := fakeclient.CoordinationV1().Leases("fleetlock").Create(t.Context(), lease, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	s.handleReserve(rr, newFleetlockRequest("default", testNodeZincatiID), "", nil)
+	res, response, err := parseResponse(rr)
+
+	assert := assert.New(t)
+
+	assert.NoError(err)
+	assert.Equal(http.StatusAccepted, res.StatusCode)
+	assert.Equal(msgWaitingForNodeDrain.Kind, response.Kind)
+	assert.Equal(msgWaitingForNodeDrain.Value+", pods remaining: 2, failed evictions: default/db (blocked by pdb)", response.Value)
+}
+
+func TestAuditEvents(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		groups := lockmanager.NewDefaultGroups()
+		groups["default"] = lockmanager.GroupConfig{
+			Slots: 1,
+		}
+		lm := lockmanager.NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"default"}))
+		require.NoError(t, lm.EnableAudit(lockmanager.AuditConfig{Sink: lockmanager.AuditSinkStorage}))
+		k8sClient, fakeclient := k8s.NewFakeClient()
+		s := &Server{
+			cfg: &ServerConfig{},
+			lm:  lm,
+			k8s: k8sClient,
+		}
+		s.createHTTPServer()
+		initTestCluster(t, fakeclient)
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		sendRequest := func(target, id string) int {
+			req := createRequest(target, "default", id)
+			req.RemoteAddr = "192.0.2.1:1234"
+			rr := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(rr, req)
+			return rr.Result().StatusCode
+		}
+
+		assert.Equal(http.StatusAccepted, sendRequest("/v1/pre-reboot", testNodeZincatiID), "Should start drain")
+		synctest.Wait()
+		assert.Equal(http.StatusOK, sendRequest("/v1/pre-reboot", testNodeZincatiID), "Should finish drain")
+		assert.Equal(http.StatusLocked, sendRequest("/v1/pre-reboot", "testUser"), "Should deny second reservation")
+		assert.Equal(http.StatusOK, sendRequest("/v1/steady-state", testNodeZincatiID), "Should release slot")
+
+		events, err := lm.QueryEvents(lmtypes.EventFilter{})
+		require.NoError(err)
+		for i := range events {
+			events[i].Time = time.Time{}
+		}
+
+		expectedEvents := []lmtypes.Event{
+			{Type: lmtypes.EventReserve, Group: "default", ID: testNodeZincatiID, Remote: "192.0.2.1:1234"},
+			{Type: lmtypes.EventDrainStarted, Group: "default", ID: testNodeZincatiID, Node: testNodeName},
+			{Type: lmtypes.EventDrainFinished, Group: "default", ID: testNodeZincatiID, Node: testNodeName},
+			{Type: lmtypes.EventReserveDenied, Group: "default", ID: "testUser", Remote: "192.0.2.1:1234", Reason: msgSlotsFull.Kind},
+			{Type: lmtypes.EventUncordon, Group: "default", ID: testNodeZincatiID, Node: testNodeName, Remote: "192.0.2.1:1234"},
+			{Type: lmtypes.EventRelease, Group: "default", ID: testNodeZincatiID, Remote: "192.0.2.1:1234"},
+		}
+		assert.Equal(expectedEvents, events)
+	})
+}
+
+func TestNotifications(t *testing.T) {
+	events := make(chan notify.Event, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		var event notify.Event
+		err := json.UnmarshalRead(req.Body, &event)
+		assert.NoError(t, err, "Should send parsable event")
+		events <- event
+	}))
+	t.Cleanup(srv.Close)
+
+	notifier, err := notify.NewNotifier(notify.Config{
+		Webhooks: []notify.WebhookConfig{{URL: srv.URL}},
+	})
+	require.NoError(t, err)
+
+	s := &Server{
+		lm: lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"})),
+	}
+	s.SetNotifier(notifier)
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	s.handleReserve(httptest.NewRecorder(), newFleetlockRequest("default", "testUser"), "", nil)
+	s.handleReserve(httptest.NewRecorder(), newFleetlockRequest("default", "testUser"), "", nil)
+	s.handleReserve(httptest.NewRecorder(), newFleetlockRequest("default", "otherUser"), "", nil)
+	notifier.Wait()
+
+	require.Len(events, 1, "Should only notify about new reservations")
+	event := <-events
+	assert.Equal(notify.EventReserve, event.Type)
+	assert.Equal("default", event.Group)
+	assert.Equal("testUser", event.ID)
+
+	s.handleRelease(httptest.NewRecorder(), newFleetlockRequest("default", "testUser"), "", nil)
+	s.handleRelease(httptest.NewRecorder(), newFleetlockRequest("default", "testUser"), "", nil)
+	notifier.Wait()
+
+	require.Len(events, 1, "Should only notify about releases of held slots")
+	event = <-events
+	assert.Equal(notify.EventRelease, event.Type)
+	assert.Equal("default", event.Group)
+	assert.Equal("testUser", event.ID)
+}
+
+func TestNotificationsSlotsFull(t *testing.T) {
+	events := make(chan notify.Event, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		var event notify.Event
+		err := json.UnmarshalRead(req.Body, &event)
+		assert.NoError(t, err, "Should send parsable event")
+		events <- event
+	}))
+	t.Cleanup(srv.Close)
+
+	notifier, err := notify.NewNotifier(notify.Config{
+		Webhooks:       []notify.WebhookConfig{{URL: srv.URL, Events: []string{notify.EventSlotsFull}}},
+		SlotsFullAfter: time.Nanosecond,
+	})
+	require.NoError(t, err)
+
+	s := &Server{
+		lm: lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"})),
+	}
+	s.SetNotifier(notifier)
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	s.handleReserve(httptest.NewRecorder(), newFleetlockRequest("default", "testUser"), "", nil)
+	s.handleReserve(httptest.NewRecorder(), newFleetlockRequest("default", "otherUser"), "", nil)
+	// Polling again while holding the slot does not free any slots
+	s.handleReserve(httptest.NewRecorder(), newFleetlockRequest("default", "testUser"), "", nil)
+	s.handleReserve(httptest.NewRecorder(), newFleetlockRequest("default", "otherUser"), "", nil)
+	notifier.Wait()
+
+	require.Len(events, 1, "Should notify that the slots are full")
+	event := <-events
+	assert.Equal(notify.EventSlotsFull, event.Type)
+	assert.Equal("default", event.Group)
+
+	s.handleRelease(httptest.NewRecorder(), newFleetlockRequest("default", "testUser"), "", nil)
+	s.handleReserve(httptest.NewRecorder(), newFleetlockRequest("default", "otherUser"), "", nil)
+	s.handleReserve(httptest.NewRecorder(), newFleetlockRequest("default", "testUser"), "", nil)
+	notifier.Wait()
+
+	assert.Empty(events, "Should restart the timer after a release")
+}
+
 func TestHandleReleaseSkipUncordonWithoutLock(t *testing.T) {
 	groups := lockmanager.NewDefaultGroups()
 	groups["default"] = lockmanager.GroupConfig{
@@ -350,7 +755,7 @@ func TestHandleReleaseSkipUncordonWithoutLock(t *testing.T) {
 	params := newFleetlockRequest("default", testNodeZincatiID)
 
 	rr := httptest.NewRecorder()
-	s.handleRelease(rr, params)
+	s.handleRelease(rr, params, "", nil)
 	res, response, err := parseResponse(rr)
 	assert.NoError(err)
 	assert.Equal(http.StatusOK, res.StatusCode)
@@ -361,6 +766,58 @@ func TestHandleReleaseSkipUncordonWithoutLock(t *testing.T) {
 	assert.True(node.Spec.Unschedulable, "Node should not be uncordoned when lock is not held")
 }
 
+func TestHandleReleaseAfterLockExpired(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		groups := lockmanager.NewDefaultGroups()
+		groups["default"] = lockmanager.GroupConfig{
+			Slots:         1,
+			LeaseDuration: time.Minute,
+		}
+		lm := lockmanager.NewManagerWithStorage(groups, memory.NewMemoryBackend([]string{"default"}))
+		k8sClient, fakeclient := k8s.NewFakeClient()
+		s := &Server{
+			lm:  lm,
+			k8s: k8sClient,
+		}
+		initTestCluster(t, fakeclient)
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		params := newFleetlockRequest("default", testNodeZincatiID)
+
+		rr := httptest.NewRecorder()
+		s.handleReserve(rr, params, "", nil)
+		synctest.Sleep(30 * time.Second)
+		rr = httptest.NewRecorder()
+		s.handleReserve(rr, params, "", nil)
+		res, _, err := parseResponse(rr)
+		require.NoError(err)
+		require.Equal(http.StatusOK, res.StatusCode, "Should have drained the node")
+
+		// The client does not renew the slot while rebooting
+		synctest.Sleep(2 * time.Minute)
+		ok, err := lm.HasLock("default", testNodeZincatiID)
+		require.NoError(err)
+		require.False(ok, "Lock should have expired")
+
+		rr = httptest.NewRecorder()
+		s.handleRelease(rr, params, "", nil)
+		res, response, err := parseResponse(rr)
+		assert.NoError(err)
+		assert.Equal(http.StatusOK, res.StatusCode)
+		assert.Equal(msgSuccess, response)
+
+		node, err := fakeclient.CoreV1().Nodes().Get(t.Context(), testNodeName, metav1.GetOptions{})
+		assert.NoError(err)
+		assert.False(node.Spec.Unschedulable, "Node should be uncordoned")
+
+		status, err := k8sClient.GetDrainStatus(testNodeName)
+		assert.NoError(err)
+		assert.Nil(status, "Should delete the drain lease")
+	})
+}
+
 func TestUncordonNode(t *testing.T) {
 	lm := lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
 	k8s, fakeclient := k8s.NewFakeClient()
@@ -374,7 +831,7 @@ func TestUncordonNode(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 	params := newFleetlockRequest("default", "abcdef123456789")
-	s.handleRelease(rr, params)
+	s.handleRelease(rr, params, "", nil)
 	res, response, err := parseResponse(rr)
 
 	assert.NoError(err)
@@ -383,7 +840,7 @@ func TestUncordonNode(t *testing.T) {
 
 	rr = httptest.NewRecorder()
 	params.Client.ID = testNodeZincatiID
-	assert.True(s.uncordonNode(rr, params))
+	assert.True(s.uncordonNode(rr, params, ""))
 }
 
 func TestHealthCheck(t *testing.T) {
@@ -407,6 +864,151 @@ func TestHealthCheck(t *testing.T) {
 	assert.Equal(expectedRes, res, "Response should match")
 }
 
+func TestReadinessCheck(t *testing.T) {
+	t.Run("Ready", func(t *testing.T) {
+		k8sClient, _ := k8s.NewFakeClient()
+		s := &Server{
+			lm:  lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"})),
+			k8s: k8sClient,
+		}
+		rr := httptest.NewRecorder()
+
+		assert := assert.New(t)
+
+		s.handleReadinessCheck(rr, nil)
+
+		assert.Equal(http.StatusOK, rr.Result().StatusCode, "Should be ready")
+		assert.Equal("application/json", rr.Header().Get("Content-Type"), "Content type should be json")
+
+		var res api.FleetlockReadinessResponse
+		assert.NoError(json.UnmarshalRead(rr.Result().Body, &res), "Response should be parsable")
+		assert.Equal(api.FleetlockReadinessResponse{
+			Status: "ok",
+			Components: map[string]api.FleetlockHealthResponse{
+				"storage":    {Status: "ok"},
+				"kubernetes": {Status: "ok"},
+			},
+		}, res, "Response should match")
+	})
+	t.Run("WithoutKubernetes", func(t *testing.T) {
+		s := &Server{
+			lm: lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"})),
+		}
+		rr := httptest.NewRecorder()
+
+		assert := assert.New(t)
+
+		s.handleReadinessCheck(rr, nil)
+
+		assert.Equal(http.StatusOK, rr.Result().StatusCode, "Should be ready")
+
+		var res api.FleetlockReadinessResponse
+		assert.NoError(json.UnmarshalRead(rr.Result().Body, &res), "Response should be parsable")
+		assert.NotContains(res.Components, "kubernetes", "Should not check kubernetes when it is not configured")
+	})
+	t.Run("KubernetesUnreachable", func(t *testing.T) {
+		k8sClient, fakeclient := k8s.NewFakeClient()
+		fakeclient.PrependReactor("list", "nodes", func(_ clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewServiceUnavailable("api server down")
+		})
+		s := &Server{
+			lm:  lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"})),
+			k8s: k8sClient,
+		}
+		rr := httptest.NewRecorder()
+
+		assert := assert.New(t)
+
+		s.handleReadinessCheck(rr, nil)
+
+		assert.Equal(http.StatusServiceUnavailable, rr.Result().StatusCode, "Should not be ready")
+
+		var res api.FleetlockReadinessResponse
+		assert.NoError(json.UnmarshalRead(rr.Result().Body, &res), "Response should be parsable")
+		assert.Equal("error", res.Status)
+		assert.Equal(api.FleetlockHealthResponse{Status: "ok"}, res.Components["storage"], "Storage should be healthy")
+		assert.Equal("error", res.Components["kubernetes"].Status)
+		assert.Contains(res.Components["kubernetes"].Error, "api server down", "Should contain the error")
+	})
+	t.Run("StorageUnreachable", func(t *testing.T) {
+		storage, err := sql.NewSQLiteBackend(sql.SQLiteConfig{File: ":memory:"})
+		require.NoError(t, err, "Should create sqlite backend")
+		require.NoError(t, storage.Close(), "Should close sqlite backend")
+		s := &Server{
+			lm: lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), storage),
+		}
+		rr := httptest.NewRecorder()
+
+		assert := assert.New(t)
+
+		s.handleReadinessCheck(rr, nil)
+
+		assert.Equal(http.StatusServiceUnavailable, rr.Result().StatusCode, "Should not be ready")
+
+		var res api.FleetlockReadinessResponse
+		assert.NoError(json.UnmarshalRead(rr.Result().Body, &res), "Response should be parsable")
+		assert.Equal("error", res.Status)
+		assert.Equal("error", res.Components["storage"].Status)
+		assert.NotEmpty(res.Components["storage"].Error, "Should contain the error")
+	})
+}
+
+func TestLeaderElection(t *testing.T) {
+	newServer := func(t *testing.T) (*Server, *k8s.LeaderElector) {
+		k8sClient, _ := k8s.NewFakeClient()
+		elector, err := k8sClient.NewLeaderElector(k8s.NewDefaultConfig().LeaderElection)
+		require.NoError(t, err, "Should create leader elector")
+		s := &Server{
+			cfg: &ServerConfig{},
+			lm:  lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"})),
+		}
+		s.SetLeaderElector(elector)
+		return s, elector
+	}
+
+	t.Run("Follower", func(t *testing.T) {
+		s, _ := newServer(t)
+		assert := assert.New(t)
+
+		rr := httptest.NewRecorder()
+		s.requestHandler(rr, createRequest("/v1/pre-reboot", "default", "testUser"))
+		res, response, err := parseResponse(rr)
+
+		assert.NoError(err)
+		assert.Equal(http.StatusServiceUnavailable, res.StatusCode, "Should reject requests")
+		assert.Equal(msgNotLeader, response)
+
+		rr = httptest.NewRecorder()
+		s.handleReadinessCheck(rr, nil)
+		assert.Equal(http.StatusServiceUnavailable, rr.Result().StatusCode, "Should not be ready")
+
+		var readiness api.FleetlockReadinessResponse
+		assert.NoError(json.UnmarshalRead(rr.Result().Body, &readiness), "Response should be parsable")
+		assert.Equal(api.FleetlockHealthResponse{Status: "error", Error: NewErrorNotLeader("").Error()}, readiness.Components["leader"])
+	})
+	t.Run("Leader", func(t *testing.T) {
+		s, elector := newServer(t)
+		assert := assert.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go elector.Run(ctx)
+		require.Eventually(t, elector.IsLeader, 5*time.Second, 10*time.Millisecond, "Should become leader")
+
+		rr := httptest.NewRecorder()
+		s.requestHandler(rr, createRequest("/v1/pre-reboot", "default", "testUser"))
+		res, response, err := parseResponse(rr)
+
+		assert.NoError(err)
+		assert.Equal(http.StatusOK, res.StatusCode, "Should serve requests")
+		assert.Equal(msgSuccess, response)
+
+		rr = httptest.NewRecorder()
+		s.handleReadinessCheck(rr, nil)
+		assert.Equal(http.StatusOK, rr.Result().StatusCode, "Should be ready")
+	})
+}
+
 func TestServerShutdown(t *testing.T) {
 	s := &Server{}
 
@@ -421,6 +1023,52 @@ func TestServerShutdown(t *testing.T) {
 	assert.NoError(s.Shutdown(), "Should succeed in shutting down the server")
 }
 
+func TestServerShutdownInterruptsDrain(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		lm := lockmanager.NewManagerWithStorage(lockmanager.NewDefaultGroups(), memory.NewMemoryBackend([]string{"default"}))
+		k8sClient, fakeclient := k8s.NewFakeClient()
+		initTestCluster(t, fakeclient)
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "default",
+			},
+			Spec: v1.PodSpec{
+				NodeName: testNodeName,
+			},
+		}
+		_, err := fakeclient.CoreV1().Pods("default").Create(t.Context(), pod, metav1.CreateOptions{})
+		require.NoError(t, err)
+		// Evictions are always blocked, so the drain never finishes
+		fakeclient.PrependReactor("create", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "eviction" {
+				return false, nil, nil
+			}
+			return true, nil, apierrors.NewTooManyRequests("blocked by pdb", 1)
+		})
+		s := &Server{
+			cfg: &ServerConfig{ShutdownTimeout: 5 * time.Second},
+			lm:  lm,
+			k8s: k8sClient,
+		}
+
+		rr := httptest.NewRecorder()
+		s.handleReserve(rr, newFleetlockRequest("default", testNodeZincatiID), "", nil)
+		res, _, err := parseResponse(rr)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusAccepted, res.StatusCode, "Should start draining the node")
+
+		assert := assert.New(t)
+
+		assert.NoError(s.Shutdown(), "Should shutdown without error")
+
+		status, err := k8sClient.GetDrainStatus(testNodeName)
+		assert.NoError(err)
+		require.NotNil(t, status, "Should have a drain lease")
+		assert.Equal("interrupted", status.State, "Should mark the drain as interrupted")
+	})
+}
+
 func newFleetlockRequest(group, id string) api.FleetLockRequest {
 	return api.FleetLockRequest{
 		Client: api.FleetLockRequestClient{
@@ -451,6 +1099,129 @@ func parseResponse(rr *httptest.ResponseRecorder) (*http.Response, api.FleetLock
 	return res, response, err
 }
 
+func TestResolveGroup(t *testing.T) {
+	const groupLabel = "fleetlock.heathcliff.eu/group"
+
+	tMatrix := []struct {
+		Name             string
+		Mode             string
+		Label            string
+		ID               string
+		Requested        string
+		ExpectedOK       bool
+		ExpectedGroup    string
+		ExpectedStatus   int
+		ExpectedResponse api.FleetLockResponse
+	}{
+		{
+			Name:          "OverrideMismatch",
+			Mode:          k8s.GroupLabelModeOverride,
+			Label:         "workers",
+			ID:            testNodeZincatiID,
+			Requested:     "default",
+			ExpectedOK:    true,
+			ExpectedGroup: "workers",
+		},
+		{
+			Name:             "ValidateMismatch",
+			Mode:             k8s.GroupLabelModeValidate,
+			Label:            "workers",
+			ID:               testNodeZincatiID,
+			Requested:        "default",
+			ExpectedOK:       false,
+			ExpectedStatus:   http.StatusConflict,
+			ExpectedResponse: msgGroupMismatch,
+		},
+		{
+			Name:             "InvalidLabel",
+			Mode:             k8s.GroupLabelModeOverride,
+			Label:            "fleetlock_events",
+			ID:               testNodeZincatiID,
+			Requested:        "default",
+			ExpectedOK:       false,
+			ExpectedStatus:   http.StatusConflict,
+			ExpectedResponse: msgInvalidNodeGroup,
+		},
+		{
+			Name:          "ValidateMatch",
+			Mode:          k8s.GroupLabelModeValidate,
+			Label:         "workers",
+			ID:            testNodeZincatiID,
+			Requested:     "workers",
+			ExpectedOK:    true,
+			ExpectedGroup: "workers",
+		},
+		{
+			Name:          "NodeWithoutLabel",
+			Mode:          k8s.GroupLabelModeValidate,
+			ID:            testNodeZincatiID,
+			Requested:     "default",
+			ExpectedOK:    true,
+			ExpectedGroup: "default",
+		},
+		{
+			Name:          "UnknownNode",
+			Mode:          k8s.GroupLabelModeValidate,
+			Label:         "workers",
+			ID:            "abcdef123456789",
+			Requested:     "default",
+			ExpectedOK:    true,
+			ExpectedGroup: "default",
+		},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Name, func(t *testing.T) {
+			k8sClient, fakeclient := k8s.NewFakeClient()
+			k8sClient.SetGroupLabel(groupLabel, tCase.Mode)
+			initTestCluster(t, fakeclient)
+
+			if tCase.Label != "" {
+				node, err := fakeclient.CoreV1().Nodes().Get(t.Context(), testNodeName, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("Failed to get node: %v", err)
+				}
+				node.Labels = map[string]string{groupLabel: tCase.Label}
+				_, err = fakeclient.CoreV1().Nodes().Update(t.Context(), node, metav1.UpdateOptions{})
+				if err != nil {
+					t.Fatalf("Failed to update node: %v", err)
+				}
+			}
+
+			s := &Server{k8s: k8sClient}
+
+			rr := httptest.NewRecorder()
+			params := newFleetlockRequest(tCase.Requested, tCase.ID)
+			ok := s.resolveGroup(rr, &params)
+
+			assert := assert.New(t)
+
+			assert.Equal(tCase.ExpectedOK, ok)
+			if tCase.ExpectedOK {
+				assert.Equal(tCase.ExpectedGroup, params.Client.Group)
+				return
+			}
+
+			res, response, err := parseResponse(rr)
+			assert.NoError(err)
+			assert.Equal(tCase.ExpectedStatus, res.StatusCode)
+			assert.Equal(tCase.ExpectedResponse, response)
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		k8sClient, fakeclient := k8s.NewFakeClient()
+		initTestCluster(t, fakeclient)
+		s := &Server{k8s: k8sClient}
+
+		params := newFleetlockRequest("default", testNodeZincatiID)
+		ok := s.resolveGroup(httptest.NewRecorder(), &params)
+
+		assert.True(t, ok)
+		assert.Equal(t, "default", params.Client.Group, "Should not change group when disabled")
+	})
+}
+
 func initTestCluster(t *testing.T, client *fake.Clientset) {
 	testNode := &v1.Node{
 		ObjectMeta: metav1.ObjectMeta{
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTLSVersion(t *testing.T) {
+	tMatrix := []struct {
+		Version string
+		Result  uint16
+		Error   error
+	}{
+		{"", tls.VersionTLS12, nil},
+		{"1.0", tls.VersionTLS10, nil},
+		{"1.1", tls.VersionTLS11, nil},
+		{"1.2", tls.VersionTLS12, nil},
+		{"1.3", tls.VersionTLS13, nil},
+		{"1.4", 0, NewErrorInvalidTLSVersion("1.4")},
+		{"TLS1.3", 0, NewErrorInvalidTLSVersion("TLS1.3")},
+	}
+
+	for _, tCase := range tMatrix {
+		t.Run(tCase.Version, func(t *testing.T) {
+			version, err := parseTLSVersion(tCase.Version)
+
+			assert.Equal(t, tCase.Result, version)
+			assert.Equal(t, tCase.Error, err)
+		})
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		ids, err := parseCipherSuites(nil)
+
+		assert.NoError(t, err, "Should succeed")
+		assert.Nil(t, ids, "Should use the defaults")
+	})
+	t.Run("Valid", func(t *testing.T) {
+		ids, err := parseCipherSuites([]string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"})
+
+		assert.NoError(t, err, "Should succeed")
+		assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256}, ids)
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := parseCipherSuites([]string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", "foo"})
+
+		assert.Equal(t, NewErrorUnknownCipherSuite("foo"), err)
+	})
+	t.Run("Insecure", func(t *testing.T) {
+		_, err := parseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"})
+
+		assert.Equal(t, NewErrorUnknownCipherSuite("TLS_RSA_WITH_RC4_128_SHA"), err)
+	})
+}
+
+func TestSSLConfigTLSConfig(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		cfg, err := SSLConfig{}.tlsConfig()
+
+		assert := assert.New(t)
+
+		assert.NoError(err, "Should succeed")
+		require.NotNil(t, cfg, "Should create a tls config")
+		assert.Equal(uint16(tls.VersionTLS12), cfg.MinVersion, "Should default to TLS 1.2")
+		assert.Nil(cfg.CipherSuites, "Should use the default cipher suites")
+		assert.Equal(tls.NoClientCert, cfg.ClientAuth, "Should not ask for client certificates")
+	})
+	t.Run("MinVersionAndCipherSuites", func(t *testing.T) {
+		cfg, err := SSLConfig{MinVersion: "1.3", CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}}.tlsConfig()
+
+		assert := assert.New(t)
+
+		assert.NoError(err, "Should succeed")
+		require.NotNil(t, cfg, "Should create a tls config")
+		assert.Equal(uint16(tls.VersionTLS13), cfg.MinVersion)
+		assert.Equal([]uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}, cfg.CipherSuites)
+	})
+	t.Run("InvalidMinVersion", func(t *testing.T) {
+		_, err := SSLConfig{MinVersion: "2.0"}.tlsConfig()
+
+		assert.Equal(t, NewErrorInvalidTLSVersion("2.0"), err)
+	})
+	t.Run("MissingFile", func(t *testing.T) {
+		_, err := SSLConfig{ClientCA: "not-a-file"}.tlsConfig()
+
+		assert.Error(t, err, "Should fail")
+	})
+	t.Run("NotPEM", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ca.crt")
+		require.NoError(t, os.WriteFile(path, []byte("not a certificate"), 0600), "Should write file")
+
+		_, err := SSLConfig{ClientCA: path}.tlsConfig()
+
+		assert.Error(t, err, "Should fail")
+	})
+	t.Run("ClientCABundle", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ca.crt")
+		var bundle []byte
+		expectedPool := x509.NewCertPool()
+		for _, cn := range []string{"ca1", "ca2"} {
+			cert := newTestCertificate(t, cn, "")
+			bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+			expectedPool.AddCert(cert)
+		}
+		require.NoError(t, os.WriteFile(path, bundle, 0600), "Should write file")
+
+		cfg, err := SSLConfig{ClientCA: path}.tlsConfig()
+
+		assert := assert.New(t)
+
+		assert.NoError(err, "Should succeed")
+		require.NotNil(t, cfg, "Should create a tls config")
+		assert.Equal(tls.VerifyClientCertIfGiven, cfg.ClientAuth, "Should verify client certificates")
+		require.NotNil(t, cfg.ClientCAs, "Should have the client CA set")
+		assert.True(expectedPool.Equal(cfg.ClientCAs), "Should contain all certificates of the bundle")
+	})
+}
+
+func TestCertificateReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	t.Run("MissingFiles", func(t *testing.T) {
+		_, err := newCertificateReloader(certFile, keyFile)
+
+		assert.Error(t, err, "Should fail")
+	})
+
+	writeTestKeyPair(t, certFile, keyFile, "first")
+	r, err := newCertificateReloader(certFile, keyFile)
+	require.NoError(t, err, "Should load the certificate")
+
+	getCommonName := func(t *testing.T) string {
+		cert, err := r.GetCertificate(nil)
+		require.NoError(t, err, "Should return the certificate")
+		require.NotNil(t, cert, "Should return the certificate")
+		require.NotNil(t, cert.Leaf, "Should have parsed the certificate")
+		return cert.Leaf.Subject.CommonName
+	}
+
+	assert.Equal(t, "first", getCommonName(t), "Should serve the initial certificate")
+
+	t.Run("Unchanged", func(t *testing.T) {
+		reloaded, err := r.reload()
+
+		assert.NoError(t, err, "Should succeed")
+		assert.False(t, reloaded, "Should not reload unchanged files")
+	})
+	t.Run("Changed", func(t *testing.T) {
+		writeTestKeyPair(t, certFile, keyFile, "second")
+
+		reloaded, err := r.reload()
+
+		assert.NoError(t, err, "Should succeed")
+		assert.True(t, reloaded, "Should reload the certificate")
+		assert.Equal(t, "second", getCommonName(t), "Should serve the new certificate")
+	})
+	t.Run("KeepCertificateOnError", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0600), "Should write file")
+
+		reloaded, err := r.reload()
+
+		assert.Error(t, err, "Should fail")
+		assert.False(t, reloaded, "Should not reload the certificate")
+		assert.Equal(t, "second", getCommonName(t), "Should still serve the last valid certificate")
+	})
+}
+
+// Write a self-signed certificate with the given common name and its private key
+func writeTestKeyPair(t *testing.T, certFile, keyFile, cn string) {
+	t.Helper()
+
+	key, cert := newTestKeyPair(t, cn, "")
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err, "Should marshal key")
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600), "Should write certificate")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600), "Should write key")
+}
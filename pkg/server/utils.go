@@ -5,6 +5,11 @@ import (
 	"encoding/json/v2"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/heathcliff26/fleetlock/pkg/api"
+	"github.com/heathcliff26/fleetlock/pkg/k8s"
 )
 
 func ReadUserIP(req *http.Request) string {
@@ -26,8 +31,30 @@ func sendResponse(rw http.ResponseWriter, res any) {
 		return
 	}
 
+	if r, ok := rw.(*kindRecorder); ok {
+		if res, ok := res.(api.FleetLockResponse); ok {
+			r.kind = res.Kind
+		}
+	}
+
 	_, err = rw.Write(b)
 	if err != nil {
 		slog.Error("Failed to send response to client", "err", err)
 	}
 }
+
+// Describe the progress of a drain, to be appended to the value of a response
+func drainProgressMessage(progress k8s.DrainProgress) string {
+	var b strings.Builder
+	b.WriteString(", pods remaining: " + strconv.Itoa(len(progress.PodsRemaining)))
+	if len(progress.FailedEvictions) > 0 {
+		b.WriteString(", failed evictions:")
+		for i, f := range progress.FailedEvictions {
+			if i > 0 {
+				b.WriteString(";")
+			}
+			b.WriteString(" " + f.Pod + " (" + f.Reason + ")")
+		}
+	}
+	return b.String()
+}
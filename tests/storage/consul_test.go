@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/consul"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const consulTestToken = "consul-test-token"
+
+func TestConsulBackend(t *testing.T) {
+	server := httptest.NewServer(newFakeConsul(consulTestToken))
+	t.Cleanup(server.Close)
+
+	cfg := consul.ConsulConfig{
+		Address: server.URL,
+		Token:   consulTestToken,
+	}
+	storage := newConsulBackend(t, cfg)
+
+	RunLockManagerTestsuiteWithStorage(t, storage)
+
+	t.Run("ReplicaRace", func(t *testing.T) {
+		RunReplicaRaceTest(t, storage, newConsulBackend(t, cfg))
+	})
+	t.Run("RenewChangedTTL", func(t *testing.T) {
+		require := require.New(t)
+
+		require.NoError(storage.Reserve("RenewTTL", "User1", "", 10*time.Second), "Should reserve lock")
+		require.NoError(storage.Renew("RenewTTL", "User1", time.Minute), "Should renew lock with longer ttl")
+
+		// The fake expires the initial session after 2s, the renewed one after 12s
+		time.Sleep(3 * time.Second)
+		ok, err := storage.HasLock("RenewTTL", "User1")
+		require.NoError(err)
+		assert.True(t, ok, "Should have extended the lock to the new ttl")
+
+		require.NoError(storage.Renew("RenewTTL", "User1", time.Minute), "Should renew lock with the same ttl")
+		ok, err = storage.HasLock("RenewTTL", "User1")
+		require.NoError(err)
+		assert.True(t, ok, "Should keep the lock")
+	})
+	t.Run("InvalidToken", func(t *testing.T) {
+		_, err := consul.NewConsulBackend(consul.ConsulConfig{
+			Address: server.URL,
+			Token:   "invalid",
+		})
+		assert.Error(t, err, "Should fail the connection check")
+	})
+}
+
+func TestConsulBackendTLS(t *testing.T) {
+	server := httptest.NewTLSServer(newFakeConsul(""))
+	t.Cleanup(server.Close)
+
+	_, err := consul.NewConsulBackend(consul.ConsulConfig{
+		Address: server.URL,
+	})
+	assert.Error(t, err, "Should not trust the server without CA")
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	err = os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	require.NoError(t, err, "Should write CA certificate")
+
+	storage := newConsulBackend(t, consul.ConsulConfig{
+		Address: server.URL,
+		CAFile:  ca,
+	})
+	require.NoError(t, storage.Reserve("TLS", "User1", "", 0), "Should reserve lock")
+	ok, err := storage.HasLock("TLS", "User1")
+	assert.NoError(t, err)
+	assert.True(t, ok, "Should have lock")
+}
+
+func newConsulBackend(t *testing.T, cfg consul.ConsulConfig) *consul.ConsulBackend {
+	t.Helper()
+
+	storage, err := consul.NewConsulBackend(cfg)
+	require.NoError(t, err, "Should create storage backend")
+	t.Cleanup(func() {
+		_ = storage.Close()
+	})
+	return storage
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/etcd"
 	"github.com/heathcliff26/fleetlock/tests/utils"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEtcdBackend(t *testing.T) {
@@ -47,4 +48,14 @@ func TestEtcdBackend(t *testing.T) {
 	}
 
 	RunLockManagerTestsuiteWithStorage(t, storage)
+
+	t.Run("ReplicaRace", func(t *testing.T) {
+		replica, err := etcd.NewEtcdBackend(cfg)
+		require.NoError(t, err, "Should create second storage backend")
+		t.Cleanup(func() {
+			_ = replica.Close()
+		})
+
+		RunReplicaRaceTest(t, storage, replica)
+	})
 }
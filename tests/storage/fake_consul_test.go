@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sessions expire faster than in consul, so the tests don't need to wait for the minimum ttl of 10s
+const fakeConsulTimeScale = 5
+
+// In-process stand-in for the consul agent, implementing the parts of the http api used by the consul backend
+type fakeConsul struct {
+	token string
+	mux   *http.ServeMux
+
+	mutex    sync.Mutex
+	index    uint64
+	kv       map[string]fakeConsulKV
+	sessions map[string]*fakeConsulSession
+}
+
+type fakeConsulKV struct {
+	Key         string `json:"Key"`
+	Value       []byte `json:"Value"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+	Session     string `json:"Session,omitempty"`
+}
+
+type fakeConsulSession struct {
+	// As requested on creation, consul returns it unchanged on renewal
+	ttl     string
+	expires time.Time
+}
+
+type fakeConsulTxnOp struct {
+	KV struct {
+		Verb    string `json:"Verb"`
+		Key     string `json:"Key"`
+		Value   []byte `json:"Value"`
+		Index   uint64 `json:"Index"`
+		Session string `json:"Session"`
+	} `json:"KV"`
+}
+
+func newFakeConsul(token string) *fakeConsul {
+	f := &fakeConsul{
+		token:    token,
+		mux:      http.NewServeMux(),
+		kv:       make(map[string]fakeConsulKV),
+		sessions: make(map[string]*fakeConsulSession),
+	}
+
+	f.mux.HandleFunc("GET /v1/status/leader", func(rw http.ResponseWriter, _ *http.Request) {
+		writeFakeConsulResponse(rw, http.StatusOK, "127.0.0.1:8300")
+	})
+	f.mux.HandleFunc("GET /v1/kv/{key...}", f.getKV)
+	f.mux.HandleFunc("PUT /v1/kv/{key...}", f.putKV)
+	f.mux.HandleFunc("DELETE /v1/kv/{key...}", f.deleteKV)
+	f.mux.HandleFunc("PUT /v1/txn", f.txn)
+	f.mux.HandleFunc("PUT /v1/session/create", f.createSession)
+	f.mux.HandleFunc("PUT /v1/session/renew/{id}", f.renewSession)
+	f.mux.HandleFunc("PUT /v1/session/destroy/{id}", f.destroySession)
+
+	return f
+}
+
+func (f *fakeConsul) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if f.token != "" && req.Header.Get("X-Consul-Token") != f.token {
+		http.Error(rw, "ACL not found", http.StatusForbidden)
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.expireSessions()
+	f.mux.ServeHTTP(rw, req)
+}
+
+func (f *fakeConsul) getKV(rw http.ResponseWriter, req *http.Request) {
+	key := req.PathValue("key")
+	query := req.URL.Query()
+
+	if !query.Has("recurse") && !query.Has("keys") {
+		kv, ok := f.kv[key]
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeFakeConsulResponse(rw, http.StatusOK, []fakeConsulKV{kv})
+		return
+	}
+
+	keys := slices.Sorted(maps.Keys(f.kv))
+	keys = slices.DeleteFunc(keys, func(k string) bool {
+		return !strings.HasPrefix(k, key)
+	})
+	if len(keys) == 0 {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if query.Has("keys") {
+		writeFakeConsulResponse(rw, http.StatusOK, keys)
+		return
+	}
+	result := make([]fakeConsulKV, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, f.kv[k])
+	}
+	writeFakeConsulResponse(rw, http.StatusOK, result)
+}
+
+func (f *fakeConsul) putKV(rw http.ResponseWriter, req *http.Request) {
+	value, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.index++
+	key := req.PathValue("key")
+	f.kv[key] = fakeConsulKV{
+		Key:         key,
+		Value:       value,
+		ModifyIndex: f.index,
+		Session:     f.kv[key].Session,
+	}
+	writeFakeConsulResponse(rw, http.StatusOK, true)
+}
+
+func (f *fakeConsul) deleteKV(rw http.ResponseWriter, req *http.Request) {
+	delete(f.kv, req.PathValue("key"))
+	writeFakeConsulResponse(rw, http.StatusOK, true)
+}
+
+// Apply all operations to a copy of the store, which replaces the store only if all of them succeeded
+func (f *fakeConsul) txn(rw http.ResponseWriter, req *http.Request) {
+	var ops []fakeConsulTxnOp
+	err := json.UnmarshalRead(req.Body, &ops)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// All changes of a transaction are a single raft entry in consul, so they share the index
+	index := f.index + 1
+	kv := maps.Clone(f.kv)
+	for i, op := range ops {
+		key := op.KV.Key
+		current, exists := kv[key]
+
+		var failed bool
+		switch op.KV.Verb {
+		case "set":
+			kv[key] = fakeConsulKV{Key: key, Value: op.KV.Value, ModifyIndex: index, Session: current.Session}
+		case "cas":
+			failed = (op.KV.Index == 0 && exists) || (op.KV.Index != 0 && current.ModifyIndex != op.KV.Index)
+			if !failed {
+				kv[key] = fakeConsulKV{Key: key, Value: op.KV.Value, ModifyIndex: index, Session: current.Session}
+			}
+		case "lock":
+			_, validSession := f.sessions[op.KV.Session]
+			failed = !validSession || (current.Session != "" && current.Session != op.KV.Session)
+			if !failed {
+				kv[key] = fakeConsulKV{Key: key, Value: op.KV.Value, ModifyIndex: index, Session: op.KV.Session}
+			}
+		case "unlock":
+			failed = current.Session == "" || current.Session != op.KV.Session
+			if !failed {
+				kv[key] = fakeConsulKV{Key: key, Value: op.KV.Value, ModifyIndex: index}
+			}
+		case "check-not-exists":
+			failed = exists
+		case "check-index":
+			failed = !exists || current.ModifyIndex != op.KV.Index
+		case "delete":
+			delete(kv, key)
+		default:
+			http.Error(rw, "unsupported verb "+op.KV.Verb, http.StatusBadRequest)
+			return
+		}
+
+		if failed {
+			writeFakeConsulResponse(rw, http.StatusConflict, map[string]any{
+				"Errors": []map[string]any{{"OpIndex": i, "What": fmt.Sprintf("failed %s on key %s", op.KV.Verb, key)}},
+			})
+			return
+		}
+	}
+
+	f.index = index
+	f.kv = kv
+	writeFakeConsulResponse(rw, http.StatusOK, map[string]any{"Errors": nil})
+}
+
+func (f *fakeConsul) createSession(rw http.ResponseWriter, req *http.Request) {
+	var body struct {
+		TTL      string `json:"TTL"`
+		Behavior string `json:"Behavior"`
+	}
+	err := json.UnmarshalRead(req.Body, &body)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ttl, err := time.ParseDuration(body.TTL)
+	if err != nil || ttl < 10*time.Second || ttl > 24*time.Hour {
+		http.Error(rw, "Invalid Session TTL '"+body.TTL+"', must be between [10s=24h0m0s]", http.StatusBadRequest)
+		return
+	}
+	if body.Behavior != "delete" {
+		http.Error(rw, "fake only supports sessions with behavior delete", http.StatusBadRequest)
+		return
+	}
+
+	f.index++
+	id := fmt.Sprintf("session-%d", f.index)
+	f.sessions[id] = &fakeConsulSession{
+		ttl:     body.TTL,
+		expires: time.Now().Add(ttl / fakeConsulTimeScale),
+	}
+	writeFakeConsulResponse(rw, http.StatusOK, map[string]string{"ID": id})
+}
+
+func (f *fakeConsul) renewSession(rw http.ResponseWriter, req *http.Request) {
+	session, ok := f.sessions[req.PathValue("id")]
+	if !ok {
+		http.Error(rw, "Session id not found", http.StatusNotFound)
+		return
+	}
+	ttl, _ := time.ParseDuration(session.ttl)
+	session.expires = time.Now().Add(ttl / fakeConsulTimeScale)
+	writeFakeConsulResponse(rw, http.StatusOK, []map[string]string{{"ID": req.PathValue("id"), "TTL": session.ttl}})
+}
+
+func (f *fakeConsul) destroySession(rw http.ResponseWriter, req *http.Request) {
+	f.invalidateSession(req.PathValue("id"))
+	writeFakeConsulResponse(rw, http.StatusOK, true)
+}
+
+func (f *fakeConsul) expireSessions() {
+	now := time.Now()
+	for id, session := range f.sessions {
+		if now.After(session.expires) {
+			f.invalidateSession(id)
+		}
+	}
+}
+
+// Remove the session and, as its behavior is delete, all keys locked by it
+func (f *fakeConsul) invalidateSession(id string) {
+	delete(f.sessions, id)
+	maps.DeleteFunc(f.kv, func(_ string, kv fakeConsulKV) bool {
+		return kv.Session == id
+	})
+}
+
+func writeFakeConsulResponse(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.MarshalWrite(rw, v)
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileBackend(t *testing.T) {
+	cfg := file.FileConfig{
+		Path: filepath.Join(t.TempDir(), "locks.json"),
+	}
+	storage := newFileBackend(t, cfg)
+
+	RunLockManagerTestsuiteWithStorage(t, storage)
+}
+
+func TestFileBackendReplicaRace(t *testing.T) {
+	// Both backends use the same file, as two processes on the same host would
+	cfg := file.FileConfig{
+		Path: filepath.Join(t.TempDir(), "locks.json"),
+	}
+
+	RunReplicaRaceTest(t, newFileBackend(t, cfg), newFileBackend(t, cfg))
+}
+
+func TestFileBackendPersistence(t *testing.T) {
+	cfg := file.FileConfig{
+		Path: filepath.Join(t.TempDir(), "data", "locks.json"),
+	}
+
+	storage := newFileBackend(t, cfg)
+	require.NoError(t, storage.Reserve("Persistence", "User1", "token:workers", 0), "Should reserve lock")
+	require.NoError(t, storage.Reserve("Persistence", "User2", "", 0), "Should reserve lock")
+	require.NoError(t, storage.Close())
+
+	storage = newFileBackend(t, cfg)
+	locks, err := storage.ListLocks("Persistence")
+	assert.NoError(t, err)
+	assert.Len(t, locks, 2, "Should keep locks after restart")
+	for _, l := range locks {
+		if l.ID == "User1" {
+			assert.Equal(t, "token:workers", l.Owner, "Should keep the owner")
+		}
+	}
+}
+
+func TestFileBackendRecovery(t *testing.T) {
+	cfg := file.FileConfig{
+		Path: filepath.Join(t.TempDir(), "locks.json"),
+	}
+
+	storage := newFileBackend(t, cfg)
+	require.NoError(t, storage.Reserve("Recovery", "User1", "", 0), "Should reserve lock")
+	require.NoError(t, storage.Reserve("Recovery", "User2", "", 0), "Should reserve lock")
+	require.NoError(t, storage.Close())
+
+	data, err := os.ReadFile(cfg.Path)
+	require.NoError(t, err, "Should read file")
+	// Cut the file in the middle of the last lock
+	truncated := data[:len(data)-10]
+	require.NoError(t, os.WriteFile(cfg.Path, truncated, 0600), "Should truncate file")
+
+	storage = newFileBackend(t, cfg)
+
+	ok, err := storage.HasLock("Recovery", "User1")
+	assert.NoError(t, err)
+	assert.True(t, ok, "Should recover the complete lock")
+	ok, err = storage.HasLock("Recovery", "User2")
+	assert.NoError(t, err)
+	assert.False(t, ok, "Should drop the truncated lock")
+
+	backup, err := os.ReadFile(cfg.Path + ".corrupt")
+	assert.NoError(t, err, "Should backup the damaged file")
+	assert.Equal(t, truncated, backup, "Backup should contain the damaged file")
+
+	count, err := storage.GetLocks("Recovery")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count, "Should have rewritten the file")
+	_, err = os.Stat(cfg.Path + ".tmp")
+	assert.ErrorIs(t, err, os.ErrNotExist, "Should not leave the temporary file behind")
+
+	t.Run("EmptyFile", func(t *testing.T) {
+		require.NoError(t, storage.Close())
+		require.NoError(t, os.WriteFile(cfg.Path, nil, 0600), "Should truncate file")
+
+		storage = newFileBackend(t, cfg)
+		count, err := storage.GetLocks("Recovery")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count, "Should start without locks")
+	})
+}
+
+func newFileBackend(t *testing.T, cfg file.FileConfig) *file.FileBackend {
+	t.Helper()
+
+	storage, err := file.NewFileBackend(cfg)
+	require.NoError(t, err, "Should create storage backend")
+	t.Cleanup(func() {
+		_ = storage.Close()
+	})
+	return storage
+}
@@ -8,7 +8,11 @@ import (
 
 func TestKubernetesBackend(t *testing.T) {
 	nsName := "fleetlock"
-	storage, _ := kubernetes.NewKubernetesBackendWithFakeClient(nsName)
+	storage, fakeclient := kubernetes.NewKubernetesBackendWithFakeClient(nsName)
 
 	RunLockManagerTestsuiteWithStorage(t, storage)
+
+	t.Run("ReplicaRace", func(t *testing.T) {
+		RunReplicaRaceTest(t, storage, kubernetes.NewKubernetesBackendWithClient(fakeclient.CoordinationV1(), nsName))
+	})
 }
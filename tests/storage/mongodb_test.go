@@ -40,4 +40,14 @@ func TestMongoDBBackend(t *testing.T) {
 	}, time.Minute, 5*time.Second, "Should connect to mongodb backend")
 
 	RunLockManagerTestsuiteWithStorage(t, storage)
+
+	t.Run("ReplicaRace", func(t *testing.T) {
+		replica, err := mongodb.NewMongoDBBackend(cfg)
+		require.NoError(t, err, "Should create second storage backend")
+		t.Cleanup(func() {
+			_ = replica.Close()
+		})
+
+		RunReplicaRaceTest(t, storage, replica)
+	})
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/sql"
 	"github.com/heathcliff26/fleetlock/tests/utils"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPostgresBackend(t *testing.T) {
@@ -47,4 +48,14 @@ func TestPostgresBackend(t *testing.T) {
 	}
 
 	RunLockManagerTestsuiteWithStorage(t, storage)
+
+	t.Run("ReplicaRace", func(t *testing.T) {
+		replica, err := sql.NewPostgresBackend(cfg)
+		require.NoError(t, err, "Should create second storage backend")
+		t.Cleanup(func() {
+			_ = replica.Close()
+		})
+
+		RunReplicaRaceTest(t, storage, replica)
+	})
 }
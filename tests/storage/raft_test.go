@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Number of changes needed to make the leader compact its log
+const raftCompactionChanges = 1200
+
+func TestRaftBackend(t *testing.T) {
+	cfgs := newRaftClusterConfig(t, 3)
+	nodes := make([]*raft.RaftBackend, len(cfgs))
+	for i, cfg := range cfgs {
+		nodes[i] = startRaftNode(t, cfg)
+	}
+	waitForRaftLeader(t, nodes...)
+
+	RunLockManagerTestsuiteWithStorage(t, nodes[0])
+
+	t.Run("ReplicatedToAllNodes", func(t *testing.T) {
+		require.NoError(t, nodes[1].Reserve("Replicated", "User1", "token:workers", 0), "Should reserve on follower")
+
+		for i, node := range nodes {
+			ok, err := node.HasLock("Replicated", "User1")
+			assert.NoError(t, err, "Node %d should answer", i)
+			assert.True(t, ok, "Node %d should see the lock", i)
+		}
+	})
+	t.Run("ReplicaRace", func(t *testing.T) {
+		RunReplicaRaceTest(t, nodes[0], nodes[1], nodes[2])
+	})
+	t.Run("SurvivesNodeFailure", func(t *testing.T) {
+		require.NoError(t, nodes[2].Close(), "Should stop node")
+
+		// Requests forwarded to the stopped node are lost until a new leader is elected
+		require.Eventually(t, func() bool {
+			ok, err := nodes[0].TryReserve("Failure", "User1", "", 1, 0)
+			return err == nil && ok
+		}, 30*time.Second, 100*time.Millisecond, "Should reserve without the stopped node")
+
+		// Enough changes to compact the log, so the node needs a snapshot to catch up
+		for i := range raftCompactionChanges {
+			require.NoError(t, nodes[1].Renew("Failure", "User1", time.Duration(i+1)*time.Hour), "Should renew lock")
+		}
+
+		nodes[2] = startRaftNode(t, cfgs[2])
+		assert.Eventually(t, func() bool {
+			ok, err := nodes[2].HasLock("Failure", "User1")
+			return err == nil && ok
+		}, 30*time.Second, 100*time.Millisecond, "Restarted node should catch up")
+
+		ok, err := nodes[2].HasLock("Replicated", "User1")
+		assert.NoError(t, err)
+		assert.True(t, ok, "Restarted node should keep older locks")
+	})
+}
+
+func TestRaftBackendRestart(t *testing.T) {
+	cfg := newRaftClusterConfig(t, 1)[0]
+
+	node := startRaftNode(t, cfg)
+	waitForRaftLeader(t, node)
+	for i := range raftCompactionChanges {
+		require.NoError(t, node.Reserve("Restart", "User"+strconv.Itoa(i%10), "", 0), "Should reserve lock")
+	}
+	require.NoError(t, node.Release("Restart", "User0"), "Should release lock")
+	require.NoError(t, node.Close(), "Should stop node")
+
+	node = startRaftNode(t, cfg)
+	waitForRaftLeader(t, node)
+
+	count, err := node.GetLocks("Restart")
+	assert.NoError(t, err)
+	assert.Equal(t, 9, count, "Should restore the locks from snapshot and log")
+}
+
+// Create the configuration for a cluster of the given size, listening on free ports of localhost
+func newRaftClusterConfig(t *testing.T, size int) []raft.RaftConfig {
+	t.Helper()
+
+	peers := make([]string, size)
+	for i := range size {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err, "Should find free port")
+		peers[i] = l.Addr().String()
+		require.NoError(t, l.Close())
+	}
+
+	cfgs := make([]raft.RaftConfig, size)
+	for i := range size {
+		cfgs[i] = raft.RaftConfig{
+			Listen:    peers[i],
+			Advertise: peers[i],
+			Peers:     peers,
+			DataDir:   t.TempDir(),
+			Token:     "raft-test-token",
+		}
+	}
+	return cfgs
+}
+
+func startRaftNode(t *testing.T, cfg raft.RaftConfig) *raft.RaftBackend {
+	t.Helper()
+
+	node, err := raft.NewRaftBackend(cfg)
+	require.NoError(t, err, "Should start raft node")
+	t.Cleanup(func() {
+		_ = node.Close()
+	})
+	return node
+}
+
+func waitForRaftLeader(t *testing.T, nodes ...*raft.RaftBackend) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		for _, node := range nodes {
+			if node.Ping() != nil {
+				return false
+			}
+		}
+		return true
+	}, 30*time.Second, 100*time.Millisecond, "Cluster should elect a leader")
+}
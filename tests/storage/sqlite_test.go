@@ -1,9 +1,15 @@
 package storage
 
 import (
+	gosql "database/sql"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/sql"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSQLiteBackend(t *testing.T) {
@@ -17,3 +23,89 @@ func TestSQLiteBackend(t *testing.T) {
 
 	RunLockManagerTestsuiteWithStorage(t, storage)
 }
+
+func TestSQLiteBackendReplicaRace(t *testing.T) {
+	// Both backends write to the same file, so they need to wait for each other instead of failing when the database is locked
+	cfg := sql.SQLiteConfig{
+		File: filepath.Join(t.TempDir(), "fleetlock.db") + "?_pragma=busy_timeout(10000)",
+	}
+
+	storage, err := sql.NewSQLiteBackend(cfg)
+	require.NoError(t, err, "Should create storage backend")
+	t.Cleanup(func() {
+		_ = storage.Close()
+	})
+	replica, err := sql.NewSQLiteBackend(cfg)
+	require.NoError(t, err, "Should create second storage backend")
+	t.Cleanup(func() {
+		_ = replica.Close()
+	})
+
+	RunReplicaRaceTest(t, storage, replica)
+}
+
+func TestSQLiteBackendMigration(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	file := filepath.Join(t.TempDir(), "fleetlock.db")
+
+	// Table as created by versions without expires and owner columns
+	db, err := gosql.Open("sqlite", file)
+	require.NoError(err, "Should open database")
+	_, err = db.Exec("CREATE TABLE locks (group_name VARCHAR(100) NOT NULL, id VARCHAR(100) NOT NULL, created TIMESTAMP NOT NULL, PRIMARY KEY (group_name,id));")
+	require.NoError(err, "Should create legacy table")
+	_, err = db.Exec("INSERT INTO locks (group_name, id, created) VALUES (?,?,?);", "default", "User1", time.Now())
+	require.NoError(err, "Should insert legacy lock")
+	require.NoError(db.Close())
+
+	storage, err := sql.NewSQLiteBackend(sql.SQLiteConfig{File: file})
+	require.NoError(err, "Should migrate the table")
+	t.Cleanup(func() {
+		_ = storage.Close()
+	})
+
+	require.NoError(storage.Reserve("default", "User2", "token:workers", 0), "Should reserve with owner")
+
+	locks, err := storage.ListLocks("default")
+	require.NoError(err)
+	require.Len(locks, 2, "Should keep existing locks")
+	for _, lock := range locks {
+		if lock.ID == "User1" {
+			assert.Empty(lock.Owner, "Existing locks should not have an owner")
+		} else {
+			assert.Equal("token:workers", lock.Owner, "Should save the owner")
+		}
+	}
+}
+
+func TestSQLiteBackendEventRetention(t *testing.T) {
+	storage, err := sql.NewSQLiteBackend(sql.SQLiteConfig{
+		File: "file:retention.db?mode=memory",
+	})
+	require.NoError(t, err, "Should create storage backend")
+	t.Cleanup(func() {
+		_ = storage.Close()
+	})
+
+	// Matches maxEvents of the backend
+	const maxEvents = 10000
+	start := time.Now().UTC().Truncate(time.Second)
+	for i := range maxEvents + 10 {
+		err = storage.RecordEvent(types.Event{Time: start.Add(time.Duration(i) * time.Millisecond), Type: types.EventReserve, Group: "default", ID: "User1"})
+		require.NoError(t, err, "Should record event")
+	}
+
+	assert := assert.New(t)
+
+	res, err := storage.QueryEvents(types.EventFilter{})
+	assert.NoError(err)
+	assert.Len(res, maxEvents, "Should only keep the newest events")
+	assert.True(start.Add(10*time.Millisecond).Equal(res[0].Time), "Should delete the oldest events")
+
+	res, err = storage.QueryEvents(types.EventFilter{Limit: 2})
+	assert.NoError(err)
+	require.Len(t, res, 2, "Should limit the number of events")
+	assert.True(res[0].Time.Before(res[1].Time), "Should return events sorted from oldest to newest")
+	assert.True(start.Add((maxEvents+9)*time.Millisecond).Equal(res[1].Time), "Should return the newest events")
+}
@@ -4,14 +4,17 @@ import (
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	lockmanager "github.com/heathcliff26/fleetlock/pkg/lock-manager"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/audit"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func GetGroups() lockmanager.Groups {
-	testGroups := make(lockmanager.Groups, 7)
+	testGroups := make(lockmanager.Groups, 10)
 	testGroups["basic"] = lockmanager.GroupConfig{
 		Slots: 1,
 	}
@@ -33,6 +36,19 @@ func GetGroups() lockmanager.Groups {
 	testGroups["ReserveReturnTrueIfAlreadyExists"] = lockmanager.GroupConfig{
 		Slots: 1,
 	}
+	testGroups["GetStaleLocks"] = lockmanager.GroupConfig{
+		Slots: 2,
+	}
+	testGroups["ListLocks"] = lockmanager.GroupConfig{
+		Slots: 3,
+	}
+	testGroups["LeaseDuration"] = lockmanager.GroupConfig{
+		Slots:         1,
+		LeaseDuration: 2 * time.Second,
+	}
+	testGroups["Owner"] = lockmanager.GroupConfig{
+		Slots: 2,
+	}
 	return testGroups
 }
 
@@ -45,6 +61,10 @@ func RunLockManagerTestsuiteWithStorage(t *testing.T, storage lockmanager.Storag
 		}
 	})
 
+	t.Run("Ping", func(t *testing.T) {
+		assert.NoError(t, storage.Ping(), "Should reach the backend")
+		assert.NoError(t, lm.Ping(), "Should reach the backend through the manager")
+	})
 	t.Run("Basic", func(t *testing.T) {
 		t.Run("Reserve", func(t *testing.T) {
 			assert := assert.New(t)
@@ -185,4 +205,237 @@ func RunLockManagerTestsuiteWithStorage(t *testing.T, storage lockmanager.Storag
 		assert.True(ok)
 		assert.Nil(err)
 	})
+	t.Run("GetStaleLocks", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		start := time.Now()
+		for i := range 2 {
+			ok, err := lm.Reserve("GetStaleLocks", "User"+strconv.Itoa(i))
+			require.True(ok)
+			require.NoError(err)
+		}
+
+		locks, err := storage.GetStaleLocks(time.Hour)
+		assert.NoError(err)
+		assert.False(containsLock(locks, "GetStaleLocks", "User0"), "Should not return new locks")
+		assert.False(containsLock(locks, "GetStaleLocks", "User1"), "Should not return new locks")
+
+		// Some databases only save timestamps with second precision
+		assert.Eventually(func() bool {
+			locks, err = storage.GetStaleLocks(0)
+			return err == nil && containsLock(locks, "GetStaleLocks", "User0") && containsLock(locks, "GetStaleLocks", "User1")
+		}, 5*time.Second, 100*time.Millisecond, "Should return all locks older than 0")
+		require.NoError(err)
+
+		for _, lock := range locks {
+			if lock.Group == "GetStaleLocks" {
+				assert.WithinDuration(start, lock.Created, 2*time.Second, "Should return the creation time")
+			}
+		}
+
+		err = lm.Release("GetStaleLocks", "User0")
+		require.NoError(err)
+
+		locks, err = storage.GetStaleLocks(0)
+		assert.NoError(err)
+		assert.False(containsLock(locks, "GetStaleLocks", "User0"), "Should not return released locks")
+		assert.True(containsLock(locks, "GetStaleLocks", "User1"), "Should still return unreleased locks")
+	})
+	t.Run("ListLocks", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		locks, err := storage.ListLocks("ListLocks")
+		assert.NoError(err)
+		assert.Empty(locks)
+
+		start := time.Now()
+		for i := range 3 {
+			ok, err := lm.Reserve("ListLocks", "User"+strconv.Itoa(i))
+			require.True(ok)
+			require.NoError(err)
+		}
+
+		locks, err = storage.ListLocks("ListLocks")
+		assert.NoError(err)
+		assert.Len(locks, 3)
+		for i := range 3 {
+			assert.True(containsLock(locks, "ListLocks", "User"+strconv.Itoa(i)), "Should return all locks of the group")
+		}
+		for _, lock := range locks {
+			assert.WithinDuration(start, lock.Created, 2*time.Second, "Should return the creation time")
+		}
+
+		err = lm.Release("ListLocks", "User1")
+		require.NoError(err)
+
+		status, err := lm.GetGroupStatus("ListLocks")
+		assert.NoError(err)
+		assert.Equal(3, status.Slots)
+		assert.Len(status.Locks, 2)
+		assert.False(containsLock(status.Locks, "ListLocks", "User1"), "Should not return released locks")
+	})
+	t.Run("LeaseDuration", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		ok, err := lm.Reserve("LeaseDuration", "User1")
+		require.True(ok)
+		require.NoError(err)
+
+		ok, err = lm.Renew("LeaseDuration", "User1")
+		assert.True(ok, "Should renew held lock")
+		assert.NoError(err)
+
+		ok, err = lm.Renew("LeaseDuration", "User2")
+		assert.False(ok, "Should not renew lock that is not held")
+		assert.NoError(err)
+
+		ok, err = lm.Reserve("LeaseDuration", "User2")
+		assert.False(ok, "Should not reserve while lock is held")
+		assert.NoError(err)
+
+		// Backends with native expiry only have second precision
+		assert.Eventually(func() bool {
+			ok, err := lm.HasLock("LeaseDuration", "User1")
+			return err == nil && !ok
+		}, 10*time.Second, 100*time.Millisecond, "Lock should expire when not renewed")
+
+		locks, err := storage.ListLocks("LeaseDuration")
+		assert.NoError(err)
+		assert.Empty(locks, "Should not list expired locks")
+
+		ok, err = lm.Reserve("LeaseDuration", "User2")
+		assert.True(ok, "Should reserve slot freed by expired lock")
+		assert.NoError(err)
+
+		err = lm.Release("LeaseDuration", "User2")
+		assert.NoError(err)
+	})
+	t.Run("Owner", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		ok, err := lm.ReserveAs("Owner", "User1", "token:workers")
+		require.NoError(err)
+		require.True(ok)
+		ok, err = lm.ReserveAs("Owner", "User1", "token:other")
+		require.NoError(err)
+		require.True(ok, "Should succeed when the lock is already held")
+		ok, err = lm.Reserve("Owner", "User2")
+		require.NoError(err)
+		require.True(ok)
+
+		lock, err := lm.GetLock("Owner", "User1")
+		require.NoError(err)
+		require.NotNil(lock, "Should return the lock")
+		assert.Equal("token:workers", lock.Owner, "Should not change the owner of an existing lock")
+
+		lock, err = lm.GetLock("Owner", "User2")
+		require.NoError(err)
+		require.NotNil(lock, "Should return the lock")
+		assert.Empty(lock.Owner, "Should not have an owner")
+
+		lock, err = lm.GetLock("Owner", "User3")
+		assert.NoError(err)
+		assert.Nil(lock, "Should not return a lock for ids without slot")
+	})
+	t.Run("Events", func(t *testing.T) {
+		es, ok := storage.(audit.EventStorage)
+		if !ok {
+			t.Skip("Storage does not support audit events")
+		}
+
+		assert := assert.New(t)
+		require := require.New(t)
+
+		// Some backends only store the time with millisecond precision
+		start := time.Now().UTC().Truncate(time.Millisecond)
+		events := []types.Event{
+			{Time: start, Type: types.EventReserve, Group: "Events", ID: "User1", Remote: "192.0.2.1"},
+			{Time: start.Add(time.Second), Type: types.EventReserveDenied, Group: "Events", ID: "User2", Reason: "no_slot_available"},
+			{Time: start.Add(2 * time.Second), Type: types.EventRelease, Group: "Events", ID: "User1", Node: "node1"},
+			{Time: start.Add(3 * time.Second), Type: types.EventReserve, Group: "EventsOther", ID: "User1"},
+		}
+		for _, event := range events {
+			require.NoError(es.RecordEvent(event), "Should record event")
+		}
+
+		res, err := es.QueryEvents(types.EventFilter{Group: "Events"})
+		require.NoError(err)
+		require.Len(res, 3, "Should only return events of the group")
+		for i := range res {
+			assert.True(events[i].Time.Equal(res[i].Time), "Should return events sorted from oldest to newest")
+			res[i].Time = events[i].Time
+		}
+		assert.Equal(events[:3], res)
+
+		res, err = es.QueryEvents(types.EventFilter{ID: "User1", Since: start.Add(time.Second)})
+		require.NoError(err)
+		require.Len(res, 2, "Should filter by id and time")
+		assert.Equal(types.EventRelease, res[0].Type)
+		assert.Equal("EventsOther", res[1].Group)
+
+		res, err = es.QueryEvents(types.EventFilter{Since: start, Limit: 2})
+		require.NoError(err)
+		require.Len(res, 2, "Should limit the number of events")
+		assert.Equal(types.EventRelease, res[0].Type, "Should return the newest events")
+		assert.Equal(types.EventReserve, res[1].Type, "Should return the newest events")
+	})
+}
+
+func containsLock(locks []types.Lock, group, id string) bool {
+	for _, lock := range locks {
+		if lock.Group == group && lock.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Simulate multiple servers sharing the same storage, by reserving through a separate lock manager for every backend.
+// The backends need to be separate connections to the same storage.
+func RunReplicaRaceTest(t *testing.T, replicas ...lockmanager.StorageBackend) {
+	groups := lockmanager.Groups{
+		"ReplicaRace": lockmanager.GroupConfig{
+			Slots: 3,
+		},
+	}
+
+	// Closing the managers would close the storage, which is left to the caller
+	managers := make([]*lockmanager.LockManager, 0, len(replicas))
+	for _, storage := range replicas {
+		managers = append(managers, lockmanager.NewManagerWithStorage(groups, storage))
+	}
+
+	assert := assert.New(t)
+
+	total := 10 * len(managers)
+	start := make(chan struct{})
+	result := make(chan bool, total)
+	for i := range total {
+		lm := managers[i%len(managers)]
+		go func() {
+			<-start
+			ok, err := lm.Reserve("ReplicaRace", "User"+strconv.Itoa(i))
+			assert.Nil(err)
+			result <- ok
+		}()
+	}
+	close(start)
+
+	count := 0
+	for range total {
+		if <-result {
+			count++
+		}
+	}
+	assert.Equal(3, count, "Should only reserve the available slots")
+
+	for _, storage := range replicas {
+		count, err := storage.GetLocks("ReplicaRace")
+		assert.Equal(3, count, "Every replica should see the same locks")
+		assert.Nil(err)
+	}
 }
@@ -8,11 +8,15 @@ import (
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/heathcliff26/fleetlock/pkg/lock-manager/storage/valkey"
+	"github.com/heathcliff26/fleetlock/pkg/lock-manager/types"
 	"github.com/heathcliff26/fleetlock/tests/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestValkeyBackend(t *testing.T) {
 	mr := miniredis.RunT(t)
+	advanceTime(t, mr)
 
 	cfg := valkey.ValkeyConfig{
 		Addrs: []string{mr.Addr()},
@@ -24,11 +28,59 @@ func TestValkeyBackend(t *testing.T) {
 	}
 
 	RunLockManagerTestsuiteWithStorage(t, storage)
+
+	t.Run("ReplicaRace", func(t *testing.T) {
+		replica, err := valkey.NewValkeyBackend(cfg)
+		require.NoError(t, err, "Should create second storage backend")
+		t.Cleanup(func() {
+			_ = replica.Close()
+		})
+
+		RunReplicaRaceTest(t, storage, replica)
+	})
+}
+
+func TestValkeyBackendMigrateLegacyKeys(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	value := types.FormatLockValue(time.Now(), "")
+	require.NoError(t, mr.Set("group:default,id:User1", value))
+	require.NoError(t, mr.Set("group:default,id:User2", value))
+	mr.SetTTL("group:default,id:User2", time.Hour)
+	_, err := mr.SAdd("default", "group:default,id:User1", "group:default,id:User2", "group:default,id:Expired")
+	require.NoError(t, err)
+
+	storage, err := valkey.NewValkeyBackend(valkey.ValkeyConfig{
+		Addrs: []string{mr.Addr()},
+	})
+	require.NoError(t, err, "Should create storage backend")
+	t.Cleanup(func() {
+		_ = storage.Close()
+	})
+
+	assert := assert.New(t)
+
+	for _, id := range []string{"User1", "User2"} {
+		ok, err := storage.HasLock("default", id)
+		assert.NoError(err)
+		assert.True(ok, "Should have migrated the lock of "+id)
+		assert.False(mr.Exists("group:default,id:"+id), "Should have deleted the legacy key of "+id)
+	}
+	assert.Equal(time.Hour, mr.TTL("group:{default},id:User2"), "Should keep the expiry")
+
+	ok, err := storage.TryReserve("default", "User3", "", 3, 0)
+	assert.NoError(err)
+	assert.True(ok, "Should not count the expired legacy lock")
+
+	members, err := mr.Members("default")
+	require.NoError(t, err)
+	assert.ElementsMatch([]string{"group:{default},id:User1", "group:{default},id:User2", "group:{default},id:User3"}, members, "Should remove the expired legacy lock from the group")
 }
 
 func TestValkeyLoadbalancerBackend(t *testing.T) {
 	mr1 := miniredis.RunT(t)
 	mr2 := miniredis.RunT(t)
+	advanceTime(t, mr1, mr2)
 
 	cfg := valkey.ValkeyConfig{
 		Addrs: []string{mr1.Addr(), mr2.Addr()},
@@ -112,3 +164,26 @@ func TestValkeySentinelBackend(t *testing.T) {
 
 	RunLockManagerTestsuiteWithStorage(t, storage)
 }
+
+// Miniredis does not expire keys on its own, so advance its clock in real time
+func advanceTime(t *testing.T, servers ...*miniredis.Miniredis) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		ticker.Stop()
+		close(done)
+	})
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				for _, mr := range servers {
+					mr.FastForward(100 * time.Millisecond)
+				}
+			}
+		}
+	}()
+}